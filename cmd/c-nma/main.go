@@ -0,0 +1,310 @@
+// cmd/c-nma/main.go
+
+package main
+
+import (
+	"cmas-cats-go/config"
+	"cmas-cats-go/models"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// ProbeGap 同一轮内相邻两次探测的间隔（用于测量抖动）
+const ProbeGap = 100 * time.Millisecond
+
+var (
+	selfCfg     *config.NMAConfig                     // 本C-NMA实例配置
+	pathMetrics = make(map[string]models.PathMetrics) // 各站点最近一轮的路径度量（站点ID → 度量）
+	pathMutex   sync.RWMutex
+)
+
+func main() {
+	fmt.Println("=====================================")
+	fmt.Println("        C-NMA 网络度量服务启动中...        ")
+	fmt.Println("=====================================")
+
+	// 加载拓扑配置，确定本实例所在观测点及探测的站点
+	flags := config.BindFlags()
+	flag.Parse()
+	if err := flags.Load(); err != nil {
+		fmt.Printf("❌ 加载配置失败，程序退出：%v\n", err)
+		return
+	}
+	var err error
+	if selfCfg, err = config.NMA(flags.ID); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if flags.Port > 0 {
+		selfCfg.OverridePort(flags.Port)
+	}
+	sites := selfCfg.SitesFor()
+	if len(sites) == 0 {
+		fmt.Println("⚠️  未发现任何站点配置！请检查配置文件中的 sites / nma.sites")
+	}
+
+	r := gin.Default()
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-API-Key"},
+		ExposeHeaders:    []string{"Content-Length"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+	r.GET("/network-metrics", getNetworkMetricsHandler)              // 全部路径度量（支持 ?site_id=）
+	r.GET("/network-metrics/:site_id", getSiteNetworkMetricsHandler) // 单个站点的路径度量
+	r.GET("/health", healthCheckHandler)
+
+	// 启动探测任务
+	go startProbing(sites)
+
+	listenAddr := config.Cfg.ListenIP + ":" + strconv.Itoa(selfCfg.Port)
+	fmt.Printf("\n✅ C-NMA（%s）启动成功！\n", selfCfg.ID)
+	fmt.Printf("📌 监听地址：%s\n", selfCfg.URL)
+	fmt.Printf("📌 观测点：%s | 探测方式：%s | 每轮%d次 | 周期%ds | 超时%dms\n",
+		selfCfg.Vantage, selfCfg.Probe, selfCfg.ProbeCount, selfCfg.IntervalSeconds, selfCfg.TimeoutMillis)
+	fmt.Println("📌 探测站点：")
+	for _, site := range sites {
+		fmt.Printf("   - %s (%s)\n", site.ID, site.URL)
+	}
+
+	if err := r.Run(listenAddr); err != nil {
+		panic("❌ C-NMA 启动失败：" + err.Error())
+	}
+}
+
+// ------------------------------
+// 核心：周期性探测
+// ------------------------------
+
+func startProbing(sites []config.SiteConfig) {
+	if len(sites) == 0 {
+		fmt.Println("⚠️ 无有效服务站点，停止探测任务")
+		return
+	}
+
+	probeAll(sites) // 启动后立即探测一轮，避免C-PS在首个周期内拿不到数据
+	ticker := time.NewTicker(time.Duration(selfCfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		probeAll(sites)
+	}
+}
+
+func probeAll(sites []config.SiteConfig) {
+	var wg sync.WaitGroup
+	for _, site := range sites {
+		wg.Add(1)
+		go func(site config.SiteConfig) {
+			defer wg.Done()
+			m := probeSite(site)
+
+			pathMutex.Lock()
+			pathMetrics[site.ID] = m
+			pathMutex.Unlock()
+
+			if m.Reachable() {
+				fmt.Printf("📡 [%s → %s] RTT=%.1fms 抖动=%.1fms 丢包=%.0f%%\n",
+					m.Vantage, m.SiteID, m.RTTMs, m.JitterMs, m.LossRate*100)
+			} else {
+				fmt.Printf("❌ [%s → %s] 不可达：%s\n", m.Vantage, m.SiteID, m.LastError)
+			}
+		}(site)
+	}
+	wg.Wait()
+}
+
+// probeSite 对单个站点执行一轮探测（ProbeCount 次），统计RTT、抖动与丢包
+func probeSite(site config.SiteConfig) models.PathMetrics {
+	m := models.PathMetrics{
+		Vantage: selfCfg.Vantage,
+		SiteID:  site.ID,
+		Method:  selfCfg.Probe,
+		Sent:    selfCfg.ProbeCount,
+	}
+	timeout := time.Duration(selfCfg.TimeoutMillis) * time.Millisecond
+
+	var probe func() (time.Duration, error)
+	switch selfCfg.Probe {
+	case "tcp":
+		m.Target = hostPort(site.URL)
+		probe = func() (time.Duration, error) { return probeTCP(m.Target, timeout) }
+	default:
+		m.Target = site.URL + "/health"
+		// 复用连接，使每次探测只包含一次请求往返；首次建连不计入统计
+		client := &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{Proxy: nil, MaxIdleConnsPerHost: 1},
+		}
+		defer client.CloseIdleConnections()
+		probeHTTP(client, m.Target)
+		probe = func() (time.Duration, error) { return probeHTTP(client, m.Target) }
+	}
+
+	var rtts []float64
+	for i := 0; i < selfCfg.ProbeCount; i++ {
+		if i > 0 {
+			time.Sleep(ProbeGap)
+		}
+		rtt, err := probe()
+		if err != nil {
+			m.LastError = err.Error()
+			continue
+		}
+		rtts = append(rtts, float64(rtt.Microseconds())/1000)
+	}
+
+	m.Received = len(rtts)
+	m.LossRate = float64(m.Sent-m.Received) / float64(m.Sent)
+	m.RTTMs, m.MinRTTMs, m.MaxRTTMs, m.JitterMs = rttStats(rtts)
+	m.MeasuredAt = time.Now()
+	return m
+}
+
+func probeHTTP(client *http.Client, target string) (time.Duration, error) {
+	start := time.Now()
+	resp, err := client.Get(target)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return 0, fmt.Errorf("状态码错误：%d", resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+// probeTCP 以TCP建连耗时近似一次RTT
+func probeTCP(addr string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	conn.Close()
+	return rtt, nil
+}
+
+// rttStats 计算平均/最小/最大RTT与抖动（相邻样本差值绝对值的平均）
+func rttStats(rtts []float64) (avg, min, max, jitter float64) {
+	if len(rtts) == 0 {
+		return 0, 0, 0, 0
+	}
+	min, max = rtts[0], rtts[0]
+	sum := 0.0
+	for i, v := range rtts {
+		sum += v
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+		if i > 0 {
+			jitter += math.Abs(v - rtts[i-1])
+		}
+	}
+	if len(rtts) > 1 {
+		jitter /= float64(len(rtts) - 1)
+	}
+	return round2(sum / float64(len(rtts))), round2(min), round2(max), round2(jitter)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// hostPort 从站点URL中取出 host:port（未写端口时按协议补默认端口）
+func hostPort(siteURL string) string {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return siteURL
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// ------------------------------
+// 接口
+// ------------------------------
+
+func getNetworkMetricsHandler(c *gin.Context) {
+	siteID := c.Query("site_id")
+
+	pathMutex.RLock()
+	defer pathMutex.RUnlock()
+
+	paths := []models.PathMetrics{}
+	for id, m := range pathMetrics {
+		if siteID != "" && id != siteID {
+			continue
+		}
+		paths = append(paths, m)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"nma_id":  selfCfg.ID,
+		"vantage": selfCfg.Vantage,
+		"count":   len(paths),
+		"paths":   paths,
+		"time":    time.Now().Format(time.RFC3339),
+	})
+}
+
+func getSiteNetworkMetricsHandler(c *gin.Context) {
+	siteID := c.Param("site_id")
+
+	pathMutex.RLock()
+	m, ok := pathMetrics[siteID]
+	pathMutex.RUnlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "暂无该站点的网络度量：" + siteID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"path":    m,
+	})
+}
+
+func healthCheckHandler(c *gin.Context) {
+	pathMutex.RLock()
+	reachable := 0
+	for _, m := range pathMetrics {
+		if m.Reachable() {
+			reachable++
+		}
+	}
+	total := len(pathMetrics)
+	pathMutex.RUnlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"status":          "healthy",
+		"nma_id":          selfCfg.ID,
+		"vantage":         selfCfg.Vantage,
+		"probed_sites":    total,
+		"reachable_sites": reachable,
+		"time":            time.Now().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,676 @@
+package main
+
+import (
+	"cmas-cats-go/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ------------------------------
+// API Key 管理：Key 以 SHA-256 摘要持久化（明文仅在创建/轮换时返回一次），认证时经内存缓存查询
+// ------------------------------
+
+const (
+	APIKeyPrefix       = "cmas_"          // 新签发 Key 的前缀，便于识别与泄露扫描
+	APIKeyCacheTTL     = 30 * time.Second // 认证缓存有效期（管理操作会立即清空缓存）
+	APIKeyCacheMax     = 10000            // 缓存条目上限（含无效 Key 的否定缓存），超过时整体清空
+	APIKeyTouchEvery   = time.Minute      // last_used_at 的最小写入间隔
+	DefaultRotateGrace = time.Hour        // 轮换后旧 Key 默认的继续有效时长
+)
+
+// API Key 状态
+const (
+	APIKeyStatusActive  = "active"
+	APIKeyStatusExpired = "expired"
+	APIKeyStatusRevoked = "revoked"
+)
+
+// apiKeyContextKey 认证通过后 gin.Context 中保存 *apiKeyRecord 的键
+const apiKeyContextKey = "api_key"
+
+// legacyAPIKeys 早期版本内置的 Key：Key 库为空时导入，以免已有客户端在升级后立即失效
+var legacyAPIKeys = []string{"client-001", "client-002", "client-003"}
+
+// apiKeyRecord API Key 元数据（不含明文与摘要）
+type apiKeyRecord struct {
+	ID                string             `json:"id"`
+	Prefix            string             `json:"prefix"`                     // Key 明文的前几位，用于辨认
+	Owner             string             `json:"owner"`                      // 持有方
+	AllowedServices   []string           `json:"allowed_services,omitempty"` // 允许请求的服务ID，为空表示不限
+	Strategy          string             `json:"strategy,omitempty"`         // 默认路径选择策略（请求未指定时使用）
+	Limits            *config.RateLimits `json:"limits,omitempty"`           // 限流与配额覆盖值，为空表示沿用 C-PS 默认值
+	Status            string             `json:"status"`
+	CreatedAt         time.Time          `json:"created_at"`
+	ExpiresAt         *time.Time         `json:"expires_at,omitempty"`
+	RevokedAt         *time.Time         `json:"revoked_at,omitempty"`
+	RotatedAt         *time.Time         `json:"rotated_at,omitempty"`
+	PreviousExpiresAt *time.Time         `json:"previous_expires_at,omitempty"` // 轮换前的旧 Key 在此之前仍可使用
+	LastUsedAt        *time.Time         `json:"last_used_at,omitempty"`
+}
+
+// allows 该 Key 是否可请求指定服务
+func (k *apiKeyRecord) allows(serviceID string) bool {
+	if len(k.AllowedServices) == 0 {
+		return true
+	}
+	for _, id := range k.AllowedServices {
+		if id == serviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyCacheEntry 认证缓存项，record 为 nil 表示该摘要无效（否定缓存）
+type apiKeyCacheEntry struct {
+	record   *apiKeyRecord
+	previous bool // 匹配的是轮换前的旧 Key（仅在 record.PreviousExpiresAt 之前有效）
+	cachedAt time.Time
+}
+
+var (
+	apiKeyDB *sql.DB
+
+	apiKeyCache      = make(map[string]apiKeyCacheEntry) // Key 摘要 → 缓存项
+	apiKeyTouchedAt  = make(map[string]time.Time)        // Key ID → 最近一次写入 last_used_at 的时间
+	apiKeyCacheMutex sync.Mutex
+)
+
+// initAPIKeyStore 打开 Key 库并建表；库为空时导入旧版内置 Key
+func initAPIKeyStore() error {
+	db, err := sql.Open("sqlite3", selfCfg.DBFile)
+	if err != nil {
+		return fmt.Errorf("API Key 数据库连接失败：%w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return fmt.Errorf("API Key 数据库验证失败：%w", err)
+	}
+	db.SetMaxOpenConns(1) // SQLite 单写者，避免并发写入时 database is locked
+
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,
+		key_hash TEXT NOT NULL UNIQUE,   -- SHA-256(Key) 十六进制
+		key_prefix TEXT NOT NULL,
+		owner TEXT NOT NULL,
+		allowed_services TEXT NOT NULL DEFAULT '[]',
+		strategy TEXT NOT NULL DEFAULT '',
+		limits TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,     -- 以下时间均为 Unix 秒，NULL 表示无
+		expires_at INTEGER,
+		revoked_at INTEGER,
+		rotated_at INTEGER,
+		previous_hash TEXT,              -- 轮换前的旧 Key 摘要，宽限期内仍可认证
+		previous_expires_at INTEGER,
+		last_used_at INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_api_keys_previous_hash ON api_keys(previous_hash);`
+	if _, err := db.Exec(createTableSQL); err != nil {
+		db.Close()
+		return fmt.Errorf("创建API Key表失败：%w", err)
+	}
+	apiKeyDB = db
+	if err := ensureAPIKeyColumn("limits", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := redactStoredPrefixes(); err != nil {
+		return err
+	}
+	if err := initUsageStore(); err != nil {
+		return err
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM api_keys").Scan(&count); err != nil {
+		return fmt.Errorf("读取API Key表失败：%w", err)
+	}
+	if count == 0 {
+		for _, key := range legacyAPIKeys {
+			rec := &apiKeyRecord{
+				Owner:     "legacy",
+				Strategy:  selfCfg.APIKeyStrategies[key],
+				CreatedAt: time.Now(),
+			}
+			if err := insertAPIKey(rec, key); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("⚠️ API Key 库为空，已导入旧版内置 Key（%s），请通过 /admin/api-keys 签发新 Key 后吊销\n",
+			strings.Join(legacyAPIKeys, ", "))
+	}
+	fmt.Printf("✅ API Key 库：%s\n", selfCfg.DBFile)
+	return nil
+}
+
+// ensureAPIKeyColumn 旧库缺少字段时执行 ALTER TABLE 补齐
+func ensureAPIKeyColumn(column, definition string) error {
+	rows, err := apiKeyDB.Query("PRAGMA table_info(api_keys)")
+	if err != nil {
+		return fmt.Errorf("读取api_keys表结构失败：%w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("解析api_keys表结构失败：%w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	if _, err := apiKeyDB.Exec(fmt.Sprintf("ALTER TABLE api_keys ADD COLUMN %s %s", column, definition)); err != nil {
+		return fmt.Errorf("为api_keys表添加字段%s失败：%w", column, err)
+	}
+	fmt.Printf("✅ 已为api_keys表添加字段：%s\n", column)
+	return nil
+}
+
+// generateAPIKey 生成新的 Key 明文
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成API Key失败：%w", err)
+	}
+	return APIKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+func newAPIKeyID() (string, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成API Key ID失败：%w", err)
+	}
+	return "key-" + hex.EncodeToString(buf), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// keyPrefix 保存用于辨认的 Key 前几位：新签发的 Key 取前缀后6位；其他（如旧版内置）Key 最多取 1/3 且不超过4位，
+// 避免短 Key 被整体存为前缀并经 /admin/api-keys 返回
+func keyPrefix(key string) string {
+	if strings.HasPrefix(key, APIKeyPrefix) && len(key) > len(APIKeyPrefix)+12 {
+		return key[:len(APIKeyPrefix)+6]
+	}
+	return key[:min(len(key)/3, 4)]
+}
+
+// redactStoredPrefixes 旧版本把短 Key 整体存成了前缀，启动时按当前规则截短
+func redactStoredPrefixes() error {
+	rows, err := apiKeyDB.Query("SELECT id, key_prefix FROM api_keys")
+	if err != nil {
+		return fmt.Errorf("读取API Key前缀失败：%w", err)
+	}
+	redacted := make(map[string]string)
+	for rows.Next() {
+		var id, prefix string
+		if err := rows.Scan(&id, &prefix); err != nil {
+			rows.Close()
+			return fmt.Errorf("读取API Key前缀失败：%w", err)
+		}
+		if len(prefix) > 4 && !strings.HasPrefix(prefix, APIKeyPrefix) {
+			redacted[id] = keyPrefix(prefix)
+		}
+	}
+	rows.Close()
+	for id, prefix := range redacted {
+		if _, err := apiKeyDB.Exec("UPDATE api_keys SET key_prefix = ? WHERE id = ?", prefix, id); err != nil {
+			return fmt.Errorf("截短API Key %s 的前缀失败：%w", id, err)
+		}
+	}
+	if len(redacted) > 0 {
+		fmt.Printf("✅ 已截短%d个API Key的存储前缀\n", len(redacted))
+	}
+	return nil
+}
+
+// insertAPIKey 保存新 Key，rec.ID 为空时自动生成
+func insertAPIKey(rec *apiKeyRecord, key string) error {
+	if rec.ID == "" {
+		id, err := newAPIKeyID()
+		if err != nil {
+			return err
+		}
+		rec.ID = id
+	}
+	rec.Prefix = keyPrefix(key)
+	services, _ := json.Marshal(rec.AllowedServices)
+	_, err := apiKeyDB.Exec(`INSERT INTO api_keys
+		(id, key_hash, key_prefix, owner, allowed_services, strategy, limits, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.ID, hashAPIKey(key), rec.Prefix, rec.Owner, string(services), rec.Strategy, encodeLimits(rec.Limits),
+		rec.CreatedAt.Unix(), unixOrNil(rec.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("保存API Key失败：%w", err)
+	}
+	return nil
+}
+
+// encodeLimits 限流覆盖值的存储形式，未设置时为空串
+func encodeLimits(l *config.RateLimits) string {
+	if l == nil {
+		return ""
+	}
+	b, _ := json.Marshal(l)
+	return string(b)
+}
+
+const apiKeyColumns = `id, key_prefix, owner, allowed_services, strategy, limits, created_at,
+	expires_at, revoked_at, rotated_at, previous_expires_at, last_used_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*apiKeyRecord, error) {
+	var (
+		rec       apiKeyRecord
+		services  string
+		limits    string
+		createdAt int64
+		expires   sql.NullInt64
+		revoked   sql.NullInt64
+		rotated   sql.NullInt64
+		previous  sql.NullInt64
+		lastUsed  sql.NullInt64
+	)
+	if err := row.Scan(&rec.ID, &rec.Prefix, &rec.Owner, &services, &rec.Strategy, &limits, &createdAt,
+		&expires, &revoked, &rotated, &previous, &lastUsed); err != nil {
+		return nil, err
+	}
+	_ = json.Unmarshal([]byte(services), &rec.AllowedServices)
+	if limits != "" {
+		rec.Limits = &config.RateLimits{}
+		_ = json.Unmarshal([]byte(limits), rec.Limits)
+	}
+	rec.CreatedAt = time.Unix(createdAt, 0)
+	rec.ExpiresAt = timeOrNil(expires)
+	rec.RevokedAt = timeOrNil(revoked)
+	rec.RotatedAt = timeOrNil(rotated)
+	rec.PreviousExpiresAt = timeOrNil(previous)
+	rec.LastUsedAt = timeOrNil(lastUsed)
+	rec.Status = apiKeyStatus(&rec, time.Now())
+	return &rec, nil
+}
+
+func apiKeyStatus(rec *apiKeyRecord, now time.Time) string {
+	switch {
+	case rec.RevokedAt != nil:
+		return APIKeyStatusRevoked
+	case rec.ExpiresAt != nil && !now.Before(*rec.ExpiresAt):
+		return APIKeyStatusExpired
+	}
+	return APIKeyStatusActive
+}
+
+func unixOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Unix()
+}
+
+func timeOrNil(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(v.Int64, 0)
+	return &t
+}
+
+// getAPIKey 按ID查询
+func getAPIKey(id string) (*apiKeyRecord, error) {
+	return scanAPIKey(apiKeyDB.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", id))
+}
+
+// lookupAPIKey 按 Key 明文查询当前可用的记录（含宽限期内的旧 Key）；无效、已吊销或已过期时返回 nil
+func lookupAPIKey(key string) (*apiKeyRecord, error) {
+	hash := hashAPIKey(key)
+	now := time.Now()
+
+	apiKeyCacheMutex.Lock()
+	entry, ok := apiKeyCache[hash]
+	apiKeyCacheMutex.Unlock()
+	if !ok || now.Sub(entry.cachedAt) > APIKeyCacheTTL {
+		entry = apiKeyCacheEntry{cachedAt: now}
+		rec, err := scanAPIKey(apiKeyDB.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ?", hash))
+		if errors.Is(err, sql.ErrNoRows) {
+			entry.previous = true
+			rec, err = scanAPIKey(apiKeyDB.QueryRow("SELECT "+apiKeyColumns+
+				" FROM api_keys WHERE previous_hash = ? AND previous_expires_at > ?", hash, now.Unix()))
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("查询API Key失败：%w", err)
+		}
+		entry.record = rec
+		apiKeyCacheMutex.Lock()
+		if len(apiKeyCache) >= APIKeyCacheMax {
+			apiKeyCache = make(map[string]apiKeyCacheEntry)
+		}
+		apiKeyCache[hash] = entry
+		apiKeyCacheMutex.Unlock()
+	}
+
+	rec := entry.record
+	if rec == nil || apiKeyStatus(rec, now) != APIKeyStatusActive {
+		return nil, nil
+	}
+	// 旧 Key 的宽限期可能在缓存有效期内结束
+	if entry.previous && (rec.PreviousExpiresAt == nil || !now.Before(*rec.PreviousExpiresAt)) {
+		return nil, nil
+	}
+	touchAPIKey(rec.ID, now)
+	return rec, nil
+}
+
+// touchAPIKey 异步更新 last_used_at（每个 Key 每 APIKeyTouchEvery 最多写一次）
+func touchAPIKey(id string, now time.Time) {
+	apiKeyCacheMutex.Lock()
+	if now.Sub(apiKeyTouchedAt[id]) < APIKeyTouchEvery {
+		apiKeyCacheMutex.Unlock()
+		return
+	}
+	apiKeyTouchedAt[id] = now
+	apiKeyCacheMutex.Unlock()
+
+	go func() {
+		if _, err := apiKeyDB.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", now.Unix(), id); err != nil {
+			fmt.Printf("⚠️ 更新API Key %s 最近使用时间失败：%v\n", id, err)
+		}
+	}()
+}
+
+// invalidateAPIKeyCache 管理操作后清空认证缓存，使吊销/轮换立即生效
+func invalidateAPIKeyCache() {
+	apiKeyCacheMutex.Lock()
+	apiKeyCache = make(map[string]apiKeyCacheEntry)
+	apiKeyCacheMutex.Unlock()
+}
+
+// ------------------------------
+// 管理接口（/admin/api-keys，需 X-Admin-Token）
+// ------------------------------
+
+// adminMiddleware 校验管理令牌；未配置 admin_token 时管理接口整体禁用
+func adminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if selfCfg.AdminToken == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "未配置admin_token，管理接口已禁用",
+			})
+			c.Abort()
+			return
+		}
+		token := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(selfCfg.AdminToken)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "无效的X-Admin-Token",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// apiKeyExpiry 由 expires_at 或 expires_in_seconds 计算过期时间（二者都未指定表示永不过期）
+func apiKeyExpiry(expiresAt *time.Time, expiresIn int, now time.Time) (*time.Time, error) {
+	switch {
+	case expiresAt != nil && expiresIn != 0:
+		return nil, fmt.Errorf("expires_at 与 expires_in_seconds 只能指定一个")
+	case expiresIn < 0:
+		return nil, fmt.Errorf("expires_in_seconds 不能为负数")
+	case expiresIn > 0:
+		t := now.Add(time.Duration(expiresIn) * time.Second)
+		return &t, nil
+	case expiresAt != nil && !expiresAt.After(now):
+		return nil, fmt.Errorf("expires_at 必须晚于当前时间")
+	}
+	return expiresAt, nil
+}
+
+// createAPIKeyHandler：POST /admin/api-keys —— 签发新 Key，明文仅在响应中出现一次
+func createAPIKeyHandler(c *gin.Context) {
+	var req struct {
+		Owner            string             `json:"owner"`
+		AllowedServices  []string           `json:"allowed_services"`
+		Strategy         string             `json:"strategy"`
+		Limits           *config.RateLimits `json:"limits"`
+		ExpiresAt        *time.Time         `json:"expires_at"`
+		ExpiresInSeconds int                `json:"expires_in_seconds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求格式错误：" + err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Owner) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "owner不能为空"})
+		return
+	}
+	if req.Strategy != "" {
+		if _, ok := strategies[req.Strategy]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("未知的路径选择策略：%s（可选：%s）", req.Strategy, strings.Join(strategyNames(), ", ")),
+			})
+			return
+		}
+	}
+	now := time.Now()
+	expiresAt, err := apiKeyExpiry(req.ExpiresAt, req.ExpiresInSeconds, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	rec := &apiKeyRecord{
+		Owner:           strings.TrimSpace(req.Owner),
+		AllowedServices: req.AllowedServices,
+		Strategy:        req.Strategy,
+		Limits:          req.Limits,
+		CreatedAt:       now,
+		ExpiresAt:       expiresAt,
+	}
+	if err := insertAPIKey(rec, key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	invalidateAPIKeyCache()
+	rec.CreatedAt = time.Unix(now.Unix(), 0)
+	rec.Status = apiKeyStatus(rec, now)
+
+	fmt.Printf("🔑 签发API Key %s（owner=%s）\n", rec.ID, rec.Owner)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "API Key 已签发，请妥善保存（明文不会再次返回）",
+		"api_key": key,
+		"data":    rec,
+	})
+}
+
+// listAPIKeysHandler：GET /admin/api-keys —— 全部 Key 的元数据（?owner= 过滤持有方）
+func listAPIKeysHandler(c *gin.Context) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys"
+	var args []any
+	if owner := c.Query("owner"); owner != "" {
+		query += " WHERE owner = ?"
+		args = append(args, owner)
+	}
+	rows, err := apiKeyDB.Query(query+" ORDER BY created_at, id", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "查询API Key失败：" + err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	keys := []*apiKeyRecord{}
+	for rows.Next() {
+		rec, err := scanAPIKey(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "读取API Key失败：" + err.Error()})
+			return
+		}
+		keys = append(keys, rec)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "count": len(keys), "data": keys})
+}
+
+// apiKeyOr404 按路径参数 :id 查询，不存在时写入404
+func apiKeyOr404(c *gin.Context) *apiKeyRecord {
+	rec, err := getAPIKey(c.Param("id"))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "API Key不存在：" + c.Param("id")})
+		return nil
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "查询API Key失败：" + err.Error()})
+		return nil
+	}
+	return rec
+}
+
+// getAPIKeyHandler：GET /admin/api-keys/:id
+func getAPIKeyHandler(c *gin.Context) {
+	if rec := apiKeyOr404(c); rec != nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": rec})
+	}
+}
+
+// revokeAPIKeyHandler：DELETE /admin/api-keys/:id —— 吊销（保留记录用于审计，立即失效）
+func revokeAPIKeyHandler(c *gin.Context) {
+	rec := apiKeyOr404(c)
+	if rec == nil {
+		return
+	}
+	if rec.RevokedAt == nil {
+		now := time.Now().Unix()
+		if _, err := apiKeyDB.Exec("UPDATE api_keys SET revoked_at = ?, previous_hash = NULL, previous_expires_at = NULL WHERE id = ?",
+			now, rec.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "吊销API Key失败：" + err.Error()})
+			return
+		}
+		invalidateAPIKeyCache()
+		fmt.Printf("🔒 吊销API Key %s（owner=%s）\n", rec.ID, rec.Owner)
+		rec, _ = getAPIKey(rec.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "API Key 已吊销", "data": rec})
+}
+
+// rotateAPIKeyHandler：POST /admin/api-keys/:id/rotate —— 签发新明文（ID 与元数据不变），
+// 旧 Key 在 grace_seconds（默认3600，0 表示立即失效）内仍可使用
+func rotateAPIKeyHandler(c *gin.Context) {
+	var req struct {
+		GraceSeconds *int `json:"grace_seconds"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求格式错误：" + err.Error()})
+			return
+		}
+	}
+	grace := DefaultRotateGrace
+	if req.GraceSeconds != nil {
+		if *req.GraceSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "grace_seconds 不能为负数"})
+			return
+		}
+		grace = time.Duration(*req.GraceSeconds) * time.Second
+	}
+
+	rec := apiKeyOr404(c)
+	if rec == nil {
+		return
+	}
+	if rec.Status != APIKeyStatusActive {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": fmt.Sprintf("API Key %s 状态为 %s，不能轮换", rec.ID, rec.Status)})
+		return
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	now := time.Now()
+	// 旧摘要移入 previous_hash；宽限期为0时不保留
+	var previousExpires any
+	if grace > 0 {
+		previousExpires = now.Add(grace).Unix()
+	}
+	_, err = apiKeyDB.Exec(`UPDATE api_keys SET
+		previous_hash = CASE WHEN ? IS NULL THEN NULL ELSE key_hash END,
+		previous_expires_at = ?, key_hash = ?, key_prefix = ?, rotated_at = ?
+		WHERE id = ?`,
+		previousExpires, previousExpires, hashAPIKey(key), keyPrefix(key), now.Unix(), rec.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "轮换API Key失败：" + err.Error()})
+		return
+	}
+	invalidateAPIKeyCache()
+	rec, _ = getAPIKey(rec.ID)
+
+	fmt.Printf("🔄 轮换API Key %s（旧Key宽限 %s）\n", rec.ID, grace)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "API Key 已轮换，请妥善保存（明文不会再次返回）",
+		"api_key": key,
+		"data":    rec,
+	})
+}
+
+// setAPIKeyLimitsHandler：PUT /admin/api-keys/:id/limits —— 设置该 Key 的限流与配额覆盖值（请求体为 null 时恢复默认）
+func setAPIKeyLimitsHandler(c *gin.Context) {
+	var limits *config.RateLimits
+	if err := c.ShouldBindJSON(&limits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求格式错误：" + err.Error()})
+		return
+	}
+	rec := apiKeyOr404(c)
+	if rec == nil {
+		return
+	}
+	if _, err := apiKeyDB.Exec("UPDATE api_keys SET limits = ? WHERE id = ?", encodeLimits(limits), rec.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "更新限流配置失败：" + err.Error()})
+		return
+	}
+	invalidateAPIKeyCache()
+	rec, _ = getAPIKey(rec.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"message":          "限流与配额已更新",
+		"data":             rec,
+		"effective_limits": effectiveLimits(rec),
+	})
+}
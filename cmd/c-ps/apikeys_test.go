@@ -0,0 +1,88 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cmas-cats-go/config"
+)
+
+// setupKeyStore 在临时目录打开 API Key 库（首次打开时导入旧版内置 Key），并清空内存中的限流状态
+func setupKeyStore(t *testing.T, limits config.RateLimits) {
+	t.Helper()
+	selfCfg = &config.PSConfig{ID: "ps-test", DBFile: filepath.Join(t.TempDir(), "ps.db"), Limits: limits}
+	if err := initAPIKeyStore(); err != nil {
+		t.Fatalf("初始化API Key库失败：%v", err)
+	}
+	t.Cleanup(func() { apiKeyDB.Close() })
+	rateLimitMutex.Lock()
+	buckets = make(map[string]*tokenBucket)
+	usages = make(map[string]*keyUsage)
+	rateLimitMutex.Unlock()
+}
+
+func TestKeyPrefix(t *testing.T) {
+	issued, err := generateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		key  string
+		want string
+	}{
+		{issued, issued[:len(APIKeyPrefix)+6]},
+		{"client-001", "cli"},
+		{"abcdefghijklmnopqrstuvwxyz", "abcd"},
+		{"cmas_short", "cma"},
+		{"ab", ""},
+	}
+	for _, tc := range cases {
+		got := keyPrefix(tc.key)
+		if got != tc.want {
+			t.Errorf("keyPrefix(%q) = %q，期望 %q", tc.key, got, tc.want)
+		}
+		if len(tc.key) > 0 && got == tc.key {
+			t.Errorf("keyPrefix(%q) 返回了完整 Key", tc.key)
+		}
+	}
+}
+
+// TestLegacyKeyPrefixRedacted 导入的旧版内置 Key 与旧库中整体存为前缀的 Key 都只保留截短的前缀
+func TestLegacyKeyPrefixRedacted(t *testing.T) {
+	setupKeyStore(t, config.RateLimits{})
+	// 模拟旧版本写入的完整前缀
+	rec := &apiKeyRecord{ID: "key-old", Owner: "legacy", CreatedAt: time.Now()}
+	if err := insertAPIKey(rec, "client-999"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := apiKeyDB.Exec("UPDATE api_keys SET key_prefix = ? WHERE id = ?", "client-999", "key-old"); err != nil {
+		t.Fatal(err)
+	}
+	apiKeyDB.Close()
+
+	if err := initAPIKeyStore(); err != nil {
+		t.Fatalf("重新打开API Key库失败：%v", err)
+	}
+
+	rows, err := apiKeyDB.Query("SELECT id, key_prefix FROM api_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var id, prefix string
+		if err := rows.Scan(&id, &prefix); err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if len(prefix) > 4 || strings.HasPrefix(prefix, "client-") {
+			t.Errorf("Key %s 的前缀 %q 未截短", id, prefix)
+		}
+	}
+	if n != len(legacyAPIKeys)+1 {
+		t.Errorf("共%d个Key，期望%d", n, len(legacyAPIKeys)+1)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"cmas-cats-go/models"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 约束项名称（explain 输出中 verdicts 的键）
+const (
+	ConstraintFreshness  = "freshness"  // 实例数据未过期（或客户端设置了 allow_stale）
+	ConstraintValidation = "validation" // 所属站点已通过部署验证（或服务无需验证）
+	ConstraintNetwork    = "network"    // C-NMA 确认站点网络可达
+	ConstraintGas        = "gas"        // 仍有剩余gas
+	ConstraintCost       = "cost"       // 成本不超过 max_accept_cost
+	ConstraintDelay      = "delay"      // 端到端延迟不超过 max_accept_delay
+)
+
+// constraintOrder explain 输出及拒绝原因汇总的固定顺序
+var constraintOrder = []string{ConstraintFreshness, ConstraintValidation, ConstraintNetwork, ConstraintGas, ConstraintCost, ConstraintDelay}
+
+// Verdict 单个约束项的判定结果
+type Verdict struct {
+	Passed bool        `json:"passed"`
+	Actual interface{} `json:"actual,omitempty"` // 实例的实际值
+	Limit  interface{} `json:"limit,omitempty"`  // 客户端给出的上限
+	Detail string      `json:"detail,omitempty"`
+}
+
+// evaluation 一个候选实例的完整评估（不短路：所有约束都会给出判定，便于排查）
+type evaluation struct {
+	instance  models.ServiceInstanceInfo // 已填充网络时延
+	verdicts  map[string]Verdict
+	qualified bool
+}
+
+// evaluateInstances 对缓存中的每个实例逐项检查约束；allowStale 为 true 时过期实例也可参与选择
+func evaluateInstances(instances []models.ServiceInstanceInfo, maxCost, maxDelay int, allowStale bool) []evaluation {
+	snapshot := getValidationSnapshot()
+	paths := getNetworkPaths()
+	mutex.RLock()
+	ttl := instanceTTL
+	mutex.RUnlock()
+
+	evals := make([]evaluation, 0, len(instances))
+	for _, inst := range instances {
+		verdicts := make(map[string]Verdict, len(constraintOrder))
+
+		freshness := Verdict{Passed: true, Actual: int(time.Since(inst.LastSeen).Seconds()), Limit: int(ttl.Seconds()), Detail: "距站点最近确认的秒数"}
+		switch {
+		case inst.LastSeen.IsZero():
+			freshness = Verdict{Passed: true, Detail: "C-SMA未提供确认时间，不检查"}
+		case isStale(inst) && allowStale:
+			freshness.Detail = "实例已过期，客户端设置了 allow_stale"
+		case isStale(inst):
+			freshness.Passed = false
+			freshness.Detail = "实例已过期（超过C-SMA的实例过期时长未被站点确认）"
+		}
+		verdicts[ConstraintFreshness] = freshness
+
+		switch {
+		case snapshot == nil:
+			verdicts[ConstraintValidation] = Verdict{Passed: true, Detail: "未获取到验证快照，不过滤"}
+		case snapshot.Allows(inst.SiteID, inst.ServiceID):
+			verdicts[ConstraintValidation] = Verdict{Passed: true}
+		default:
+			verdicts[ConstraintValidation] = Verdict{Passed: false, Detail: "站点" + inst.SiteID + "未通过该服务的部署验证"}
+		}
+
+		path, measured := paths[inst.SiteID]
+		switch {
+		case !measured:
+			verdicts[ConstraintNetwork] = Verdict{Passed: true, Detail: "无网络度量，仅按计算时延判断"}
+		case applyNetworkDelay(&inst, paths):
+			verdicts[ConstraintNetwork] = Verdict{Passed: true, Actual: path.RTTMs, Detail: "RTT（ms）"}
+		default:
+			verdicts[ConstraintNetwork] = Verdict{Passed: false, Actual: path.LossRate, Detail: "本轮探测全部失败：" + path.LastError}
+		}
+
+		verdicts[ConstraintGas] = Verdict{Passed: inst.Gas > 0, Actual: inst.Gas}
+		verdicts[ConstraintCost] = Verdict{Passed: inst.Cost <= maxCost, Actual: inst.Cost, Limit: maxCost}
+		verdicts[ConstraintDelay] = Verdict{
+			Passed: inst.TotalDelay() <= maxDelay,
+			Actual: inst.TotalDelay(),
+			Limit:  maxDelay,
+			Detail: fmt.Sprintf("计算时延%dms + 网络时延%dms", inst.Delay, inst.NetworkDelay),
+		}
+
+		qualified := true
+		for _, v := range verdicts {
+			qualified = qualified && v.Passed
+		}
+		evals = append(evals, evaluation{instance: inst, verdicts: verdicts, qualified: qualified})
+	}
+	return evals
+}
+
+// isStale 实例已被C-SMA标记过期，或按C-SMA通告的过期时长在本地判定已过期（订阅流断开期间缓存不再更新）
+func isStale(inst models.ServiceInstanceInfo) bool {
+	if inst.Stale {
+		return true
+	}
+	mutex.RLock()
+	ttl := instanceTTL
+	mutex.RUnlock()
+	return ttl > 0 && !inst.LastSeen.IsZero() && time.Since(inst.LastSeen) > ttl
+}
+
+// qualifiedInstances 取出通过全部约束的实例
+func qualifiedInstances(evals []evaluation) []models.ServiceInstanceInfo {
+	var qualified []models.ServiceInstanceInfo
+	for _, e := range evals {
+		if e.qualified {
+			qualified = append(qualified, e.instance)
+		}
+	}
+	return qualified
+}
+
+// CandidateExplanation explain 模式下单个候选实例的判定、得分与排名
+type CandidateExplanation struct {
+	CSCI_ID        string             `json:"csci_id"`
+	SiteID         string             `json:"site_id"`
+	Cost           int                `json:"cost"`
+	Gas            int                `json:"gas"`
+	Capacity       int                `json:"capacity"`
+	ComputingDelay int                `json:"computing_delay"`
+	NetworkDelay   int                `json:"network_delay"`
+	Delay          int                `json:"delay"` // 端到端延迟
+	LastSeen       time.Time          `json:"last_seen"`
+	Stale          bool               `json:"stale"`
+	Verdicts       map[string]Verdict `json:"verdicts"`
+	Qualified      bool               `json:"qualified"`
+	Score          *float64           `json:"score"`                 // 加权得分（越低越优，权重见 score_weights；仅在合格实例间归一化，不合格实例为 null）
+	Rank           int                `json:"rank,omitempty"`        // 按本次策略的排名（从1开始，仅合格实例）
+	Reservation    string             `json:"reservation,omitempty"` // 预占结果：reserved / failed / not_tried
+}
+
+// DecisionExplanation explain 模式的完整输出
+type DecisionExplanation struct {
+	Strategy        string                 `json:"strategy"`
+	ScoreWeights    models.StrategyWeights `json:"score_weights"`
+	CacheLastSync   string                 `json:"cache_last_sync"`
+	CacheAgeSeconds int                    `json:"cache_age_seconds"`
+	Total           int                    `json:"total"`
+	Qualified       int                    `json:"qualified"`
+	Rejections      map[string]int         `json:"rejections"` // 各约束项未通过的实例数
+	Reason          string                 `json:"reason"`     // 一句话结论
+	Candidates      []CandidateExplanation `json:"candidates"`
+}
+
+// newExplanation 汇总评估结果与策略排名；ranked 为策略排序后的合格实例
+func newExplanation(evals []evaluation, ranked []models.ServiceInstanceInfo, strategy Strategy, req models.ClientRequest) *DecisionExplanation {
+	weights := defaultWeights
+	if req.Weights != nil {
+		weights = *req.Weights
+	}
+	// 与 weighted 策略一致：只在参与排名的合格实例间归一化
+	scores := weightedScores(qualifiedInstances(evals), weights)
+
+	ranks := make(map[string]int, len(ranked))
+	for i, inst := range ranked {
+		ranks[inst.CSCI_ID] = i + 1
+	}
+
+	mutex.RLock()
+	syncedAt := lastSyncTime
+	mutex.RUnlock()
+
+	exp := &DecisionExplanation{
+		Strategy:        strategy.Name(),
+		ScoreWeights:    weights,
+		CacheLastSync:   syncedAt.Format("2006-01-02 15:04:05"),
+		CacheAgeSeconds: int(time.Since(syncedAt).Seconds()),
+		Total:           len(evals),
+		Qualified:       len(ranked),
+		Rejections:      make(map[string]int),
+		Candidates:      make([]CandidateExplanation, 0, len(evals)),
+	}
+	for _, e := range evals {
+		inst := e.instance
+		for name, v := range e.verdicts {
+			if !v.Passed {
+				exp.Rejections[name]++
+			}
+		}
+		var score *float64
+		if s, ok := scores[inst.CSCI_ID]; ok && e.qualified {
+			score = &s
+		}
+		exp.Candidates = append(exp.Candidates, CandidateExplanation{
+			CSCI_ID:        inst.CSCI_ID,
+			SiteID:         inst.SiteID,
+			Cost:           inst.Cost,
+			Gas:            inst.Gas,
+			Capacity:       inst.Capacity,
+			ComputingDelay: inst.Delay,
+			NetworkDelay:   inst.NetworkDelay,
+			Delay:          inst.TotalDelay(),
+			LastSeen:       inst.LastSeen,
+			Stale:          isStale(inst),
+			Verdicts:       e.verdicts,
+			Qualified:      e.qualified,
+			Score:          score,
+			Rank:           ranks[inst.CSCI_ID],
+		})
+	}
+	// 合格实例按排名在前，不合格实例保持缓存中的顺序在后
+	sort.SliceStable(exp.Candidates, func(i, j int) bool {
+		a, b := exp.Candidates[i], exp.Candidates[j]
+		if (a.Rank == 0) != (b.Rank == 0) {
+			return a.Rank != 0
+		}
+		return a.Rank < b.Rank
+	})
+	exp.Reason = exp.summarize()
+	return exp
+}
+
+// summarize 给出无合格实例时的主要原因（或合格实例数）
+func (e *DecisionExplanation) summarize() string {
+	if e.Total == 0 {
+		return "缓存中没有该服务的实例（服务未部署，或C-SMA尚未同步）"
+	}
+	if e.Qualified > 0 {
+		return fmt.Sprintf("%d/%d个实例满足全部约束", e.Qualified, e.Total)
+	}
+	reason := fmt.Sprintf("%d个实例均不满足约束：", e.Total)
+	first := true
+	for _, name := range constraintOrder {
+		if n := e.Rejections[name]; n > 0 {
+			if !first {
+				reason += "，"
+			}
+			reason += fmt.Sprintf("%s未通过%d个", name, n)
+			first = false
+		}
+	}
+	return reason
+}
+
+// markReservation 记录预占结果：排在选中实例之前的候选均已尝试且失败；selected 为空表示全部失败
+func (e *DecisionExplanation) markReservation(selected string) {
+	selectedRank := 0
+	for _, cand := range e.Candidates {
+		if selected != "" && cand.CSCI_ID == selected {
+			selectedRank = cand.Rank
+		}
+	}
+	for i := range e.Candidates {
+		cand := &e.Candidates[i]
+		switch {
+		case cand.Rank == 0: // 不合格，未参与预占
+		case selected == "" || cand.Rank < selectedRank:
+			cand.Reservation = "failed"
+		case cand.Rank == selectedRank:
+			cand.Reservation = "reserved"
+		default:
+			cand.Reservation = "not_tried"
+		}
+	}
+}
+
+// withExplanation 开启 explain 模式时在响应中附带 explanation 字段
+func withExplanation(h gin.H, e *DecisionExplanation) gin.H {
+	if e != nil {
+		h["explanation"] = e
+	}
+	return h
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"cmas-cats-go/models"
+)
+
+// explain 的得分须与 weighted 策略的排名一致：只在合格实例间归一化，不合格实例不给得分
+func TestExplanationScoresQualifiedOnly(t *testing.T) {
+	evals := []evaluation{
+		{instance: models.ServiceInstanceInfo{CSCI_ID: "a", Cost: 10, Delay: 10, Gas: 5}, qualified: true},
+		{instance: models.ServiceInstanceInfo{CSCI_ID: "b", Cost: 20, Delay: 5, Gas: 5}, qualified: true},
+		// 成本远高于合格实例：若参与归一化会压低 a、b 的得分
+		{instance: models.ServiceInstanceInfo{CSCI_ID: "c", Cost: 1000, Delay: 1, Gas: 0}, qualified: false},
+	}
+	req := models.ClientRequest{ServiceID: "svc"}
+	strategy := weightedStrategy{}
+	ranked := strategy.Rank(qualifiedInstances(evals), req)
+	want := weightedScores(qualifiedInstances(evals), defaultWeights)
+
+	exp := newExplanation(evals, ranked, strategy, req)
+	if len(exp.Candidates) != len(evals) {
+		t.Fatalf("候选数=%d，期望%d", len(exp.Candidates), len(evals))
+	}
+	for i, cand := range exp.Candidates {
+		if !cand.Qualified {
+			if cand.Score != nil {
+				t.Fatalf("不合格实例%s的得分应为null，实际%v", cand.CSCI_ID, *cand.Score)
+			}
+			continue
+		}
+		if cand.Score == nil || *cand.Score != want[cand.CSCI_ID] {
+			t.Fatalf("实例%s的得分=%v，期望%v", cand.CSCI_ID, cand.Score, want[cand.CSCI_ID])
+		}
+		if cand.CSCI_ID != ranked[i].CSCI_ID || cand.Rank != i+1 {
+			t.Fatalf("第%d个候选为%s（排名%d），期望%s", i+1, cand.CSCI_ID, cand.Rank, ranked[i].CSCI_ID)
+		}
+		if i > 0 && *exp.Candidates[i-1].Score > *cand.Score {
+			t.Fatalf("得分顺序与策略排名不一致：%v > %v", *exp.Candidates[i-1].Score, *cand.Score)
+		}
+	}
+}
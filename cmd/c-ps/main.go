@@ -3,10 +3,12 @@ package main
 import (
 	"cmas-cats-go/config"
 	"cmas-cats-go/models"
+	"cmas-cats-go/telemetry"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
-	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -17,23 +19,39 @@ import (
 
 // 核心配置常量
 const (
-	MaxSyncRetry  = 3
-	RetryInterval = 2 * time.Second
-	CacheExpire   = 5 * time.Minute
+	// 订阅流断开时本地缓存的有效期
+	CacheExpire = 5 * time.Minute
+	// 服务元数据（生命周期状态）缓存有效期
+	ServiceInfoExpire = 30 * time.Second
+	// 部署验证快照缓存有效期
+	ValidationExpire = 30 * time.Second
 )
 
+// errServiceNotFound 公共服务平台中不存在该服务（已删除或从未注册）
+var errServiceNotFound = errors.New("服务不存在")
+
 // 全局状态管理
 var (
 	cachedMetrics = make(map[string][]models.ServiceInstanceInfo) // 缓存服务实例数据
 	lastSyncTime  time.Time
+	instanceTTL   time.Duration // C-SMA通告的实例过期时长（来自 /sync 或订阅快照）
 	mutex         sync.RWMutex
+
+	selfCfg *config.PSConfig // 本C-PS实例配置
+	csmaURL string           // 数据来源C-SMA的地址
+
+	serviceInfoCache = make(map[string]cachedServiceInfo) // 服务元数据缓存（来自公共服务平台）
+	serviceInfoMutex sync.RWMutex
+
+	validationSnapshot  *models.ValidationSnapshot // 部署验证快照（来自公共服务平台）
+	validationFetchedAt time.Time
+	validationMutex     sync.Mutex
 )
 
-// 合法API Key列表（生产环境建议存储在数据库）
-var validAPIKeys = map[string]bool{
-	"client-001": true,
-	"client-002": true,
-	"client-003": true,
+// cachedServiceInfo 服务元数据缓存项
+type cachedServiceInfo struct {
+	service   models.Service
+	fetchedAt time.Time
 }
 
 func main() {
@@ -42,21 +60,79 @@ func main() {
 	fmt.Println("        C-PS 路径选择服务启动中...        ")
 	fmt.Println("=====================================")
 
+	// 加载拓扑配置，确定本实例及其数据来源C-SMA
+	flags := config.BindFlags()
+	flag.Parse()
+	if err := flags.Load(); err != nil {
+		fmt.Printf("❌ 加载配置失败，程序退出：%v\n", err)
+		return
+	}
+	var err error
+	if selfCfg, err = config.PS(flags.ID); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if flags.Port > 0 {
+		selfCfg.OverridePort(flags.Port)
+	}
+	sma, err := config.SMA(selfCfg.SMA)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	csmaURL = sma.URL
+	if err := validateStrategyConfig(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if err := initAPIKeyStore(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	defer apiKeyDB.Close()
+	if selfCfg.AdminToken == "" {
+		fmt.Println("⚠️ 未配置admin_token，API Key 管理接口（/admin/api-keys）已禁用")
+	}
+	if selfCfg.NMA != "" {
+		nma, err := config.NMA(selfCfg.NMA)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+		nmaURL = nma.URL
+		fmt.Printf("📌 网络时延来源：C-NMA %s（%s，观测点：%s）\n", nma.ID, nma.URL, nma.Vantage)
+	} else {
+		fmt.Println("⚠️ 未配置C-NMA，仅按站点上报的计算时延判断MaxAcceptDelay")
+	}
+
 	// 初始化Gin引擎
 	r := gin.Default() // 引擎实例名为 r
 	// ❗ 增加 CORS 配置：允许所有来源 (All Origins) 访问 ❗
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // 允许所有来源 (如果知道前端地址，可以写死，但 * 最方便)
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-API-Key"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-API-Key", "X-Admin-Token"},
+		ExposeHeaders:    []string{"Content-Length", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
+	telemetry.Register(r, "c-ps", selfCfg.ID) // 运维指标（GET /prometheus）
 	// 注册路由
-	r.POST("/request-service", authMiddleware(), handleClientRequest) // 客户端请求（需认证）
-	r.GET("/refresh-metrics", refreshMetricsCache)                    // 手动刷新缓存
-	r.GET("/cached-metrics", getCachedMetrics)                        // 查看缓存数据
+	r.POST("/request-service", authMiddleware(), rateLimitMiddleware(), handleClientRequest) // 客户端请求（需认证，按Key限流）
+	r.GET("/usage", authMiddleware(), getUsageHandler)                                       // 本Key的用量与剩余配额
+	r.GET("/refresh-metrics", refreshMetricsCache)                                           // 手动刷新缓存
+	r.GET("/cached-metrics", getCachedMetrics)                                               // 查看缓存数据
+	r.GET("/strategies", getStrategiesHandler)                                               // 可用路径选择策略及选择统计
+
+	// API Key 管理（需 X-Admin-Token）
+	admin := r.Group("/admin", adminMiddleware())
+	admin.POST("/api-keys", createAPIKeyHandler)
+	admin.GET("/api-keys", listAPIKeysHandler)
+	admin.GET("/api-keys/:id", getAPIKeyHandler)
+	admin.DELETE("/api-keys/:id", revokeAPIKeyHandler)
+	admin.POST("/api-keys/:id/rotate", rotateAPIKeyHandler)
+	admin.PUT("/api-keys/:id/limits", setAPIKeyLimitsHandler)
+	admin.GET("/api-keys/:id/usage", getAPIKeyUsageHandler)
 
 	// 添加Web界面
 	r.LoadHTMLGlob("./templates/ps/*.html")
@@ -83,7 +159,7 @@ func main() {
 	})
 
 	// 从配置获取C-SMA同步地址
-	CSMASyncURL := fmt.Sprintf("http://%s:%d/sync", config.Cfg.SMA.IP, config.Cfg.SMA.Port)
+	CSMASyncURL := csmaURL + "/sync"
 
 	// 预加载C-SMA数据
 	if err := syncMetricsFromCSMA(); err != nil {
@@ -92,17 +168,20 @@ func main() {
 		fmt.Printf("✅ 预加载成功！当前缓存 %d 个服务的实例数据\n", len(cachedMetrics))
 	}
 
+	// 订阅C-SMA的实例变化，保持缓存实时
+	go startMetricsStream()
+
 	// C-PS 模块启动配置
-	// 实际监听地址必须使用 config.LOCAL_LISTEN_IP ("0.0.0.0")
-	listenAddr := config.LOCAL_LISTEN_IP + ":" + strconv.Itoa(config.Cfg.PS.Port)
+	// 实际监听地址必须使用 config.Cfg.ListenIP ("0.0.0.0")
+	listenAddr := config.Cfg.ListenIP + ":" + strconv.Itoa(selfCfg.Port)
 
 	// 启动成功后，信息输出应该使用外部 IP
-	externalListenAddr := fmt.Sprintf("http://%s:%d", config.Cfg.PS.IP, config.Cfg.PS.Port)
+	externalListenAddr := selfCfg.URL
 
 	// 启动服务前打印信息
-	fmt.Printf("\n✅ C-PS 启动成功！\n")
+	fmt.Printf("\n✅ C-PS（%s）启动成功！\n", selfCfg.ID)
 	fmt.Printf("📌 监听地址：%s\n", externalListenAddr)
-	fmt.Printf("📌 C-SMA 同步地址：%s\n", CSMASyncURL)
+	fmt.Printf("📌 C-SMA 同步地址：%s（订阅：%s/subscribe）\n", CSMASyncURL, csmaURL)
 	fmt.Printf("📌 缓存过期时间：%v\n", CacheExpire)
 
 	// 启动服务 (使用 r 实例和 listenAddr)
@@ -134,15 +213,25 @@ func authMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if !validAPIKeys[apiKey] {
+		key, err := lookupAPIKey(apiKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if key == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
-				"message": "无效的API Key，拒绝访问",
+				"message": "无效、已吊销或已过期的API Key，拒绝访问",
 			})
 			c.Abort()
 			return
 		}
 
+		c.Set(apiKeyContextKey, key)
 		c.Next() // 认证通过，继续处理请求
 	}
 }
@@ -150,7 +239,8 @@ func authMiddleware() gin.HandlerFunc {
 // 从C-SMA同步数据
 func syncMetricsFromCSMA() error {
 	// 发送请求到C-SMA
-	csmaSyncURL := fmt.Sprintf("%s/sync", config.Cfg.SMA.URL)
+	// 过期实例也一并获取（带 stale 标记），由请求的 allow_stale 决定是否使用
+	csmaSyncURL := fmt.Sprintf("%s/sync?include_stale=true", csmaURL)
 	resp, err := http.Get(csmaSyncURL)
 	if err != nil {
 		return fmt.Errorf("请求C-SMA失败：%w", err)
@@ -163,9 +253,10 @@ func syncMetricsFromCSMA() error {
 
 	// 解析响应数据
 	var csmaResp struct {
-		Success    bool `json:"success"`
-		ServiceNum int  `json:"service_num"`
-		Data       []struct {
+		Success            bool `json:"success"`
+		ServiceNum         int  `json:"service_num"`
+		InstanceTTLSeconds int  `json:"instance_ttl_seconds"`
+		Data               []struct {
 			ServiceID string                       `json:"service_id"`
 			Instances []models.ServiceInstanceInfo `json:"instances"`
 		} `json:"data"`
@@ -189,6 +280,7 @@ func syncMetricsFromCSMA() error {
 		cachedMetrics[item.ServiceID] = item.Instances
 	}
 	lastSyncTime = time.Now()
+	instanceTTL = time.Duration(csmaResp.InstanceTTLSeconds) * time.Second
 
 	fmt.Printf("[%s] 同步C-SMA成功：%d个服务，共%d个实例\n",
 		lastSyncTime.Format("15:04:05"),
@@ -233,91 +325,256 @@ func handleClientRequest(c *gin.Context) {
 		return
 	}
 
-	// 检查并刷新缓存
+	key := c.MustGet(apiKeyContextKey).(*apiKeyRecord)
+	if !key.allows(req.ServiceID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("API Key %s 无权请求服务%s", key.ID, req.ServiceID),
+		})
+		return
+	}
+
+	// 确定路径选择策略（请求字段 > API Key 默认策略 > C-PS 默认）
+	holder := selfCfg.ID + "/" + key.ID
+	strategy, err := resolveStrategy(req, key)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// 服务生命周期检查：已删除或已下线（retired）的服务不再路由
+	service, err := getServiceInfo(req.ServiceID)
+	switch {
+	case errors.Is(err, errServiceNotFound):
+		observeSelection(req.ServiceID, strategy.Name(), OutcomeNotFound)
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("服务%s不存在于公共服务平台", req.ServiceID),
+		})
+		return
+	case err != nil:
+		// 平台不可用时不阻断路由，仅记录日志
+		fmt.Printf("⚠️ 查询服务%s状态失败：%v（跳过生命周期检查）\n", req.ServiceID, err)
+	case !service.IsRoutable():
+		observeSelection(req.ServiceID, strategy.Name(), OutcomeRetired)
+		c.JSON(http.StatusGone, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("服务%s已下线（状态：%s），不再提供路由", req.ServiceID, service.Status),
+			"status":  service.Status,
+		})
+		return
+	}
+
+	// 订阅流断开时缓存可能过时：同步一次（重试由后台订阅流的重连负责，不在请求中等待）
 	if needRefreshCache(req.ServiceID) {
 		fmt.Printf("缓存过期或无%s实例数据，尝试刷新...\n", req.ServiceID)
-		var syncErr error
-		for i := 0; i < MaxSyncRetry; i++ {
-			if err := syncMetricsFromCSMA(); err != nil {
-				syncErr = err
-				fmt.Printf("刷新重试(%d/%d)失败：%v\n", i+1, MaxSyncRetry, err)
-				time.Sleep(RetryInterval)
-			} else {
-				syncErr = nil
-				break
-			}
-		}
-		if syncErr != nil {
+		if err := syncMetricsFromCSMA(); err != nil {
+			observeSelection(req.ServiceID, strategy.Name(), OutcomeSyncError)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
-				"message": "无法获取服务实例数据：" + syncErr.Error(),
+				"message": "无法获取服务实例数据：" + err.Error(),
 			})
 			return
 		}
 	}
 
-	// 获取可用实例
-	mutex.RLock()
-	targetInstances := cachedMetrics[req.ServiceID]
-	mutex.RUnlock()
+	// 获取可用实例（副本，选择过程中不持有缓存锁）
+	targetInstances := cachedInstances(req.ServiceID)
 
-	// 筛选符合条件的实例（成本+延迟）
-	qualified := filterInstances(targetInstances, req.MaxAcceptCost, req.MaxAcceptDelay)
-	if len(qualified) == 0 {
-		c.JSON(http.StatusForbidden, gin.H{
+	// 逐项检查约束（新鲜度、验证、网络、gas、成本、端到端延迟），并按策略对合格实例排序
+	evals := evaluateInstances(targetInstances, req.MaxAcceptCost, req.MaxAcceptDelay, req.AllowStale)
+	ranked := strategy.Rank(qualifiedInstances(evals), req)
+	observeRejections(req.ServiceID, evals)
+
+	// explain 模式：在响应中附带每个候选实例的判定、得分与排名
+	var explanation *DecisionExplanation
+	if req.Explain || c.Query("explain") == "true" {
+		explanation = newExplanation(evals, ranked, strategy, req)
+	}
+
+	if len(ranked) == 0 {
+		observeSelection(req.ServiceID, strategy.Name(), OutcomeNoCandidate)
+		c.JSON(http.StatusForbidden, withExplanation(gin.H{
 			"success": false,
-			"message": fmt.Sprintf("无符合条件的%s实例：所有实例成本或延迟超出限制", req.ServiceID),
-		})
+			"message": fmt.Sprintf("无符合条件的%s实例：所有实例成本或端到端延迟超出限制、gas已耗尽、站点网络不可达、未通过部署验证，或数据已过期（可设置 allow_stale 接受过期实例）", req.ServiceID),
+		}, explanation))
 		return
 	}
 
-	// 选择最优实例
-	bestInst := selectBestInstance(qualified)
+	// 按排名依次在站点预占gas，预占成功的实例即为选择结果；预占前预留该实例的成本，超出成本配额的候选跳过
+	ticket := c.MustGet(quotaTicketContextKey).(*quotaTicket)
+	bestInst, session, siteURL, err := reserveInstance(ranked, holder, req.SessionTTL, func(inst models.ServiceInstanceInfo) error {
+		return ticket.holdCost(inst.Cost)
+	})
+	if err != nil {
+		if explanation != nil {
+			explanation.markReservation("")
+		}
+		var exceeded *limitExceeded
+		if errors.As(err, &exceeded) {
+			respondLimitExceeded(c, key, exceeded)
+			return
+		}
+		observeSelection(req.ServiceID, strategy.Name(), OutcomeReservationFailed)
+		c.JSON(http.StatusServiceUnavailable, withExplanation(gin.H{
+			"success": false,
+			"message": fmt.Sprintf("%s的候选实例均无法预占gas：%v", req.ServiceID, err),
+		}, explanation))
+		return
+	}
+	if explanation != nil {
+		explanation.markReservation(bestInst.CSCI_ID)
+	}
 
-	// 返回结果
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "路径选择成功",
+	recordSelection(strategy.Name(), bestInst)
+	ticket.commit()
+	observeSelection(req.ServiceID, strategy.Name(), OutcomeSelected)
+	selectedSiteTotal.Inc(req.ServiceID, bestInst.SiteID)
+
+	// 返回结果（客户端处理完成后调用 release_url 归还gas，长请求在到期前调用 renew_url 续期）
+	c.JSON(http.StatusOK, withExplanation(gin.H{
+		"success":  true,
+		"message":  "路径选择成功",
+		"strategy": strategy.Name(),
 		"result": map[string]interface{}{
-			"service_id":    bestInst.ServiceID,
-			"csci_id":       bestInst.CSCI_ID,
-			"cost":          bestInst.Cost,
-			"delay":         bestInst.Delay,
-			"available_gas": bestInst.Gas,
-			"decision_time": time.Now().Format("2006-01-02 15:04:05"),
+			"service_id":      bestInst.ServiceID,
+			"csci_id":         bestInst.CSCI_ID,
+			"cost":            bestInst.Cost,
+			"delay":           bestInst.TotalDelay(), // 端到端延迟
+			"computing_delay": bestInst.Delay,
+			"network_delay":   bestInst.NetworkDelay,
+			"available_gas":   bestInst.Gas - 1,
+			"site_id":         bestInst.SiteID,
+			"last_seen":       bestInst.LastSeen,
+			"stale":           isStale(bestInst),
+			"decision_time":   time.Now().Format("2006-01-02 15:04:05"),
 		},
-	})
+		"session": map[string]interface{}{
+			"session_id":  session.ID,
+			"expires_at":  session.ExpiresAt,
+			"release_url": fmt.Sprintf("%s/sessions/%s", siteURL, session.ID),
+			"renew_url":   fmt.Sprintf("%s/sessions/%s/renew", siteURL, session.ID),
+		},
+	}, explanation))
+}
+
+// getServiceInfo 从公共服务平台查询服务元数据（含缓存，超过ServiceInfoExpire后重新查询）
+func getServiceInfo(serviceID string) (models.Service, error) {
+	serviceInfoMutex.RLock()
+	cached, ok := serviceInfoCache[serviceID]
+	serviceInfoMutex.RUnlock()
+	if ok && time.Since(cached.fetchedAt) < ServiceInfoExpire {
+		return cached.service, nil
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/services/%s", config.Cfg.Platform.URL, serviceID))
+	if err != nil {
+		return models.Service{}, fmt.Errorf("请求公共服务平台失败：%w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return models.Service{}, errServiceNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return models.Service{}, fmt.Errorf("公共服务平台返回非200状态码：%d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool           `json:"success"`
+		Service models.Service `json:"service"`
+		Message string         `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return models.Service{}, fmt.Errorf("解析服务信息失败：%w", err)
+	}
+	if !result.Success {
+		return models.Service{}, fmt.Errorf("公共服务平台业务错误：%s", result.Message)
+	}
+
+	serviceInfoMutex.Lock()
+	serviceInfoCache[serviceID] = cachedServiceInfo{service: result.Service, fetchedAt: time.Now()}
+	serviceInfoMutex.Unlock()
+
+	return result.Service, nil
 }
 
 // 检查是否需要刷新缓存
 func needRefreshCache(serviceID string) bool {
+	// 订阅流在线时缓存由C-SMA实时维护
+	if streamConnected() {
+		return false
+	}
+
 	mutex.RLock()
 	defer mutex.RUnlock()
 
 	// 缓存过期 或 目标服务无实例数据
-	return time.Since(lastSyncTime) > CacheExpire || len(cachedMetrics[serviceID]) == 0
+	if time.Since(lastSyncTime) > CacheExpire || len(cachedMetrics[serviceID]) == 0 {
+		return true
+	}
+	// 缓存中的实例gas均已耗尽：站点可能已归还gas（会话释放/超时），重新同步
+	for _, inst := range cachedMetrics[serviceID] {
+		if inst.Gas > 0 {
+			return false
+		}
+	}
+	return true
 }
 
-// 筛选符合条件的实例
-func filterInstances(instances []models.ServiceInstanceInfo, maxCost, maxDelay int) []models.ServiceInstanceInfo {
-	var qualified []models.ServiceInstanceInfo
-	for _, inst := range instances {
-		if inst.Cost <= maxCost && inst.Delay <= maxDelay {
-			qualified = append(qualified, inst)
-		}
+// cachedInstances 返回服务缓存实例的副本
+func cachedInstances(serviceID string) []models.ServiceInstanceInfo {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return append([]models.ServiceInstanceInfo(nil), cachedMetrics[serviceID]...)
+}
+
+// getValidationSnapshot 获取部署验证快照（含缓存）；平台不可用时沿用旧快照，从未获取成功则返回nil（不过滤）
+func getValidationSnapshot() *models.ValidationSnapshot {
+	validationMutex.Lock()
+	defer validationMutex.Unlock()
+
+	if validationSnapshot != nil && time.Since(validationFetchedAt) < ValidationExpire {
+		return validationSnapshot
+	}
+
+	snapshot, err := fetchValidationSnapshot()
+	if err != nil {
+		fmt.Printf("⚠️ 获取部署验证状态失败：%v\n", err)
+		return validationSnapshot
 	}
-	return qualified
+	validationSnapshot = snapshot
+	validationFetchedAt = time.Now()
+	return validationSnapshot
 }
 
-// 选择最优实例（成本优先，延迟为辅）
-func selectBestInstance(instances []models.ServiceInstanceInfo) models.ServiceInstanceInfo {
-	sort.Slice(instances, func(i, j int) bool {
-		if instances[i].Cost != instances[j].Cost {
-			return instances[i].Cost < instances[j].Cost
-		}
-		return instances[i].Delay < instances[j].Delay
-	})
-	return instances[0]
+// fetchValidationSnapshot 请求公共服务平台的部署验证快照
+func fetchValidationSnapshot() (*models.ValidationSnapshot, error) {
+	resp, err := http.Get(config.Cfg.Platform.URL + "/api/v1/validations")
+	if err != nil {
+		return nil, fmt.Errorf("请求公共服务平台失败：%w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("公共服务平台返回非200状态码：%d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+		models.ValidationSnapshot
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析验证快照失败：%w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("公共服务平台业务错误：%s", result.Message)
+	}
+	return &result.ValidationSnapshot, nil
 }
 
 // 统计实例总数
@@ -349,11 +606,16 @@ func refreshMetricsCache(c *gin.Context) {
 
 // 查看缓存数据接口
 func getCachedMetrics(c *gin.Context) {
+	streamMutex.Lock()
+	streamStatus := stream
+	streamMutex.Unlock()
+
 	mutex.RLock()
 	defer mutex.RUnlock()
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":         true,
+		"stream":          streamStatus,
 		"last_sync_time":  lastSyncTime.Format("2006-01-02 15:04:05"),
 		"cache_expire":    CacheExpire.String(),
 		"service_count":   len(cachedMetrics),
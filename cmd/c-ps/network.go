@@ -0,0 +1,90 @@
+package main
+
+import (
+	"cmas-cats-go/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NetworkMetricsExpire C-NMA 网络度量缓存有效期（略大于C-NMA默认探测周期）
+const NetworkMetricsExpire = 15 * time.Second
+
+var (
+	nmaURL           string                        // 网络时延来源C-NMA的地址（为空表示未配置，仅使用计算时延）
+	networkPaths     map[string]models.PathMetrics // 站点ID → 本观测点到该站点的路径度量
+	networkFetchedAt time.Time
+	networkMutex     sync.Mutex
+)
+
+// getNetworkPaths 获取本观测点到各站点的网络度量（含缓存）；
+// C-NMA 不可用时沿用旧数据，从未获取成功或未配置C-NMA时返回nil（仅按计算时延判断）
+func getNetworkPaths() map[string]models.PathMetrics {
+	if nmaURL == "" {
+		return nil
+	}
+
+	networkMutex.Lock()
+	defer networkMutex.Unlock()
+
+	if networkPaths != nil && time.Since(networkFetchedAt) < NetworkMetricsExpire {
+		return networkPaths
+	}
+
+	paths, err := fetchNetworkPaths()
+	if err != nil {
+		fmt.Printf("⚠️ 获取网络度量失败：%v（沿用上次结果）\n", err)
+		return networkPaths
+	}
+	networkPaths = paths
+	networkFetchedAt = time.Now()
+	return networkPaths
+}
+
+// fetchNetworkPaths 请求C-NMA的路径度量
+func fetchNetworkPaths() (map[string]models.PathMetrics, error) {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(nmaURL + "/network-metrics")
+	if err != nil {
+		return nil, fmt.Errorf("请求C-NMA失败：%w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("C-NMA返回非200状态码：%d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool                 `json:"success"`
+		Message string               `json:"message"`
+		Paths   []models.PathMetrics `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析C-NMA响应失败：%w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("C-NMA业务错误：%s", result.Message)
+	}
+
+	paths := make(map[string]models.PathMetrics, len(result.Paths))
+	for _, p := range result.Paths {
+		paths[p.SiteID] = p
+	}
+	return paths, nil
+}
+
+// applyNetworkDelay 为实例填充到其所属站点的网络时延；
+// 返回 false 表示C-NMA确认该站点当前不可达（本轮探测全部失败），实例不应被选择
+func applyNetworkDelay(inst *models.ServiceInstanceInfo, paths map[string]models.PathMetrics) bool {
+	path, ok := paths[inst.SiteID]
+	if !ok {
+		return true // 无该站点的网络度量：仅按计算时延判断
+	}
+	if !path.Reachable() {
+		return false
+	}
+	inst.NetworkDelay = path.OneWayDelayMs()
+	return true
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"cmas-cats-go/config"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ------------------------------
+// 按 API Key 限流与配额：令牌桶限制请求速率，按自然日/自然月（UTC）统计路径选择成功的请求数与所选实例成本；
+// 用量持久化在 API Key 库的 api_key_usage 表中，C-PS 重启后继续累计
+// ------------------------------
+
+// 超限项（429 响应的 limit 字段与 cmas_ps_rate_limited_total 的 limit 标签）
+const (
+	LimitRate            = "rate"
+	LimitDailyRequests   = "daily_requests"
+	LimitMonthlyRequests = "monthly_requests"
+	LimitDailyCost       = "daily_cost"
+	LimitMonthlyCost     = "monthly_cost"
+)
+
+// quotaTicketContextKey 限流中间件放行后，配额预留（*quotaTicket）在 gin 上下文中的键
+const quotaTicketContextKey = "quota_ticket"
+
+// tokenBucket 令牌桶（容量与速率每次由当前生效的配置传入，管理员调整后立即生效）
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill 按流逝时间补充令牌，返回当前令牌数
+func (b *tokenBucket) refill(now time.Time, rate, burst float64) float64 {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	return b.tokens
+}
+
+// usageCounter 一个统计周期内的用量
+type usageCounter struct {
+	Requests int `json:"requests"`
+	Cost     int `json:"cost"`
+}
+
+// keyUsage 单个 Key 当前日、月周期的用量；pending 为处理中的请求预留的请求数与成本（同时占用日、月配额，不持久化）
+type keyUsage struct {
+	day, month           string
+	dayUsage, monthUsage usageCounter
+	pending              usageCounter
+}
+
+var (
+	buckets        = make(map[string]*tokenBucket) // Key ID → 令牌桶
+	usages         = make(map[string]*keyUsage)    // Key ID → 当前周期用量
+	rateLimitMutex sync.Mutex
+)
+
+// initUsageStore 建用量表（与 API Key 同库）
+func initUsageStore() error {
+	_, err := apiKeyDB.Exec(`
+	CREATE TABLE IF NOT EXISTS api_key_usage (
+		key_id TEXT NOT NULL,
+		period TEXT NOT NULL,            -- day:2006-01-02 / month:2006-01（UTC）
+		requests INTEGER NOT NULL DEFAULT 0,
+		cost INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (key_id, period)
+	);`)
+	if err != nil {
+		return fmt.Errorf("创建api_key_usage表失败：%w", err)
+	}
+	return nil
+}
+
+func dayPeriod(now time.Time) string   { return "day:" + now.UTC().Format("2006-01-02") }
+func monthPeriod(now time.Time) string { return "month:" + now.UTC().Format("2006-01") }
+
+// nextDay / nextMonth 当前周期结束（配额重置）的时间
+func nextDay(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+func nextMonth(now time.Time) time.Time {
+	y, m, _ := now.UTC().Date()
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// effectiveLimits 合并 C-PS 默认值与 Key 覆盖值（覆盖值 0 沿用默认，负数表示不限）
+func effectiveLimits(key *apiKeyRecord) config.RateLimits {
+	l := selfCfg.Limits
+	if o := key.Limits; o != nil {
+		pick := func(def, override int) int {
+			switch {
+			case override < 0:
+				return 0
+			case override > 0:
+				return override
+			}
+			return def
+		}
+		switch {
+		case o.RatePerSecond < 0:
+			l.RatePerSecond = 0
+		case o.RatePerSecond > 0:
+			l.RatePerSecond = o.RatePerSecond
+		}
+		l.Burst = pick(l.Burst, o.Burst)
+		l.DailyRequests = pick(l.DailyRequests, o.DailyRequests)
+		l.MonthlyRequests = pick(l.MonthlyRequests, o.MonthlyRequests)
+		l.DailyCost = pick(l.DailyCost, o.DailyCost)
+		l.MonthlyCost = pick(l.MonthlyCost, o.MonthlyCost)
+	}
+	switch {
+	case l.RatePerSecond <= 0:
+		l.RatePerSecond, l.Burst = 0, 0
+	case l.Burst <= 0:
+		l.Burst = max(1, int(math.Ceil(l.RatePerSecond)))
+	}
+	return l
+}
+
+// loadUsage 读取持久化的周期用量（不存在时为0）
+func loadUsage(keyID, period string) usageCounter {
+	var u usageCounter
+	err := apiKeyDB.QueryRow("SELECT requests, cost FROM api_key_usage WHERE key_id = ? AND period = ?",
+		keyID, period).Scan(&u.Requests, &u.Cost)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		fmt.Printf("⚠️ 读取API Key %s 用量失败：%v\n", keyID, err)
+	}
+	return u
+}
+
+// currentUsage 返回 Key 当前周期的用量（跨日/跨月时切换到新周期），调用方须持有 rateLimitMutex
+func currentUsage(keyID string, now time.Time) *keyUsage {
+	u, ok := usages[keyID]
+	if !ok {
+		u = &keyUsage{}
+		usages[keyID] = u
+	}
+	if day := dayPeriod(now); u.day != day {
+		u.day, u.dayUsage = day, loadUsage(keyID, day)
+	}
+	if month := monthPeriod(now); u.month != month {
+		u.month, u.monthUsage = month, loadUsage(keyID, month)
+	}
+	return u
+}
+
+// persistUsage 将增量累加到日、月两条用量记录
+func persistUsage(keyID string, periods []string, requests, cost int) {
+	for _, period := range periods {
+		_, err := apiKeyDB.Exec(`INSERT INTO api_key_usage (key_id, period, requests, cost) VALUES (?, ?, ?, ?)
+			ON CONFLICT(key_id, period) DO UPDATE SET requests = requests + excluded.requests, cost = cost + excluded.cost`,
+			keyID, period, requests, cost)
+		if err != nil {
+			fmt.Printf("⚠️ 保存API Key %s 用量失败：%v\n", keyID, err)
+		}
+	}
+}
+
+// limitExceeded 超限信息
+type limitExceeded struct {
+	Limit      string
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *limitExceeded) Error() string { return e.Message }
+
+// quotaExceeded 检查在已用量与预留量之上再增加 requests 次请求、cost 成本是否超出配额（调用方须持有 rateLimitMutex）
+func quotaExceeded(u *keyUsage, l config.RateLimits, requests, cost int, now time.Time) *limitExceeded {
+	switch {
+	case l.DailyRequests > 0 && u.dayUsage.Requests+u.pending.Requests+requests > l.DailyRequests:
+		return &limitExceeded{LimitDailyRequests, nextDay(now).Sub(now), fmt.Sprintf("已达每日请求数配额（%d）", l.DailyRequests)}
+	case l.MonthlyRequests > 0 && u.monthUsage.Requests+u.pending.Requests+requests > l.MonthlyRequests:
+		return &limitExceeded{LimitMonthlyRequests, nextMonth(now).Sub(now), fmt.Sprintf("已达每月请求数配额（%d）", l.MonthlyRequests)}
+	case l.DailyCost > 0 && u.dayUsage.Cost+u.pending.Cost+cost > l.DailyCost:
+		return &limitExceeded{LimitDailyCost, nextDay(now).Sub(now), fmt.Sprintf("已达每日成本配额（%d）", l.DailyCost)}
+	case l.MonthlyCost > 0 && u.monthUsage.Cost+u.pending.Cost+cost > l.MonthlyCost:
+		return &limitExceeded{LimitMonthlyCost, nextMonth(now).Sub(now), fmt.Sprintf("已达每月成本配额（%d）", l.MonthlyCost)}
+	}
+	return nil
+}
+
+// quotaTicket 一次请求在配额中的预留：放行时预留1次请求，预占实例前预留该实例的成本；
+// 路径选择成功时 commit 计入用量，其余结果（参数错误、服务下线、无合格实例、预占失败）在请求结束时 release 归还
+type quotaTicket struct {
+	key    *apiKeyRecord
+	limits config.RateLimits
+	at     time.Time // 放行时间，用量计入该时间所在的日、月周期
+	cost   int       // 当前预留的成本
+	closed bool      // 已计入或已归还
+}
+
+// admitRequest 检查配额与令牌桶，通过时消耗一个令牌并预留一次请求；超限时不消耗令牌也不预留。
+// 已用量加上处理中请求的预留量计入配额，并发请求不会超出请求数配额
+func admitRequest(key *apiKeyRecord, now time.Time) (*quotaTicket, *limitExceeded) {
+	l := effectiveLimits(key)
+
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	u := currentUsage(key.ID, now)
+	if e := quotaExceeded(u, l, 1, 1, now); e != nil { // 成本按至少1计：剩余成本为0时直接拒绝
+		return nil, e
+	}
+	if l.RatePerSecond > 0 {
+		b, ok := buckets[key.ID]
+		if !ok {
+			b = &tokenBucket{}
+			buckets[key.ID] = b
+		}
+		if tokens := b.refill(now, l.RatePerSecond, float64(l.Burst)); tokens < 1 {
+			wait := time.Duration((1 - tokens) / l.RatePerSecond * float64(time.Second))
+			return nil, &limitExceeded{LimitRate, wait, fmt.Sprintf("请求过于频繁（限流 %g 次/秒，突发 %d）", l.RatePerSecond, l.Burst)}
+		}
+		b.tokens--
+	}
+	u.pending.Requests++
+	return &quotaTicket{key: key, limits: l, at: now}, nil
+}
+
+// holdCost 预占实例前预留其成本（替换此前预留的成本）：加上已用量与其他请求的预留量超出成本配额时拒绝
+func (t *quotaTicket) holdCost(cost int) error {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	u := currentUsage(t.key.ID, t.at)
+	u.pending.Cost -= t.cost
+	t.cost = 0
+	if e := quotaExceeded(u, t.limits, 0, cost, t.at); e != nil {
+		return e
+	}
+	u.pending.Cost += cost
+	t.cost = cost
+	return nil
+}
+
+// commit 路径选择成功：将预留的请求与成本计入日、月用量并持久化
+func (t *quotaTicket) commit() {
+	rateLimitMutex.Lock()
+	if t.closed {
+		rateLimitMutex.Unlock()
+		return
+	}
+	t.closed = true
+	u := currentUsage(t.key.ID, t.at)
+	u.pending.Requests--
+	u.pending.Cost -= t.cost
+	u.dayUsage.Requests++
+	u.monthUsage.Requests++
+	u.dayUsage.Cost += t.cost
+	u.monthUsage.Cost += t.cost
+	periods := []string{u.day, u.month}
+	rateLimitMutex.Unlock()
+
+	persistUsage(t.key.ID, periods, 1, t.cost)
+}
+
+// release 请求结束：未计入用量的预留全部归还（已 commit 时不做处理）
+func (t *quotaTicket) release() {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	u := currentUsage(t.key.ID, t.at)
+	u.pending.Requests--
+	u.pending.Cost -= t.cost
+}
+
+// rateLimitMiddleware 在认证之后执行：超出限流或配额时返回 429 并附带 Retry-After（秒）；
+// 放行的请求在上下文中带上配额预留，请求结束时归还未计入用量的部分
+func rateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.MustGet(apiKeyContextKey).(*apiKeyRecord)
+		ticket, exceeded := admitRequest(key, time.Now())
+		if exceeded != nil {
+			respondLimitExceeded(c, key, exceeded)
+			c.Abort()
+			return
+		}
+		c.Set(quotaTicketContextKey, ticket)
+		defer ticket.release()
+		c.Next()
+	}
+}
+
+// respondLimitExceeded 返回 429 与 Retry-After（秒）
+func respondLimitExceeded(c *gin.Context, key *apiKeyRecord, exceeded *limitExceeded) {
+	retryAfter := int(math.Ceil(exceeded.RetryAfter.Seconds()))
+	retryAfter = max(retryAfter, 1)
+	rateLimitedTotal.Inc(key.ID, exceeded.Limit)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"success":             false,
+		"message":             exceeded.Message + "，请稍后重试",
+		"limit":               exceeded.Limit,
+		"retry_after_seconds": retryAfter,
+	})
+}
+
+// ------------------------------
+// 用量查询
+// ------------------------------
+
+// quotaView 一个统计周期的用量与剩余配额（剩余为空表示不限）
+type quotaView struct {
+	Period            string    `json:"period"`
+	Requests          int       `json:"requests"`
+	RequestLimit      int       `json:"request_limit,omitempty"`
+	RequestsRemaining *int      `json:"requests_remaining,omitempty"`
+	Cost              int       `json:"cost"`
+	CostLimit         int       `json:"cost_limit,omitempty"`
+	CostRemaining     *int      `json:"cost_remaining,omitempty"`
+	ResetsAt          time.Time `json:"resets_at"`
+}
+
+func newQuotaView(period string, u usageCounter, requestLimit, costLimit int, resetsAt time.Time) quotaView {
+	v := quotaView{
+		Period:       period,
+		Requests:     u.Requests,
+		RequestLimit: requestLimit,
+		Cost:         u.Cost,
+		CostLimit:    costLimit,
+		ResetsAt:     resetsAt,
+	}
+	if requestLimit > 0 {
+		remaining := max(requestLimit-u.Requests, 0)
+		v.RequestsRemaining = &remaining
+	}
+	if costLimit > 0 {
+		remaining := max(costLimit-u.Cost, 0)
+		v.CostRemaining = &remaining
+	}
+	return v
+}
+
+// usageView 单个 Key 的限流配置、令牌桶状态与日/月用量
+func usageView(key *apiKeyRecord) gin.H {
+	now := time.Now()
+	l := effectiveLimits(key)
+
+	rateLimitMutex.Lock()
+	u := currentUsage(key.ID, now)
+	daily := newQuotaView(u.day, u.dayUsage, l.DailyRequests, l.DailyCost, nextDay(now))
+	monthly := newQuotaView(u.month, u.monthUsage, l.MonthlyRequests, l.MonthlyCost, nextMonth(now))
+	rate := gin.H{"rate_per_second": l.RatePerSecond, "burst": l.Burst}
+	if l.RatePerSecond > 0 {
+		tokens := float64(l.Burst)
+		if b, ok := buckets[key.ID]; ok {
+			// 只读查询：按流逝时间估算，不修改令牌桶
+			tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.RatePerSecond)
+		}
+		rate["tokens"] = math.Floor(tokens*100) / 100
+	}
+	rateLimitMutex.Unlock()
+
+	return gin.H{
+		"key_id":  key.ID,
+		"owner":   key.Owner,
+		"limits":  l,
+		"rate":    rate,
+		"daily":   daily,
+		"monthly": monthly,
+	}
+}
+
+// getUsageHandler：GET /usage —— 调用方（X-API-Key）自己的用量与剩余配额，不消耗限流令牌
+func getUsageHandler(c *gin.Context) {
+	key := c.MustGet(apiKeyContextKey).(*apiKeyRecord)
+	resp := usageView(key)
+	resp["success"] = true
+	c.JSON(http.StatusOK, resp)
+}
+
+// getAPIKeyUsageHandler：GET /admin/api-keys/:id/usage
+func getAPIKeyUsageHandler(c *gin.Context) {
+	rec := apiKeyOr404(c)
+	if rec == nil {
+		return
+	}
+	resp := usageView(rec)
+	resp["success"] = true
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"cmas-cats-go/config"
+)
+
+func TestTokenBucketRefill(t *testing.T) {
+	t0 := time.Unix(1_700_000_000, 0)
+	var b tokenBucket
+
+	if got := b.refill(t0, 2, 5); got != 5 {
+		t.Fatalf("首次补充应为满桶：%v", got)
+	}
+	b.tokens -= 5
+	steps := []struct {
+		after time.Duration
+		want  float64
+	}{
+		{0, 0},
+		{250 * time.Millisecond, 0.5},
+		{time.Second, 2}, // 相对上一次再过0.75秒：0.5 + 1.5
+		{time.Minute, 5}, // 不超过容量
+	}
+	for _, s := range steps {
+		if got := b.refill(t0.Add(s.after), 2, 5); !approx(got, s.want) {
+			t.Errorf("t0+%v 时令牌数 %v，期望 %v", s.after, got, s.want)
+		}
+	}
+	// 容量调小后立即按新容量截断
+	if got := b.refill(t0.Add(time.Minute), 2, 3); got != 3 {
+		t.Errorf("容量调小后令牌数 %v，期望 3", got)
+	}
+}
+
+func TestEffectiveLimits(t *testing.T) {
+	defaults := config.RateLimits{RatePerSecond: 5, Burst: 10, DailyRequests: 100, MonthlyRequests: 1000, DailyCost: 50, MonthlyCost: 500}
+	cases := []struct {
+		name     string
+		defaults config.RateLimits
+		override *config.RateLimits
+		want     config.RateLimits
+	}{
+		{"无覆盖沿用默认", defaults, nil, defaults},
+		{"覆盖值为0沿用默认", defaults, &config.RateLimits{}, defaults},
+		{"正数覆盖", defaults, &config.RateLimits{RatePerSecond: 1, Burst: 2, DailyRequests: 3, MonthlyRequests: 4, DailyCost: 5, MonthlyCost: 6},
+			config.RateLimits{RatePerSecond: 1, Burst: 2, DailyRequests: 3, MonthlyRequests: 4, DailyCost: 5, MonthlyCost: 6}},
+		{"负数表示不限", defaults, &config.RateLimits{RatePerSecond: -1, DailyRequests: -1, MonthlyCost: -1},
+			config.RateLimits{MonthlyRequests: 1000, DailyCost: 50}},
+		{"部分覆盖", defaults, &config.RateLimits{DailyRequests: 7},
+			config.RateLimits{RatePerSecond: 5, Burst: 10, DailyRequests: 7, MonthlyRequests: 1000, DailyCost: 50, MonthlyCost: 500}},
+		{"未设容量时取速率向上取整", config.RateLimits{RatePerSecond: 2.5}, nil, config.RateLimits{RatePerSecond: 2.5, Burst: 3}},
+		{"低速率容量至少为1", config.RateLimits{RatePerSecond: 0.2}, nil, config.RateLimits{RatePerSecond: 0.2, Burst: 1}},
+		{"只覆盖速率时容量按新速率推导", config.RateLimits{}, &config.RateLimits{RatePerSecond: 4}, config.RateLimits{RatePerSecond: 4, Burst: 4}},
+		{"不限速时忽略容量", config.RateLimits{Burst: 10}, nil, config.RateLimits{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			selfCfg = &config.PSConfig{Limits: tc.defaults}
+			got := effectiveLimits(&apiKeyRecord{ID: "k", Limits: tc.override})
+			if got != tc.want {
+				t.Errorf("effectiveLimits = %+v，期望 %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// admitAndCommit 放行后立即按路径选择成功计入用量（成本为0）
+func admitAndCommit(key *apiKeyRecord, now time.Time) *limitExceeded {
+	ticket, e := admitRequest(key, now)
+	if e == nil {
+		ticket.commit()
+	}
+	return e
+}
+
+func TestAdmitRequestBurst(t *testing.T) {
+	setupKeyStore(t, config.RateLimits{RatePerSecond: 1, Burst: 3})
+	key := &apiKeyRecord{ID: "key-burst"}
+	t0 := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if e := admitAndCommit(key, t0); e != nil {
+			t.Fatalf("突发内第%d个请求被拒：%+v", i+1, e)
+		}
+	}
+	e := admitAndCommit(key, t0)
+	if e == nil || e.Limit != LimitRate || e.RetryAfter != time.Second {
+		t.Fatalf("超出突发应按速率限流并等待1秒：%+v", e)
+	}
+	e = admitAndCommit(key, t0.Add(600*time.Millisecond))
+	if e == nil || e.Limit != LimitRate || e.RetryAfter != 400*time.Millisecond {
+		t.Fatalf("补充0.6个令牌后应再等待0.4秒：%+v", e)
+	}
+	if e := admitAndCommit(key, t0.Add(time.Second)); e != nil {
+		t.Fatalf("补满1个令牌后应放行：%+v", e)
+	}
+
+	// 被拒的请求不计入用量
+	rateLimitMutex.Lock()
+	u := currentUsage(key.ID, t0)
+	rateLimitMutex.Unlock()
+	if u.dayUsage.Requests != 4 || u.monthUsage.Requests != 4 {
+		t.Errorf("用量 %d/%d，期望 4/4", u.dayUsage.Requests, u.monthUsage.Requests)
+	}
+}
+
+func TestAdmitRequestQuotaRollover(t *testing.T) {
+	setupKeyStore(t, config.RateLimits{DailyRequests: 2, MonthlyRequests: 3})
+	key := &apiKeyRecord{ID: "key-quota"}
+
+	jan30 := time.Date(2024, 1, 30, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if e := admitAndCommit(key, jan30); e != nil {
+			t.Fatalf("1月30日第%d个请求被拒：%+v", i+1, e)
+		}
+	}
+	e := admitAndCommit(key, jan30)
+	if e == nil || e.Limit != LimitDailyRequests || e.RetryAfter != 14*time.Hour {
+		t.Fatalf("应达到每日配额并在次日0点（UTC）重置：%+v", e)
+	}
+
+	// 跨日：日配额重置，月配额继续累计
+	jan31 := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+	if e := admitAndCommit(key, jan31); e != nil {
+		t.Fatalf("跨日后应放行：%+v", e)
+	}
+	e = admitAndCommit(key, jan31)
+	if e == nil || e.Limit != LimitMonthlyRequests || e.RetryAfter != time.Hour {
+		t.Fatalf("应达到每月配额并在下月1日重置：%+v", e)
+	}
+
+	// 跨月：日、月配额均重置
+	feb1 := time.Date(2024, 2, 1, 0, 0, 1, 0, time.UTC)
+	if e := admitAndCommit(key, feb1); e != nil {
+		t.Fatalf("跨月后应放行：%+v", e)
+	}
+
+	// 内存状态丢失（C-PS 重启）后从库中恢复当前周期用量
+	rateLimitMutex.Lock()
+	usages = make(map[string]*keyUsage)
+	u := currentUsage(key.ID, feb1)
+	rateLimitMutex.Unlock()
+	if u.dayUsage.Requests != 1 || u.monthUsage.Requests != 1 {
+		t.Errorf("2月用量 %d/%d，期望 1/1", u.dayUsage.Requests, u.monthUsage.Requests)
+	}
+	if got := loadUsage(key.ID, monthPeriod(jan30)); got.Requests != 3 {
+		t.Errorf("1月用量 %d，期望 3", got.Requests)
+	}
+}
+
+func TestAdmitRequestCostQuota(t *testing.T) {
+	setupKeyStore(t, config.RateLimits{DailyCost: 10})
+	key := &apiKeyRecord{ID: "key-cost"}
+	// 按 Key 覆盖：该 Key 不限成本
+	unlimited := &apiKeyRecord{ID: "key-cost-unlimited", Limits: &config.RateLimits{DailyCost: -1}}
+
+	now := time.Now()
+	ticket, e := admitRequest(key, now)
+	if e != nil {
+		t.Fatalf("首个请求被拒：%+v", e)
+	}
+	if err := ticket.holdCost(6); err != nil {
+		t.Fatalf("成本未超配额时应可预留：%v", err)
+	}
+	ticket.commit()
+
+	ticket, e = admitRequest(key, now)
+	if e != nil {
+		t.Fatalf("成本未达配额时应放行：%+v", e)
+	}
+	// 预占前按实例成本检查：超出剩余成本的候选被拒，不会使成本超出配额
+	var exceeded *limitExceeded
+	if err := ticket.holdCost(6); !errors.As(err, &exceeded) || exceeded.Limit != LimitDailyCost {
+		t.Fatalf("超出剩余成本的实例应被拒：%v", err)
+	}
+	if err := ticket.holdCost(4); err != nil {
+		t.Fatalf("恰好用完剩余成本的实例应可预留：%v", err)
+	}
+	ticket.commit()
+	if _, e := admitRequest(key, now); e == nil || e.Limit != LimitDailyCost {
+		t.Fatalf("应达到每日成本配额：%+v", e)
+	}
+
+	ticket, e = admitRequest(unlimited, now)
+	if e != nil {
+		t.Fatalf("覆盖为不限成本的 Key 应放行：%+v", e)
+	}
+	if err := ticket.holdCost(100); err != nil {
+		t.Fatalf("覆盖为不限成本的 Key 应可预留任意成本：%v", err)
+	}
+}
+
+// 只有路径选择成功的请求计入用量；处理中请求的预留计入配额，并发请求不会超出配额
+func TestQuotaTicketRelease(t *testing.T) {
+	setupKeyStore(t, config.RateLimits{DailyRequests: 2, DailyCost: 10})
+	key := &apiKeyRecord{ID: "key-ticket"}
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	first, e := admitRequest(key, now)
+	if e != nil {
+		t.Fatalf("首个请求被拒：%+v", e)
+	}
+	second, e := admitRequest(key, now)
+	if e != nil {
+		t.Fatalf("第二个请求被拒：%+v", e)
+	}
+	if _, e := admitRequest(key, now); e == nil || e.Limit != LimitDailyRequests {
+		t.Fatalf("两个请求处理中时应达到每日请求数配额：%+v", e)
+	}
+	if err := first.holdCost(6); err != nil {
+		t.Fatalf("预留成本失败：%v", err)
+	}
+	if err := second.holdCost(6); err == nil {
+		t.Fatalf("其他请求已预留的成本应计入配额")
+	}
+
+	// 未完成路径选择（参数错误、无合格实例、预占失败等）的请求归还预留
+	first.release()
+	second.release()
+	second.commit() // 已归还的预留不再计入
+	rateLimitMutex.Lock()
+	u := currentUsage(key.ID, now)
+	usage, pending := u.dayUsage, u.pending
+	rateLimitMutex.Unlock()
+	if usage != (usageCounter{}) || pending != (usageCounter{}) {
+		t.Fatalf("归还后用量 %+v、预留 %+v，期望均为0", usage, pending)
+	}
+	if got := loadUsage(key.ID, dayPeriod(now)); got != (usageCounter{}) {
+		t.Fatalf("归还的请求不应持久化：%+v", got)
+	}
+
+	ticket, e := admitRequest(key, now)
+	if e != nil {
+		t.Fatalf("归还后应放行：%+v", e)
+	}
+	if err := ticket.holdCost(6); err != nil {
+		t.Fatalf("归还后应可预留成本：%v", err)
+	}
+	ticket.commit()
+	ticket.release() // 已计入的请求不再归还
+	if got := loadUsage(key.ID, dayPeriod(now)); got != (usageCounter{Requests: 1, Cost: 6}) {
+		t.Fatalf("用量 %+v，期望 1 次请求、成本 6", got)
+	}
+}
+
+func TestNextPeriod(t *testing.T) {
+	// 以 UTC 计算周期，与本地时区无关
+	now := time.Date(2024, 12, 31, 20, 30, 0, 0, time.FixedZone("UTC-5", -5*3600)) // UTC 2025-01-01 01:30
+	if got, want := nextDay(now), time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("nextDay = %v，期望 %v", got, want)
+	}
+	if got, want := nextMonth(now), time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("nextMonth = %v，期望 %v", got, want)
+	}
+	if got := dayPeriod(now); got != "day:2025-01-01" {
+		t.Errorf("dayPeriod = %s", got)
+	}
+	if got := monthPeriod(now); got != "month:2025-01" {
+		t.Errorf("monthPeriod = %s", got)
+	}
+}
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
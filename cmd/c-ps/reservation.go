@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"cmas-cats-go/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SessionAcquireTimeout 向站点预占gas的超时时间
+const SessionAcquireTimeout = 3 * time.Second
+
+// errGasExhausted 站点返回409：实例gas已被占满（C-PS缓存的gas已过时）
+var errGasExhausted = errors.New("实例gas已耗尽")
+
+var sessionClient = &http.Client{Timeout: SessionAcquireTimeout}
+
+// acquireGas 在实例所属站点预占1个gas单位，返回会话与站点地址
+func acquireGas(inst models.ServiceInstanceInfo, holder string, ttlSeconds int) (*models.GasSession, string, error) {
+	siteURL, err := inst.SiteURL()
+	if err != nil {
+		return nil, "", err
+	}
+
+	payload, _ := json.Marshal(models.AcquireGasRequest{
+		CSCI_ID:    inst.CSCI_ID,
+		Holder:     holder,
+		TTLSeconds: ttlSeconds,
+	})
+	resp, err := sessionClient.Post(siteURL+"/sessions", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, siteURL, fmt.Errorf("请求站点%s失败：%w", siteURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return nil, siteURL, errGasExhausted
+	}
+	var result struct {
+		Success bool               `json:"success"`
+		Message string             `json:"message"`
+		Session *models.GasSession `json:"session"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, siteURL, fmt.Errorf("解析站点预占响应失败（状态码%d）：%w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || !result.Success || result.Session == nil {
+		return nil, siteURL, fmt.Errorf("站点拒绝预占（状态码%d）：%s", resp.StatusCode, result.Message)
+	}
+	return result.Session, siteURL, nil
+}
+
+// adjustCachedGas 预占成功/失败后修正本地缓存中的可用gas，避免在下次同步前继续分配已占满的实例
+// exhausted 为 true 时直接置0，否则减1；写入新切片后替换，不修改其他请求可能仍在读取的旧切片
+func adjustCachedGas(serviceID, csciID string, exhausted bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i, inst := range cachedMetrics[serviceID] {
+		if inst.CSCI_ID != csciID {
+			continue
+		}
+		if exhausted || inst.Gas <= 1 {
+			inst.Gas = 0
+		} else {
+			inst.Gas--
+		}
+		instances := append([]models.ServiceInstanceInfo(nil), cachedMetrics[serviceID]...)
+		instances[i] = inst
+		cachedMetrics[serviceID] = instances
+		return
+	}
+}
+
+// reserveInstance 按排名依次尝试预占，返回第一个预占成功的实例及会话；
+// hold 非空时在预占每个候选前调用（如预留该实例的成本配额），返回错误则跳过该候选。全部失败时返回最后一个错误
+func reserveInstance(ranked []models.ServiceInstanceInfo, holder string, ttlSeconds int, hold func(models.ServiceInstanceInfo) error) (models.ServiceInstanceInfo, *models.GasSession, string, error) {
+	var lastErr error
+	for _, inst := range ranked {
+		if hold != nil {
+			if err := hold(inst); err != nil {
+				fmt.Printf("⚠️ 跳过实例%s：%v\n", inst.CSCI_ID, err)
+				lastErr = err
+				continue
+			}
+		}
+		session, siteURL, err := acquireGas(inst, holder, ttlSeconds)
+		if err == nil {
+			adjustCachedGas(inst.ServiceID, inst.CSCI_ID, false)
+			return inst, session, siteURL, nil
+		}
+		if errors.Is(err, errGasExhausted) {
+			adjustCachedGas(inst.ServiceID, inst.CSCI_ID, true)
+		}
+		fmt.Printf("⚠️ 预占实例%s失败：%v，尝试下一个候选\n", inst.CSCI_ID, err)
+		lastErr = err
+	}
+	return models.ServiceInstanceInfo{}, nil, "", lastErr
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cmas-cats-go/config"
+	"cmas-cats-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeSite 模拟站点的 POST /sessions：总是预占成功
+func fakeSite(t *testing.T) *httptest.Server {
+	var seq int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/sessions" {
+			http.NotFound(w, r)
+			return
+		}
+		now := time.Now()
+		json.NewEncoder(w).Encode(gin.H{
+			"success": true,
+			"session": models.GasSession{
+				ID:         fmt.Sprintf("sess-%d", atomic.AddInt64(&seq, 1)),
+				AcquiredAt: now,
+				ExpiresAt:  now.Add(time.Minute),
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// setupRouting 准备路由所需的全局状态：API Key 库、服务元数据、验证快照与实例缓存
+func setupRouting(t *testing.T, serviceID string, instances []models.ServiceInstanceInfo) {
+	gin.SetMode(gin.TestMode)
+	setupKeyStore(t, config.RateLimits{})
+
+	serviceInfoMutex.Lock()
+	serviceInfoCache[serviceID] = cachedServiceInfo{
+		service:   models.Service{ID: serviceID, Status: models.ServiceStatusPublished},
+		fetchedAt: time.Now().Add(time.Hour), // 测试期间不过期，不访问平台
+	}
+	serviceInfoMutex.Unlock()
+
+	validationMutex.Lock()
+	validationSnapshot = &models.ValidationSnapshot{}
+	validationFetchedAt = time.Now().Add(time.Hour)
+	validationMutex.Unlock()
+
+	mutex.Lock()
+	cachedMetrics = map[string][]models.ServiceInstanceInfo{serviceID: instances}
+	lastSyncTime = time.Now().Add(time.Hour)
+	mutex.Unlock()
+}
+
+// TestRequestConcurrentWithReservation 路由请求在缓存副本上选择实例，与预占修正缓存gas并发时不应产生数据竞争（go test -race）
+func TestRequestConcurrentWithReservation(t *testing.T) {
+	site := fakeSite(t)
+	const serviceID = "svc-race"
+	instances := make([]models.ServiceInstanceInfo, 4)
+	for i := range instances {
+		instances[i] = models.ServiceInstanceInfo{
+			ServiceID: serviceID,
+			Gas:       1 << 20,
+			Capacity:  1 << 20,
+			Cost:      1 + i,
+			CSCI_ID:   fmt.Sprintf("%s/%s/%d", site.URL, serviceID, i),
+			Delay:     10,
+			SiteID:    "site-test",
+		}
+	}
+	setupRouting(t, serviceID, instances)
+
+	key := &apiKeyRecord{ID: "key-test", Status: "active"}
+	r := gin.New()
+	r.POST("/request-service", func(c *gin.Context) {
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}, rateLimitMiddleware(), handleClientRequest)
+
+	body, _ := json.Marshal(models.ClientRequest{ServiceID: serviceID, MaxAcceptCost: 100, MaxAcceptDelay: 100})
+	const rounds = 50
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/request-service", bytes.NewReader(body)))
+				if rec.Code != http.StatusOK {
+					t.Errorf("路由请求失败：状态码%d，%s", rec.Code, rec.Body.String())
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if _, _, _, err := reserveInstance(cachedInstances(serviceID), "holder-test", 0, nil); err != nil {
+					t.Errorf("预占失败：%v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 每次成功预占都在缓存中扣减1个gas
+	total := 0
+	for _, inst := range cachedInstances(serviceID) {
+		total += instances[0].Gas - inst.Gas
+	}
+	if want := 2 * 4 * rounds; total != want {
+		t.Errorf("缓存gas共扣减%d，期望%d", total, want)
+	}
+}
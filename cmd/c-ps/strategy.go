@@ -0,0 +1,283 @@
+package main
+
+import (
+	"cmas-cats-go/models"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Strategy 路径选择策略：对满足约束的候选实例排序，第一个为首选，其余作为预占失败时的备选
+type Strategy interface {
+	Name() string
+	Rank(instances []models.ServiceInstanceInfo, req models.ClientRequest) []models.ServiceInstanceInfo
+}
+
+// DefaultStrategy 未指定策略时使用（与原有的「成本优先，延迟为辅」一致）
+const DefaultStrategy = "lowest-cost"
+
+// defaultWeights weighted 策略的默认权重
+var defaultWeights = models.StrategyWeights{Cost: 0.5, Delay: 0.4, Gas: 0.1}
+
+// strategies 内置策略注册表
+var strategies = map[string]Strategy{}
+
+func registerStrategy(s Strategy) {
+	strategies[s.Name()] = s
+}
+
+func init() {
+	registerStrategy(lowestCostStrategy{})
+	registerStrategy(lowestDelayStrategy{})
+	registerStrategy(weightedStrategy{})
+	registerStrategy(mostGasStrategy{})
+	registerStrategy(&roundRobinStrategy{next: make(map[string]int)})
+	registerStrategy(&weightedRandomStrategy{rnd: rand.New(rand.NewSource(rand.Int63()))})
+}
+
+// strategyNames 已注册的策略名（排序后，用于提示）
+func strategyNames() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveStrategy 确定本次请求使用的策略：请求字段 > API Key 默认策略 > C-PS 默认配置 > DefaultStrategy
+func resolveStrategy(req models.ClientRequest, key *apiKeyRecord) (Strategy, error) {
+	name := req.Strategy
+	if name == "" && key != nil {
+		name = key.Strategy
+	}
+	if name == "" {
+		name = selfCfg.Strategy
+	}
+	if name == "" {
+		name = DefaultStrategy
+	}
+	s, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的路径选择策略：%s（可选：%s）", name, strings.Join(strategyNames(), ", "))
+	}
+	if w := req.Weights; w != nil && (w.Cost < 0 || w.Delay < 0 || w.Gas < 0) {
+		return nil, fmt.Errorf("策略权重不能为负数")
+	}
+	return s, nil
+}
+
+// validateStrategyConfig 启动时检查配置中引用的策略是否存在
+func validateStrategyConfig() error {
+	if selfCfg.Strategy != "" {
+		if _, ok := strategies[selfCfg.Strategy]; !ok {
+			return fmt.Errorf("C-PS %s 配置了未知的策略：%s", selfCfg.ID, selfCfg.Strategy)
+		}
+	}
+	for key, name := range selfCfg.APIKeyStrategies {
+		if _, ok := strategies[name]; !ok {
+			return fmt.Errorf("API Key %s 配置了未知的策略：%s", key, name)
+		}
+	}
+	return nil
+}
+
+// ------------------------------
+// 内置策略
+// ------------------------------
+
+// lowestCostStrategy 成本最低优先，成本相同时端到端延迟低者优先
+type lowestCostStrategy struct{}
+
+func (lowestCostStrategy) Name() string { return "lowest-cost" }
+
+func (lowestCostStrategy) Rank(instances []models.ServiceInstanceInfo, _ models.ClientRequest) []models.ServiceInstanceInfo {
+	sort.SliceStable(instances, func(i, j int) bool {
+		if instances[i].Cost != instances[j].Cost {
+			return instances[i].Cost < instances[j].Cost
+		}
+		return instances[i].TotalDelay() < instances[j].TotalDelay()
+	})
+	return instances
+}
+
+// lowestDelayStrategy 端到端延迟（计算+网络）最低优先，延迟相同时成本低者优先
+type lowestDelayStrategy struct{}
+
+func (lowestDelayStrategy) Name() string { return "lowest-delay" }
+
+func (lowestDelayStrategy) Rank(instances []models.ServiceInstanceInfo, _ models.ClientRequest) []models.ServiceInstanceInfo {
+	sort.SliceStable(instances, func(i, j int) bool {
+		if instances[i].TotalDelay() != instances[j].TotalDelay() {
+			return instances[i].TotalDelay() < instances[j].TotalDelay()
+		}
+		return instances[i].Cost < instances[j].Cost
+	})
+	return instances
+}
+
+// mostGasStrategy 剩余gas最多优先（负载均衡倾向），gas相同时成本低者优先
+type mostGasStrategy struct{}
+
+func (mostGasStrategy) Name() string { return "most-gas" }
+
+func (mostGasStrategy) Rank(instances []models.ServiceInstanceInfo, _ models.ClientRequest) []models.ServiceInstanceInfo {
+	sort.SliceStable(instances, func(i, j int) bool {
+		if instances[i].Gas != instances[j].Gas {
+			return instances[i].Gas > instances[j].Gas
+		}
+		return instances[i].Cost < instances[j].Cost
+	})
+	return instances
+}
+
+// weightedStrategy 加权得分：成本、延迟按候选集内最大值归一化，剩余gas取反，得分越低越优
+type weightedStrategy struct{}
+
+func (weightedStrategy) Name() string { return "weighted" }
+
+func (weightedStrategy) Rank(instances []models.ServiceInstanceInfo, req models.ClientRequest) []models.ServiceInstanceInfo {
+	w := defaultWeights
+	if req.Weights != nil {
+		w = *req.Weights
+	}
+	scores := weightedScores(instances, w)
+	sort.SliceStable(instances, func(i, j int) bool {
+		return scores[instances[i].CSCI_ID] < scores[instances[j].CSCI_ID]
+	})
+	return instances
+}
+
+// weightedScores 计算各实例的加权得分（按CSCI_ID索引）
+func weightedScores(instances []models.ServiceInstanceInfo, w models.StrategyWeights) map[string]float64 {
+	maxCost, maxDelay, maxGas := 1, 1, 1
+	for _, inst := range instances {
+		maxCost = max(maxCost, inst.Cost)
+		maxDelay = max(maxDelay, inst.TotalDelay())
+		maxGas = max(maxGas, inst.Gas)
+	}
+	scores := make(map[string]float64, len(instances))
+	for _, inst := range instances {
+		score := w.Cost*float64(inst.Cost)/float64(maxCost) +
+			w.Delay*float64(inst.TotalDelay())/float64(maxDelay) +
+			w.Gas*(1-float64(inst.Gas)/float64(maxGas))
+		scores[inst.CSCI_ID] = math.Round(score*1000) / 1000
+	}
+	return scores
+}
+
+// roundRobinStrategy 按服务轮询：候选按CSCI_ID固定排序后，每次请求起点后移一位
+type roundRobinStrategy struct {
+	mu   sync.Mutex
+	next map[string]int // 服务ID → 下一次的起点
+}
+
+func (*roundRobinStrategy) Name() string { return "round-robin" }
+
+func (s *roundRobinStrategy) Rank(instances []models.ServiceInstanceInfo, req models.ClientRequest) []models.ServiceInstanceInfo {
+	if len(instances) == 0 {
+		return instances
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instances[i].CSCI_ID < instances[j].CSCI_ID
+	})
+
+	s.mu.Lock()
+	start := s.next[req.ServiceID] % len(instances)
+	s.next[req.ServiceID] = start + 1
+	s.mu.Unlock()
+
+	return append(instances[start:], instances[:start]...)
+}
+
+// weightedRandomStrategy 按剩余gas加权随机：剩余gas越多被选中概率越大，其余候选依次按同样方式抽取
+type weightedRandomStrategy struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (*weightedRandomStrategy) Name() string { return "weighted-random" }
+
+func (s *weightedRandomStrategy) Rank(instances []models.ServiceInstanceInfo, _ models.ClientRequest) []models.ServiceInstanceInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pool := append([]models.ServiceInstanceInfo(nil), instances...)
+	ranked := make([]models.ServiceInstanceInfo, 0, len(pool))
+	for len(pool) > 0 {
+		total := 0
+		for _, inst := range pool {
+			total += max(inst.Gas, 1)
+		}
+		pick := s.rnd.Intn(total)
+		for i, inst := range pool {
+			pick -= max(inst.Gas, 1)
+			if pick < 0 {
+				ranked = append(ranked, inst)
+				pool = append(pool[:i], pool[i+1:]...)
+				break
+			}
+		}
+	}
+	return ranked
+}
+
+// ------------------------------
+// 策略效果统计（用于在同一拓扑上对比不同策略）
+// ------------------------------
+
+type strategyStat struct {
+	Selections int            `json:"selections"` // 成功选择次数
+	AvgCost    float64        `json:"avg_cost"`   // 所选实例的平均成本
+	AvgDelay   float64        `json:"avg_delay"`  // 所选实例的平均端到端延迟（ms）
+	SiteShare  map[string]int `json:"site_share"` // 各站点被选中的次数
+	totalCost  int
+	totalDelay int
+}
+
+var (
+	strategyStats      = make(map[string]*strategyStat)
+	strategyStatsMutex sync.Mutex
+)
+
+// recordSelection 记录一次成功的选择结果
+func recordSelection(strategy string, inst models.ServiceInstanceInfo) {
+	strategyStatsMutex.Lock()
+	defer strategyStatsMutex.Unlock()
+
+	st, ok := strategyStats[strategy]
+	if !ok {
+		st = &strategyStat{SiteShare: make(map[string]int)}
+		strategyStats[strategy] = st
+	}
+	st.Selections++
+	st.totalCost += inst.Cost
+	st.totalDelay += inst.TotalDelay()
+	st.AvgCost = math.Round(float64(st.totalCost)/float64(st.Selections)*100) / 100
+	st.AvgDelay = math.Round(float64(st.totalDelay)/float64(st.Selections)*100) / 100
+	st.SiteShare[inst.SiteID]++
+}
+
+// getStrategiesHandler：GET /strategies —— 可用策略、当前默认策略及各策略的选择统计
+func getStrategiesHandler(c *gin.Context) {
+	strategyStatsMutex.Lock()
+	defer strategyStatsMutex.Unlock()
+
+	defaultName := selfCfg.Strategy
+	if defaultName == "" {
+		defaultName = DefaultStrategy
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"strategies":      strategyNames(),
+		"default":         defaultName,
+		"default_weights": defaultWeights,
+		"stats":           strategyStats,
+	})
+}
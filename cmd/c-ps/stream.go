@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"cmas-cats-go/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StreamReconnectMin = 1 * time.Second  // 订阅断开后的首次重连等待
+	StreamReconnectMax = 30 * time.Second // 重连等待上限（指数退避）
+	StreamIdleTimeout  = 45 * time.Second // 超过该时长未收到任何数据（含心跳）视为连接失效
+	StreamMaxEventSize = 16 << 20         // 单条事件上限（全量快照可能较大）
+)
+
+// errStreamGap 增量事件版本不连续（或C-SMA已重启），需要重新订阅获取全量
+var errStreamGap = errors.New("订阅流版本不连续")
+
+// streamState 订阅流状态（/cached-metrics 中展示）
+type streamState struct {
+	Connected   bool      `json:"connected"` // 已收到全量快照，缓存由订阅流维护
+	ConnectedAt time.Time `json:"connected_at"`
+	LastEventAt time.Time `json:"last_event_at"`
+	StreamID    int64     `json:"stream_id"`
+	Version     int64     `json:"version"`
+	Reconnects  int       `json:"reconnects"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+var (
+	stream      streamState
+	streamMutex sync.Mutex
+)
+
+// streamConnected 订阅流是否在线（在线时缓存始终是最新的，无需在请求中同步）
+func streamConnected() bool {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+	return stream.Connected
+}
+
+// startMetricsStream 持续订阅C-SMA的实例变化，断开后指数退避重连，每次重连都会重新获取全量
+func startMetricsStream() {
+	backoff := StreamReconnectMin
+	for {
+		err := consumeMetricsStream()
+
+		streamMutex.Lock()
+		wasConnected := stream.Connected
+		stream.Connected = false
+		stream.Reconnects++
+		stream.LastError = err.Error()
+		streamMutex.Unlock()
+
+		if wasConnected {
+			backoff = StreamReconnectMin // 正常工作过的连接断开后尽快重连
+		}
+		fmt.Printf("⚠️ C-SMA订阅流断开：%v（%v后重连）\n", err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, StreamReconnectMax)
+	}
+}
+
+// consumeMetricsStream 建立一次订阅并处理事件，直到连接断开或出错
+func consumeMetricsStream() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, csmaURL+"/subscribe", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("连接C-SMA失败：%w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("C-SMA返回非200状态码：%d", resp.StatusCode)
+	}
+
+	// 长时间没有任何数据（心跳也没有）时主动断开
+	watchdog := time.AfterFunc(StreamIdleTimeout, cancel)
+	defer watchdog.Stop()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), StreamMaxEventSize)
+	var data strings.Builder
+	for scanner.Scan() {
+		watchdog.Reset(StreamIdleTimeout)
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// 空行：一条事件结束
+			if data.Len() > 0 {
+				if err := handleStreamEvent([]byte(data.String())); err != nil {
+					return err
+				}
+				data.Reset()
+			}
+		case strings.HasPrefix(line, ":"):
+			// 心跳注释
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("超过%v未收到数据", StreamIdleTimeout)
+		}
+		return err
+	}
+	return errors.New("C-SMA关闭了连接")
+}
+
+// handleStreamEvent 应用一条订阅事件到本地缓存
+func handleStreamEvent(data []byte) error {
+	var ev models.MetricsStreamEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return fmt.Errorf("解析订阅事件失败：%w", err)
+	}
+
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+
+	switch ev.Type {
+	case models.StreamEventSnapshot:
+		mutex.Lock()
+		cachedMetrics = make(map[string][]models.ServiceInstanceInfo, len(ev.Services))
+		for _, svc := range ev.Services {
+			cachedMetrics[svc.ServiceID] = svc.Instances
+		}
+		lastSyncTime = time.Now()
+		instanceTTL = time.Duration(ev.InstanceTTLSeconds) * time.Second
+		mutex.Unlock()
+
+		stream.Connected = true
+		stream.ConnectedAt = time.Now()
+		stream.LastError = ""
+		fmt.Printf("[%s] ✅ 已订阅C-SMA：全量快照版本%d，%d个服务\n",
+			time.Now().Format("15:04:05"), ev.Version, len(ev.Services))
+
+	case models.StreamEventUpdate:
+		if !stream.Connected || ev.StreamID != stream.StreamID || ev.Version != stream.Version+1 {
+			return fmt.Errorf("%w（本地版本%d，收到版本%d）", errStreamGap, stream.Version, ev.Version)
+		}
+		mutex.Lock()
+		for _, svc := range ev.Services {
+			cachedMetrics[svc.ServiceID] = svc.Instances
+		}
+		for _, serviceID := range ev.Removed {
+			delete(cachedMetrics, serviceID)
+		}
+		lastSyncTime = time.Now()
+		mutex.Unlock()
+		fmt.Printf("[%s] 📡 订阅流版本%d：%d个服务更新，%d个服务移除\n",
+			time.Now().Format("15:04:05"), ev.Version, len(ev.Services), len(ev.Removed))
+
+	default:
+		return nil // 忽略未知事件类型，兼容C-SMA新增事件
+	}
+
+	stream.StreamID = ev.StreamID
+	stream.Version = ev.Version
+	stream.LastEventAt = time.Now()
+	return nil
+}
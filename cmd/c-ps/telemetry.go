@@ -0,0 +1,73 @@
+package main
+
+import (
+	"cmas-cats-go/telemetry"
+	"time"
+)
+
+// 路径选择结果（cmas_ps_selections_total 的 outcome 标签）
+const (
+	OutcomeSelected          = "selected"           // 选中并预占成功
+	OutcomeNoCandidate       = "no_candidate"       // 无满足约束的实例（403）
+	OutcomeReservationFailed = "reservation_failed" // 候选实例均预占失败（503）
+	OutcomeNotFound          = "not_found"          // 服务不存在（404）
+	OutcomeRetired           = "retired"            // 服务已下线（410）
+	OutcomeSyncError         = "sync_error"         // 无法从C-SMA获取实例数据（500）
+)
+
+// C-PS 运维指标（GET /prometheus）
+var (
+	selectionsTotal   = telemetry.NewCounter("cmas_ps_selections_total", "路径选择请求结果（按服务、策略）", "service_id", "strategy", "outcome")
+	selectedSiteTotal = telemetry.NewCounter("cmas_ps_selected_total", "选中实例次数（按服务与所属站点）", "service_id", "site_id")
+	rejectionsTotal   = telemetry.NewCounter("cmas_ps_constraint_rejections_total", "候选实例未通过的约束次数（按服务与约束项）", "service_id", "constraint")
+	rateLimitedTotal  = telemetry.NewCounter("cmas_ps_rate_limited_total", "因限流或配额被拒绝（429）的请求数（按Key与超限项）", "key_id", "limit")
+
+	cacheAgeGauge       = telemetry.NewGauge("cmas_ps_cache_age_seconds", "实例缓存距最近一次同步（快照/增量/同步）的秒数")
+	cacheInstancesGauge = telemetry.NewGauge("cmas_ps_cache_instances", "缓存中的实例数", "service_id")
+	streamUpGauge       = telemetry.NewGauge("cmas_ps_stream_connected", "C-SMA订阅流是否在线（1/0）")
+	streamReconnects    = telemetry.NewCounter("cmas_ps_stream_reconnects_total", "C-SMA订阅流断开重连次数")
+	streamVersionGauge  = telemetry.NewGauge("cmas_ps_stream_version", "已应用的订阅流版本")
+)
+
+func init() {
+	telemetry.OnScrape(collectPSMetrics)
+}
+
+// observeSelection 记录一次路径选择的结果
+func observeSelection(serviceID, strategy, outcome string) {
+	selectionsTotal.Inc(serviceID, strategy, outcome)
+}
+
+// observeRejections 记录候选实例未通过的约束项
+func observeRejections(serviceID string, evals []evaluation) {
+	for _, e := range evals {
+		for name, v := range e.verdicts {
+			if !v.Passed {
+				rejectionsTotal.Inc(serviceID, name)
+			}
+		}
+	}
+}
+
+// collectPSMetrics 抓取时由内存状态重建仪表
+func collectPSMetrics() {
+	streamMutex.Lock()
+	connected, reconnects, version := stream.Connected, stream.Reconnects, stream.Version
+	streamMutex.Unlock()
+	streamUpGauge.Set(0)
+	if connected {
+		streamUpGauge.Set(1)
+	}
+	streamReconnects.Set(float64(reconnects))
+	streamVersionGauge.Set(float64(version))
+
+	cacheInstancesGauge.Reset()
+	mutex.RLock()
+	if !lastSyncTime.IsZero() {
+		cacheAgeGauge.Set(time.Since(lastSyncTime).Seconds())
+	}
+	for serviceID, instances := range cachedMetrics {
+		cacheInstancesGauge.Set(float64(len(instances)), serviceID)
+	}
+	mutex.RUnlock()
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"cmas-cats-go/config"
+	"cmas-cats-go/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ------------------------------
+// 层级部署：父级C-SMA拉取下级C-SMA的 /sync，与直接监控的站点合并
+// ------------------------------
+
+// childState 下级C-SMA的同步状态（/health 中展示）
+type childState struct {
+	SMAID       string    `json:"sma_id"`
+	URL         string    `json:"url"`
+	LastSync    time.Time `json:"last_sync"`
+	FetchMillis int64     `json:"fetch_ms"` // 最近一次拉取 /sync 的往返耗时
+	Instances   int       `json:"instances"`
+	LastError   string    `json:"last_error,omitempty"`
+
+	levels []models.AggregationLevel // 下级上报的各级聚合延迟（首项为下级自身）
+}
+
+var (
+	childInstances    = make(map[string][]models.ServiceInstanceInfo) // 下级C-SMA ID → 其通告的实例
+	childStates       = make(map[string]*childState)
+	droppedDuplicates int           // 最近一次重建视图时合并掉的重复实例数
+	lastRound         time.Duration // 最近一轮拉取（站点与下级C-SMA）耗时
+	// 以上均受 metricsMutex 保护
+)
+
+// initChildren 按配置登记下级C-SMA
+func initChildren() error {
+	for _, id := range selfCfg.Children {
+		child, err := config.SMA(id)
+		if err != nil {
+			return err
+		}
+		childStates[id] = &childState{SMAID: id, URL: child.URL}
+	}
+	return nil
+}
+
+// pollChildren 并发拉取全部下级C-SMA的 /sync（含过期实例，是否过期由本级按自身TTL判断）
+func pollChildren() []pollResult {
+	if len(selfCfg.Children) == 0 {
+		return nil
+	}
+
+	results := make([]pollResult, len(selfCfg.Children))
+	var wg sync.WaitGroup
+	for i, id := range selfCfg.Children {
+		metricsMutex.RLock()
+		url := childStates[id].URL
+		metricsMutex.RUnlock()
+
+		wg.Add(1)
+		go func(result *pollResult, childID, url string) {
+			defer wg.Done()
+			startedAt := time.Now()
+			services, levels, err := fetchChildSync(url)
+			fetch := time.Since(startedAt)
+			*result = pollResult{Target: childID, Kind: PollKindSMA, Latency: fetch}
+
+			metricsMutex.Lock()
+			defer metricsMutex.Unlock()
+			state := childStates[childID]
+			if err != nil {
+				result.Error = err.Error()
+				state.LastError = err.Error()
+				fmt.Printf("❌ 拉取下级C-SMA [%s] 失败：%v（保留上次数据，超过实例过期时长后不再通告）\n", childID, err)
+				return
+			}
+			n := replaceChildInstances(childID, services)
+			result.Success = true
+			result.Instances = n
+			state.LastSync = time.Now()
+			state.FetchMillis = fetch.Milliseconds()
+			state.Instances = n
+			state.LastError = ""
+			state.levels = levels
+			fmt.Printf("✅ 拉取下级C-SMA [%s] 成功：%d 个实例，耗时 %v\n", childID, n, fetch.Round(time.Millisecond))
+		}(&results[i], id, url)
+	}
+	wg.Wait()
+	return results
+}
+
+// fetchChildSync 拉取下级C-SMA的通告数据及其各级聚合延迟
+func fetchChildSync(url string) ([]models.ServiceSummary, []models.AggregationLevel, error) {
+	resp, err := pollClient.Get(url + "/sync?include_stale=true")
+	if err != nil {
+		return nil, nil, fmt.Errorf("HTTP请求失败：%w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("状态码错误：%d", resp.StatusCode)
+	}
+
+	var syncResp struct {
+		Success     bool                      `json:"success"`
+		Data        []models.ServiceSummary   `json:"data"`
+		Aggregation []models.AggregationLevel `json:"aggregation"`
+		Message     string                    `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&syncResp); err != nil {
+		return nil, nil, fmt.Errorf("JSON解析失败：%w", err)
+	}
+	if !syncResp.Success {
+		return nil, nil, fmt.Errorf("下级C-SMA业务错误：%s", syncResp.Message)
+	}
+	return syncResp.Data, syncResp.Aggregation, nil
+}
+
+// replaceChildInstances 原子替换某下级C-SMA的全部实例，返回实例数（调用方持有 metricsMutex 写锁）；
+// 保留站点ID与站点确认时间，路径追加该下级ID
+func replaceChildInstances(childID string, services []models.ServiceSummary) int {
+	var owned []models.ServiceInstanceInfo
+	for _, svc := range services {
+		for _, inst := range svc.Instances {
+			inst.Path = append(append([]string(nil), inst.Path...), childID)
+			inst.Stale = false // 按本级TTL重新判断
+			owned = append(owned, inst)
+		}
+	}
+	childInstances[childID] = owned
+	rebuildAggregated()
+	return len(owned)
+}
+
+// mergeChildInstances 合并下级C-SMA上报的实例（调用方持有 metricsMutex 写锁）：
+// 站点已由本级直接监控时以本级数据为准；多个下级上报同一实例（CSCI_ID）时取站点最近确认的一份
+func mergeChildInstances() ([]models.ServiceInstanceInfo, int) {
+	childIDs := make([]string, 0, len(childInstances))
+	for childID := range childInstances {
+		childIDs = append(childIDs, childID)
+	}
+	sort.Strings(childIDs)
+
+	best := make(map[string]models.ServiceInstanceInfo)
+	dropped := 0
+	for _, childID := range childIDs {
+		for _, inst := range childInstances[childID] {
+			if _, direct := siteInstances[inst.SiteID]; direct {
+				dropped++
+				continue
+			}
+			if cur, ok := best[inst.CSCI_ID]; ok {
+				dropped++
+				if !inst.LastSeen.After(cur.LastSeen) {
+					continue
+				}
+			}
+			best[inst.CSCI_ID] = inst
+		}
+	}
+
+	merged := make([]models.ServiceInstanceInfo, 0, len(best))
+	for _, inst := range best {
+		merged = append(merged, inst)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].SiteID != merged[j].SiteID {
+			return merged[i].SiteID < merged[j].SiteID
+		}
+		return merged[i].CSCI_ID < merged[j].CSCI_ID
+	})
+	return merged, dropped
+}
+
+// aggregationLevels 本级及全部下级的聚合延迟，本级在前（调用方持有 metricsMutex 读锁）
+func aggregationLevels() []models.AggregationLevel {
+	now := time.Now()
+	self := models.AggregationLevel{
+		SMAID:       selfCfg.ID,
+		Depth:       1,
+		Instances:   countTotalInstances(),
+		RoundMillis: lastRound.Milliseconds(),
+		At:          now,
+	}
+	for _, instances := range aggregatedMetrics {
+		for _, inst := range instances {
+			self.MaxAgeMillis = max(self.MaxAgeMillis, now.Sub(inst.LastSeen).Milliseconds())
+		}
+	}
+
+	var below []models.AggregationLevel
+	for _, childID := range selfCfg.Children {
+		state := childStates[childID]
+		child := models.AggregationLevel{SMAID: childID, Depth: 1} // 下级尚未同步成功，或为不上报聚合延迟的旧版本
+		if len(state.levels) > 0 {
+			child = state.levels[0]
+		}
+		child.Parent = selfCfg.ID
+		child.FetchMillis = state.FetchMillis
+		self.Depth = max(self.Depth, child.Depth+1)
+		below = append(below, child)
+		if len(state.levels) > 1 {
+			below = append(below, state.levels[1:]...)
+		}
+	}
+	return append([]models.AggregationLevel{self}, below...)
+}
+
+// childList 下级C-SMA状态（按配置顺序，调用方持有 metricsMutex 读锁）
+func childList() []childState {
+	list := make([]childState, 0, len(selfCfg.Children))
+	for _, childID := range selfCfg.Children {
+		list = append(list, *childStates[childID])
+	}
+	return list
+}
+
+// childrenHealthy 是否有下级C-SMA最近一次同步成功（调用方持有 metricsMutex 读锁）
+func childrenHealthy() bool {
+	for _, state := range childStates {
+		if state.LastError == "" && !state.LastSync.IsZero() {
+			return true
+		}
+	}
+	return false
+}
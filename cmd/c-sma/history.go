@@ -0,0 +1,340 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ------------------------------
+// 历史数据：每轮拉取后记录各实例的 gas/成本/时延 以及各站点（下级C-SMA）的拉取结果，按保留时长清理
+// ------------------------------
+
+const (
+	HistoryPruneInterval = 10 * time.Minute // 过期历史清理周期
+	HistoryDefaultRange  = time.Hour        // 未指定 from 时的默认查询范围
+	HistoryDefaultPoints = 200              // 未指定 step 时按此点数自动降采样
+	HistoryMaxPoints     = 1000             // 单次查询最多返回的时间段数（step 过小时自动放大）
+)
+
+// 拉取目标类型
+const (
+	PollKindSite = "site" // 服务站点
+	PollKindSMA  = "sma"  // 下级C-SMA
+)
+
+// pollResult 一次拉取（站点或下级C-SMA）的结果
+type pollResult struct {
+	Target    string
+	Kind      string
+	Success   bool
+	Latency   time.Duration
+	Instances int
+	Error     string
+}
+
+var historyDB *sql.DB // 为 nil 时不记录历史
+
+// initHistory 打开历史数据库并建表
+func initHistory() error {
+	db, err := sql.Open("sqlite3", selfCfg.DBFile)
+	if err != nil {
+		return fmt.Errorf("历史数据库连接失败：%w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return fmt.Errorf("历史数据库验证失败：%w", err)
+	}
+
+	createTablesSQL := `
+	CREATE TABLE IF NOT EXISTS instance_samples (
+		ts INTEGER NOT NULL,        -- 拉取轮次时间（Unix毫秒），同一轮的样本相同
+		service_id TEXT NOT NULL,
+		site_id TEXT NOT NULL,
+		csci_id TEXT NOT NULL,
+		gas INT NOT NULL,
+		capacity INT NOT NULL,
+		cost INT NOT NULL,
+		delay INT NOT NULL,
+		stale INT NOT NULL          -- 1 表示该轮时已过期
+	);
+	CREATE INDEX IF NOT EXISTS idx_instance_samples_ts ON instance_samples(ts);
+	CREATE INDEX IF NOT EXISTS idx_instance_samples_service ON instance_samples(service_id, ts);
+	CREATE INDEX IF NOT EXISTS idx_instance_samples_site ON instance_samples(site_id, ts);
+	CREATE TABLE IF NOT EXISTS poll_results (
+		ts INTEGER NOT NULL,
+		target TEXT NOT NULL,       -- 站点ID或下级C-SMA ID
+		kind TEXT NOT NULL,         -- site / sma
+		success INT NOT NULL,
+		latency_ms INT NOT NULL,
+		instances INT NOT NULL,
+		error TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_poll_results_ts ON poll_results(ts);
+	CREATE INDEX IF NOT EXISTS idx_poll_results_target ON poll_results(target, ts);`
+	if _, err := db.Exec(createTablesSQL); err != nil {
+		db.Close()
+		return fmt.Errorf("创建历史表失败：%w", err)
+	}
+
+	historyDB = db
+	fmt.Printf("✅ 历史数据库初始化成功（SQLite：%s，保留%d小时）\n", selfCfg.DBFile, selfCfg.HistoryRetentionHours)
+	return nil
+}
+
+// recordRound 记录一轮拉取后的聚合实例与各目标的拉取结果
+func recordRound(at time.Time, results []pollResult) {
+	if historyDB == nil {
+		return
+	}
+	ts := at.UnixMilli()
+
+	type sample struct {
+		serviceID, siteID, csciID       string
+		gas, capacity, cost, delay, stl int
+	}
+	metricsMutex.RLock()
+	var samples []sample
+	for serviceID, instances := range aggregatedMetrics {
+		for _, inst := range instances {
+			stl := 0
+			if isStale(inst) {
+				stl = 1
+			}
+			samples = append(samples, sample{serviceID, inst.SiteID, inst.CSCI_ID, inst.Gas, inst.Capacity, inst.Cost, inst.Delay, stl})
+		}
+	}
+	metricsMutex.RUnlock()
+
+	tx, err := historyDB.Begin()
+	if err != nil {
+		fmt.Printf("⚠️ 记录历史失败：%v\n", err)
+		return
+	}
+	defer tx.Rollback()
+	for _, s := range samples {
+		if _, err := tx.Exec(`INSERT INTO instance_samples (ts, service_id, site_id, csci_id, gas, capacity, cost, delay, stale)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ts, s.serviceID, s.siteID, s.csciID, s.gas, s.capacity, s.cost, s.delay, s.stl); err != nil {
+			fmt.Printf("⚠️ 记录实例历史失败：%v\n", err)
+			return
+		}
+	}
+	for _, r := range results {
+		if _, err := tx.Exec(`INSERT INTO poll_results (ts, target, kind, success, latency_ms, instances, error)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			ts, r.Target, r.Kind, r.Success, r.Latency.Milliseconds(), r.Instances, r.Error); err != nil {
+			fmt.Printf("⚠️ 记录拉取历史失败：%v\n", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		fmt.Printf("⚠️ 记录历史失败：%v\n", err)
+	}
+}
+
+// startHistoryRetention 周期性删除超过保留时长的历史
+func startHistoryRetention() {
+	if historyDB == nil {
+		return
+	}
+	retention := time.Duration(selfCfg.HistoryRetentionHours) * time.Hour
+	for {
+		cutoff := time.Now().Add(-retention).UnixMilli()
+		var deleted int64
+		for _, table := range []string{"instance_samples", "poll_results"} {
+			res, err := historyDB.Exec(`DELETE FROM `+table+` WHERE ts < ?`, cutoff)
+			if err != nil {
+				fmt.Printf("⚠️ 清理历史表%s失败：%v\n", table, err)
+				continue
+			}
+			n, _ := res.RowsAffected()
+			deleted += n
+		}
+		if deleted > 0 {
+			fmt.Printf("[%s] 🧹 已清理 %d 条超过保留时长（%v）的历史记录\n",
+				time.Now().Format("2006-01-02 15:04:05"), deleted, retention)
+		}
+		time.Sleep(HistoryPruneInterval)
+	}
+}
+
+// historyPoint 一个时间段内实例度量的降采样结果（同一轮的实例先求和，再在时间段内的各轮之间聚合）
+type historyPoint struct {
+	Time      time.Time `json:"time"`      // 时间段起点
+	Rounds    int       `json:"rounds"`    // 时间段内有样本的拉取轮数
+	Instances float64   `json:"instances"` // 平均实例数
+	GasAvg    float64   `json:"gas_avg"`   // 剩余gas合计：各轮平均/最小/最大
+	GasMin    int       `json:"gas_min"`
+	GasMax    int       `json:"gas_max"`
+	Capacity  float64   `json:"capacity"`  // 部署总gas合计（各轮平均）
+	CostAvg   float64   `json:"cost_avg"`  // 实例平均成本
+	DelayAvg  float64   `json:"delay_avg"` // 实例平均计算时延（ms）
+	Stale     float64   `json:"stale"`     // 平均过期实例数
+}
+
+// pollPoint 一个时间段内某拉取目标的成功率与时延
+type pollPoint struct {
+	Target       string    `json:"target"`
+	Kind         string    `json:"kind"`
+	Time         time.Time `json:"time"`
+	Polls        int       `json:"polls"`
+	SuccessRate  float64   `json:"success_rate"`
+	LatencyAvgMs float64   `json:"latency_avg_ms"`
+	LatencyMaxMs int64     `json:"latency_max_ms"`
+}
+
+// historyHandler：GET /history?service_id=&site=&from=&to=&step= —— 按时间段降采样的历史
+// from/to 支持 RFC3339、"2006-01-02 15:04:05"（本地时间）或 Unix 秒；step 支持 "5m" 形式或秒数
+func historyHandler(c *gin.Context) {
+	if historyDB == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"message": "历史数据库不可用",
+		})
+		return
+	}
+
+	now := time.Now()
+	to, err := parseHistoryTime(c.Query("to"), now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "to 格式错误：" + err.Error()})
+		return
+	}
+	from, err := parseHistoryTime(c.Query("from"), to.Add(-HistoryDefaultRange))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "from 格式错误：" + err.Error()})
+		return
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "from 必须早于 to"})
+		return
+	}
+
+	span := to.Sub(from)
+	step := max(span/HistoryDefaultPoints, PollInterval)
+	if v := c.Query("step"); v != "" {
+		if step, err = parseHistoryStep(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "step 格式错误：" + err.Error()})
+			return
+		}
+	}
+	step = max(step, span/HistoryMaxPoints, time.Second)
+
+	serviceID, siteID := c.Query("service_id"), c.Query("site")
+	points, err := queryInstanceHistory(serviceID, siteID, from, to, step)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "查询实例历史失败：" + err.Error()})
+		return
+	}
+	polls, err := queryPollHistory(siteID, from, to, step)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "查询拉取历史失败：" + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"service_id":      serviceID,
+		"site":            siteID,
+		"from":            from,
+		"to":              to,
+		"step_seconds":    int(step.Seconds()),
+		"retention_hours": selfCfg.HistoryRetentionHours,
+		"points":          points, // 无样本的时间段不返回
+		"polls":           polls,
+	})
+}
+
+// queryInstanceHistory 按时间段聚合实例样本
+func queryInstanceHistory(serviceID, siteID string, from, to time.Time, step time.Duration) ([]historyPoint, error) {
+	fromMs, stepMs := from.UnixMilli(), step.Milliseconds()
+	rows, err := historyDB.Query(`
+		SELECT bucket, COUNT(*), AVG(instances), AVG(gas), MIN(gas), MAX(gas), AVG(capacity), AVG(cost), AVG(delay), AVG(stale)
+		FROM (
+			SELECT (ts - ?) / ? AS bucket, COUNT(*) AS instances, SUM(gas) AS gas, SUM(capacity) AS capacity,
+				AVG(cost) AS cost, AVG(delay) AS delay, SUM(stale) AS stale
+			FROM instance_samples
+			WHERE ts >= ? AND ts < ? AND (? = '' OR service_id = ?) AND (? = '' OR site_id = ?)
+			GROUP BY ts
+		)
+		GROUP BY bucket ORDER BY bucket`,
+		fromMs, stepMs, fromMs, to.UnixMilli(), serviceID, serviceID, siteID, siteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []historyPoint{}
+	for rows.Next() {
+		var bucket int64
+		var p historyPoint
+		if err := rows.Scan(&bucket, &p.Rounds, &p.Instances, &p.GasAvg, &p.GasMin, &p.GasMax,
+			&p.Capacity, &p.CostAvg, &p.DelayAvg, &p.Stale); err != nil {
+			return nil, err
+		}
+		p.Time = time.UnixMilli(fromMs + bucket*stepMs)
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// queryPollHistory 按拉取目标与时间段聚合拉取结果（site 为空时返回全部目标）
+func queryPollHistory(siteID string, from, to time.Time, step time.Duration) ([]pollPoint, error) {
+	fromMs, stepMs := from.UnixMilli(), step.Milliseconds()
+	rows, err := historyDB.Query(`
+		SELECT target, kind, (ts - ?) / ? AS bucket, COUNT(*), AVG(success), AVG(latency_ms), MAX(latency_ms)
+		FROM poll_results
+		WHERE ts >= ? AND ts < ? AND (? = '' OR target = ?)
+		GROUP BY target, kind, bucket ORDER BY target, bucket`,
+		fromMs, stepMs, fromMs, to.UnixMilli(), siteID, siteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	polls := []pollPoint{}
+	for rows.Next() {
+		var bucket int64
+		var p pollPoint
+		if err := rows.Scan(&p.Target, &p.Kind, &bucket, &p.Polls, &p.SuccessRate, &p.LatencyAvgMs, &p.LatencyMaxMs); err != nil {
+			return nil, err
+		}
+		p.Time = time.UnixMilli(fromMs + bucket*stepMs)
+		polls = append(polls, p)
+	}
+	return polls, rows.Err()
+}
+
+// parseHistoryTime 解析查询时间，空值返回默认值
+func parseHistoryTime(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", v, time.Local)
+}
+
+// parseHistoryStep 解析降采样步长（"5m" 或秒数）
+func parseHistoryStep(v string) (time.Duration, error) {
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec <= 0 {
+			return 0, fmt.Errorf("必须大于0")
+		}
+		return time.Duration(sec) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err == nil && d <= 0 {
+		return 0, fmt.Errorf("必须大于0")
+	}
+	return d, err
+}
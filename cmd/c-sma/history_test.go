@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cmas-cats-go/config"
+	"cmas-cats-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyBase 测试样本的时间起点（整分钟，便于核对时间段边界）
+var historyBase = time.Unix(1_700_000_000, 0)
+
+func setupHistory(t *testing.T) {
+	t.Helper()
+	selfCfg = &config.SMAConfig{ID: "sma-test", DBFile: filepath.Join(t.TempDir(), "sma.db"), HistoryRetentionHours: 72}
+	if err := initHistory(); err != nil {
+		t.Fatalf("初始化历史数据库失败：%v", err)
+	}
+	instanceTTL = time.Minute
+	t.Cleanup(func() {
+		historyDB.Close()
+		historyDB = nil
+	})
+}
+
+// recordAt 以给定实例作为聚合视图记录一轮（stale 的实例按过期记录）
+func recordAt(t *testing.T, offset time.Duration, instances []models.ServiceInstanceInfo, results ...pollResult) {
+	t.Helper()
+	metricsMutex.Lock()
+	aggregatedMetrics = make(map[string][]models.ServiceInstanceInfo)
+	for _, inst := range instances {
+		if inst.LastSeen.IsZero() {
+			inst.LastSeen = time.Now()
+		}
+		aggregatedMetrics[inst.ServiceID] = append(aggregatedMetrics[inst.ServiceID], inst)
+	}
+	metricsMutex.Unlock()
+	recordRound(historyBase.Add(offset), results)
+}
+
+func inst(serviceID, siteID string, n, gas, capacity, cost, delay int) models.ServiceInstanceInfo {
+	return models.ServiceInstanceInfo{
+		ServiceID: serviceID,
+		SiteID:    siteID,
+		CSCI_ID:   fmt.Sprintf("http://%s/%s-%d", siteID, serviceID, n),
+		Gas:       gas,
+		Capacity:  capacity,
+		Cost:      cost,
+		Delay:     delay,
+	}
+}
+
+func staleInst(i models.ServiceInstanceInfo) models.ServiceInstanceInfo {
+	i.LastSeen = time.Now().Add(-time.Hour)
+	return i
+}
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestQueryInstanceHistoryDownsampling(t *testing.T) {
+	setupHistory(t)
+
+	// 时间段1（0~30s）：3轮，每轮2个实例
+	recordAt(t, 0, []models.ServiceInstanceInfo{inst("svc-a", "site-1", 1, 3, 4, 2, 10), inst("svc-a", "site-2", 2, 5, 6, 4, 20)})
+	recordAt(t, 10*time.Second, []models.ServiceInstanceInfo{inst("svc-a", "site-1", 1, 2, 4, 2, 10), inst("svc-a", "site-2", 2, 4, 6, 4, 20)})
+	recordAt(t, 20*time.Second, []models.ServiceInstanceInfo{inst("svc-a", "site-1", 1, 1, 4, 2, 10), inst("svc-a", "site-2", 2, 1, 6, 4, 20)})
+	// 时间段2（30~60s）：一轮1个实例，一轮2个实例（其一已过期）
+	recordAt(t, 30*time.Second, []models.ServiceInstanceInfo{inst("svc-a", "site-1", 1, 4, 4, 2, 10)})
+	recordAt(t, 40*time.Second, []models.ServiceInstanceInfo{inst("svc-a", "site-1", 1, 4, 4, 2, 10), staleInst(inst("svc-a", "site-2", 2, 6, 6, 6, 30))})
+	// 其他服务与范围外（to 为开区间）的样本不计入
+	recordAt(t, 50*time.Second, []models.ServiceInstanceInfo{inst("svc-b", "site-1", 3, 9, 9, 9, 9)})
+	recordAt(t, 60*time.Second, []models.ServiceInstanceInfo{inst("svc-a", "site-1", 1, 100, 100, 100, 100)})
+
+	points, err := queryInstanceHistory("svc-a", "", historyBase, historyBase.Add(time.Minute), 30*time.Second)
+	if err != nil {
+		t.Fatalf("查询失败：%v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("返回%d个时间段，期望2：%+v", len(points), points)
+	}
+
+	p := points[0]
+	if !p.Time.Equal(historyBase) || p.Rounds != 3 || !approx(p.Instances, 2) {
+		t.Errorf("时间段1 起点/轮数/实例数 = %v/%d/%v", p.Time, p.Rounds, p.Instances)
+	}
+	// 每轮先求和：8、6、2
+	if !approx(p.GasAvg, 16.0/3) || p.GasMin != 2 || p.GasMax != 8 {
+		t.Errorf("时间段1 gas avg/min/max = %v/%d/%d，期望 %v/2/8", p.GasAvg, p.GasMin, p.GasMax, 16.0/3)
+	}
+	if !approx(p.Capacity, 10) || !approx(p.CostAvg, 3) || !approx(p.DelayAvg, 15) || !approx(p.Stale, 0) {
+		t.Errorf("时间段1 capacity/cost/delay/stale = %v/%v/%v/%v", p.Capacity, p.CostAvg, p.DelayAvg, p.Stale)
+	}
+
+	p = points[1]
+	if !p.Time.Equal(historyBase.Add(30*time.Second)) || p.Rounds != 2 || !approx(p.Instances, 1.5) {
+		t.Errorf("时间段2 起点/轮数/实例数 = %v/%d/%v", p.Time, p.Rounds, p.Instances)
+	}
+	// 各轮 gas 合计 4、10；成本为轮内平均（2、4）再跨轮平均
+	if !approx(p.GasAvg, 7) || p.GasMin != 4 || p.GasMax != 10 || !approx(p.Capacity, 7) {
+		t.Errorf("时间段2 gas avg/min/max/capacity = %v/%d/%d/%v", p.GasAvg, p.GasMin, p.GasMax, p.Capacity)
+	}
+	if !approx(p.CostAvg, 3) || !approx(p.DelayAvg, 15) || !approx(p.Stale, 0.5) {
+		t.Errorf("时间段2 cost/delay/stale = %v/%v/%v", p.CostAvg, p.DelayAvg, p.Stale)
+	}
+
+	// 按站点过滤：只统计该站点的实例
+	points, err = queryInstanceHistory("svc-a", "site-2", historyBase, historyBase.Add(time.Minute), time.Minute)
+	if err != nil {
+		t.Fatalf("查询失败：%v", err)
+	}
+	if len(points) != 1 || points[0].Rounds != 4 || points[0].GasMax != 6 || points[0].GasMin != 1 {
+		t.Errorf("按站点过滤结果不符：%+v", points)
+	}
+
+	// 不指定服务：50s 的 svc-b 样本进入第二个时间段
+	points, err = queryInstanceHistory("", "", historyBase, historyBase.Add(time.Minute), 30*time.Second)
+	if err != nil {
+		t.Fatalf("查询失败：%v", err)
+	}
+	if len(points) != 2 || points[1].Rounds != 3 {
+		t.Errorf("不过滤服务时时间段2应有3轮：%+v", points)
+	}
+}
+
+func TestQueryPollHistory(t *testing.T) {
+	setupHistory(t)
+
+	recordAt(t, 0, nil,
+		pollResult{Target: "site-1", Kind: PollKindSite, Success: true, Latency: 10 * time.Millisecond},
+		pollResult{Target: "sma-child", Kind: PollKindSMA, Success: true, Latency: 5 * time.Millisecond})
+	recordAt(t, 10*time.Second, nil,
+		pollResult{Target: "site-1", Kind: PollKindSite, Success: false, Latency: 30 * time.Millisecond, Error: "timeout"})
+	recordAt(t, 20*time.Second, nil,
+		pollResult{Target: "site-1", Kind: PollKindSite, Success: true, Latency: 20 * time.Millisecond})
+	recordAt(t, 40*time.Second, nil,
+		pollResult{Target: "site-1", Kind: PollKindSite, Success: true, Latency: 7 * time.Millisecond})
+
+	polls, err := queryPollHistory("site-1", historyBase, historyBase.Add(time.Minute), 30*time.Second)
+	if err != nil {
+		t.Fatalf("查询失败：%v", err)
+	}
+	if len(polls) != 2 {
+		t.Fatalf("返回%d个时间段，期望2：%+v", len(polls), polls)
+	}
+	p := polls[0]
+	if p.Target != "site-1" || p.Kind != PollKindSite || p.Polls != 3 || !approx(p.SuccessRate, 2.0/3) ||
+		!approx(p.LatencyAvgMs, 20) || p.LatencyMaxMs != 30 {
+		t.Errorf("时间段1 = %+v", p)
+	}
+	if p := polls[1]; !p.Time.Equal(historyBase.Add(30*time.Second)) || p.Polls != 1 || !approx(p.SuccessRate, 1) {
+		t.Errorf("时间段2 = %+v", p)
+	}
+
+	polls, err = queryPollHistory("", historyBase, historyBase.Add(time.Minute), time.Minute)
+	if err != nil {
+		t.Fatalf("查询失败：%v", err)
+	}
+	if len(polls) != 2 || polls[0].Target != "site-1" || polls[1].Target != "sma-child" || polls[1].Kind != PollKindSMA {
+		t.Errorf("不过滤目标时应按目标分别返回：%+v", polls)
+	}
+}
+
+func TestHistoryHandlerStep(t *testing.T) {
+	setupHistory(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/history", historyHandler)
+
+	from, to := historyBase.Unix(), historyBase.Add(time.Hour).Unix()
+	cases := []struct {
+		name  string
+		query string
+		code  int
+		step  int
+	}{
+		{"默认按点数降采样", fmt.Sprintf("from=%d&to=%d", from, to), http.StatusOK, 18},
+		{"默认步长不小于拉取间隔", fmt.Sprintf("from=%d&to=%d", from, from+600), http.StatusOK, int(PollInterval.Seconds())},
+		{"指定步长", fmt.Sprintf("from=%d&to=%d&step=5m", from, to), http.StatusOK, 300},
+		{"秒数步长", fmt.Sprintf("from=%d&to=%d&step=120", from, to), http.StatusOK, 120},
+		{"步长过小按最大点数放大", fmt.Sprintf("from=%d&to=%d&step=1", from, from+20000), http.StatusOK, 20},
+		{"步长为0", fmt.Sprintf("from=%d&to=%d&step=0", from, to), http.StatusBadRequest, 0},
+		{"负步长", fmt.Sprintf("from=%d&to=%d&step=-5m", from, to), http.StatusBadRequest, 0},
+		{"步长格式错误", fmt.Sprintf("from=%d&to=%d&step=abc", from, to), http.StatusBadRequest, 0},
+		{"from 不早于 to", fmt.Sprintf("from=%d&to=%d", to, from), http.StatusBadRequest, 0},
+		{"时间格式错误", "from=yesterday", http.StatusBadRequest, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/history?"+tc.query, nil))
+			if rec.Code != tc.code {
+				t.Fatalf("状态码%d，期望%d：%s", rec.Code, tc.code, rec.Body.String())
+			}
+			if tc.code != http.StatusOK {
+				return
+			}
+			var resp struct {
+				StepSeconds int `json:"step_seconds"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("解析响应失败：%v", err)
+			}
+			if resp.StepSeconds != tc.step {
+				t.Errorf("step_seconds=%d，期望%d", resp.StepSeconds, tc.step)
+			}
+		})
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	def := time.Unix(42, 0)
+	local := time.Date(2024, 3, 1, 12, 30, 0, 0, time.Local)
+	cases := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", def},
+		{"1700000000", historyBase},
+		{"2023-11-14T22:13:20Z", historyBase},
+		{"2024-03-01 12:30:00", local},
+	}
+	for _, tc := range cases {
+		got, err := parseHistoryTime(tc.in, def)
+		if err != nil || !got.Equal(tc.want) {
+			t.Errorf("parseHistoryTime(%q) = %v, %v，期望 %v", tc.in, got, err, tc.want)
+		}
+	}
+	if _, err := parseHistoryTime("2024/03/01", def); err == nil {
+		t.Error("无法识别的格式应返回错误")
+	}
+}
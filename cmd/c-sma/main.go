@@ -5,10 +5,13 @@ package main
 import (
 	"cmas-cats-go/config"
 	"cmas-cats-go/models"
+	"cmas-cats-go/telemetry"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,11 +23,23 @@ import (
 
 const (
 	PollInterval = 10 * time.Second
+	PollTimeout  = 5 * time.Second // 单个站点拉取超时，避免无响应站点拖住整轮拉取（其余站点实例随之过期）
 )
 
+var pollClient = &http.Client{Timeout: PollTimeout}
+
 var (
-	aggregatedMetrics = make(map[string][]models.ServiceInstanceInfo)
+	siteInstances     = make(map[string][]models.ServiceInstanceInfo) // 站点ID → 该站点的全部实例（权威数据）
+	siteReports       = make(map[string]siteReport)                   // 站点ID → 最近一次数据更新
+	aggregatedMetrics = make(map[string][]models.ServiceInstanceInfo) // 服务ID → 实例（由 siteInstances 重建的视图）
 	metricsMutex      sync.RWMutex
+
+	selfCfg     *config.SMAConfig // 本C-SMA实例配置
+	instanceTTL time.Duration     // 实例过期时长（见 SMAConfig.InstanceTTLSeconds）
+
+	// 最近一次从公共服务平台获取的部署验证快照（nil 表示尚未获取成功，此时不做过滤）
+	validationSnapshot *models.ValidationSnapshot
+	validationMutex    sync.RWMutex
 )
 
 func main() {
@@ -32,12 +47,41 @@ func main() {
 	fmt.Println("        C-SMA 度量收集服务启动中...        ")
 	fmt.Println("=====================================")
 
-	// ✅ 关键修改：使用 config.GetAllSiteURLs() 动态获取所有站点
-	sites := config.GetAllSiteURLs()
+	// 加载拓扑配置，确定本实例及其监控的站点
+	flags := config.BindFlags()
+	flag.Parse()
+	if err := flags.Load(); err != nil {
+		fmt.Printf("❌ 加载配置失败，程序退出：%v\n", err)
+		return
+	}
+	var err error
+	if selfCfg, err = config.SMA(flags.ID); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if flags.Port > 0 {
+		selfCfg.OverridePort(flags.Port)
+	}
+	instanceTTL = time.Duration(selfCfg.InstanceTTLSeconds) * time.Second
+	var sites []string
+	for _, site := range selfCfg.SitesFor() {
+		sites = append(sites, site.URL)
+	}
 	printSiteConfig(sites)
 
-	if len(sites) == 0 {
-		fmt.Println("⚠️  未发现任何 SiteN.URL 配置！请检查 config.Cfg 中 Site1/2/3...")
+	if len(sites) == 0 && len(selfCfg.Children) == 0 {
+		fmt.Println("⚠️  未发现任何站点配置，等待站点运行时注册（POST /sites/register）")
+	}
+	// 配置中的站点预先登记，其余站点运行时注册；按心跳判定存活
+	seedConfiguredSites()
+	// 层级部署：登记下级C-SMA
+	if err := initChildren(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	// 历史数据库不可用时仅停止记录历史，不影响聚合与通告
+	if err := initHistory(); err != nil {
+		fmt.Printf("⚠️ %v（不记录历史数据）\n", err)
 	}
 
 	r := gin.Default() // Gin 引擎实例名为 r
@@ -50,10 +94,21 @@ func main() {
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
+	telemetry.Register(r, "c-sma", selfCfg.ID) // 运维指标（GET /prometheus）
 	// API 路由
 	r.GET("/sync", syncToCPSHandler)
+	r.POST("/push", pushMetricsHandler)   // 站点主动推送实例变化（部署/扩缩容/删除）
+	r.GET("/subscribe", subscribeHandler) // C-PS 订阅流（SSE：全量快照 + 增量更新）
 	r.GET("/current-metrics", getMetricsHandler)
 	r.GET("/health", healthCheckHandler)
+	r.GET("/history", historyHandler) // 实例度量与站点拉取结果的历史（按时间段降采样）
+
+	// 站点注册与心跳
+	r.POST("/sites/register", registerSiteHandler)
+	r.POST("/sites/:id/heartbeat", heartbeatHandler)
+	r.GET("/sites", listSitesHandler)
+	r.GET("/sites/:id", getSiteHandler)
+	r.GET("/sites/:id/metrics", getSiteMetricsHandler)
 
 	// Web 页面
 	r.LoadHTMLGlob("./templates/sma/*.html")
@@ -63,29 +118,38 @@ func main() {
 		})
 	})
 	r.GET("/dashboard", func(c *gin.Context) {
+		var siteLines []string
+		for _, site := range pollTargets() {
+			siteLines = append(siteLines, fmt.Sprintf("%s %s（%s）", site.SiteID, site.URL, site.State))
+		}
 		metricsMutex.RLock()
 		defer metricsMutex.RUnlock()
 		c.HTML(http.StatusOK, "dashboard.html", gin.H{
 			"title":   "服务度量数据",
 			"metrics": aggregatedMetrics,
-			"sites":   sites,
+			"sites":   siteLines,
 		})
 	})
 
-	// 启动拉取任务
-	go startMultiSitePolling(sites)
+	// 启动拉取与存活检查任务
+	go startMultiSitePolling()
+	go startLivenessChecker()
+	go startHistoryRetention()
 
 	// C-SMA 模块启动配置
-	// 实际监听地址必须使用 config.LOCAL_LISTEN_IP ("0.0.0.0")
-	listenAddr := config.LOCAL_LISTEN_IP + ":" + strconv.Itoa(config.Cfg.SMA.Port)
+	// 实际监听地址必须使用 config.Cfg.ListenIP ("0.0.0.0")
+	listenAddr := config.Cfg.ListenIP + ":" + strconv.Itoa(selfCfg.Port)
 	// 外部展示地址
-	externalListenAddr := fmt.Sprintf("http://%s:%d", config.Cfg.SMA.IP, config.Cfg.SMA.Port)
+	externalListenAddr := selfCfg.URL
 
 	// 启动服务前打印信息
-	fmt.Printf("\n✅ C-SMA 启动成功！\n")
+	fmt.Printf("\n✅ C-SMA（%s）启动成功！\n", selfCfg.ID)
 	fmt.Printf("📌 监听地址：%s\n", externalListenAddr)
-	fmt.Printf("📌 监控站点数：%d（动态发现）\n", len(sites))
-	fmt.Printf("📌 拉取间隔：%v\n", PollInterval)
+	fmt.Printf("📌 配置站点数：%d（其余站点可运行时注册）\n", len(sites))
+	fmt.Printf("📌 拉取间隔：%v | 实例过期时长：%v\n", PollInterval, instanceTTL)
+	if len(selfCfg.Children) > 0 {
+		fmt.Printf("📌 下级C-SMA：%s\n", strings.Join(selfCfg.Children, ", "))
+	}
 
 	fmt.Println("📌 站点列表：")
 	for _, site := range sites {
@@ -103,7 +167,7 @@ func main() {
 
 	// --------------------------------------------------------------------------------
 	// 移除：以下代码是原始代码中的冗余或错误，已被上面的代码替代。
-	// listenAddr := config.LOCAL_LISTEN_IP + ":" + strconv.Itoa(config.Cfg.SMA.Port)
+	// listenAddr := config.Cfg.ListenIP + ":" + strconv.Itoa(selfCfg.Port)
 	// router.Run(listenAddr) // 错误：router 未定义
 	// fmt.Printf("\n✅ C-SMA 启动成功！...")
 	// if err := r.Run(listenAddr); err != nil { panic(...) } // 错误：重复调用 Run
@@ -114,53 +178,109 @@ func main() {
 // 核心：多站点拉取 (保持不变)
 // ------------------------------
 
-func startMultiSitePolling(sites []string) {
-	if len(sites) == 0 {
-		fmt.Println("⚠️ 无有效服务站点，停止拉取任务")
-		return
-	}
-
+func startMultiSitePolling() {
+	// 启动后立即拉取一轮，避免重启后的首个周期内向订阅者通告空视图
+	pollRound()
 	ticker := time.NewTicker(PollInterval)
 	defer ticker.Stop()
-
 	for range ticker.C {
-		fmt.Printf("\n[%s] 📥 开始拉取 %d 个服务站点的metrics...\n",
-			time.Now().Format("2006-01-02 15:04:05"), len(sites))
-
-		var wg sync.WaitGroup
-		for _, siteURL := range sites {
-			wg.Add(1)
-			go func(url string) {
-				defer wg.Done()
-				siteMetrics, siteID, err := fetchSingleSiteMetrics(url + "/metrics")
-				if err != nil {
-					fmt.Printf("❌ 拉取站点 [%s] 失败：%v\n", url, err)
-					return
-				}
-				metricsMutex.Lock()
-				aggregateSiteMetrics(url, siteMetrics)
-				metricsMutex.Unlock()
+		pollRound()
+	}
+}
 
-				fmt.Printf("✅ 拉取站点 [%s] 成功：%d 个实例（站点ID：%s）\n",
-					url, len(siteMetrics), siteID)
-			}(siteURL)
-		}
+// pollRound 一轮拉取：同步部署验证状态，并发拉取站点与下级C-SMA，完成后发布变化并记录历史
+func pollRound() {
+	startedAt := time.Now()
+
+	// 每轮同步一次部署验证状态，用于决定哪些实例可以向C-PS通告
+	if err := refreshValidationSnapshot(); err != nil {
+		fmt.Printf("⚠️ 获取部署验证状态失败：%v（沿用上次结果）\n", err)
+	}
 
-		wg.Wait()
+	var siteResults, childResults []pollResult
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		siteResults = pollSites(pollTargets())
+	}()
+	go func() {
+		defer wg.Done()
+		childResults = pollChildren()
+	}()
+	wg.Wait()
+
+	metricsMutex.Lock()
+	lastRound = time.Since(startedAt)
+	metricsMutex.Unlock()
+	publishChanges()
+	results := append(siteResults, childResults...)
+	recordRound(startedAt, results)
+	observePolls(results, time.Since(startedAt))
 
-		metricsMutex.RLock()
-		serviceCount := len(aggregatedMetrics)
-		totalInstances := countTotalInstances()
-		metricsMutex.RUnlock()
-		fmt.Printf("[%s] 📊 所有站点拉取完成 | 聚合服务数：%d | 总实例数：%d\n",
-			time.Now().Format("2006-01-02 15:04:05"), serviceCount, totalInstances)
+	metricsMutex.RLock()
+	serviceCount := len(aggregatedMetrics)
+	totalInstances := countTotalInstances()
+	metricsMutex.RUnlock()
+	fmt.Printf("[%s] 📊 本轮拉取完成（耗时 %v）| 聚合服务数：%d | 总实例数：%d\n",
+		time.Now().Format("2006-01-02 15:04:05"), time.Since(startedAt).Round(time.Millisecond), serviceCount, totalInstances)
+}
+
+// pollSites 拉取一轮站点（注册表中未失联的站点）的metrics
+func pollSites(sites []models.SiteStatus) []pollResult {
+	if len(sites) == 0 {
+		return nil
 	}
+
+	fmt.Printf("\n[%s] 📥 开始拉取 %d 个服务站点的metrics...\n",
+		time.Now().Format("2006-01-02 15:04:05"), len(sites))
+
+	results := make([]pollResult, len(sites))
+	var wg sync.WaitGroup
+	for i, site := range sites {
+		wg.Add(1)
+		go func(result *pollResult, url, registeredID string) {
+			defer wg.Done()
+			startedAt := time.Now()
+			siteMetrics, siteID, err := fetchSingleSiteMetrics(url + "/metrics")
+			*result = pollResult{Target: registeredID, Kind: PollKindSite, Latency: time.Since(startedAt)}
+			if err != nil {
+				result.Error = err.Error()
+				fmt.Printf("❌ 拉取站点 [%s] 失败：%v\n", url, err)
+				return
+			}
+			result.Success = true
+			result.Instances = len(siteMetrics)
+			markPolled(registeredID)
+			switch {
+			case siteID == "":
+				siteID = registeredID // 旧版本站点未返回site_id
+			case siteID != registeredID:
+				fmt.Printf("⚠️ 站点 [%s] 登记为 %s，但上报的site_id为 %s，按上报值归属实例\n", url, registeredID, siteID)
+			}
+			metricsMutex.Lock()
+			if pushedSince(siteID, startedAt) {
+				// 拉取期间站点推送了更新的数据，本轮结果已过时，等待下一轮对账
+				metricsMutex.Unlock()
+				fmt.Printf("⏭️ 站点 [%s] 在拉取期间有推送，跳过本轮结果\n", url)
+				return
+			}
+			replaceSiteInstances(siteID, "poll", siteMetrics)
+			metricsMutex.Unlock()
+
+			fmt.Printf("✅ 拉取站点 [%s] 成功：%d 个实例（站点ID：%s）\n",
+				url, len(siteMetrics), siteID)
+		}(&results[i], site.URL, site.SiteID)
+	}
+
+	wg.Wait()
+	return results
 }
 
-// ... (fetchSingleSiteMetrics, aggregateSiteMetrics, printSiteConfig, countTotalInstances 保持不变)
+// ... (fetchSingleSiteMetrics, printSiteConfig, countTotalInstances 保持不变)
 
 func fetchSingleSiteMetrics(siteURL string) ([]models.ServiceInstanceInfo, string, error) {
-	resp, err := http.Get(siteURL)
+	resp, err := pollClient.Get(siteURL)
 	if err != nil {
 		return nil, "", fmt.Errorf("HTTP请求失败：%w", err)
 	}
@@ -184,48 +304,71 @@ func fetchSingleSiteMetrics(siteURL string) ([]models.ServiceInstanceInfo, strin
 		return nil, "", fmt.Errorf("站点业务错误：%s", siteResp.Message)
 	}
 
+	// 旧版本站点未在实例中携带站点ID，用响应中的site_id补齐
+	for i := range siteResp.Metrics {
+		if siteResp.Metrics[i].SiteID == "" {
+			siteResp.Metrics[i].SiteID = siteResp.SiteID
+		}
+	}
+
 	return siteResp.Metrics, siteResp.SiteID, nil
 }
 
 // ------------------------------
-// 聚合逻辑（去重）
+// 聚合逻辑：按站点ID保存各站点上报的实例集合，按服务汇总的视图由其重建
 // ------------------------------
 
-func aggregateSiteMetrics(siteURL string, newMetrics []models.ServiceInstanceInfo) {
-	parsedURL, err := url.Parse(siteURL)
-	if err != nil {
-		fmt.Printf("⚠️ 解析站点URL [%s] 失败：%v\n", siteURL, err)
-		return
-	}
-	siteKey := parsedURL.Host // e.g., "192.168.235.48:8081"
-	
-	// 从站点URL和CSCI_ID格式中提取正确的站点标识
-	var siteIdentifier string
-	// 根据CSCI_ID的实际格式：site-1使用localhost:8082，site-2使用localhost:8085
-	if strings.Contains(siteURL, "8081") {
-		siteIdentifier = "localhost:8082"  // site-1的实际监听地址
-	} else if strings.Contains(siteURL, "8085") {
-		siteIdentifier = "localhost:8085"  // site-2的实际监听地址
-	} else {
-		siteIdentifier = siteKey // 回退到使用host
-	}
-
-	// 删除旧实例 - 使用更准确的匹配逻辑
-	for serviceID, oldInstances := range aggregatedMetrics {
-		var retained []models.ServiceInstanceInfo
-		for _, inst := range oldInstances {
-			// 检查实例是否属于当前站点（通过CSCI_ID中的地址标识）
-			if !strings.Contains(inst.CSCI_ID, siteIdentifier) {
-				retained = append(retained, inst)
-			}
+// replaceSiteInstances 原子替换某站点的全部实例（调用方持有 metricsMutex 写锁）；
+// 未携带 last_seen 的实例（本次由站点确认）记为当前时间，已有的保持不变
+func replaceSiteInstances(siteID, via string, instances []models.ServiceInstanceInfo) {
+	now := time.Now()
+	owned := make([]models.ServiceInstanceInfo, 0, len(instances))
+	for _, inst := range instances {
+		inst.SiteID = siteID // 实例归属以上报方站点ID为准
+		if inst.LastSeen.IsZero() {
+			inst.LastSeen = now
 		}
-		aggregatedMetrics[serviceID] = retained
+		owned = append(owned, inst)
+	}
+	siteInstances[siteID] = owned
+	markSiteReport(siteID, via)
+	rebuildAggregated()
+}
+
+// removeSiteInstances 删除某站点的全部实例，返回删除数（调用方持有 metricsMutex 写锁）
+func removeSiteInstances(siteID string) int {
+	n := len(siteInstances[siteID])
+	delete(siteInstances, siteID)
+	delete(siteReports, siteID)
+	rebuildAggregated()
+	return n
+}
+
+// markSiteReport 记录站点数据最近一次更新的时间与途径
+func markSiteReport(siteID, via string) {
+	siteReports[siteID] = siteReport{UpdatedAt: time.Now(), Via: via}
+}
+
+// rebuildAggregated 由各站点实例集合及下级C-SMA上报的实例重建按服务汇总的视图（调用方持有 metricsMutex 写锁）
+func rebuildAggregated() {
+	siteIDs := make([]string, 0, len(siteInstances))
+	for siteID := range siteInstances {
+		siteIDs = append(siteIDs, siteID)
 	}
+	sort.Strings(siteIDs)
 
-	// 添加新实例
-	for _, inst := range newMetrics {
-		aggregatedMetrics[inst.ServiceID] = append(aggregatedMetrics[inst.ServiceID], inst)
+	aggregated := make(map[string][]models.ServiceInstanceInfo)
+	for _, siteID := range siteIDs {
+		for _, inst := range siteInstances[siteID] {
+			aggregated[inst.ServiceID] = append(aggregated[inst.ServiceID], inst)
+		}
+	}
+	merged, dropped := mergeChildInstances()
+	for _, inst := range merged {
+		aggregated[inst.ServiceID] = append(aggregated[inst.ServiceID], inst)
 	}
+	aggregatedMetrics = aggregated
+	droppedDuplicates = dropped
 }
 
 // ------------------------------
@@ -248,6 +391,46 @@ func printSiteConfig(sites []string) {
 	}
 }
 
+// refreshValidationSnapshot 从公共服务平台拉取部署验证快照
+func refreshValidationSnapshot() error {
+	resp, err := http.Get(config.Cfg.Platform.URL + "/api/v1/validations")
+	if err != nil {
+		return fmt.Errorf("HTTP请求失败：%w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("状态码错误：%d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+		models.ValidationSnapshot
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("JSON解析失败：%w", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("平台业务错误：%s", result.Message)
+	}
+
+	validationMutex.Lock()
+	validationSnapshot = &result.ValidationSnapshot
+	validationMutex.Unlock()
+	return nil
+}
+
+// isAdvertisable 实例是否可以向C-PS通告（需要验证的服务必须在该站点验证通过）
+func isAdvertisable(inst models.ServiceInstanceInfo) bool {
+	validationMutex.RLock()
+	defer validationMutex.RUnlock()
+	if validationSnapshot == nil {
+		return true
+	}
+	return validationSnapshot.Allows(inst.SiteID, inst.ServiceID)
+}
+
 func countTotalInstances() int {
 	total := 0
 	for _, instances := range aggregatedMetrics {
@@ -264,15 +447,47 @@ func syncToCPSHandler(c *gin.Context) {
 	metricsMutex.RLock()
 	defer metricsMutex.RUnlock()
 
-	var syncData []struct {
-		ServiceID string                       `json:"service_id"`
-		Instances []models.ServiceInstanceInfo `json:"instances"`
-		TotalGas  int                          `json:"total_gas"`
-		MinDelay  int                          `json:"min_delay"`
-		MaxDelay  int                          `json:"max_delay"`
-	}
+	includeStale := c.Query("include_stale") == "true"
+	syncData, unvalidated, stale := advertisedServices(includeStale)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":              true,
+		"sync_time":            time.Now().Format("2006-01-02 15:04:05"),
+		"service_num":          len(syncData),
+		"site_num":             len(pollTargets()),
+		"unvalidated":          unvalidated, // 因未通过部署验证而未通告的实例数
+		"stale":                stale,       // 已过期的实例数（include_stale=true 时仍返回并标记 stale）
+		"instance_ttl_seconds": selfCfg.InstanceTTLSeconds,
+		"aggregation":          aggregationLevels(), // 本级及各下级的聚合延迟（层级部署时父级据此逐级汇总）
+		"data":                 syncData,
+	})
+}
+
+// advertisedServices 按服务汇总可向C-PS通告的实例：过滤未通过部署验证的实例，
+// 标记过期实例（includeStale 为 false 时一并过滤），同时返回两类实例数；调用方持有 metricsMutex 读锁
+func advertisedServices(includeStale bool) ([]models.ServiceSummary, int, int) {
+	var syncData []models.ServiceSummary
+	unvalidated, stale := 0, 0
+	for serviceID, allInstances := range aggregatedMetrics {
+		var instances []models.ServiceInstanceInfo
+		for _, inst := range allInstances {
+			if !isAdvertisable(inst) {
+				unvalidated++
+				continue
+			}
+			inst.Stale = isStale(inst)
+			if inst.Stale {
+				stale++
+				if !includeStale {
+					continue
+				}
+			}
+			instances = append(instances, inst)
+		}
+		if len(instances) == 0 {
+			continue
+		}
 
-	for serviceID, instances := range aggregatedMetrics {
 		totalGas := 0
 		minDelay := 1 << 30
 		maxDelay := 0
@@ -289,13 +504,7 @@ func syncToCPSHandler(c *gin.Context) {
 			minDelay = 0
 		}
 
-		syncData = append(syncData, struct {
-			ServiceID string                       `json:"service_id"`
-			Instances []models.ServiceInstanceInfo `json:"instances"`
-			TotalGas  int                          `json:"total_gas"`
-			MinDelay  int                          `json:"min_delay"`
-			MaxDelay  int                          `json:"max_delay"`
-		}{
+		syncData = append(syncData, models.ServiceSummary{
 			ServiceID: serviceID,
 			Instances: instances,
 			TotalGas:  totalGas,
@@ -303,25 +512,22 @@ func syncToCPSHandler(c *gin.Context) {
 			MaxDelay:  maxDelay,
 		})
 	}
+	return syncData, unvalidated, stale
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success":     true,
-		"sync_time":   time.Now().Format("2006-01-02 15:04:05"),
-		"service_num": len(syncData),
-		"site_num":    len(config.GetAllSiteURLs()), // ✅ 动态获取
-		"data":        syncData,
-	})
+// isStale 实例超过 instanceTTL 未被站点确认
+func isStale(inst models.ServiceInstanceInfo) bool {
+	return time.Since(inst.LastSeen) > instanceTTL
 }
 
 func getMetricsHandler(c *gin.Context) {
 	metricsMutex.RLock()
 	defer metricsMutex.RUnlock()
 
-	sites := config.GetAllSiteURLs() // ✅ 动态获取
 	c.JSON(http.StatusOK, gin.H{
 		"success":          true,
 		"last_update_time": time.Now().Format("2006-01-02 15:04:05"),
-		"monitored_sites":  len(sites),
+		"monitored_sites":  len(pollTargets()),
 		"service_count":    len(aggregatedMetrics),
 		"total_instances":  countTotalInstances(),
 		"aggregated_data":  aggregatedMetrics,
@@ -329,9 +535,12 @@ func getMetricsHandler(c *gin.Context) {
 }
 
 func healthCheckHandler(c *gin.Context) {
-	sites := config.GetAllSiteURLs() // ✅ 动态获取
+	metricsMutex.RLock()
+	defer metricsMutex.RUnlock()
+
+	total, alive := siteCounts()
 	status := "healthy"
-	if len(sites) == 0 {
+	if alive == 0 && !childrenHealthy() {
 		status = "degraded"
 	}
 
@@ -340,6 +549,12 @@ func healthCheckHandler(c *gin.Context) {
 		"status":          status,
 		"service":         "c-sma",
 		"time":            time.Now().Format("2006-01-02 15:04:05"),
-		"monitored_sites": len(sites),
+		"monitored_sites": len(pollTargets()),
+		"known_sites":     total,
+		"alive_sites":     alive,
+		"push":            pushStates,
+		"children":        childList(),
+		"duplicates":      droppedDuplicates, // 合并下级数据时去除的重复实例数
+		"aggregation":     aggregationLevels(),
 	})
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"cmas-cats-go/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sitePushState 某站点最近一次被接受的推送（由 metricsMutex 保护）
+type sitePushState struct {
+	Epoch    int64     `json:"epoch"`
+	Seq      int64     `json:"seq"`
+	LastPush time.Time `json:"last_push"`
+	Accepted int       `json:"accepted"` // 已应用的推送数
+	Stale    int       `json:"stale"`    // 因乱序/过期被丢弃的推送数
+}
+
+var pushStates = make(map[string]*sitePushState) // 站点ID → 推送状态
+
+// pushMetricsHandler：POST /push —— 站点在部署、扩缩容、删除时主动推送实例变化（须携带站点令牌）
+func pushMetricsHandler(c *gin.Context) {
+	var update models.MetricsUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+	if authenticateSite(c, update.SiteID) == nil {
+		return
+	}
+	if !isRegistered(update.SiteID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("站点%s未在本C-SMA（%s）注册或已被判定失联", update.SiteID, selfCfg.ID),
+		})
+		return
+	}
+	switch update.Event {
+	case models.MetricsEventDeploy, models.MetricsEventScale, models.MetricsEventGas:
+		if update.Instance == nil || update.Instance.CSCI_ID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": update.Event + "事件缺少instance",
+			})
+			return
+		}
+	case models.MetricsEventUndeploy:
+		if update.CSCI_ID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "undeploy事件缺少csci_id",
+			})
+			return
+		}
+	case models.MetricsEventSnapshot:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "未知的事件类型：" + update.Event,
+		})
+		return
+	}
+
+	metricsMutex.Lock()
+	state, ok := pushStates[update.SiteID]
+	if !ok {
+		state = &sitePushState{}
+		pushStates[update.SiteID] = state
+	}
+	// 同一进程内序号不大于已接受序号、或来自更早的进程：乱序到达的旧事件，丢弃
+	if update.Epoch < state.Epoch || (update.Epoch == state.Epoch && update.Seq <= state.Seq) {
+		state.Stale++
+		metricsMutex.Unlock()
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"applied": false,
+			"message": fmt.Sprintf("事件已过期（seq=%d，已接受seq=%d），忽略", update.Seq, state.Seq),
+		})
+		return
+	}
+
+	applyMetricsUpdate(update)
+	state.Epoch, state.Seq = update.Epoch, update.Seq
+	state.LastPush = time.Now()
+	state.Accepted++
+	metricsMutex.Unlock()
+	publishChanges()
+
+	fmt.Printf("[%s] 📨 站点 [%s] 推送%s事件（seq=%d）\n",
+		time.Now().Format("2006-01-02 15:04:05"), update.SiteID, update.Event, update.Seq)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"applied": true,
+		"message": "已更新",
+	})
+}
+
+// applyMetricsUpdate 将推送事件应用到该站点的实例集合（调用方持有 metricsMutex 写锁）
+func applyMetricsUpdate(update models.MetricsUpdate) {
+	if update.Event == models.MetricsEventSnapshot {
+		replaceSiteInstances(update.SiteID, "push", update.Instances)
+		return
+	}
+
+	csciID := update.CSCI_ID
+	if update.Instance != nil {
+		csciID = update.Instance.CSCI_ID
+	}
+	current := siteInstances[update.SiteID]
+	next := make([]models.ServiceInstanceInfo, 0, len(current)+1)
+	for _, inst := range current {
+		if inst.CSCI_ID != csciID {
+			next = append(next, inst)
+		}
+	}
+	if update.Event != models.MetricsEventUndeploy {
+		next = append(next, *update.Instance)
+	}
+	replaceSiteInstances(update.SiteID, "push", next)
+}
+
+// pushedSince 站点在 t 之后是否有推送被应用（调用方持有 metricsMutex）；
+// 用于丢弃在推送之前发起的拉取结果，避免旧数据覆盖新推送
+func pushedSince(siteID string, t time.Time) bool {
+	state, ok := pushStates[siteID]
+	return ok && state.LastPush.After(t)
+}
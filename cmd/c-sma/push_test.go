@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cmas-cats-go/models"
+)
+
+func TestPushRequiresToken(t *testing.T) {
+	r := setupRegistry(t)
+	r.POST("/push", pushMetricsHandler)
+	t.Cleanup(func() { delete(pushStates, "site-a") })
+	if got := postSite(r, "/sites/register", "token-a", models.SiteRegistration{SiteID: "site-a", URL: "http://127.0.0.1:9001"}); got != http.StatusOK {
+		t.Fatalf("注册失败：状态码%d", got)
+	}
+
+	update := models.MetricsUpdate{SiteID: "site-a", Event: models.MetricsEventUndeploy, CSCI_ID: "http://127.0.0.1:9001/x", Epoch: 1}
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"缺少令牌", "", http.StatusUnauthorized},
+		{"令牌错误", "token-b", http.StatusUnauthorized},
+		{"通过", "token-a", http.StatusOK},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update.Seq = int64(i + 1)
+			body, _ := json.Marshal(update)
+			req := httptest.NewRequest(http.MethodPost, "/push", bytes.NewReader(body))
+			if tt.token != "" {
+				req.Header.Set("X-Site-Token", tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("状态码=%d，期望%d：%s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+	if state := pushStates["site-a"]; state == nil || state.Accepted != 1 {
+		t.Fatalf("只应接受携带正确令牌的推送：%+v", state)
+	}
+}
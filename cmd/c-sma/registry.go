@@ -0,0 +1,388 @@
+package main
+
+import (
+	"cmas-cats-go/config"
+	"cmas-cats-go/models"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeartbeatInterval   = 5 * time.Second  // 建议站点的心跳间隔（注册响应中返回）
+	SiteSuspectAfter    = 15 * time.Second // 超过该时长无心跳标记为 suspect
+	SiteDeadAfter       = 30 * time.Second // 超过该时长无心跳标记为 dead 并剔除实例
+	LivenessCheckPeriod = 5 * time.Second
+)
+
+var (
+	siteRegistry  = make(map[string]*models.SiteStatus) // 站点ID → 注册与存活信息
+	registryMutex sync.RWMutex
+	registryStart = time.Now() // 配置站点的宽限期起点
+)
+
+// seedConfiguredSites 将配置中的站点登记为 pending：注册前仍按配置拉取，拉取成功即视为存活（兼容不发送心跳的旧版本站点），
+// 自启动起超过 SiteDeadAfter 既未注册也未拉取成功则判定失联
+func seedConfiguredSites() {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	for _, site := range selfCfg.SitesFor() {
+		siteRegistry[site.ID] = &models.SiteStatus{
+			SiteRegistration: models.SiteRegistration{
+				SiteID:        site.ID,
+				URL:           site.URL,
+				TotalResource: site.TotalResource,
+			},
+			Source:     "config",
+			State:      models.SiteStatePending,
+			StateSince: registryStart,
+		}
+	}
+}
+
+// isRegistered 站点是否在注册表中且未失联（仅接受这些站点的推送）
+func isRegistered(siteID string) bool {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	site, ok := siteRegistry[siteID]
+	return ok && site.State != models.SiteStateDead
+}
+
+// pollTargets 需要拉取的站点，按ID排序：运行时注册的站点失联后不再拉取（等待其重新注册），
+// 配置中的站点始终拉取，以便不注册的旧版本站点恢复后重新上线
+func pollTargets() []models.SiteStatus {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	var targets []models.SiteStatus
+	for _, site := range siteRegistry {
+		if site.State != models.SiteStateDead || site.Source == "config" {
+			targets = append(targets, *site)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].SiteID < targets[j].SiteID })
+	return targets
+}
+
+// siteCounts 注册表中的站点总数与存活（alive）数
+func siteCounts() (total, alive int) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	for _, site := range siteRegistry {
+		if site.State == models.SiteStateAlive {
+			alive++
+		}
+	}
+	return len(siteRegistry), alive
+}
+
+// setState 切换站点状态（调用方持有 registryMutex 写锁）
+func setState(site *models.SiteStatus, state string) {
+	if site.State == state {
+		return
+	}
+	fmt.Printf("[%s] 🔄 站点 [%s] 状态：%s → %s\n",
+		time.Now().Format("2006-01-02 15:04:05"), site.SiteID, site.State, state)
+	site.State = state
+	site.StateSince = time.Now()
+}
+
+// markPolled 记录一次成功拉取：刷新存活时间，已判定失联的配置站点恢复（未注册过的回到 pending）
+func markPolled(siteID string) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	site, ok := siteRegistry[siteID]
+	if !ok {
+		return
+	}
+	site.LastPolled = time.Now()
+	if site.State == models.SiteStateDead {
+		if site.RegisteredAt.IsZero() {
+			setState(site, models.SiteStatePending)
+		} else {
+			setState(site, models.SiteStateAlive)
+		}
+	}
+}
+
+// startLivenessChecker 周期性根据心跳与成功拉取的时间更新站点状态，失联站点的实例从聚合数据中剔除，并发布过期状态变化
+func startLivenessChecker() {
+	ticker := time.NewTicker(LivenessCheckPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		var evicted []string
+		registryMutex.Lock()
+		for _, site := range siteRegistry {
+			if site.State == models.SiteStateDead {
+				continue
+			}
+			last := site.LastHeartbeat
+			if site.State == models.SiteStatePending {
+				last = registryStart
+			}
+			if site.LastPolled.After(last) {
+				last = site.LastPolled
+			}
+			silence := time.Since(last)
+			switch {
+			case silence > SiteDeadAfter:
+				setState(site, models.SiteStateDead)
+				evicted = append(evicted, site.SiteID)
+			case silence > SiteSuspectAfter && site.State == models.SiteStateAlive:
+				setState(site, models.SiteStateSuspect)
+			case silence <= SiteSuspectAfter && site.State == models.SiteStateSuspect:
+				setState(site, models.SiteStateAlive) // 心跳中断但拉取仍成功
+			}
+		}
+		registryMutex.Unlock()
+
+		if len(evicted) > 0 {
+			metricsMutex.Lock()
+			for _, siteID := range evicted {
+				n := removeSiteInstances(siteID)
+				fmt.Printf("🗑️ 站点 [%s] 已失联，剔除 %d 个实例\n", siteID, n)
+			}
+			metricsMutex.Unlock()
+		}
+		// 每轮都发布一次：实例随时间过期（stale）也需要下发给订阅者
+		publishChanges()
+	}
+}
+
+// authenticateSite 核对站点身份：站点须在配置中且配置了令牌，请求须携带匹配的 X-Site-Token；
+// 未通过时写入错误响应并返回 nil
+func authenticateSite(c *gin.Context, siteID string) *config.SiteConfig {
+	site, err := config.Site(siteID)
+	if siteID == "" || err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("站点%s不在配置中", siteID),
+		})
+		return nil
+	}
+	if site.Token == "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("站点%s未配置令牌，只能由C-SMA定时拉取", siteID),
+		})
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Site-Token")), []byte(site.Token)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("站点%s的X-Site-Token无效", siteID),
+		})
+		return nil
+	}
+	return site
+}
+
+// ------------------------------
+// 接口
+// ------------------------------
+
+// siteReport 站点数据最近一次更新（拉取或推送）
+type siteReport struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	Via       string    `json:"via"` // poll / push
+}
+
+// getSiteMetricsHandler：GET /sites/:id/metrics —— 单个站点的实例视图（含未通过部署验证、不向C-PS通告的实例）
+func getSiteMetricsHandler(c *gin.Context) {
+	siteID := c.Param("id")
+
+	registryMutex.RLock()
+	site, known := siteRegistry[siteID]
+	var status models.SiteStatus
+	if known {
+		status = *site
+	}
+	registryMutex.RUnlock()
+
+	metricsMutex.RLock()
+	instances, reported := siteInstances[siteID]
+	report := siteReports[siteID]
+	instances = append([]models.ServiceInstanceInfo(nil), instances...)
+	metricsMutex.RUnlock()
+
+	if !known && !reported {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "未知站点：" + siteID,
+		})
+		return
+	}
+
+	type instanceView struct {
+		models.ServiceInstanceInfo
+		Advertised bool `json:"advertised"` // 是否通过 /sync 向C-PS通告（通过部署验证且未过期）
+	}
+	views := make([]instanceView, 0, len(instances))
+	totalGas := 0
+	for _, inst := range instances {
+		inst.Stale = isStale(inst)
+		views = append(views, instanceView{ServiceInstanceInfo: inst, Advertised: isAdvertisable(inst) && !inst.Stale})
+		totalGas += inst.Gas
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"site_id":   siteID,
+		"state":     status.State,
+		"url":       status.URL,
+		"count":     len(views),
+		"total_gas": totalGas,
+		"updated":   report,
+		"instances": views,
+	})
+}
+
+// registerSiteHandler：POST /sites/register —— 站点启动时注册（重复注册视为重新上线）；
+// 须携带该站点配置的令牌，注册地址须与配置中的站点地址一致
+func registerSiteHandler(c *gin.Context) {
+	var reg models.SiteRegistration
+	if err := c.ShouldBindJSON(&reg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+	reg.URL = strings.TrimRight(reg.URL, "/")
+	if reg.SiteID == "" || !strings.HasPrefix(reg.URL, "http") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "site_id不能为空，url必须为http(s)地址",
+		})
+		return
+	}
+	cfg := authenticateSite(c, reg.SiteID)
+	if cfg == nil {
+		fmt.Printf("⚠️ 拒绝站点 [%s] 注册：%s\n", reg.SiteID, reg.URL)
+		return
+	}
+	if reg.URL != cfg.URL {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("站点%s的注册地址%s与配置中的地址%s不一致", reg.SiteID, reg.URL, cfg.URL),
+		})
+		return
+	}
+
+	now := time.Now()
+	registryMutex.Lock()
+	site, ok := siteRegistry[reg.SiteID]
+	if !ok {
+		site = &models.SiteStatus{Source: "registered", State: models.SiteStateAlive, StateSince: now}
+		siteRegistry[reg.SiteID] = site
+	}
+	site.SiteRegistration = reg
+	site.RegisteredAt = now
+	site.LastHeartbeat = now
+	setState(site, models.SiteStateAlive)
+	status := *site
+	registryMutex.Unlock()
+
+	fmt.Printf("[%s] 🆕 站点 [%s] 注册：%s（总资源%d）\n",
+		now.Format("2006-01-02 15:04:05"), reg.SiteID, reg.URL, reg.TotalResource)
+	c.JSON(http.StatusOK, gin.H{
+		"success":                    true,
+		"message":                    "注册成功",
+		"site":                       status,
+		"heartbeat_interval_seconds": int(HeartbeatInterval.Seconds()),
+	})
+}
+
+// heartbeatHandler：POST /sites/:id/heartbeat —— 站点心跳（附带资源占用，须携带站点令牌）；
+// 未注册或已判定失联的站点返回 404，站点收到后应重新注册
+func heartbeatHandler(c *gin.Context) {
+	siteID := c.Param("id")
+	if authenticateSite(c, siteID) == nil {
+		return
+	}
+	var reg models.SiteRegistration
+	if err := c.ShouldBindJSON(&reg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	site, ok := siteRegistry[siteID]
+	if !ok || site.State == models.SiteStateDead || site.State == models.SiteStatePending {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("站点%s未注册或已被判定失联，请重新注册", siteID),
+		})
+		return
+	}
+	site.LastHeartbeat = time.Now()
+	site.UsedResource = reg.UsedResource
+	site.Deployments = reg.Deployments
+	if reg.TotalResource > 0 {
+		site.TotalResource = reg.TotalResource
+	}
+	setState(site, models.SiteStateAlive)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"state":   site.State,
+	})
+}
+
+// listSitesHandler：GET /sites —— 已知站点及其存活状态（支持 ?state=）
+func listSitesHandler(c *gin.Context) {
+	state := c.Query("state")
+
+	registryMutex.RLock()
+	sites := make([]models.SiteStatus, 0, len(siteRegistry))
+	for _, site := range siteRegistry {
+		if state == "" || site.State == state {
+			sites = append(sites, *site)
+		}
+	}
+	registryMutex.RUnlock()
+	sort.Slice(sites, func(i, j int) bool { return sites[i].SiteID < sites[j].SiteID })
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"count":   len(sites),
+		"sites":   sites,
+		"thresholds": gin.H{
+			"heartbeat_interval_seconds": int(HeartbeatInterval.Seconds()),
+			"suspect_after_seconds":      int(SiteSuspectAfter.Seconds()),
+			"dead_after_seconds":         int(SiteDeadAfter.Seconds()),
+		},
+	})
+}
+
+// getSiteHandler：GET /sites/:id
+func getSiteHandler(c *gin.Context) {
+	siteID := c.Param("id")
+	registryMutex.RLock()
+	site, ok := siteRegistry[siteID]
+	var status models.SiteStatus
+	if ok {
+		status = *site
+	}
+	registryMutex.RUnlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "未知站点：" + siteID,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"site":    status,
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cmas-cats-go/config"
+	"cmas-cats-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupRegistry(t *testing.T) *gin.Engine {
+	t.Helper()
+	saved := config.Cfg
+	config.Cfg.Sites = []config.SiteConfig{
+		{ID: "site-a", Endpoint: config.Endpoint{URL: "http://127.0.0.1:9001"}, Token: "token-a"},
+		{ID: "site-b", Endpoint: config.Endpoint{URL: "http://127.0.0.1:9002"}},
+	}
+	siteRegistry = make(map[string]*models.SiteStatus)
+	t.Cleanup(func() {
+		config.Cfg = saved
+		siteRegistry = make(map[string]*models.SiteStatus)
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/sites/register", registerSiteHandler)
+	r.POST("/sites/:id/heartbeat", heartbeatHandler)
+	return r
+}
+
+func postSite(r *gin.Engine, path, token string, reg models.SiteRegistration) int {
+	body, _ := json.Marshal(reg)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Site-Token", token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRegisterSiteRequiresToken(t *testing.T) {
+	r := setupRegistry(t)
+	tests := []struct {
+		name   string
+		reg    models.SiteRegistration
+		token  string
+		status int
+	}{
+		{"未配置的站点", models.SiteRegistration{SiteID: "site-x", URL: "http://127.0.0.1:9009"}, "token-a", http.StatusForbidden},
+		{"站点未配置令牌", models.SiteRegistration{SiteID: "site-b", URL: "http://127.0.0.1:9002"}, "", http.StatusForbidden},
+		{"缺少令牌", models.SiteRegistration{SiteID: "site-a", URL: "http://127.0.0.1:9001"}, "", http.StatusUnauthorized},
+		{"令牌错误", models.SiteRegistration{SiteID: "site-a", URL: "http://127.0.0.1:9001"}, "token-b", http.StatusUnauthorized},
+		{"地址与配置不一致", models.SiteRegistration{SiteID: "site-a", URL: "http://10.0.0.1:9001"}, "token-a", http.StatusConflict},
+		{"通过", models.SiteRegistration{SiteID: "site-a", URL: "http://127.0.0.1:9001"}, "token-a", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postSite(r, "/sites/register", tt.token, tt.reg); got != tt.status {
+				t.Fatalf("状态码=%d，期望%d", got, tt.status)
+			}
+		})
+	}
+	if site := siteRegistry["site-a"]; site == nil || site.URL != "http://127.0.0.1:9001" {
+		t.Fatalf("注册表中的站点地址被篡改：%+v", site)
+	}
+}
+
+func TestHeartbeatRequiresToken(t *testing.T) {
+	r := setupRegistry(t)
+	reg := models.SiteRegistration{SiteID: "site-a", URL: "http://127.0.0.1:9001"}
+	if got := postSite(r, "/sites/register", "token-a", reg); got != http.StatusOK {
+		t.Fatalf("注册失败：状态码%d", got)
+	}
+	reg.UsedResource = 10
+	if got := postSite(r, "/sites/site-a/heartbeat", "token-b", reg); got != http.StatusUnauthorized {
+		t.Fatalf("令牌错误的心跳：状态码=%d，期望401", got)
+	}
+	if siteRegistry["site-a"].UsedResource != 0 {
+		t.Fatalf("令牌错误的心跳不应更新资源占用")
+	}
+	if got := postSite(r, "/sites/site-a/heartbeat", "token-a", reg); got != http.StatusOK {
+		t.Fatalf("心跳：状态码=%d，期望200", got)
+	}
+	if siteRegistry["site-a"].UsedResource != 10 {
+		t.Fatalf("心跳未更新资源占用")
+	}
+}
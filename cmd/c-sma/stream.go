@@ -0,0 +1,186 @@
+package main
+
+import (
+	"cmas-cats-go/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StreamHeartbeat = 15 * time.Second // 订阅流心跳间隔（SSE注释行），订阅方据此判断连接存活
+	StreamBuffer    = 64               // 每个订阅者的事件缓冲；写满说明订阅方过慢，断开后由其重连并重新获取全量
+)
+
+var (
+	streamID      = time.Now().UnixNano()                  // 本进程的流标识
+	streamVersion int64                                    // 已发布的最新版本
+	streamView    = make(map[string]models.ServiceSummary) // 最近一次发布时的通告视图（服务ID → 汇总）
+	subscribers   = make(map[chan models.MetricsStreamEvent]struct{})
+	streamMutex   sync.Mutex // 加锁顺序：streamMutex → metricsMutex
+)
+
+// publishChanges 对比当前通告视图与上次发布的视图，有变化时向所有订阅者广播增量事件；
+// 在聚合数据或验证快照变化后调用，调用方不得持有 metricsMutex
+func publishChanges() {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+
+	metricsMutex.RLock()
+	current, _, _ := advertisedServices(true) // 过期实例带 stale 标记下发，由C-PS按客户端意愿决定是否使用
+	metricsMutex.RUnlock()
+
+	next := make(map[string]models.ServiceSummary, len(current))
+	var changed []models.ServiceSummary
+	for _, svc := range current {
+		sortInstances(svc.Instances)
+		next[svc.ServiceID] = svc
+		if old, ok := streamView[svc.ServiceID]; !ok || !sameSummary(old, svc) {
+			changed = append(changed, svc)
+		}
+	}
+	var removed []string
+	for serviceID := range streamView {
+		if _, ok := next[serviceID]; !ok {
+			removed = append(removed, serviceID)
+		}
+	}
+	streamView = next
+	if len(changed) == 0 && len(removed) == 0 {
+		return
+	}
+
+	streamVersion++
+	broadcast(models.MetricsStreamEvent{
+		Type:     models.StreamEventUpdate,
+		StreamID: streamID,
+		Version:  streamVersion,
+		Services: changed,
+		Removed:  removed,
+		Time:     time.Now(),
+	})
+	fmt.Printf("[%s] 📡 订阅流版本%d：%d个服务变化，%d个服务移除，推送给%d个订阅者\n",
+		time.Now().Format("2006-01-02 15:04:05"), streamVersion, len(changed), len(removed), len(subscribers))
+}
+
+// broadcast 非阻塞地发送事件，缓冲已满的订阅者被断开（调用方持有 streamMutex）
+func broadcast(ev models.MetricsStreamEvent) {
+	for ch := range subscribers {
+		select {
+		case ch <- ev:
+		default:
+			delete(subscribers, ch)
+			close(ch)
+			fmt.Println("⚠️ 订阅者消费过慢，已断开（将重连并重新获取全量）")
+		}
+	}
+}
+
+// sameSummary 比较两个服务汇总是否相同（忽略 last_seen：仅确认时间变化不必下发，过期状态由 stale 标记体现）
+func sameSummary(a, b models.ServiceSummary) bool {
+	if len(a.Instances) != len(b.Instances) {
+		return false
+	}
+	strip := func(s models.ServiceSummary) models.ServiceSummary {
+		instances := make([]models.ServiceInstanceInfo, len(s.Instances))
+		for i, inst := range s.Instances {
+			inst.LastSeen = time.Time{}
+			instances[i] = inst
+		}
+		s.Instances = instances
+		return s
+	}
+	return reflect.DeepEqual(strip(a), strip(b))
+}
+
+// sortInstances 按CSCI_ID排序，保证视图比较不受聚合顺序影响
+func sortInstances(instances []models.ServiceInstanceInfo) {
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].CSCI_ID < instances[j].CSCI_ID
+	})
+}
+
+// subscribeHandler：GET /subscribe —— SSE订阅：先发送全量快照，之后推送增量更新
+func subscribeHandler(c *gin.Context) {
+	publishChanges() // 先把视图追平，快照与后续增量的版本才能衔接
+
+	ch := make(chan models.MetricsStreamEvent, StreamBuffer)
+	streamMutex.Lock()
+	snapshot := models.MetricsStreamEvent{
+		Type:     models.StreamEventSnapshot,
+		StreamID: streamID,
+		Version:  streamVersion,
+		Services: make([]models.ServiceSummary, 0, len(streamView)),
+		Time:     time.Now(),
+
+		InstanceTTLSeconds: selfCfg.InstanceTTLSeconds,
+	}
+	for _, svc := range streamView {
+		snapshot.Services = append(snapshot.Services, svc)
+	}
+	subscribers[ch] = struct{}{}
+	total := len(subscribers)
+	streamMutex.Unlock()
+
+	defer func() {
+		streamMutex.Lock()
+		if _, ok := subscribers[ch]; ok {
+			delete(subscribers, ch)
+			close(ch)
+		}
+		streamMutex.Unlock()
+	}()
+
+	fmt.Printf("[%s] 🔗 新订阅者 %s（当前%d个），发送版本%d全量快照\n",
+		time.Now().Format("2006-01-02 15:04:05"), c.ClientIP(), total, snapshot.Version)
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := writeStreamEvent(w, snapshot); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(StreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return // 被断开（消费过慢）
+			}
+			if err := writeStreamEvent(w, ev); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprintf(w, ": ping %d\n\n", time.Now().Unix()); err != nil {
+				return
+			}
+			w.Flush()
+		}
+	}
+}
+
+// writeStreamEvent 按SSE格式写出一条事件
+func writeStreamEvent(w gin.ResponseWriter, ev models.MetricsStreamEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", ev.Type, ev.Version, data); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"cmas-cats-go/models"
+	"cmas-cats-go/telemetry"
+	"time"
+)
+
+// C-SMA 运维指标（GET /prometheus）
+var (
+	pollsTotal        = telemetry.NewCounter("cmas_sma_polls_total", "拉取次数（站点或下级C-SMA，按结果）", "target", "kind", "result")
+	pollDuration      = telemetry.NewHistogram("cmas_sma_poll_duration_seconds", "单次拉取耗时（秒）", nil, "target", "kind")
+	pollLastSuccess   = telemetry.NewGauge("cmas_sma_poll_last_success_timestamp_seconds", "最近一次成功拉取的时间（Unix秒）", "target", "kind")
+	pollRoundDuration = telemetry.NewGauge("cmas_sma_poll_round_duration_seconds", "最近一轮拉取耗时（秒）")
+
+	pushesTotal        = telemetry.NewCounter("cmas_sma_pushes_total", "站点推送事件数（applied 已应用 / stale 乱序丢弃）", "site_id", "result")
+	instancesGauge     = telemetry.NewGauge("cmas_sma_instances", "聚合实例数", "service_id", "site_id")
+	staleGauge         = telemetry.NewGauge("cmas_sma_stale_instances", "已过期（超过TTL未确认）的实例数", "service_id", "site_id")
+	gasGauge           = telemetry.NewGauge("cmas_sma_instance_gas", "实例剩余gas合计", "service_id", "site_id")
+	capacityGauge      = telemetry.NewGauge("cmas_sma_instance_capacity", "实例部署总gas合计", "service_id", "site_id")
+	sitesGauge         = telemetry.NewGauge("cmas_sma_sites", "注册表中的站点数（按存活状态）", "state")
+	subscribersGauge   = telemetry.NewGauge("cmas_sma_stream_subscribers", "订阅流订阅者数")
+	streamVersionGauge = telemetry.NewGauge("cmas_sma_stream_version", "订阅流已发布的最新版本")
+)
+
+func init() {
+	telemetry.OnScrape(collectSMAMetrics)
+}
+
+// observePolls 记录一轮拉取中各目标的结果
+func observePolls(results []pollResult, round time.Duration) {
+	now := float64(time.Now().Unix())
+	for _, r := range results {
+		result := "success"
+		if !r.Success {
+			result = "failure"
+		}
+		pollsTotal.Inc(r.Target, r.Kind, result)
+		pollDuration.Observe(r.Latency.Seconds(), r.Target, r.Kind)
+		if r.Success {
+			pollLastSuccess.Set(now, r.Target, r.Kind)
+		}
+	}
+	pollRoundDuration.Set(round.Seconds())
+}
+
+// collectSMAMetrics 抓取时由内存状态重建仪表
+func collectSMAMetrics() {
+	for _, g := range []*telemetry.Vec{instancesGauge, staleGauge, gasGauge, capacityGauge, sitesGauge} {
+		g.Reset()
+	}
+
+	metricsMutex.RLock()
+	for serviceID, instances := range aggregatedMetrics {
+		for _, inst := range instances {
+			instancesGauge.Add(1, serviceID, inst.SiteID)
+			gasGauge.Add(float64(inst.Gas), serviceID, inst.SiteID)
+			capacityGauge.Add(float64(inst.Capacity), serviceID, inst.SiteID)
+			if isStale(inst) {
+				staleGauge.Add(1, serviceID, inst.SiteID)
+			}
+		}
+	}
+	for siteID, state := range pushStates {
+		pushesTotal.Set(float64(state.Accepted), siteID, "applied")
+		pushesTotal.Set(float64(state.Stale), siteID, "stale")
+	}
+	metricsMutex.RUnlock()
+
+	registryMutex.RLock()
+	for _, state := range []string{models.SiteStatePending, models.SiteStateAlive, models.SiteStateSuspect, models.SiteStateDead} {
+		sitesGauge.Set(0, state)
+	}
+	for _, site := range siteRegistry {
+		sitesGauge.Add(1, site.State)
+	}
+	registryMutex.RUnlock()
+
+	streamMutex.Lock()
+	subscribersGauge.Set(float64(len(subscribers)))
+	streamVersionGauge.Set(float64(streamVersion))
+	streamMutex.Unlock()
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"cmas-cats-go/config"
 	"cmas-cats-go/models"
+	"cmas-cats-go/telemetry"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -24,6 +26,17 @@ func main() {
 	fmt.Println("            公共服务平台启动中...            ")
 	fmt.Println("=====================================")
 
+	// 0. 加载拓扑配置（配置文件 + 环境变量 + 命令行参数）
+	flags := config.BindFlags()
+	flag.Parse()
+	if err := flags.Load(); err != nil {
+		fmt.Printf("❌ 加载配置失败，程序退出：%v\n", err)
+		return
+	}
+	if flags.Port > 0 {
+		config.Cfg.Platform.OverridePort(flags.Port)
+	}
+
 	// 1. 初始化数据库（带详细日志）
 	if err := initDB(); err != nil {
 		fmt.Printf("❌ 初始化失败，程序退出：%v\n", err)
@@ -37,15 +50,24 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // 允许所有来源 (如果知道前端地址，可以写死，但 * 最方便)
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-API-Key"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-API-Key", "X-Site-Token"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
+	telemetry.Register(r, "platform", "platform") // 运维指标（GET /prometheus）
 	// 3. 注册API路由
-	r.POST("/api/v1/services", registerServiceHandler)   // 注册服务
-	r.GET("/api/v1/services", getServicesHandler)        // 获取所有服务
-	r.GET("/api/v1/services/:id", getServiceByIDHandler) // 获取单个服务详情
+	r.POST("/api/v1/services", registerServiceHandler)     // 注册服务
+	r.GET("/api/v1/services", getServicesHandler)          // 获取所有服务
+	r.GET("/api/v1/services/:id", getServiceByIDHandler)   // 获取单个服务详情
+	r.PUT("/api/v1/services/:id", updateServiceHandler)    // 整体更新服务
+	r.PATCH("/api/v1/services/:id", patchServiceHandler)   // 部分更新服务（含生命周期状态变更）
+	r.DELETE("/api/v1/services/:id", deleteServiceHandler) // 删除服务
+
+	// 部署验证：站点提交样本输出，平台比对并记录验证结果
+	r.POST("/validate", validateDeploymentHandler)                              // 提交部署验证
+	r.GET("/api/v1/services/:id/validation-sample", getValidationSampleHandler) // 获取验证样本（不含预期结果）
+	r.GET("/api/v1/validations", getValidationsHandler)                         // 查询验证结果快照
 
 	// 4. 添加简单的Web界面
 	r.LoadHTMLGlob("./templates/platform/*.html")
@@ -73,8 +95,8 @@ func main() {
 	})
 
 	// 5. 启动服务配置
-	// 实际监听地址必须使用 config.LOCAL_LISTEN_IP ("0.0.0.0")
-	listenAddr := config.LOCAL_LISTEN_IP + ":" + strconv.Itoa(config.Cfg.Platform.Port)
+	// 实际监听地址必须使用 config.Cfg.ListenIP ("0.0.0.0")
+	listenAddr := config.Cfg.ListenIP + ":" + strconv.Itoa(config.Cfg.Platform.Port)
 
 	// 外部展示地址
 	externalListenAddr := fmt.Sprintf("http://%s:%d", config.Cfg.Platform.IP, config.Cfg.Platform.Port)
@@ -86,6 +108,17 @@ func main() {
 	fmt.Printf("    - POST    /api/v1/services          注册服务\n")
 	fmt.Printf("    - GET      /api/v1/services          获取所有服务\n")
 	fmt.Printf("    - GET      /api/v1/services/:id    获取单个服务详情\n")
+	fmt.Printf("    - PUT      /api/v1/services/:id    整体更新服务\n")
+	fmt.Printf("    - PATCH    /api/v1/services/:id    部分更新服务/变更生命周期状态\n")
+	fmt.Printf("    - DELETE   /api/v1/services/:id    删除服务（仅 draft/retired，或 ?force=true）\n")
+	fmt.Printf("    - POST     /validate                 提交部署验证结果\n")
+	fmt.Printf("    - GET      /api/v1/services/:id/validation-sample  获取验证样本\n")
+	fmt.Printf("    - GET      /api/v1/validations       查询验证结果快照\n")
+	for _, site := range config.Cfg.Sites {
+		if site.Token == "" {
+			fmt.Printf("⚠️ 站点%s未配置令牌（token），其部署验证提交仅按站点ID核对\n", site.ID)
+		}
+	}
 
 	// ❗ 修复：使用 r 实例启动HTTP服务（带错误处理） ❗
 	if err := r.Run(listenAddr); err != nil {
@@ -125,37 +158,317 @@ func initDB() error {
 		software_dependency TEXT, -- 存储JSON数组字符串
 		created_at DATETIME NOT NULL, -- 对应time.Time类型
 		validation_sample TEXT,
-		validation_result TEXT
+		validation_result TEXT,
+		status TEXT NOT NULL DEFAULT 'published', -- 生命周期状态
+		updated_at DATETIME,
+		requirements TEXT -- 结构化资源需求（JSON）
 	);`
 	_, err = db.Exec(createTableSQL)
 	if err != nil {
 		return fmt.Errorf("创建services表失败：%w", err)
 	}
 
+	// 4. 兼容旧库：补充生命周期相关字段（已有服务默认视为已发布）
+	if err := ensureColumn("services", "status", "TEXT NOT NULL DEFAULT '"+models.ServiceStatusPublished+"'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("services", "updated_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := ensureColumn("services", "requirements", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`UPDATE services SET updated_at = created_at WHERE updated_at IS NULL`); err != nil {
+		return fmt.Errorf("初始化updated_at失败：%w", err)
+	}
+	if err := runMigration("default_requirements", migrateDefaultRequirements); err != nil {
+		return err
+	}
+
+	// 5. 创建部署验证结果表（每个站点+服务仅保留最近一次验证）
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS validations (
+		site_id TEXT NOT NULL,
+		service_id TEXT NOT NULL,
+		passed INT NOT NULL,
+		submitted_result TEXT,
+		message TEXT,
+		validated_at DATETIME NOT NULL,
+		PRIMARY KEY (site_id, service_id)
+	);`)
+	if err != nil {
+		return fmt.Errorf("创建validations表失败：%w", err)
+	}
+
 	fmt.Println("✅ 数据库初始化成功（SQLite）")
 	return nil
 }
 
-// registerServiceHandler：处理服务注册请求 (保持不变)
+// ensureColumn：旧库缺少字段时执行 ALTER TABLE 补齐
+func ensureColumn(table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("读取%s表结构失败：%w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("解析%s表结构失败：%w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("为%s表添加字段%s失败：%w", table, column, err)
+	}
+	fmt.Printf("✅ 已为%s表添加字段：%s\n", table, column)
+	return nil
+}
+
+// runMigration：执行一次性数据迁移，已执行过的迁移（记录在 migrations 表）直接跳过
+func runMigration(name string, migrate func(tx *sql.Tx) (int64, error)) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS migrations (
+		name TEXT PRIMARY KEY,
+		applied_at DATETIME NOT NULL
+	);`); err != nil {
+		return fmt.Errorf("创建migrations表失败：%w", err)
+	}
+	var applied int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM migrations WHERE name = ?`, name).Scan(&applied); err != nil {
+		return fmt.Errorf("查询迁移%s失败：%w", name, err)
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("迁移%s失败：%w", name, err)
+	}
+	defer tx.Rollback()
+	n, err := migrate(tx)
+	if err != nil {
+		return fmt.Errorf("迁移%s失败：%w", name, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO migrations (name, applied_at) VALUES (?, ?)`, name, time.Now()); err != nil {
+		return fmt.Errorf("记录迁移%s失败：%w", name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("迁移%s失败：%w", name, err)
+	}
+	fmt.Printf("✅ 已执行数据迁移%s（更新%d条记录）\n", name, n)
+	return nil
+}
+
+// migrateDefaultRequirements：为尚未登记结构化资源需求的旧服务补齐默认需求 models.DefaultRequirements，
+// 站点从此只依据 requirements 计算资源占用；需求不同的服务需通过 PATCH 调整
+func migrateDefaultRequirements(tx *sql.Tx) (int64, error) {
+	data, err := json.Marshal(models.DefaultRequirements)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`UPDATE services SET requirements = ? WHERE requirements IS NULL OR requirements = ''`,
+		string(data))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// serviceColumns 查询服务时统一使用的字段顺序（与 scanService 对应）
+const serviceColumns = `id, name, description, input_format, computing_requirement,
+			   storage_requirement, computing_time, code_location,
+			   software_dependency, created_at, updated_at, status,
+			   validation_sample, validation_result, requirements`
+
+// rowScanner 兼容 *sql.Row 与 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanService：按 serviceColumns 的顺序解析一行服务数据
+func scanService(row rowScanner) (models.Service, error) {
+	var s models.Service
+	var depsJSON string
+	var updatedAt sql.NullTime
+	var sample, result, requirements sql.NullString
+
+	err := row.Scan(
+		&s.ID, &s.Name, &s.Description, &s.InputFormat, &s.ComputingRequirement,
+		&s.StorageRequirement, &s.ComputingTime, &s.CodeLocation,
+		&depsJSON, &s.CreatedAt, &updatedAt, &s.Status,
+		&sample, &result, &requirements,
+	)
+	if err != nil {
+		return s, err
+	}
+
+	// 反序列化软件依赖
+	if err := json.Unmarshal([]byte(depsJSON), &s.SoftwareDependency); err != nil {
+		s.SoftwareDependency = []string{} // 默认为空列表
+	}
+	if updatedAt.Valid {
+		s.UpdatedAt = updatedAt.Time
+	} else {
+		s.UpdatedAt = s.CreatedAt
+	}
+	s.ValidationSample = sample.String
+	s.ValidationResult = result.String
+
+	// 反序列化结构化资源需求（旧数据可能为空）
+	if requirements.String != "" {
+		var req models.ResourceRequirements
+		if err := json.Unmarshal([]byte(requirements.String), &req); err != nil {
+			fmt.Printf("⚠️ 解析服务%s资源需求失败：%v\n", s.ID, err)
+		} else {
+			s.Requirements = &req
+		}
+	}
+	return s, nil
+}
+
+// loadService：按ID读取服务（不存在时返回 sql.ErrNoRows）
+func loadService(serviceID string) (models.Service, error) {
+	return scanService(db.QueryRow(`SELECT `+serviceColumns+` FROM services WHERE id = ?`, serviceID))
+}
+
+// saveService：将服务的可变字段写回数据库
+func saveService(s models.Service) error {
+	depsJSON, err := json.Marshal(s.SoftwareDependency)
+	if err != nil {
+		return fmt.Errorf("软件依赖序列化失败：%w", err)
+	}
+	requirementsJSON, err := marshalRequirements(s.Requirements)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		UPDATE services SET
+			name = ?, description = ?, input_format = ?, computing_requirement = ?,
+			storage_requirement = ?, computing_time = ?, code_location = ?,
+			software_dependency = ?, updated_at = ?, status = ?,
+			validation_sample = ?, validation_result = ?, requirements = ?
+		WHERE id = ?`,
+		s.Name, s.Description, s.InputFormat, s.ComputingRequirement,
+		s.StorageRequirement, s.ComputingTime, s.CodeLocation,
+		string(depsJSON), s.UpdatedAt, s.Status,
+		s.ValidationSample, s.ValidationResult, requirementsJSON, s.ID)
+	if err != nil {
+		return fmt.Errorf("数据库错误：%w", err)
+	}
+	return nil
+}
+
+// marshalRequirements：结构化资源需求序列化为JSON（nil 存为 NULL）
+func marshalRequirements(r *models.ResourceRequirements) (interface{}, error) {
+	if r == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("资源需求序列化失败：%w", err)
+	}
+	return string(data), nil
+}
+
+// checkRequirements：校验结构化资源需求（注册与整体更新时必填，站点据此计算资源占用）
+func checkRequirements(r *models.ResourceRequirements) error {
+	if r == nil {
+		return fmt.Errorf("缺少requirements（结构化资源需求）")
+	}
+	if err := r.Validate(); err != nil {
+		return fmt.Errorf("requirements不合法：%w", err)
+	}
+	return nil
+}
+
+// respondServiceLookupError：统一处理按ID查询服务的错误响应
+func respondServiceLookupError(c *gin.Context, serviceID string, err error) {
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "服务不存在（ID：" + serviceID + "）",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"success": false,
+		"message": "查询服务失败：" + err.Error(),
+	})
+}
+
+// serviceRequest 注册/更新请求体：models.Service 的验证字段不对外输出，这里单独接收
+type serviceRequest struct {
+	models.Service
+	ValidationSample string `json:"validation_sample"`
+	ValidationResult string `json:"validation_result"`
+}
+
+// registerServiceHandler：处理服务注册请求
 func registerServiceHandler(c *gin.Context) {
-	var service models.Service
+	var req serviceRequest
 
 	// 1. 解析请求体
-	if err := c.ShouldBindJSON(&service); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": "请求格式错误：" + err.Error(),
 		})
 		return
 	}
+	service := req.Service
+	service.ValidationSample = req.ValidationSample
+	service.ValidationResult = req.ValidationResult
 
 	// 2. 生成服务ID（确保唯一性）
 	service.ID = "AR" + fmt.Sprintf("%d", time.Now().UnixNano()/1e6) // 毫秒级时间戳
 
 	// 3. 设置创建时间（time.Time类型，修复类型错误）
 	service.CreatedAt = time.Now()
+	service.UpdatedAt = service.CreatedAt
 
-	// 4. 序列化软件依赖列表（[]string → JSON字符串）
+	// 4. 生命周期状态：未指定时直接发布（兼容原有“注册即可部署”的流程）
+	if service.Status == "" {
+		service.Status = models.ServiceStatusPublished
+	}
+	if !models.IsValidServiceStatus(service.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "未知的服务状态：" + service.Status,
+		})
+		return
+	}
+
+	// 5. 校验结构化资源需求
+	if err := checkRequirements(service.Requirements); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	requirementsJSON, err := marshalRequirements(service.Requirements)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// 6. 序列化软件依赖列表（[]string → JSON字符串）
 	depsJSON, err := json.Marshal(service.SoftwareDependency)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -165,17 +478,18 @@ func registerServiceHandler(c *gin.Context) {
 		return
 	}
 
-	// 5. 插入数据库
+	// 7. 插入数据库
 	_, err = db.Exec(`
 		INSERT INTO services (
 			id, name, description, input_format, computing_requirement,
 			storage_requirement, computing_time, code_location, software_dependency,
-			created_at, validation_sample, validation_result
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			created_at, validation_sample, validation_result, status, updated_at, requirements
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		service.ID, service.Name, service.Description, service.InputFormat,
 		service.ComputingRequirement, service.StorageRequirement, service.ComputingTime,
 		service.CodeLocation, string(depsJSON), service.CreatedAt, // 正确传入time.Time类型
-		service.ValidationSample, service.ValidationResult)
+		service.ValidationSample, service.ValidationResult, service.Status, service.UpdatedAt,
+		requirementsJSON)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -185,26 +499,40 @@ func registerServiceHandler(c *gin.Context) {
 		return
 	}
 
-	// 6. 返回成功响应
+	// 8. 返回成功响应
 	c.JSON(http.StatusOK, gin.H{
-		"success":    true,
-		"message":    "服务注册成功",
-		"service_id": service.ID,
-		"created_at": service.CreatedAt.Format(time.RFC3339), // 响应时转为字符串
+		"success":           true,
+		"message":           "服务注册成功",
+		"service_id":        service.ID,
+		"status":            service.Status,
+		"created_at":        service.CreatedAt.Format(time.RFC3339), // 响应时转为字符串
+		"resource_per_inst": service.Requirements.UnitsPerInstance(),
 	})
-	fmt.Printf("[%s] 服务注册成功：ID=%s, 名称=%s\n",
-		time.Now().Format("15:04:05"), service.ID, service.Name)
+	fmt.Printf("[%s] 服务注册成功：ID=%s, 名称=%s, 状态=%s\n",
+		time.Now().Format("15:04:05"), service.ID, service.Name, service.Status)
 }
 
-// getServicesHandler：获取所有服务列表 (保持不变)
+// getServicesHandler：获取所有服务列表（支持 ?status= 过滤）
 func getServicesHandler(c *gin.Context) {
+	statusFilter := c.Query("status")
+	if statusFilter != "" && !models.IsValidServiceStatus(statusFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "未知的服务状态：" + statusFilter,
+		})
+		return
+	}
+
 	// 1. 查询数据库
-	rows, err := db.Query(`
-		SELECT id, name, description, input_format, computing_requirement,
-			   storage_requirement, computing_time, code_location,
-			   software_dependency, created_at
-		FROM services
-		ORDER BY created_at DESC`)
+	query := `SELECT ` + serviceColumns + ` FROM services`
+	args := []interface{}{}
+	if statusFilter != "" {
+		query += ` WHERE status = ?`
+		args = append(args, statusFilter)
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -217,30 +545,11 @@ func getServicesHandler(c *gin.Context) {
 	// 2. 解析结果
 	var services []models.Service
 	for rows.Next() {
-		var s models.Service
-		var depsJSON string     // 数据库中存储的JSON字符串
-		var createdAt time.Time // 从数据库读取的time.Time类型
-
-		// 扫描字段（注意与表结构顺序一致）
-		err := rows.Scan(
-			&s.ID, &s.Name, &s.Description, &s.InputFormat, &s.ComputingRequirement,
-			&s.StorageRequirement, &s.ComputingTime, &s.CodeLocation,
-			&depsJSON, &createdAt,
-		)
+		s, err := scanService(rows)
 		if err != nil {
 			fmt.Printf("⚠️ 解析服务数据失败：%v\n", err)
 			continue
 		}
-
-		// 反序列化软件依赖
-		if err := json.Unmarshal([]byte(depsJSON), &s.SoftwareDependency); err != nil {
-			fmt.Printf("⚠️ 解析依赖列表失败：%v\n", err)
-			s.SoftwareDependency = []string{} // 默认为空列表
-		}
-
-		// 赋值时间字段
-		s.CreatedAt = createdAt
-
 		services = append(services, s)
 	}
 
@@ -252,7 +561,7 @@ func getServicesHandler(c *gin.Context) {
 	})
 }
 
-// getServiceByIDHandler：根据ID获取单个服务详情 (保持不变)
+// getServiceByIDHandler：根据ID获取单个服务详情
 func getServiceByIDHandler(c *gin.Context) {
 	serviceID := c.Param("id")
 	if serviceID == "" {
@@ -264,43 +573,252 @@ func getServiceByIDHandler(c *gin.Context) {
 	}
 
 	// 查询数据库
-	var s models.Service
-	var depsJSON string
-	var createdAt time.Time
+	s, err := loadService(serviceID)
+	if err != nil {
+		respondServiceLookupError(c, serviceID, err)
+		return
+	}
 
-	err := db.QueryRow(`
-		SELECT id, name, description, input_format, computing_requirement,
-			   storage_requirement, computing_time, code_location,
-			   software_dependency, created_at, validation_sample, validation_result
-		FROM services WHERE id = ?`, serviceID).Scan(
-		&s.ID, &s.Name, &s.Description, &s.InputFormat, &s.ComputingRequirement,
-		&s.StorageRequirement, &s.ComputingTime, &s.CodeLocation,
-		&depsJSON, &createdAt, &s.ValidationSample, &s.ValidationResult,
-	)
+	// 返回结果
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"service": s,
+	})
+}
 
-	// 处理查询结果
-	switch {
-	case err == sql.ErrNoRows:
-		c.JSON(http.StatusNotFound, gin.H{
+// updateServiceHandler：整体更新服务（PUT，ID与创建时间不可修改，状态留空则保持不变）
+func updateServiceHandler(c *gin.Context) {
+	serviceID := c.Param("id")
+
+	var body serviceRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "服务不存在（ID：" + serviceID + "）",
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+	req := body.Service
+
+	current, err := loadService(serviceID)
+	if err != nil {
+		respondServiceLookupError(c, serviceID, err)
+		return
+	}
+
+	if req.Status == "" {
+		req.Status = current.Status
+	}
+	if err := checkRequirements(req.Requirements); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := checkStatusTransition(current.Status, req.Status); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": err.Error(),
 		})
 		return
-	case err != nil:
+	}
+
+	// 保留不可修改字段；验证数据未提供时沿用原值
+	req.ID = current.ID
+	req.CreatedAt = current.CreatedAt
+	req.UpdatedAt = time.Now()
+	req.ValidationSample = current.ValidationSample
+	req.ValidationResult = current.ValidationResult
+	if body.ValidationSample != "" || body.ValidationResult != "" {
+		req.ValidationSample = body.ValidationSample
+		req.ValidationResult = body.ValidationResult
+	}
+
+	if err := saveService(req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "查询服务失败：" + err.Error(),
+			"message": "服务更新失败：" + err.Error(),
 		})
 		return
 	}
+	if req.ValidationSample != current.ValidationSample || req.ValidationResult != current.ValidationResult {
+		if err := resetValidations(req.ID); err != nil {
+			fmt.Printf("⚠️ %v\n", err)
+		}
+	}
 
-	// 反序列化依赖列表
-	json.Unmarshal([]byte(depsJSON), &s.SoftwareDependency)
-	s.CreatedAt = createdAt
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "服务更新成功",
+		"service": req,
+	})
+	fmt.Printf("[%s] 服务更新成功：ID=%s, 名称=%s, 状态=%s\n",
+		time.Now().Format("15:04:05"), req.ID, req.Name, req.Status)
+}
+
+// servicePatch PATCH请求体：仅非nil字段会被修改
+type servicePatch struct {
+	Name                 *string                      `json:"name"`
+	Description          *string                      `json:"description"`
+	InputFormat          *string                      `json:"input_format"`
+	ComputingRequirement *string                      `json:"computing_requirement"`
+	StorageRequirement   *string                      `json:"storage_requirement"`
+	ComputingTime        *string                      `json:"computing_time"`
+	CodeLocation         *string                      `json:"code_location"`
+	SoftwareDependency   *[]string                    `json:"software_dependency"`
+	Status               *string                      `json:"status"`
+	ValidationSample     *string                      `json:"validation_sample"`
+	ValidationResult     *string                      `json:"validation_result"`
+	Requirements         *models.ResourceRequirements `json:"requirements"`
+}
+
+// patchServiceHandler：部分更新服务（PATCH），常用于修正CodeLocation或变更生命周期状态
+func patchServiceHandler(c *gin.Context) {
+	serviceID := c.Param("id")
+
+	var patch servicePatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+
+	s, err := loadService(serviceID)
+	if err != nil {
+		respondServiceLookupError(c, serviceID, err)
+		return
+	}
+	previous := s
+
+	if patch.Status != nil {
+		if err := checkStatusTransition(s.Status, *patch.Status); err != nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		s.Status = *patch.Status
+	}
+	if patch.Name != nil {
+		s.Name = *patch.Name
+	}
+	if patch.Description != nil {
+		s.Description = *patch.Description
+	}
+	if patch.InputFormat != nil {
+		s.InputFormat = *patch.InputFormat
+	}
+	if patch.ComputingRequirement != nil {
+		s.ComputingRequirement = *patch.ComputingRequirement
+	}
+	if patch.StorageRequirement != nil {
+		s.StorageRequirement = *patch.StorageRequirement
+	}
+	if patch.ComputingTime != nil {
+		s.ComputingTime = *patch.ComputingTime
+	}
+	if patch.CodeLocation != nil {
+		s.CodeLocation = *patch.CodeLocation
+	}
+	if patch.SoftwareDependency != nil {
+		s.SoftwareDependency = *patch.SoftwareDependency
+	}
+	if patch.Requirements != nil {
+		if err := checkRequirements(patch.Requirements); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		s.Requirements = patch.Requirements
+	}
+	if patch.ValidationSample != nil {
+		s.ValidationSample = *patch.ValidationSample
+	}
+	if patch.ValidationResult != nil {
+		s.ValidationResult = *patch.ValidationResult
+	}
+	s.UpdatedAt = time.Now()
+
+	if err := saveService(s); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "服务更新失败：" + err.Error(),
+		})
+		return
+	}
+	if s.ValidationSample != previous.ValidationSample || s.ValidationResult != previous.ValidationResult {
+		if err := resetValidations(s.ID); err != nil {
+			fmt.Printf("⚠️ %v\n", err)
+		}
+	}
 
-	// 返回结果
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
+		"message": "服务更新成功",
 		"service": s,
 	})
+	if previous.Status != s.Status {
+		fmt.Printf("[%s] 服务状态变更：ID=%s, %s → %s\n",
+			time.Now().Format("15:04:05"), s.ID, previous.Status, s.Status)
+	} else {
+		fmt.Printf("[%s] 服务更新成功：ID=%s, 名称=%s\n",
+			time.Now().Format("15:04:05"), s.ID, s.Name)
+	}
+}
+
+// deleteServiceHandler：删除服务
+// 仅允许删除 draft / retired 状态的服务，避免仍在使用的服务被误删；?force=true 可强制删除（如修正误注册）
+func deleteServiceHandler(c *gin.Context) {
+	serviceID := c.Param("id")
+
+	s, err := loadService(serviceID)
+	if err != nil {
+		respondServiceLookupError(c, serviceID, err)
+		return
+	}
+
+	force := c.Query("force") == "true"
+	if !force && s.Status != models.ServiceStatusDraft && s.Status != models.ServiceStatusRetired {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("服务当前状态为%s，请先将其置为retired后再删除（或使用 ?force=true）", s.Status),
+		})
+		return
+	}
+
+	if _, err := db.Exec(`DELETE FROM services WHERE id = ?`, serviceID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "删除服务失败（数据库错误）：" + err.Error(),
+		})
+		return
+	}
+	if err := resetValidations(serviceID); err != nil {
+		fmt.Printf("⚠️ %v\n", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "服务已删除",
+		"service_id": serviceID,
+	})
+	fmt.Printf("[%s] 服务已删除：ID=%s, 名称=%s（原状态：%s）\n",
+		time.Now().Format("15:04:05"), s.ID, s.Name, s.Status)
+}
+
+// checkStatusTransition：校验生命周期状态迁移是否合法
+func checkStatusTransition(from, to string) error {
+	if !models.IsValidServiceStatus(to) {
+		return fmt.Errorf("未知的服务状态：%s", to)
+	}
+	if !models.CanTransitionServiceStatus(from, to) {
+		return fmt.Errorf("不允许的状态变更：%s → %s", from, to)
+	}
+	return nil
 }
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"cmas-cats-go/config"
+	"cmas-cats-go/models"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// siteClient 向站点核实部署时使用
+var siteClient = &http.Client{Timeout: 5 * time.Second}
+
+// validateDeploymentHandler：处理 POST /validate
+// 站点用验证样本调用其部署的工作负载并提交输出；平台与 ValidationResult 比对，记录该站点的验证结果
+// site_id 必须是配置了令牌的站点，请求须携带匹配的 X-Site-Token；deployment_id 须是该站点上报的、属于该服务的部署
+func validateDeploymentHandler(c *gin.Context) {
+	var req struct {
+		ServiceID    string `json:"service_id" binding:"required"`
+		SiteID       string `json:"site_id" binding:"required"`
+		DeploymentID string `json:"deployment_id" binding:"required"`
+		Result       string `json:"result"` // 站点工作负载对样本产生的输出
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+	site, status, err := authenticateSite(req.SiteID, c.GetHeader("X-Site-Token"))
+	if err == nil {
+		status, err = checkSiteDeployment(site, req.DeploymentID, req.ServiceID)
+	}
+	if err != nil {
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		fmt.Printf("⚠️ 拒绝部署验证提交：站点=%s, 服务=%s（%v）\n", req.SiteID, req.ServiceID, err)
+		return
+	}
+
+	s, err := loadService(req.ServiceID)
+	if err != nil {
+		respondServiceLookupError(c, req.ServiceID, err)
+		return
+	}
+	if s.ValidationResult == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("服务%s未配置验证样本，无需验证", req.ServiceID),
+		})
+		return
+	}
+
+	// 比对输出（忽略首尾空白）
+	attestation := models.ValidationAttestation{
+		SiteID:      req.SiteID,
+		ServiceID:   req.ServiceID,
+		Passed:      true,
+		Message:     "输出与预期结果一致",
+		ValidatedAt: time.Now(),
+	}
+	if strings.TrimSpace(req.Result) != strings.TrimSpace(s.ValidationResult) {
+		attestation.Passed = false
+		attestation.Message = "输出与预期结果不一致"
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO validations (site_id, service_id, passed, submitted_result, message, validated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(site_id, service_id) DO UPDATE SET
+			passed = excluded.passed,
+			submitted_result = excluded.submitted_result,
+			message = excluded.message,
+			validated_at = excluded.validated_at`,
+		attestation.SiteID, attestation.ServiceID, attestation.Passed,
+		req.Result, attestation.Message, attestation.ValidatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "记录验证结果失败（数据库错误）：" + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "验证完成",
+		"attestation": attestation,
+	})
+	fmt.Printf("[%s] 部署验证：站点=%s, 服务=%s, 结果=%v（%s）\n",
+		time.Now().Format("15:04:05"), req.SiteID, req.ServiceID, attestation.Passed, attestation.Message)
+}
+
+// authenticateSite 核对提交方身份：站点须在配置中且配置了令牌，请求须提供匹配的令牌
+func authenticateSite(siteID, token string) (*config.SiteConfig, int, error) {
+	site, err := config.Site(siteID)
+	if siteID == "" || err != nil {
+		return nil, http.StatusForbidden, fmt.Errorf("站点%s不在配置中，不接受其验证结果", siteID)
+	}
+	if site.Token == "" {
+		return nil, http.StatusForbidden, fmt.Errorf("站点%s未配置令牌，不接受其验证结果", siteID)
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(site.Token)) != 1 {
+		return nil, http.StatusUnauthorized, fmt.Errorf("站点%s的X-Site-Token无效", siteID)
+	}
+	return site, 0, nil
+}
+
+// checkSiteDeployment 向站点核实部署：站点须上报该部署，且部署属于所验证的服务
+func checkSiteDeployment(site *config.SiteConfig, deploymentID, serviceID string) (int, error) {
+	resp, err := siteClient.Get(site.URL + "/deployments/" + url.PathEscape(deploymentID))
+	if err != nil {
+		return http.StatusBadGateway, fmt.Errorf("无法向站点%s核实部署%s：%w", site.ID, deploymentID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return http.StatusConflict, fmt.Errorf("站点%s未上报部署%s", site.ID, deploymentID)
+	}
+	var result struct {
+		Deployment struct {
+			ServiceID string `json:"service_id"`
+		} `json:"deployment"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return http.StatusBadGateway, fmt.Errorf("站点%s查询部署%s返回状态码%d", site.ID, deploymentID, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return http.StatusBadGateway, fmt.Errorf("解析站点%s的部署记录失败：%w", site.ID, err)
+	}
+	if result.Deployment.ServiceID != serviceID {
+		return http.StatusConflict, fmt.Errorf("站点%s的部署%s不属于服务%s", site.ID, deploymentID, serviceID)
+	}
+	return 0, nil
+}
+
+// getValidationSampleHandler：返回服务的验证样本（预期结果不对外暴露）
+func getValidationSampleHandler(c *gin.Context) {
+	serviceID := c.Param("id")
+
+	s, err := loadService(serviceID)
+	if err != nil {
+		respondServiceLookupError(c, serviceID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"service_id": s.ID,
+		"required":   s.ValidationResult != "",
+		"sample":     s.ValidationSample,
+	})
+}
+
+// getValidationsHandler：返回验证结果快照（支持 ?service_id= / ?site_id= 过滤）
+func getValidationsHandler(c *gin.Context) {
+	snapshot := models.ValidationSnapshot{
+		RequiredServices: []string{},
+		Attestations:     []models.ValidationAttestation{},
+	}
+
+	// 1. 需要验证的服务
+	query := `SELECT id FROM services WHERE validation_result IS NOT NULL AND validation_result != ''`
+	args := []interface{}{}
+	if serviceID := c.Query("service_id"); serviceID != "" {
+		query += ` AND id = ?`
+		args = append(args, serviceID)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "查询验证服务失败：" + err.Error(),
+		})
+		return
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			snapshot.RequiredServices = append(snapshot.RequiredServices, id)
+		}
+	}
+	rows.Close()
+
+	// 2. 验证记录
+	query = `SELECT site_id, service_id, passed, message, validated_at FROM validations WHERE 1 = 1`
+	args = args[:0]
+	if serviceID := c.Query("service_id"); serviceID != "" {
+		query += ` AND service_id = ?`
+		args = append(args, serviceID)
+	}
+	if siteID := c.Query("site_id"); siteID != "" {
+		query += ` AND site_id = ?`
+		args = append(args, siteID)
+	}
+	rows, err = db.Query(query+` ORDER BY validated_at DESC`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "查询验证记录失败：" + err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a models.ValidationAttestation
+		var message sql.NullString
+		if err := rows.Scan(&a.SiteID, &a.ServiceID, &a.Passed, &message, &a.ValidatedAt); err != nil {
+			fmt.Printf("⚠️ 解析验证记录失败：%v\n", err)
+			continue
+		}
+		a.Message = message.String
+		snapshot.Attestations = append(snapshot.Attestations, a)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":           true,
+		"required_services": snapshot.RequiredServices,
+		"attestations":      snapshot.Attestations,
+	})
+}
+
+// resetValidations：服务的验证样本/预期结果变化或服务被删除后，清除已有验证记录
+func resetValidations(serviceID string) error {
+	if _, err := db.Exec(`DELETE FROM validations WHERE service_id = ?`, serviceID); err != nil {
+		return fmt.Errorf("清除验证记录失败：%w", err)
+	}
+	return nil
+}
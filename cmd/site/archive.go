@@ -0,0 +1,377 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// 上传包归档：zip、tar、tar.gz 统一按条目读取并解压，
+// 同样的路径检查（防止路径遍历）、大小与条目数上限、符号链接处理与文件权限保留
+// ------------------------------
+
+// 归档格式
+const (
+	ArchiveZip   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+)
+
+const ArchiveMaxEntries = 10000 // 单个上传包的最大条目数
+
+var (
+	errBadArchive      = errors.New("上传包不合法")
+	errArchiveTooLarge = errors.New("上传包超出大小上限")
+)
+
+// archiveEntry 归档中的一项
+type archiveEntry struct {
+	Name     string      // 归档内路径（/ 分隔）
+	Mode     fs.FileMode // 类型与权限位
+	Size     int64       // 声明的大小（仅供提前检查，写入时以实际读取为准）
+	Linkname string      // 符号链接目标；硬链接时为归档内的目标路径
+	HardLink bool
+	ModTime  time.Time
+	Open     func() (io.ReadCloser, error) // 普通文件的内容
+}
+
+// archiveReader 逐条读取归档
+type archiveReader interface {
+	Next() (*archiveEntry, error) // 读完时返回 io.EOF
+	Close() error
+}
+
+// detectArchive 按文件头识别格式，识别不出时参考文件名后缀
+func detectArchive(path, filename string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return ArchiveZip, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return ArchiveTarGz, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return ArchiveTar, nil
+	}
+	name := strings.ToLower(filename)
+	if strings.HasSuffix(name, ".tar") && n > 0 {
+		return ArchiveTar, nil // 旧式 v7 tar 没有 ustar 标记
+	}
+	return "", fmt.Errorf("%w：不支持的格式（仅支持 zip、tar、tar.gz/tgz）：%s", errBadArchive, filename)
+}
+
+func openArchive(path, format string) (archiveReader, error) {
+	switch format {
+	case ArchiveZip:
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w：%v", errBadArchive, err)
+		}
+		return &zipArchive{r: r}, nil
+	case ArchiveTar, ArchiveTarGz:
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		a := &tarArchive{f: f}
+		var src io.Reader = bufio.NewReader(f)
+		if format == ArchiveTarGz {
+			gz, err := gzip.NewReader(src)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%w：%v", errBadArchive, err)
+			}
+			a.gz, src = gz, gz
+		}
+		a.r = tar.NewReader(src)
+		return a, nil
+	}
+	return nil, fmt.Errorf("%w：未知格式%s", errBadArchive, format)
+}
+
+type zipArchive struct {
+	r *zip.ReadCloser
+	i int
+}
+
+func (a *zipArchive) Next() (*archiveEntry, error) {
+	if a.i >= len(a.r.File) {
+		return nil, io.EOF
+	}
+	f := a.r.File[a.i]
+	a.i++
+	e := &archiveEntry{
+		Name:    f.Name,
+		Mode:    f.Mode(),
+		Size:    int64(f.UncompressedSize64),
+		ModTime: f.Modified,
+		Open:    func() (io.ReadCloser, error) { return f.Open() },
+	}
+	if e.Mode&fs.ModeSymlink != 0 {
+		// zip 中符号链接的目标保存为条目内容
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w：%v", errBadArchive, err)
+		}
+		target, err := io.ReadAll(io.LimitReader(rc, 4096))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w：%v", errBadArchive, err)
+		}
+		e.Linkname = string(target)
+	}
+	return e, nil
+}
+
+func (a *zipArchive) Close() error { return a.r.Close() }
+
+type tarArchive struct {
+	f  *os.File
+	gz *gzip.Reader
+	r  *tar.Reader
+}
+
+func (a *tarArchive) Next() (*archiveEntry, error) {
+	for {
+		h, err := a.r.Next()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w：%v", errBadArchive, err)
+		}
+		e := &archiveEntry{
+			Name:     h.Name,
+			Size:     h.Size,
+			Linkname: h.Linkname,
+			ModTime:  h.ModTime,
+			Mode:     fs.FileMode(h.Mode).Perm(),
+			Open:     func() (io.ReadCloser, error) { return io.NopCloser(a.r), nil },
+		}
+		switch h.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+		case tar.TypeDir:
+			e.Mode |= fs.ModeDir
+		case tar.TypeSymlink:
+			e.Mode |= fs.ModeSymlink
+		case tar.TypeLink:
+			e.HardLink = true
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			// 设备文件、FIFO 等不属于代码包内容
+			fmt.Printf("⚠️ 跳过上传包中的特殊文件：%s（类型%q）\n", h.Name, h.Typeflag)
+			continue
+		}
+		return e, nil
+	}
+}
+
+func (a *tarArchive) Close() error {
+	if a.gz != nil {
+		a.gz.Close()
+	}
+	return a.f.Close()
+}
+
+// extractArchive 解压归档到 dest（须为空目录）：
+//   - 条目路径必须位于 dest 内，绝对路径与 .. 一律拒绝；
+//   - 解压后的总大小不超过 maxBytes，条目数不超过 ArchiveMaxEntries（以实际写入的字节计，不信任声明的大小）；
+//   - 符号链接的目标必须解析到 dest 内，且在全部文件写完后才创建，写入时不会经过链接；
+//   - 保留文件权限位（去掉 setuid/setgid/sticky，属主至少可读写）与修改时间
+func extractArchive(path, format, dest string, maxBytes int64) error {
+	a, err := openArchive(path, format)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	// 以解析后的真实路径比较，链接检查不受上级目录中符号链接的影响
+	if dest, err = filepath.Abs(dest); err == nil {
+		dest, err = filepath.EvalSymlinks(dest)
+	}
+	if err != nil {
+		return err
+	}
+	type link struct{ path, target string }
+	var symlinks []link
+	var total int64
+	for n := 0; ; n++ {
+		e, err := a.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if n >= ArchiveMaxEntries {
+			return fmt.Errorf("%w：条目数超过%d", errArchiveTooLarge, ArchiveMaxEntries)
+		}
+		target, err := archivePath(dest, e.Name)
+		if err != nil {
+			return err
+		}
+		if target == dest {
+			continue // 根目录条目（./）
+		}
+
+		switch {
+		case e.Mode.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, e.Mode.Perm()|0700); err != nil {
+				return err
+			}
+		case e.Mode&fs.ModeSymlink != 0:
+			if err := checkLinkTarget(dest, target, e.Linkname); err != nil {
+				return fmt.Errorf("%w：%s → %s：%v", errBadArchive, e.Name, e.Linkname, err)
+			}
+			symlinks = append(symlinks, link{target, e.Linkname})
+		case e.HardLink:
+			src, err := archivePath(dest, e.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Link(src, target); err != nil {
+				return fmt.Errorf("%w：硬链接%s → %s：%v", errBadArchive, e.Name, e.Linkname, err)
+			}
+		case e.Mode.IsRegular():
+			if total+e.Size > maxBytes {
+				return fmt.Errorf("%w：解压后超过%d字节", errArchiveTooLarge, maxBytes)
+			}
+			written, err := writeArchiveFile(target, e, maxBytes-total)
+			if err != nil {
+				return err
+			}
+			total += written
+		default:
+			fmt.Printf("⚠️ 跳过上传包中的特殊文件：%s（%v）\n", e.Name, e.Mode.Type())
+		}
+	}
+
+	// 符号链接最后创建，并按实际解析结果再检查一次（链接指向链接时的逐级解析）
+	for _, l := range symlinks {
+		// 链接所在目录不能经过此前创建的链接
+		if underSymlink(dest, l.path) {
+			return fmt.Errorf("%w：符号链接%s位于另一个符号链接之下", errBadArchive, strings.TrimPrefix(l.path, dest+string(filepath.Separator)))
+		}
+		if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+			return err
+		}
+		os.RemoveAll(l.path)
+		if err := os.Symlink(l.target, l.path); err != nil {
+			return err
+		}
+	}
+	for _, l := range symlinks {
+		resolved, err := filepath.EvalSymlinks(l.path)
+		if err != nil {
+			// 悬空链接：目标不含 .. 时只会落在链接所在目录之下
+			if strings.Contains(filepath.ToSlash(l.target), "..") {
+				return fmt.Errorf("%w：悬空的符号链接%s不能包含 ..", errBadArchive, strings.TrimPrefix(l.path, dest+string(filepath.Separator)))
+			}
+			continue
+		}
+		if !withinDir(dest, resolved) {
+			return fmt.Errorf("%w：符号链接%s解析到了解压目录之外", errBadArchive, strings.TrimPrefix(l.path, dest+string(filepath.Separator)))
+		}
+	}
+	return nil
+}
+
+// archivePath 条目在 dest 下的路径；拒绝绝对路径与跳出 dest 的路径
+func archivePath(dest, name string) (string, error) {
+	name = strings.TrimPrefix(filepath.FromSlash(name), "."+string(filepath.Separator))
+	name = strings.TrimRight(name, string(filepath.Separator))
+	if name == "" || name == "." {
+		return dest, nil
+	}
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%w：非法文件路径: %s", errBadArchive, name)
+	}
+	return filepath.Join(dest, name), nil
+}
+
+// checkLinkTarget 符号链接目标须为相对路径，且相对链接所在目录解析后仍位于 dest 内
+func checkLinkTarget(dest, linkPath, target string) error {
+	if target == "" || filepath.IsAbs(target) {
+		return fmt.Errorf("不允许绝对路径或空目标")
+	}
+	if !withinDir(dest, filepath.Join(filepath.Dir(linkPath), target)) {
+		return fmt.Errorf("目标位于解压目录之外")
+	}
+	return nil
+}
+
+// underSymlink path 在 dest 之下的上级目录中是否有符号链接
+func underSymlink(dest, path string) bool {
+	for dir := filepath.Dir(path); dir != dest && withinDir(dest, dir); dir = filepath.Dir(dir) {
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && (rel == "." || filepath.IsLocal(rel))
+}
+
+// writeArchiveFile 写入普通文件，最多写入 limit 字节；返回实际写入的字节数
+func writeArchiveFile(target string, e *archiveEntry, limit int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+	src, err := e.Open()
+	if err != nil {
+		return 0, fmt.Errorf("%w：%v", errBadArchive, err)
+	}
+	defer src.Close()
+	// 同名条目覆盖此前的文件；先删除，避免写穿已有的链接
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(dst, io.LimitReader(src, limit+1))
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return written, fmt.Errorf("%w：写入%s失败：%v", errBadArchive, e.Name, err)
+	}
+	if written > limit {
+		return written, fmt.Errorf("%w：解压后超过上限", errArchiveTooLarge)
+	}
+	if err := os.Chmod(target, e.Mode.Perm()|0600); err != nil {
+		return written, err
+	}
+	if !e.ModTime.IsZero() {
+		os.Chtimes(target, e.ModTime, e.ModTime)
+	}
+	return written, nil
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tarEntry 测试用 tar 条目；typ 为 0 时是普通文件
+type tarEntry struct {
+	name string
+	typ  byte
+	body string
+	link string
+	mode int64
+}
+
+func writeTar(t *testing.T, path string, gz bool, entries []tarEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	var gw *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if gz {
+		gw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gw)
+	}
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Typeflag: e.typ, Linkname: e.link, Mode: e.mode, ModTime: time.Unix(1_700_000_000, 0)}
+		if h.Typeflag == 0 {
+			h.Typeflag = tar.TypeReg
+		}
+		if h.Mode == 0 {
+			h.Mode = 0644
+		}
+		if h.Typeflag == tar.TypeReg {
+			h.Size = int64(len(e.body))
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Size > 0 {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// zipEntry 测试用 zip 条目；符号链接的 body 为链接目标
+type zipEntry struct {
+	name string
+	body string
+	mode fs.FileMode
+}
+
+func writeZip(t *testing.T, path string, entries []zipEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		mode := e.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		h.SetMode(mode)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// extractDest 解压目录 <tmp>/dest，旁边的 <tmp>/outside 用于检查是否写到了解压目录之外
+func extractDest(t *testing.T) string {
+	t.Helper()
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "dest")
+	outside := filepath.Join(parent, "outside")
+	for _, dir := range []string{dest, outside} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dest
+}
+
+// assertOutsideUntouched 解压目录之外只有原来的 secret
+func assertOutsideUntouched(t *testing.T, dest string) {
+	t.Helper()
+	parent := filepath.Dir(dest)
+	entries, _ := os.ReadDir(parent)
+	if len(entries) != 2 {
+		t.Errorf("解压目录之外多出了文件：%v", entries)
+	}
+	entries, _ = os.ReadDir(filepath.Join(parent, "outside"))
+	if len(entries) != 1 {
+		t.Errorf("outside 目录被改动：%v", entries)
+	}
+	if data, _ := os.ReadFile(filepath.Join(parent, "outside", "secret")); string(data) != "secret" {
+		t.Errorf("outside/secret 被改写：%q", data)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取%s失败：%v", path, err)
+	}
+	return string(data)
+}
+
+func TestExtractTar(t *testing.T) {
+	cases := []struct {
+		name    string
+		entries []tarEntry
+		max     int64
+		wantErr error
+		check   func(t *testing.T, dest string)
+	}{
+		{
+			name: "普通文件与目录",
+			entries: []tarEntry{
+				{name: "./", typ: tar.TypeDir, mode: 0755},
+				{name: "app/", typ: tar.TypeDir, mode: 0755},
+				{name: "app/run.sh", body: "#!/bin/sh\n", mode: 0755},
+				{name: "./README", body: "hi"},
+			},
+			check: func(t *testing.T, dest string) {
+				if got := readFile(t, filepath.Join(dest, "app", "run.sh")); got != "#!/bin/sh\n" {
+					t.Errorf("run.sh 内容 %q", got)
+				}
+				if got := readFile(t, filepath.Join(dest, "README")); got != "hi" {
+					t.Errorf("README 内容 %q", got)
+				}
+				info, _ := os.Stat(filepath.Join(dest, "README"))
+				if !info.ModTime().Equal(time.Unix(1_700_000_000, 0)) {
+					t.Errorf("未保留修改时间：%v", info.ModTime())
+				}
+			},
+		},
+		{name: "../ 跳出", entries: []tarEntry{{name: "../outside/evil", body: "x"}}, wantErr: errBadArchive},
+		{name: "中间的 .. 跳出", entries: []tarEntry{{name: "app/../../evil", body: "x"}}, wantErr: errBadArchive},
+		{name: "绝对路径", entries: []tarEntry{{name: "/tmp/evil", body: "x"}}, wantErr: errBadArchive},
+		{name: "目录条目跳出", entries: []tarEntry{{name: "../evil/", typ: tar.TypeDir, mode: 0755}}, wantErr: errBadArchive},
+		{name: "符号链接指向绝对路径", entries: []tarEntry{{name: "etc", typ: tar.TypeSymlink, link: "/etc"}}, wantErr: errBadArchive},
+		{name: "符号链接跳出", entries: []tarEntry{{name: "out", typ: tar.TypeSymlink, link: "../outside"}}, wantErr: errBadArchive},
+		{name: "子目录中的符号链接跳出", entries: []tarEntry{{name: "a/b/up", typ: tar.TypeSymlink, link: "../../../outside/secret"}}, wantErr: errBadArchive},
+		{name: "空链接目标", entries: []tarEntry{{name: "empty", typ: tar.TypeSymlink, link: ""}}, wantErr: errBadArchive},
+		{
+			// 先有符号链接、再有经过该链接的文件：文件写入真实目录，不跟随链接（链接最后创建并替换该目录）
+			name: "先符号链接后文件",
+			entries: []tarEntry{
+				{name: "data/", typ: tar.TypeDir, mode: 0755},
+				{name: "link", typ: tar.TypeSymlink, link: "data"},
+				{name: "link/file", body: "x"},
+			},
+			check: func(t *testing.T, dest string) {
+				if _, err := os.Stat(filepath.Join(dest, "data", "file")); !os.IsNotExist(err) {
+					t.Errorf("文件经过符号链接写入了 data/file：%v", err)
+				}
+				info, err := os.Lstat(filepath.Join(dest, "link"))
+				if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+					t.Errorf("link 应为符号链接：%v %v", info, err)
+				}
+			},
+		},
+		{
+			name: "先符号链接后文件覆盖链接本身",
+			entries: []tarEntry{
+				{name: "link", typ: tar.TypeSymlink, link: "target"},
+				{name: "target", body: "orig"},
+				{name: "link", body: "x"},
+			},
+			check: func(t *testing.T, dest string) {
+				if got := readFile(t, filepath.Join(dest, "target")); got != "orig" {
+					t.Errorf("写入穿过了链接：target 内容 %q", got)
+				}
+			},
+		},
+		{
+			name: "符号链接之下的符号链接",
+			entries: []tarEntry{
+				{name: "data/", typ: tar.TypeDir, mode: 0755},
+				{name: "link", typ: tar.TypeSymlink, link: "data"},
+				{name: "link/inner", typ: tar.TypeSymlink, link: "x"},
+			},
+			wantErr: errBadArchive,
+		},
+		{
+			name: "合法的链接链",
+			entries: []tarEntry{
+				{name: "a", typ: tar.TypeSymlink, link: "b"},
+				{name: "b", typ: tar.TypeSymlink, link: "sub/c"},
+				{name: "sub/c", body: "chain"},
+			},
+			check: func(t *testing.T, dest string) {
+				if got := readFile(t, filepath.Join(dest, "a")); got != "chain" {
+					t.Errorf("a 解析后内容 %q", got)
+				}
+			},
+		},
+		{
+			// 逐个检查时都在解压目录内，按链接逐级解析后才跳出
+			name: "链接链跳出",
+			entries: []tarEntry{
+				{name: "self", typ: tar.TypeSymlink, link: "."},
+				{name: "sub/esc", typ: tar.TypeSymlink, link: "../self/../outside"},
+			},
+			wantErr: errBadArchive,
+		},
+		{name: "悬空链接含 ..", entries: []tarEntry{{name: "d", typ: tar.TypeSymlink, link: "sub/../missing"}}, wantErr: errBadArchive},
+		{
+			name:    "悬空链接不含 ..",
+			entries: []tarEntry{{name: "d", typ: tar.TypeSymlink, link: "missing"}},
+			check: func(t *testing.T, dest string) {
+				if target, err := os.Readlink(filepath.Join(dest, "d")); err != nil || target != "missing" {
+					t.Errorf("悬空链接 %q %v", target, err)
+				}
+			},
+		},
+		{
+			name: "硬链接",
+			entries: []tarEntry{
+				{name: "f", body: "hard"},
+				{name: "sub/h", typ: tar.TypeLink, link: "f"},
+			},
+			check: func(t *testing.T, dest string) {
+				if got := readFile(t, filepath.Join(dest, "sub", "h")); got != "hard" {
+					t.Errorf("硬链接内容 %q", got)
+				}
+			},
+		},
+		{name: "硬链接跳出", entries: []tarEntry{{name: "h", typ: tar.TypeLink, link: "../outside/secret"}}, wantErr: errBadArchive},
+		{name: "硬链接指向绝对路径", entries: []tarEntry{{name: "h", typ: tar.TypeLink, link: "/etc/passwd"}}, wantErr: errBadArchive},
+		{name: "硬链接目标不存在", entries: []tarEntry{{name: "h", typ: tar.TypeLink, link: "missing"}}, wantErr: errBadArchive},
+		{
+			name: "硬链接经过符号链接",
+			entries: []tarEntry{
+				{name: "link", typ: tar.TypeSymlink, link: "."},
+				{name: "h", typ: tar.TypeLink, link: "link/../outside/secret"},
+			},
+			wantErr: errBadArchive,
+		},
+		{name: "单个文件超过大小上限", entries: []tarEntry{{name: "big", body: strings.Repeat("x", 2048)}}, max: 1024, wantErr: errArchiveTooLarge},
+		{
+			name: "累计大小超过上限",
+			entries: []tarEntry{
+				{name: "a", body: strings.Repeat("x", 600)},
+				{name: "b", body: strings.Repeat("x", 600)},
+			},
+			max:     1024,
+			wantErr: errArchiveTooLarge,
+		},
+		{
+			name: "恰好达到上限",
+			entries: []tarEntry{
+				{name: "a", body: strings.Repeat("x", 512)},
+				{name: "b", body: strings.Repeat("x", 512)},
+			},
+			max: 1024,
+		},
+		{
+			name: "去掉特殊权限位",
+			entries: []tarEntry{
+				{name: "suid", body: "x", mode: 0o4755 | 0o2000 | 0o1000},
+				{name: "ro", body: "x", mode: 0400},
+				{name: "rodir/", typ: tar.TypeDir, mode: 0500},
+				{name: "rodir/f", body: "x", mode: 0644},
+			},
+			check: func(t *testing.T, dest string) {
+				want := map[string]fs.FileMode{"suid": 0755, "ro": 0600, "rodir": fs.ModeDir | 0700}
+				for name, mode := range want {
+					info, err := os.Stat(filepath.Join(dest, name))
+					if err != nil {
+						t.Fatal(err)
+					}
+					got := info.Mode() & (fs.ModeType | fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+					if got != mode {
+						t.Errorf("%s 权限 %v，期望 %v", name, got, mode)
+					}
+				}
+			},
+		},
+		{
+			name: "跳过FIFO与设备文件",
+			entries: []tarEntry{
+				{name: "fifo", typ: tar.TypeFifo},
+				{name: "null", typ: tar.TypeChar},
+				{name: "f", body: "x"},
+			},
+			check: func(t *testing.T, dest string) {
+				entries, _ := os.ReadDir(dest)
+				if len(entries) != 1 || entries[0].Name() != "f" {
+					t.Errorf("解压结果 %v，期望只有 f", entries)
+				}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, gz := range []bool{false, true} {
+				format, file := ArchiveTar, "bundle.tar"
+				if gz {
+					format, file = ArchiveTarGz, "bundle.tar.gz"
+				}
+				path := filepath.Join(t.TempDir(), file)
+				writeTar(t, path, gz, tc.entries)
+				dest := extractDest(t)
+				max := tc.max
+				if max == 0 {
+					max = 1 << 20
+				}
+				err := extractArchive(path, format, dest, max)
+				if tc.wantErr != nil {
+					if !errors.Is(err, tc.wantErr) {
+						t.Errorf("%s：错误 %v，期望 %v", format, err, tc.wantErr)
+					}
+				} else if err != nil {
+					t.Errorf("%s：解压失败：%v", format, err)
+				} else if tc.check != nil {
+					tc.check(t, dest)
+				}
+				assertOutsideUntouched(t, dest)
+			}
+		})
+	}
+}
+
+func TestExtractTarEntryLimit(t *testing.T) {
+	entries := make([]tarEntry, ArchiveMaxEntries+1)
+	for i := range entries {
+		entries[i] = tarEntry{name: "d/", typ: tar.TypeDir, mode: 0755}
+	}
+	path := filepath.Join(t.TempDir(), "many.tar")
+	writeTar(t, path, false, entries)
+	if err := extractArchive(path, ArchiveTar, extractDest(t), 1<<20); !errors.Is(err, errArchiveTooLarge) {
+		t.Errorf("超过%d个条目：错误 %v，期望 %v", ArchiveMaxEntries, err, errArchiveTooLarge)
+	}
+
+	path = filepath.Join(t.TempDir(), "max.tar")
+	writeTar(t, path, false, entries[:ArchiveMaxEntries])
+	if err := extractArchive(path, ArchiveTar, extractDest(t), 1<<20); err != nil {
+		t.Errorf("恰好%d个条目：%v", ArchiveMaxEntries, err)
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	cases := []struct {
+		name    string
+		entries []zipEntry
+		max     int64
+		wantErr error
+		check   func(t *testing.T, dest string)
+	}{
+		{
+			name: "普通文件与权限",
+			entries: []zipEntry{
+				{name: "app/", mode: fs.ModeDir | 0755},
+				{name: "app/run.sh", body: "#!/bin/sh\n", mode: 0755},
+				{name: "suid", body: "x", mode: fs.ModeSetuid | 0755},
+			},
+			check: func(t *testing.T, dest string) {
+				if got := readFile(t, filepath.Join(dest, "app", "run.sh")); got != "#!/bin/sh\n" {
+					t.Errorf("run.sh 内容 %q", got)
+				}
+				info, _ := os.Stat(filepath.Join(dest, "suid"))
+				if info.Mode()&fs.ModeSetuid != 0 || info.Mode().Perm() != 0755 {
+					t.Errorf("suid 权限 %v，期望 -rwxr-xr-x", info.Mode())
+				}
+			},
+		},
+		{name: "../ 跳出", entries: []zipEntry{{name: "../outside/evil", body: "x"}}, wantErr: errBadArchive},
+		{name: "绝对路径", entries: []zipEntry{{name: "/tmp/evil", body: "x"}}, wantErr: errBadArchive},
+		{name: "符号链接指向绝对路径", entries: []zipEntry{{name: "etc", body: "/etc", mode: fs.ModeSymlink | 0777}}, wantErr: errBadArchive},
+		{name: "符号链接跳出", entries: []zipEntry{{name: "out", body: "../outside", mode: fs.ModeSymlink | 0777}}, wantErr: errBadArchive},
+		{
+			name: "链接链跳出",
+			entries: []zipEntry{
+				{name: "self", body: ".", mode: fs.ModeSymlink | 0777},
+				{name: "sub/esc", body: "../self/../outside", mode: fs.ModeSymlink | 0777},
+			},
+			wantErr: errBadArchive,
+		},
+		{
+			name: "先符号链接后文件",
+			entries: []zipEntry{
+				{name: "data/", mode: fs.ModeDir | 0755},
+				{name: "link", body: "data", mode: fs.ModeSymlink | 0777},
+				{name: "link/file", body: "x"},
+			},
+			check: func(t *testing.T, dest string) {
+				if _, err := os.Stat(filepath.Join(dest, "data", "file")); !os.IsNotExist(err) {
+					t.Errorf("文件经过符号链接写入了 data/file：%v", err)
+				}
+			},
+		},
+		{name: "超过大小上限", entries: []zipEntry{{name: "big", body: strings.Repeat("x", 2048)}}, max: 1024, wantErr: errArchiveTooLarge},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bundle.zip")
+			writeZip(t, path, tc.entries)
+			dest := extractDest(t)
+			max := tc.max
+			if max == 0 {
+				max = 1 << 20
+			}
+			err := extractArchive(path, ArchiveZip, dest, max)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("错误 %v，期望 %v", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("解压失败：%v", err)
+			} else if tc.check != nil {
+				tc.check(t, dest)
+			}
+			assertOutsideUntouched(t, dest)
+		})
+	}
+}
+
+func TestDetectArchive(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "a.bin")
+	writeZip(t, zipPath, []zipEntry{{name: "f", body: "x"}})
+	tgzPath := filepath.Join(dir, "b.bin")
+	writeTar(t, tgzPath, true, []tarEntry{{name: "f", body: "x"}})
+	tarPath := filepath.Join(dir, "c.bin")
+	writeTar(t, tarPath, false, []tarEntry{{name: "f", body: "x"}})
+	textPath := filepath.Join(dir, "d.bin")
+	os.WriteFile(textPath, []byte("not an archive"), 0644)
+	emptyPath := filepath.Join(dir, "e.tar")
+	os.WriteFile(emptyPath, nil, 0644)
+
+	cases := []struct {
+		path, filename string
+		want           string
+	}{
+		{zipPath, "upload", ArchiveZip},
+		{tgzPath, "upload.zip", ArchiveTarGz}, // 以文件头为准
+		{tarPath, "upload", ArchiveTar},
+		{textPath, "old.tar", ArchiveTar}, // 无 ustar 标记时参考后缀
+		{textPath, "upload.txt", ""},
+		{emptyPath, "empty.tar", ""},
+	}
+	for _, tc := range cases {
+		got, err := detectArchive(tc.path, tc.filename)
+		if tc.want == "" {
+			if !errors.Is(err, errBadArchive) {
+				t.Errorf("detectArchive(%s, %s) = %q, %v，期望 %v", filepath.Base(tc.path), tc.filename, got, err, errBadArchive)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("detectArchive(%s, %s) = %q, %v，期望 %q", filepath.Base(tc.path), tc.filename, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,791 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// ------------------------------
+// 部署包：按内容摘要存储的上传包、包内清单（服务ID、版本、启动命令、健康检查、资源单位、环境变量），
+// 每个服务保留多个版本，部署可切换版本或回滚到上一个版本
+// ------------------------------
+
+const (
+	BundleStoreDir = "bundles" // 上传目录下按摘要存放解压后的包：uploads/bundles/<sha256>
+	BundleLatest   = "latest"  // 版本号取 latest 时使用该服务最近上传的版本
+)
+
+// BundleManifestNames 包根目录（或唯一的顶层目录）中的清单文件名，按顺序查找
+var BundleManifestNames = []string{"cmas-bundle.yaml", "cmas-bundle.yml", "cmas-bundle.json"}
+
+var (
+	bundleVersionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]{0,63}$`)
+	envNamePattern       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	bundleMutex sync.Mutex // 登记、清理与版本切换的记录互斥，避免正在使用的版本被清理
+
+	errBundleNotFound = errors.New("部署包版本不存在")
+	errBundleConflict = errors.New("部署包版本已存在且内容不同")
+	errBadManifest    = errors.New("部署包清单不合法")
+)
+
+// BundleManifest 包内清单（cmas-bundle.yaml，也可写成 JSON）
+type BundleManifest struct {
+	ServiceID     string              `yaml:"service_id" json:"service_id"`                             // 留空时取上传请求的 service_id
+	Version       string              `yaml:"version" json:"version"`                                   // 同一服务内唯一，内容不可变
+	Start         string              `yaml:"start" json:"start"`                                       // 启动命令：相对包根目录的脚本，可带参数（按空白分隔）
+	Args          []string            `yaml:"args,omitempty" json:"args,omitempty"`                     // 追加在启动命令之后的参数
+	Env           map[string]string   `yaml:"env,omitempty" json:"env,omitempty"`                       // 工作负载环境变量
+	ResourceUnits int                 `yaml:"resource_units,omitempty" json:"resource_units,omitempty"` // 单实例资源单位，留空按服务注册的资源需求计算
+	HealthCheck   *HealthCheck        `yaml:"health_check,omitempty" json:"health_check,omitempty"`
+	Validation    *ValidationEndpoint `yaml:"validation,omitempty" json:"validation,omitempty"` // 部署验证入口（服务配置了验证样本时需要）
+}
+
+// Bundle 已登记的部署包版本
+type Bundle struct {
+	ServiceID  string         `json:"service_id"`
+	Version    string         `json:"version"`
+	Digest     string         `json:"digest"` // sha256:<hex>，上传包（及单独上传的启动脚本）的内容摘要
+	Path       string         `json:"path"`   // 包根目录，即工作负载的工作目录
+	Size       int64          `json:"size"`
+	Manifest   BundleManifest `json:"manifest"`
+	UploadedAt time.Time      `json:"uploaded_at"`
+	InUse      []string       `json:"in_use,omitempty"` // 当前使用该版本的部署
+}
+
+// DeploymentVersion 部署的版本记录（最新的为当前版本）
+type DeploymentVersion struct {
+	ID          int64     `json:"id"`
+	Version     string    `json:"version"`
+	Digest      string    `json:"digest"`
+	ActivatedAt time.Time `json:"activated_at"`
+}
+
+func initBundles() error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS bundles (
+		service_id TEXT NOT NULL,
+		version TEXT NOT NULL,
+		digest TEXT NOT NULL,
+		path TEXT NOT NULL,
+		size INT NOT NULL,
+		manifest TEXT NOT NULL,          -- BundleManifest 的 JSON
+		uploaded_at DATETIME NOT NULL,
+		PRIMARY KEY (service_id, version)
+	);
+	CREATE TABLE IF NOT EXISTS deployment_versions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		deployment_id TEXT NOT NULL,
+		service_id TEXT NOT NULL,
+		version TEXT NOT NULL,
+		digest TEXT NOT NULL,
+		activated_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_deployment_versions ON deployment_versions (deployment_id, id);`)
+	if err != nil {
+		return fmt.Errorf("创建部署包表失败：%w", err)
+	}
+	return os.MkdirAll(filepath.Join(UploadDir, BundleStoreDir), 0755)
+}
+
+// ------------------------------
+// 清单
+// ------------------------------
+
+// command 启动命令拆分为脚本与参数
+func (m BundleManifest) command() (script string, args []string) {
+	fields := strings.Fields(m.Start)
+	if len(fields) == 0 {
+		return "", m.Args
+	}
+	return fields[0], append(fields[1:], m.Args...)
+}
+
+// validate 校验清单，root 为包根目录（启动脚本须位于其中）
+func (m *BundleManifest) validate(root string) error {
+	if m.ServiceID == "" {
+		return fmt.Errorf("清单缺少 service_id（也可在上传时通过 service_id 字段指定）")
+	}
+	if !bundleVersionPattern.MatchString(m.Version) || m.Version == BundleLatest {
+		return fmt.Errorf("清单版本号不合法：%q（字母数字开头，仅含字母、数字与 ._+-，不能为 %s）", m.Version, BundleLatest)
+	}
+	script, _ := m.command()
+	if script == "" {
+		return fmt.Errorf("清单缺少启动命令 start")
+	}
+	if filepath.IsAbs(script) || !filepath.IsLocal(script) {
+		return fmt.Errorf("启动脚本必须位于包内：%s", script)
+	}
+	if info, err := os.Stat(filepath.Join(root, script)); err != nil || !info.Mode().IsRegular() {
+		return fmt.Errorf("包内不存在启动脚本：%s", script)
+	}
+	if m.ResourceUnits < 0 {
+		return fmt.Errorf("resource_units 不能为负数：%d", m.ResourceUnits)
+	}
+	for k := range m.Env {
+		if !envNamePattern.MatchString(k) {
+			return fmt.Errorf("环境变量名不合法：%q", k)
+		}
+	}
+	if m.HealthCheck != nil {
+		if err := m.HealthCheck.normalize(); err != nil {
+			return err
+		}
+	}
+	if m.Validation != nil {
+		if err := m.Validation.normalize(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findManifest 在包根目录查找清单，根目录下只有一个目录时也在其中查找；返回包根目录（无清单时为 dir）
+func findManifest(dir string) (root string, m *BundleManifest, err error) {
+	candidates := []string{dir}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		candidates = append(candidates, filepath.Join(dir, entries[0].Name()))
+	}
+	for _, root := range candidates {
+		for _, name := range BundleManifestNames {
+			data, err := os.ReadFile(filepath.Join(root, name))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return "", nil, fmt.Errorf("读取清单%s失败：%w", name, err)
+			}
+			m := &BundleManifest{}
+			dec := yaml.NewDecoder(bytes.NewReader(data))
+			dec.KnownFields(true)
+			if err := dec.Decode(m); err != nil && err != io.EOF {
+				return "", nil, fmt.Errorf("%w：解析%s失败：%v", errBadManifest, name, err)
+			}
+			return root, m, nil
+		}
+	}
+	return dir, nil, nil
+}
+
+// ------------------------------
+// 存储与登记
+// ------------------------------
+
+// storedUpload 一次上传的存储结果
+type storedUpload struct {
+	Format   string // zip / tar / tar.gz
+	Digest   string
+	Dir      string // 按摘要存放的解压目录
+	Root     string // 包根目录
+	Size     int64
+	Manifest *BundleManifest // 包内没有清单时为空
+}
+
+// storeUpload 将上传包（zip/tar/tar.gz）按内容摘要解压到 uploads/bundles/<sha256>，相同内容只解压一次；
+// script 为单独上传的启动脚本（可为空），一并计入摘要并保存到包根目录
+func storeUpload(file, script *multipart.FileHeader, serviceID string) (storedUpload, error) {
+	var s storedUpload
+	maxBytes := int64(siteCfg.MaxBundleMB) << 20
+	if file.Size > maxBytes {
+		return s, fmt.Errorf("%w：%d字节，上限%dMB", errArchiveTooLarge, file.Size, siteCfg.MaxBundleMB)
+	}
+	store := filepath.Join(UploadDir, BundleStoreDir)
+	if err := os.MkdirAll(store, 0755); err != nil {
+		return s, fmt.Errorf("创建存储目录失败：%w", err)
+	}
+
+	// 保存到临时文件并计算摘要
+	tmp, err := os.CreateTemp(store, ".upload-*")
+	if err != nil {
+		return s, fmt.Errorf("保存文件失败：%w", err)
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	src, err := file.Open()
+	if err != nil {
+		tmp.Close()
+		return s, fmt.Errorf("读取上传文件失败：%w", err)
+	}
+	s.Size, err = io.Copy(io.MultiWriter(tmp, h), io.LimitReader(src, maxBytes+1))
+	src.Close()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return s, fmt.Errorf("保存文件失败：%w", err)
+	}
+	if s.Size > maxBytes {
+		return s, fmt.Errorf("%w：上限%dMB", errArchiveTooLarge, siteCfg.MaxBundleMB)
+	}
+	if s.Format, err = detectArchive(tmp.Name(), file.Filename); err != nil {
+		return s, err
+	}
+	var scriptName string
+	var scriptData []byte
+	if script != nil {
+		scriptName = filepath.Base(script.Filename)
+		f, err := script.Open()
+		if err != nil {
+			return s, fmt.Errorf("读取启动脚本失败：%w", err)
+		}
+		scriptData, err = io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return s, fmt.Errorf("读取启动脚本失败：%w", err)
+		}
+		fmt.Fprintf(h, "\x00script\x00%s\x00", scriptName)
+		h.Write(scriptData)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	s.Digest = "sha256:" + sum
+	s.Dir = filepath.Join(store, sum)
+
+	bundleMutex.Lock()
+	defer bundleMutex.Unlock()
+	if _, err := os.Stat(s.Dir); err == nil {
+		// 相同内容已解压过
+		s.Root, s.Manifest, err = findManifest(s.Dir)
+		if err != nil {
+			return s, err
+		}
+		return s, s.checkManifest(serviceID)
+	}
+
+	// 先解压到临时目录，清单校验通过后再改名为摘要目录
+	extractDir, err := os.MkdirTemp(store, ".extract-")
+	if err != nil {
+		return s, fmt.Errorf("创建解压目录失败：%w", err)
+	}
+	if err := os.Chmod(extractDir, 0755); err != nil {
+		os.RemoveAll(extractDir)
+		return s, fmt.Errorf("创建解压目录失败：%w", err)
+	}
+	if err := extractArchive(tmp.Name(), s.Format, extractDir, maxBytes); err != nil {
+		os.RemoveAll(extractDir)
+		return s, fmt.Errorf("解压文件失败：%w", err)
+	}
+	if script != nil {
+		if err := os.WriteFile(filepath.Join(extractDir, scriptName), scriptData, 0644); err != nil {
+			os.RemoveAll(extractDir)
+			return s, fmt.Errorf("保存启动脚本失败：%w", err)
+		}
+	}
+	root, manifest, err := findManifest(extractDir)
+	if err != nil {
+		os.RemoveAll(extractDir)
+		return s, err
+	}
+	s.Manifest = manifest
+	s.Root = filepath.Join(s.Dir, strings.TrimPrefix(root, extractDir))
+	if err := s.checkManifestAt(serviceID, root); err != nil {
+		os.RemoveAll(extractDir)
+		return s, err
+	}
+	if err := os.Rename(extractDir, s.Dir); err != nil {
+		os.RemoveAll(extractDir)
+		return s, fmt.Errorf("保存解压目录失败：%w", err)
+	}
+	return s, nil
+}
+
+func (s *storedUpload) checkManifest(serviceID string) error {
+	return s.checkManifestAt(serviceID, s.Root)
+}
+
+// checkManifestAt 以上传请求的 service_id 补全清单并校验（两者不一致时拒绝）
+func (s *storedUpload) checkManifestAt(serviceID, root string) error {
+	if s.Manifest == nil {
+		return nil
+	}
+	if s.Manifest.ServiceID == "" {
+		s.Manifest.ServiceID = serviceID
+	} else if serviceID != "" && serviceID != s.Manifest.ServiceID {
+		return fmt.Errorf("%w：上传的 service_id（%s）与清单中的（%s）不一致", errBadManifest, serviceID, s.Manifest.ServiceID)
+	}
+	if err := s.Manifest.validate(root); err != nil {
+		return fmt.Errorf("%w：%v", errBadManifest, err)
+	}
+	return nil
+}
+
+// registerBundle 登记包版本；同一版本重复上传相同内容时幂等，内容不同返回 errBundleConflict。
+// 登记后按 bundle_retention 清理该服务较旧的版本
+func registerBundle(s storedUpload) (Bundle, bool, error) {
+	bundleMutex.Lock()
+	defer bundleMutex.Unlock()
+
+	m := *s.Manifest
+	existing, err := loadBundle(m.ServiceID, m.Version)
+	if err == nil {
+		if existing.Digest != s.Digest {
+			removeBundleDir(s.Digest) // 未登记的内容不保留
+			return existing, false, errBundleConflict
+		}
+		return existing, false, nil
+	}
+	if !errors.Is(err, errBundleNotFound) {
+		return Bundle{}, false, err
+	}
+
+	b := Bundle{
+		ServiceID:  m.ServiceID,
+		Version:    m.Version,
+		Digest:     s.Digest,
+		Path:       s.Root,
+		Size:       s.Size,
+		Manifest:   m,
+		UploadedAt: time.Now(),
+	}
+	manifest, _ := json.Marshal(b.Manifest)
+	_, err = db.Exec(`INSERT INTO bundles (service_id, version, digest, path, size, manifest, uploaded_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		b.ServiceID, b.Version, b.Digest, b.Path, b.Size, string(manifest), b.UploadedAt)
+	if err != nil {
+		return Bundle{}, false, fmt.Errorf("登记部署包失败：%w", err)
+	}
+	fmt.Printf("📦 登记部署包：%s@%s（%s）\n", b.ServiceID, b.Version, b.Digest)
+	pruneBundles(b.ServiceID)
+	return b, true, nil
+}
+
+const bundleColumns = `service_id, version, digest, path, size, manifest, uploaded_at`
+
+func scanBundle(row rowScanner) (Bundle, error) {
+	var b Bundle
+	var manifest string
+	if err := row.Scan(&b.ServiceID, &b.Version, &b.Digest, &b.Path, &b.Size, &manifest, &b.UploadedAt); err != nil {
+		return b, err
+	}
+	if err := json.Unmarshal([]byte(manifest), &b.Manifest); err != nil {
+		return b, fmt.Errorf("解析部署包%s@%s的清单失败：%w", b.ServiceID, b.Version, err)
+	}
+	return b, nil
+}
+
+// loadBundle 查询服务的某个版本，version 为 latest 时取最近上传的版本
+func loadBundle(serviceID, version string) (Bundle, error) {
+	query := `SELECT ` + bundleColumns + ` FROM bundles WHERE service_id = ? AND version = ?`
+	args := []any{serviceID, version}
+	if version == BundleLatest {
+		query = `SELECT ` + bundleColumns + ` FROM bundles WHERE service_id = ? ORDER BY uploaded_at DESC LIMIT 1`
+		args = args[:1]
+	}
+	b, err := scanBundle(db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return b, fmt.Errorf("%w：%s@%s", errBundleNotFound, serviceID, version)
+	}
+	if err != nil {
+		return b, fmt.Errorf("查询部署包失败：%w", err)
+	}
+	return b, nil
+}
+
+// pruneBundles 每个服务保留最近的 bundle_retention 个版本；部署当前使用的版本及其上一个版本（回滚目标）不清理，
+// 更早的历史版本照常清理（回滚到已清理的版本时返回409）。调用方须持有 bundleMutex
+func pruneBundles(serviceID string) {
+	rows, err := db.Query(`SELECT `+bundleColumns+` FROM bundles WHERE service_id = ? ORDER BY uploaded_at DESC`, serviceID)
+	if err != nil {
+		fmt.Printf("⚠️ 清理部署包失败：%v\n", err)
+		return
+	}
+	var stale []Bundle
+	for i := 0; rows.Next(); i++ {
+		b, err := scanBundle(rows)
+		if err == nil && i >= siteCfg.BundleRetention {
+			stale = append(stale, b)
+		}
+	}
+	rows.Close()
+
+	for _, b := range stale {
+		if bundleProtected(b.ServiceID, b.Version) {
+			continue
+		}
+		if _, err := db.Exec(`DELETE FROM bundles WHERE service_id = ? AND version = ?`, b.ServiceID, b.Version); err != nil {
+			fmt.Printf("⚠️ 清理部署包%s@%s失败：%v\n", b.ServiceID, b.Version, err)
+			continue
+		}
+		fmt.Printf("🧹 清理部署包：%s@%s（每个服务保留%d个版本）\n", b.ServiceID, b.Version, siteCfg.BundleRetention)
+		removeBundleDir(b.Digest)
+	}
+}
+
+// bundleProtected 版本是否为某个部署的当前版本或上一个版本（部署版本记录中最新的两条）
+func bundleProtected(serviceID, version string) bool {
+	var refs int
+	db.QueryRow(`
+		SELECT COUNT(*) FROM deployment_versions v
+		WHERE v.service_id = ? AND v.version = ?
+		  AND (SELECT COUNT(*) FROM deployment_versions n WHERE n.deployment_id = v.deployment_id AND n.id > v.id) < 2`,
+		serviceID, version).Scan(&refs)
+	return refs > 0
+}
+
+// removeBundleDir 摘要目录不再被任何版本或现有部署的工作负载使用时删除（调用方须持有 bundleMutex）
+func removeBundleDir(digest string) {
+	var refs int
+	db.QueryRow(`SELECT COUNT(*) FROM bundles WHERE digest = ?`, digest).Scan(&refs)
+	if refs > 0 {
+		return
+	}
+	dir := filepath.Join(UploadDir, BundleStoreDir, strings.TrimPrefix(digest, "sha256:"))
+	ids, err := deploymentsUsingBundle(dir)
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		if _, err := loadDeployment(id); err != sql.ErrNoRows {
+			return // 现有部署的工作负载仍使用该目录
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		fmt.Printf("⚠️ 删除部署包目录%s失败：%v\n", dir, err)
+	}
+}
+
+// bundleInUse 当前版本为该包版本的部署
+func bundleInUse(serviceID, version string) []string {
+	rows, err := db.Query(`
+		SELECT v.deployment_id FROM deployment_versions v
+		WHERE v.service_id = ? AND v.version = ?
+		  AND v.id = (SELECT MAX(id) FROM deployment_versions WHERE deployment_id = v.deployment_id)
+		ORDER BY v.deployment_id`, serviceID, version)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ------------------------------
+// 部署使用的版本
+// ------------------------------
+
+// workloadSpec 按清单启动工作负载的配置
+func (b Bundle) workloadSpec() *workloadSpec {
+	script, args := b.Manifest.command()
+	return &workloadSpec{
+		Script:      script,
+		WorkDir:     b.Path,
+		Args:        args,
+		Env:         b.Manifest.Env,
+		HealthCheck: b.Manifest.HealthCheck,
+		bundle:      b.ServiceID + "@" + b.Version,
+		digest:      b.Digest,
+	}
+}
+
+// resourcePerInst 按清单确定单实例资源单位，清单未指定时为 fallback
+func (b Bundle) resourcePerInst(fallback int) int {
+	if b.Manifest.ResourceUnits > 0 {
+		return b.Manifest.ResourceUnits
+	}
+	return fallback
+}
+
+// deploymentVersions 部署的版本记录，最新的在前
+func deploymentVersions(deploymentID string) ([]DeploymentVersion, error) {
+	rows, err := db.Query(`SELECT id, version, digest, activated_at FROM deployment_versions WHERE deployment_id = ? ORDER BY id DESC`, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("查询版本记录失败：%w", err)
+	}
+	defer rows.Close()
+	history := []DeploymentVersion{}
+	for rows.Next() {
+		var v DeploymentVersion
+		if err := rows.Scan(&v.ID, &v.Version, &v.Digest, &v.ActivatedAt); err != nil {
+			return nil, fmt.Errorf("读取版本记录失败：%w", err)
+		}
+		history = append(history, v)
+	}
+	return history, nil
+}
+
+// recordDeploymentVersion 记录部署切换到包版本；回滚时删除当前版本记录 rollbackFrom，上一条记录即成为当前版本。
+// 版本在此期间被清理时返回 errBundleNotFound
+func recordDeploymentVersion(deploymentID string, b Bundle, rollbackFrom int64) error {
+	bundleMutex.Lock()
+	defer bundleMutex.Unlock()
+	current, err := loadBundle(b.ServiceID, b.Version)
+	if err != nil {
+		return err
+	}
+	if current.Digest != b.Digest {
+		return fmt.Errorf("%w：%s@%s", errBundleNotFound, b.ServiceID, b.Version)
+	}
+	if rollbackFrom > 0 {
+		_, err = db.Exec(`DELETE FROM deployment_versions WHERE id = ?`, rollbackFrom)
+	} else {
+		_, err = db.Exec(`INSERT INTO deployment_versions (deployment_id, service_id, version, digest, activated_at) VALUES (?, ?, ?, ?, ?)`,
+			deploymentID, b.ServiceID, b.Version, b.Digest, time.Now())
+	}
+	if err != nil {
+		return fmt.Errorf("记录部署版本失败：%w", err)
+	}
+	return nil
+}
+
+// activateBundle 将部署切换到包版本：按清单调整单实例资源，停止当前工作负载并按清单启动新版本
+func activateBundle(d Deployment, b Bundle, rollbackFrom int64) (Deployment, Workload, int, error) {
+	if per := b.resourcePerInst(d.ResourcePerInst); per != d.ResourcePerInst {
+		var status int
+		var err error
+		if d, status, err = resizeDeployment(d.ID, per); err != nil {
+			return d, Workload{}, status, err
+		}
+	}
+	if err := recordDeploymentVersion(d.ID, b, rollbackFrom); err != nil {
+		if errors.Is(err, errBundleNotFound) {
+			return d, Workload{}, http.StatusConflict, fmt.Errorf("版本已被清理：%w", err)
+		}
+		return d, Workload{}, http.StatusInternalServerError, err
+	}
+	if _, err := stopWorkload(d.ID); err != nil && !errors.Is(err, errWorkloadNotFound) {
+		return d, Workload{}, http.StatusInternalServerError, err
+	}
+	w, status, err := startWorkload(d.ID, b.workloadSpec())
+	return d, w, status, err
+}
+
+// ------------------------------
+// 接口
+// ------------------------------
+
+// listBundlesHandler：GET /bundles?service_id= —— 已登记的版本，最新的在前
+func listBundlesHandler(c *gin.Context) {
+	query := `SELECT ` + bundleColumns + ` FROM bundles`
+	args := []any{}
+	if serviceID := c.Query("service_id"); serviceID != "" {
+		query += ` WHERE service_id = ?`
+		args = append(args, serviceID)
+	}
+	query += ` ORDER BY service_id, uploaded_at DESC`
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "查询部署包失败：" + err.Error(),
+		})
+		return
+	}
+	bundles := []Bundle{}
+	for rows.Next() {
+		b, err := scanBundle(rows)
+		if err != nil {
+			fmt.Printf("⚠️ %v\n", err)
+			continue
+		}
+		bundles = append(bundles, b)
+	}
+	rows.Close()
+	for i := range bundles {
+		bundles[i].InUse = bundleInUse(bundles[i].ServiceID, bundles[i].Version)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"count":     len(bundles),
+		"retention": siteCfg.BundleRetention,
+		"bundles":   bundles,
+	})
+}
+
+// getBundleHandler：GET /bundles/:service_id/:version（version 可为 latest）
+func getBundleHandler(c *gin.Context) {
+	b, err := loadBundle(c.Param("service_id"), c.Param("version"))
+	if err != nil {
+		respondBundleLookupError(c, err)
+		return
+	}
+	b.InUse = bundleInUse(b.ServiceID, b.Version)
+	c.JSON(http.StatusOK, gin.H{"success": true, "bundle": b})
+}
+
+// deleteBundleHandler：DELETE /bundles/:service_id/:version —— 部署当前使用的版本及其上一个版本不能删除
+func deleteBundleHandler(c *gin.Context) {
+	bundleMutex.Lock()
+	defer bundleMutex.Unlock()
+	b, err := loadBundle(c.Param("service_id"), c.Param("version"))
+	if err != nil {
+		respondBundleLookupError(c, err)
+		return
+	}
+	if bundleProtected(b.ServiceID, b.Version) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("部署包%s@%s被部署使用（或可回滚到该版本），不能删除", b.ServiceID, b.Version),
+			"in_use":  bundleInUse(b.ServiceID, b.Version),
+		})
+		return
+	}
+	if _, err := db.Exec(`DELETE FROM bundles WHERE service_id = ? AND version = ?`, b.ServiceID, b.Version); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "删除部署包失败：" + err.Error(),
+		})
+		return
+	}
+	removeBundleDir(b.Digest)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("部署包%s@%s已删除", b.ServiceID, b.Version),
+		"bundle":  b,
+	})
+}
+
+// deploymentVersionsHandler：GET /deployments/:id/versions —— 部署的版本记录，第一条为当前版本
+func deploymentVersionsHandler(c *gin.Context) {
+	d, err := loadDeployment(c.Param("id"))
+	if err != nil {
+		respondDeploymentLookupError(c, c.Param("id"), err)
+		return
+	}
+	history, err := deploymentVersions(d.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"service_id": d.ServiceID,
+		"count":      len(history),
+		"versions":   history,
+	})
+}
+
+// setDeploymentVersionHandler：POST /deployments/:id/version —— 切换到服务的指定版本（{"version": "1.2.0"}，可为 latest）
+func setDeploymentVersionHandler(c *gin.Context) {
+	var req struct {
+		Version string `json:"version" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+	d, err := loadDeployment(c.Param("id"))
+	if err != nil {
+		respondDeploymentLookupError(c, c.Param("id"), err)
+		return
+	}
+	b, err := loadBundle(d.ServiceID, req.Version)
+	if err != nil {
+		respondBundleLookupError(c, err)
+		return
+	}
+	respondVersionSwitch(c, d, b, 0, "")
+}
+
+// rollbackDeploymentHandler：POST /deployments/:id/rollback —— 回滚到上一个版本
+func rollbackDeploymentHandler(c *gin.Context) {
+	d, err := loadDeployment(c.Param("id"))
+	if err != nil {
+		respondDeploymentLookupError(c, c.Param("id"), err)
+		return
+	}
+	history, err := deploymentVersions(d.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(history) < 2 {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":  false,
+			"message":  fmt.Sprintf("部署%s没有可回滚的上一个版本", d.ID),
+			"versions": history,
+		})
+		return
+	}
+	current, previous := history[0], history[1]
+	b, err := loadBundle(d.ServiceID, previous.Version)
+	if err == nil && b.Digest != previous.Digest {
+		err = fmt.Errorf("%w：%s@%s", errBundleNotFound, d.ServiceID, previous.Version)
+	}
+	if err != nil {
+		if errors.Is(err, errBundleNotFound) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "上一个版本已被清理：" + err.Error(),
+			})
+			return
+		}
+		respondBundleLookupError(c, err)
+		return
+	}
+	respondVersionSwitch(c, d, b, current.ID, current.Version)
+}
+
+func respondVersionSwitch(c *gin.Context, d Deployment, b Bundle, rollbackFrom int64, from string) {
+	if from == "" {
+		if history, err := deploymentVersions(d.ID); err == nil && len(history) > 0 {
+			from = history[0].Version
+		}
+	}
+	d, w, status, err := activateBundle(d, b, rollbackFrom)
+	if err != nil {
+		resp := gin.H{"success": false, "message": err.Error(), "bundle": b}
+		if w.DeploymentID != "" {
+			resp["workload"] = w
+		}
+		c.JSON(status, resp)
+		return
+	}
+	action := "切换"
+	if rollbackFrom > 0 {
+		action = "回滚"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"message":          fmt.Sprintf("部署%s已%s到版本%s", d.ID, action, b.Version),
+		"previous_version": from,
+		"deployment":       d,
+		"bundle":           b,
+		"workload":         w,
+	})
+	if from == "" {
+		from = "-"
+	}
+	fmt.Printf("[%s] 部署%s%s版本：%s → %s（%s）\n",
+		time.Now().Format("15:04:05"), d.ID, action, from, b.Version, b.Digest)
+}
+
+func respondBundleLookupError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, errBundleNotFound) {
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": err.Error(),
+	})
+}
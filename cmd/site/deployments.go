@@ -0,0 +1,392 @@
+package main
+
+import (
+	"cmas-cats-go/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deployment 站点上的一条部署记录（deployed_services 表的一行）
+type Deployment struct {
+	ID                string    `json:"id"`
+	ServiceID         string    `json:"service_id"`
+	Gas               int       `json:"gas"`
+	Cost              int       `json:"cost"`
+	CSCI_ID           string    `json:"csci_id"`
+	Delay             int       `json:"delay"`
+	ResourcePerInst   int       `json:"resource_per_inst"`
+	TotalResourceUsed int       `json:"total_resource_used"`
+	CreatedAt         time.Time `json:"created_at"`
+	InUseGas          int       `json:"in_use_gas"` // 进行中的gas预占会话数（不入库）
+}
+
+const deploymentColumns = `id, service_id, gas, cost, csci_id, delay, resource_per_inst, total_resource_used, created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeployment(row rowScanner) (Deployment, error) {
+	var d Deployment
+	err := row.Scan(&d.ID, &d.ServiceID, &d.Gas, &d.Cost, &d.CSCI_ID, &d.Delay,
+		&d.ResourcePerInst, &d.TotalResourceUsed, &d.CreatedAt)
+	return d, err
+}
+
+// instanceInfo 部署对应的实例信息（与 /metrics 一致，gas 为剩余可用能力）
+func (d Deployment) instanceInfo() *models.ServiceInstanceInfo {
+	return &models.ServiceInstanceInfo{
+		ServiceID: d.ServiceID,
+		Gas:       max(d.Gas-d.InUseGas, 0),
+		Capacity:  d.Gas,
+		Cost:      d.Cost,
+		CSCI_ID:   d.CSCI_ID,
+		Delay:     d.Delay,
+		SiteID:    SiteID,
+	}
+}
+
+func loadDeployment(id string) (Deployment, error) {
+	return scanDeployment(db.QueryRow(`SELECT `+deploymentColumns+` FROM deployed_services WHERE id = ?`, id))
+}
+
+// simulatedDelay 模拟延迟（10-20ms，与实例数量正相关），部署与扩缩容共用
+func simulatedDelay(gas int) int {
+	return 10 + (gas % 10)
+}
+
+// listDeploymentsHandler：GET /deployments
+// 支持过滤：?service_id= / ?min_gas= / ?max_gas= / ?since=&until=（RFC3339，按创建时间）/ ?limit=
+func listDeploymentsHandler(c *gin.Context) {
+	query := `SELECT ` + deploymentColumns + ` FROM deployed_services WHERE 1 = 1`
+	args := []interface{}{}
+
+	if serviceID := c.Query("service_id"); serviceID != "" {
+		query += ` AND service_id = ?`
+		args = append(args, serviceID)
+	}
+	for param, cond := range map[string]string{"min_gas": ` AND gas >= ?`, "max_gas": ` AND gas <= ?`} {
+		if v := c.Query(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"message": fmt.Sprintf("参数%s必须为整数：%s", param, v),
+				})
+				return
+			}
+			query += cond
+			args = append(args, n)
+		}
+	}
+	for param, cond := range map[string]string{"since": ` AND created_at >= ?`, "until": ` AND created_at <= ?`} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"message": fmt.Sprintf("参数%s必须为RFC3339时间：%s", param, v),
+				})
+				return
+			}
+			query += cond
+			args = append(args, t)
+		}
+	}
+	query += ` ORDER BY created_at DESC`
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "参数limit必须为正整数：" + v,
+			})
+			return
+		}
+		query += ` LIMIT ?`
+		args = append(args, n)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "查询部署记录失败：" + err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+
+	deployments := []Deployment{}
+	for rows.Next() {
+		d, err := scanDeployment(rows)
+		if err != nil {
+			fmt.Printf("⚠️ 解析部署记录失败：%v\n", err)
+			continue
+		}
+		deployments = append(deployments, d)
+	}
+	inUse := inUseGasSnapshot()
+	for i := range deployments {
+		deployments[i].InUseGas = inUse[deployments[i].ID]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"site_id":     SiteID,
+		"count":       len(deployments),
+		"deployments": deployments,
+	})
+}
+
+// getDeploymentHandler：GET /deployments/:id
+func getDeploymentHandler(c *gin.Context) {
+	d, err := loadDeployment(c.Param("id"))
+	if err != nil {
+		respondDeploymentLookupError(c, c.Param("id"), err)
+		return
+	}
+	d.InUseGas = inUseGasSnapshot()[d.ID]
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"deployment": d,
+	})
+}
+
+// deleteDeploymentHandler：DELETE /deployments/:id
+// 停止其工作负载，删除部署记录并释放其占用的资源，实例随即从 /metrics 中消失
+func deleteDeploymentHandler(c *gin.Context) {
+	id := c.Param("id")
+	d, _, err := removeDeployment(id)
+	if err != nil {
+		respondRemoveError(c, id, err)
+		return
+	}
+
+	resourceMutex.RLock()
+	used := usedResource
+	resourceMutex.RUnlock()
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    fmt.Sprintf("部署%s已删除，释放%d单位资源", id, d.TotalResourceUsed),
+		"deployment": d,
+		"resource_detail": map[string]int{
+			"released":           d.TotalResourceUsed,
+			"current_used":       used,
+			"remaining_resource": TotalResource - used,
+		},
+	})
+}
+
+// removeDeployment 停止部署的工作负载，删除部署记录并释放其占用的资源，推送下线事件使实例从 /metrics 中消失；
+// 返回被删除的部署与工作负载的最终状态（没有工作负载时为 nil）
+func removeDeployment(id string) (Deployment, *Workload, error) {
+	// 先停止工作负载进程（可能等待至 WorkloadStopTimeout，不占用资源锁）
+	var workload *Workload
+	if w, err := stopWorkload(id); err == nil {
+		workload = &w
+	} else if !errors.Is(err, errWorkloadNotFound) {
+		fmt.Printf("⚠️ 停止部署%s的工作负载失败：%v\n", id, err)
+	}
+
+	// 资源检查与数据库更新在同一把写锁内完成，保证 usedResource 与数据库一致
+	resourceMutex.Lock()
+	defer resourceMutex.Unlock()
+
+	d, err := loadDeployment(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Deployment{}, workload, err
+		}
+		return Deployment{}, workload, fmt.Errorf("查询部署记录失败：%w", err)
+	}
+	if _, err := db.Exec(`DELETE FROM deployed_services WHERE id = ?`, id); err != nil {
+		return d, workload, fmt.Errorf("删除部署失败（数据库错误）：%w", err)
+	}
+	db.Exec(`DELETE FROM deployment_events WHERE deployment_id = ?`, id)
+	db.Exec(`DELETE FROM deployment_versions WHERE deployment_id = ?`, id) // 其当前及上一个包版本不再受保留保护
+	if err := os.RemoveAll(workloadDataDir(id)); err != nil {
+		fmt.Printf("⚠️ 清除部署%s的数据目录失败：%v\n", id, err)
+	}
+	usedResource -= d.TotalResourceUsed
+	d.InUseGas = dropDeploymentSessions(id) // 进行中的会话随部署一并失效
+	pushMetricsUpdate(models.MetricsUpdate{
+		Event:   models.MetricsEventUndeploy,
+		CSCI_ID: d.CSCI_ID,
+	})
+	fmt.Printf("[%s] 删除部署：ID=%s, 服务=%s, 释放资源%d单位\n",
+		time.Now().Format("15:04:05"), id, d.ServiceID, d.TotalResourceUsed)
+	return d, workload, nil
+}
+
+// respondRemoveError removeDeployment 失败时的响应：部署不存在返回404，其余为500
+func respondRemoveError(c *gin.Context, id string, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "部署不存在：" + id,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"success": false,
+		"message": err.Error(),
+	})
+}
+
+// scaleDeploymentHandler：PATCH /deployments/:id
+// 调整实例数量（gas），按部署时的单实例资源占用重新检查资源并重算成本；
+// 缩容后的gas不得低于进行中的预占会话数
+func scaleDeploymentHandler(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		Gas int `json:"gas" binding:"min=1"` // 调整后的实例数量（至少1个；缩容到0请使用DELETE）
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+
+	resourceMutex.Lock()
+	defer resourceMutex.Unlock()
+
+	d, err := loadDeployment(id)
+	if err != nil {
+		respondDeploymentLookupError(c, id, err)
+		return
+	}
+
+	// 持有会话锁直至更新完成，避免缩容检查后又有新会话预占
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+	d.InUseGas = inUseGas(d.ID)
+	if req.Gas < d.InUseGas {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":    false,
+			"message":    fmt.Sprintf("部署%s当前有%d个进行中的会话，gas不能缩容至%d", id, d.InUseGas, req.Gas),
+			"in_use_gas": d.InUseGas,
+		})
+		return
+	}
+
+	newTotal := d.ResourcePerInst * req.Gas
+	delta := newTotal - d.TotalResourceUsed
+	remaining := TotalResource - usedResource
+	if delta > remaining {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("资源不足！当前已用%d/总%d单位，扩容需新增%d单位，剩余%d单位",
+				usedResource, TotalResource, delta, remaining),
+			"resource_status": map[string]int{
+				"used":      usedResource,
+				"total":     TotalResource,
+				"remaining": remaining,
+				"need":      delta,
+			},
+		})
+		return
+	}
+
+	previousGas := d.Gas
+	d.Gas = req.Gas
+	d.Cost = calculateCostByResource(newTotal)
+	d.Delay = simulatedDelay(req.Gas)
+	d.TotalResourceUsed = newTotal
+	_, err = db.Exec(`
+		UPDATE deployed_services SET gas = ?, cost = ?, delay = ?, total_resource_used = ?
+		WHERE id = ?`,
+		d.Gas, d.Cost, d.Delay, d.TotalResourceUsed, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "调整部署失败（数据库错误）：" + err.Error(),
+		})
+		return
+	}
+	usedResource += delta
+	updateSandboxLimits(id, newTotal) // 运行中的工作负载按新的资源单位调整 CPU/内存上限
+	pushMetricsUpdate(models.MetricsUpdate{
+		Event:    models.MetricsEventScale,
+		Instance: d.instanceInfo(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    fmt.Sprintf("部署%s实例数已从%d调整为%d", id, previousGas, req.Gas),
+		"deployment": d,
+		"resource_detail": map[string]int{
+			"delta":              delta,
+			"current_used":       usedResource,
+			"remaining_resource": TotalResource - usedResource,
+		},
+	})
+	fmt.Printf("[%s] 调整部署：ID=%s, 实例数%d→%d, 成本=%d, 资源变化%+d单位\n",
+		time.Now().Format("15:04:05"), id, previousGas, req.Gas, d.Cost, delta)
+}
+
+// resizeDeployment 调整部署的单实例资源占用（切换部署包版本时按清单的 resource_units），
+// 重新检查资源并重算成本；返回调整后的部署，失败时附带响应状态码
+func resizeDeployment(id string, resourcePerInst int) (Deployment, int, error) {
+	resourceMutex.Lock()
+	defer resourceMutex.Unlock()
+
+	d, err := loadDeployment(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return d, http.StatusNotFound, fmt.Errorf("部署不存在：%s", id)
+		}
+		return d, http.StatusInternalServerError, fmt.Errorf("查询部署记录失败：%w", err)
+	}
+	newTotal := resourcePerInst * d.Gas
+	delta := newTotal - d.TotalResourceUsed
+	if remaining := TotalResource - usedResource; delta > remaining {
+		return d, http.StatusForbidden, fmt.Errorf("资源不足！当前已用%d/总%d单位，单实例资源调整为%d单位需新增%d单位，剩余%d单位",
+			usedResource, TotalResource, resourcePerInst, delta, remaining)
+	}
+
+	previous := d.ResourcePerInst
+	d.ResourcePerInst = resourcePerInst
+	d.TotalResourceUsed = newTotal
+	d.Cost = calculateCostByResource(newTotal)
+	_, err = db.Exec(`
+		UPDATE deployed_services SET resource_per_inst = ?, cost = ?, total_resource_used = ?
+		WHERE id = ?`,
+		d.ResourcePerInst, d.Cost, d.TotalResourceUsed, id)
+	if err != nil {
+		return d, http.StatusInternalServerError, fmt.Errorf("调整部署失败（数据库错误）：%w", err)
+	}
+	usedResource += delta
+	updateSandboxLimits(id, newTotal)
+	d.InUseGas = inUseGasSnapshot()[id]
+	pushMetricsUpdate(models.MetricsUpdate{
+		Event:    models.MetricsEventScale,
+		Instance: d.instanceInfo(),
+	})
+	fmt.Printf("[%s] 调整部署：ID=%s, 单实例资源%d→%d, 成本=%d, 资源变化%+d单位\n",
+		time.Now().Format("15:04:05"), id, previous, resourcePerInst, d.Cost, delta)
+	return d, http.StatusOK, nil
+}
+
+func respondDeploymentLookupError(c *gin.Context, id string, err error) {
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "部署不存在：" + id,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"success": false,
+		"message": "查询部署记录失败：" + err.Error(),
+	})
+}
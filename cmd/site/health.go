@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// 工作负载健康检查：按部署包清单的 health_check 探测工作负载监听的端口，
+// 连续失败达到阈值时记录部署事件并结束进程，由重启策略决定是否重启
+// ------------------------------
+
+// 健康状态（仅配置了健康检查的工作负载）
+const (
+	HealthStarting  = "starting"  // 尚未通过首次检查
+	HealthHealthy   = "healthy"   // 最近一次检查通过
+	HealthUnhealthy = "unhealthy" // 连续失败达到阈值，进程已被结束
+)
+
+// HealthCheck 健康检查配置：path 非空时对 http://127.0.0.1:<port><path> 发起 GET（2xx/3xx 为通过），否则检查 TCP 端口可连接
+type HealthCheck struct {
+	Port                int    `yaml:"port" json:"port"`
+	Path                string `yaml:"path,omitempty" json:"path,omitempty"`
+	IntervalSeconds     int    `yaml:"interval_seconds,omitempty" json:"interval_seconds,omitempty"`           // 默认10
+	TimeoutSeconds      int    `yaml:"timeout_seconds,omitempty" json:"timeout_seconds,omitempty"`             // 默认2
+	FailureThreshold    int    `yaml:"failure_threshold,omitempty" json:"failure_threshold,omitempty"`         // 连续失败次数，默认3
+	InitialDelaySeconds int    `yaml:"initial_delay_seconds,omitempty" json:"initial_delay_seconds,omitempty"` // 启动后首次检查前等待，默认5
+}
+
+// normalize 校验并补全默认值
+func (h *HealthCheck) normalize() error {
+	if h.Port <= 0 || h.Port > 65535 {
+		return fmt.Errorf("健康检查端口不合法：%d", h.Port)
+	}
+	if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+		return fmt.Errorf("健康检查路径必须以 / 开头：%s", h.Path)
+	}
+	if h.IntervalSeconds < 0 || h.TimeoutSeconds < 0 || h.FailureThreshold < 0 || h.InitialDelaySeconds < 0 {
+		return fmt.Errorf("健康检查的间隔、超时、阈值与初始等待不能为负数")
+	}
+	if h.IntervalSeconds == 0 {
+		h.IntervalSeconds = 10
+	}
+	if h.TimeoutSeconds == 0 {
+		h.TimeoutSeconds = 2
+	}
+	if h.FailureThreshold == 0 {
+		h.FailureThreshold = 3
+	}
+	if h.InitialDelaySeconds == 0 {
+		h.InitialDelaySeconds = 5
+	}
+	return nil
+}
+
+// probe 执行一次检查
+func (h HealthCheck) probe() error {
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(h.Port))
+	timeout := time.Duration(h.TimeoutSeconds) * time.Second
+	if h.Path == "" {
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get("http://" + addr + h.Path)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP状态码%d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (h HealthCheck) target() string {
+	if h.Path == "" {
+		return fmt.Sprintf("tcp://127.0.0.1:%d", h.Port)
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d%s", h.Port, h.Path)
+}
+
+// watchHealth 检查工作负载的一次运行，直至 stop 关闭（进程退出）；连续失败达到阈值时结束进程
+func watchHealth(w *Workload, hc HealthCheck, proc *os.Process, stop <-chan struct{}) {
+	select {
+	case <-time.After(time.Duration(hc.InitialDelaySeconds) * time.Second):
+	case <-stop:
+		return
+	}
+	ticker := time.NewTicker(time.Duration(hc.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		err := hc.probe()
+		if err == nil {
+			failures = 0
+			setHealth(w, proc, HealthHealthy)
+		} else if failures++; failures >= hc.FailureThreshold {
+			if !setHealth(w, proc, HealthUnhealthy) {
+				return
+			}
+			recordDeploymentEvent(w.DeploymentID, EventHealthCheckFailed, EventError,
+				fmt.Sprintf("健康检查%s连续失败%d次（%v），结束进程", hc.target(), failures, err))
+			if err := terminateProcess(proc); err != nil {
+				fmt.Printf("⚠️ 向工作负载%s发送SIGTERM失败：%v\n", w.DeploymentID, err)
+			}
+			select {
+			case <-stop:
+			case <-time.After(WorkloadStopTimeout):
+				killProcess(proc)
+			}
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// setHealth 更新本次运行的健康状态；进程已不是 proc（已退出或被重启）时返回 false
+func setHealth(w *Workload, proc *os.Process, health string) bool {
+	workloadMutex.Lock()
+	defer workloadMutex.Unlock()
+	if w.cmd == nil || w.cmd.Process != proc {
+		return false
+	}
+	if w.Health != health {
+		w.Health = health
+		saveWorkload(w)
+	}
+	return true
+}
@@ -1,21 +1,21 @@
 package main
 
 import (
-	"archive/zip"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
-    "strconv" // ❗ 需要添加 strconv 导入，因为它在其他站点中被使用，尽管此文件中没有直接使用
-    
+
 	"cmas-cats-go/config"
 	"cmas-cats-go/models"
+	"cmas-cats-go/telemetry"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/mattn/go-sqlite3" // SQLite驱动
@@ -24,24 +24,62 @@ import (
 // 全局状态：资源管理（需加锁保证并发安全）
 var (
 	db                *sql.DB
-	usedResource      int                               // 已使用资源单位（动态更新）
-	resourceMutex     sync.RWMutex                      // 资源操作锁（避免并发修改冲突）
-	serviceStore      = make(map[string]models.Service) // 缓存已查询的服务信息，减少重复请求
-	serviceStoreMutex sync.RWMutex                      // 服务信息缓存锁
+	usedResource      int                              // 已使用资源单位（动态更新）
+	resourceMutex     sync.RWMutex                     // 资源操作锁（避免并发修改冲突）
+	serviceStore      = make(map[string]cachedService) // 缓存已查询的服务信息，减少重复请求
+	serviceStoreMutex sync.RWMutex                     // 服务信息缓存锁
+	siteCfg           *config.SiteConfig               // 本站点配置（对外地址等）
+)
+
+// cachedService 服务信息缓存项（带拉取时间，过期后重新查询以感知生命周期状态变化）
+type cachedService struct {
+	service   models.Service
+	fetchedAt time.Time
+}
+
+// 服务站点核心配置（资源与成本相关），启动时从拓扑配置中本站点条目加载
+// 同一二进制通过 -id 参数即可启动任意站点：go run ./cmd/site -id site-2
+var (
+	SiteID          string // 站点唯一标识
+	DBFile          string // 数据库文件路径
+	TotalResource   int    // 站点总资源单位（可根据硬件调整）
+	ResourcePerCost int    // 每多少单位资源对应1个成本单位（成本换算系数）
 )
 
-// 服务站点核心配置（资源与成本相关）
 const (
-	DBFile          = "./db/site1.db" // 数据库文件路径
-	SiteID          = "site-1"        // 站点唯一标识
-	TotalResource   = 400             // 站点总资源单位（可根据硬件调整）
-	ResourcePerCost = 40              // 每40单位资源对应1个成本单位（成本换算系数）
+	ServiceCacheTTL = 30 * time.Second // 服务信息缓存有效期（状态变更最多延迟该时长生效）
+	UploadDir       = "./uploads"      // 上传包保存与解压目录（工作负载只能在其中运行）
 )
 
 func main() {
+	// 站点以 sandboxInitArg 重新执行自身时作为工作负载沙箱内的1号进程
+	if len(os.Args) > 1 && os.Args[1] == sandboxInitArg {
+		os.Exit(runSandboxInit(os.Args[2:]))
+	}
+
+	// 0. 加载拓扑配置，确定本站点身份、资源与对外地址
+	flags := config.BindFlags()
+	flag.Parse()
+	if err := flags.Load(); err != nil {
+		fmt.Printf("❌ 加载配置失败，程序退出：%v\n", err)
+		return
+	}
+	var err error
+	if siteCfg, err = config.Site(flags.ID); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if flags.Port > 0 {
+		siteCfg.OverridePort(flags.Port)
+	}
+	SiteID = siteCfg.ID
+	DBFile = siteCfg.DBFile
+	TotalResource = siteCfg.TotalResource
+	ResourcePerCost = siteCfg.ResourcePerCost
+
 	// 启动标识日志
 	fmt.Println("=====================================")
-	fmt.Println("          服务站点（site-1）启动中...          ")
+	fmt.Printf("          服务站点（%s）启动中...          \n", SiteID)
 	fmt.Println("=====================================")
 	fmt.Printf("📌 站点总资源：%d 单位 | 成本换算：每%d单位资源=1成本\n",
 		TotalResource, ResourcePerCost)
@@ -58,8 +96,21 @@ func main() {
 		fmt.Printf("⚠️ 加载历史资源占用失败：%v（将从0开始计算）\n", err)
 	}
 
+	// 部署包存储（按内容摘要存放的上传包、版本登记与部署的版本记录）
+	if err := initBundles(); err != nil {
+		fmt.Printf("❌ 初始化失败，程序退出：%v\n", err)
+		return
+	}
+
+	// 工作负载监管（加载已有工作负载，站点重启前运行中的按重启策略重新拉起）
+	if err := initSupervisor(); err != nil {
+		fmt.Printf("❌ 初始化失败，程序退出：%v\n", err)
+		return
+	}
+
 	// 3. 初始化Gin引擎
 	r := gin.Default()
+	telemetry.Register(r, "site", SiteID) // 运维指标（GET /prometheus）
 
 	// 4. 注册API接口
 	r.POST("/deploy", deployServiceHandler)      // 部署服务实例（核心：资源+成本计算）
@@ -67,22 +118,52 @@ func main() {
 	r.GET("/health", healthCheckHandler)         // 健康检查接口
 	r.GET("/resource-status", getResourceStatus) // 查看资源占用状态
 	r.POST("/upload", uploadHandler)             // 文件上传接口（供WebUI使用）
-	r.POST("/execute", executeHandler)           // 执行脚本接口（供WebUI使用）
+	r.POST("/execute", executeHandler)           // 为部署启动上传包中的脚本（供WebUI使用）
+	r.POST("/stop", stopHandler)                 // 停止部署的工作负载并释放资源（供WebUI使用）
+	r.POST("/validate", validateHandler)         // 用验证样本调用部署的工作负载，将输出提交至公共服务平台
+
+	// 部署管理：列表/查询、扩缩容、删除（释放资源）
+	r.GET("/deployments", listDeploymentsHandler)
+	r.GET("/deployments/:id", getDeploymentHandler)
+	r.PATCH("/deployments/:id", scaleDeploymentHandler)
+	r.DELETE("/deployments/:id", deleteDeploymentHandler)
+
+	// 工作负载进程：启动/停止/状态/日志
+	r.GET("/workloads", listWorkloadsHandler)
+	r.POST("/deployments/:id/start", startWorkloadHandler)
+	r.POST("/deployments/:id/stop", stopWorkloadHandler)
+	r.GET("/deployments/:id/status", workloadStatusHandler)
+	r.GET("/deployments/:id/logs", workloadLogsHandler)
+	r.GET("/deployments/:id/events", deploymentEventsHandler) // 部署事件（沙箱越限、隔离降级、健康检查失败）
+
+	// 部署包版本：列表/查询/删除，部署切换版本与回滚
+	r.GET("/bundles", listBundlesHandler)
+	r.GET("/bundles/:service_id/:version", getBundleHandler)
+	r.DELETE("/bundles/:service_id/:version", deleteBundleHandler)
+	r.GET("/deployments/:id/versions", deploymentVersionsHandler)
+	r.POST("/deployments/:id/version", setDeploymentVersionHandler)
+	r.POST("/deployments/:id/rollback", rollbackDeploymentHandler)
+
+	// gas预占会话：C-PS/客户端按请求占用与归还实例能力
+	r.POST("/sessions", acquireSessionHandler)
+	r.GET("/sessions", listSessionsHandler)
+	r.POST("/sessions/:id/renew", renewSessionHandler)
+	r.DELETE("/sessions/:id", releaseSessionHandler)
+	startSessionReaper()
+
+	// 向C-SMA注册并维持心跳，主动推送实例变化（C-SMA 定时拉取作为兜底）
+	initMetricsPush()
+	startRegistration()
 
 	// 5. 启动服务配置
-    // ❗ 修正：监听地址使用 0.0.0.0 确保远程 SSH 启动时可以绑定，端口从配置中获取
-    listenAddr := "0.0.0.0:" + strconv.Itoa(config.Cfg.Site1.Port)
-    
+	// 监听地址使用 0.0.0.0 确保远程 SSH 启动时可以绑定，端口从配置中获取
+	listenAddr := "0.0.0.0:" + strconv.Itoa(siteCfg.Port)
+
 	publicPlatformURL := fmt.Sprintf("%s/api/v1/services/", config.Cfg.Platform.URL)
 
-    // ❗ 修正：将原有的启动信息打印函数 printStartInfo() 挪到 r.Run() 之前 ❗
-    // ❗ 原始代码的 printStartInfo() 里面有重复的 listenAddr 打印，已在下面简化
 	fmt.Printf("📌 平台地址：%s\n", publicPlatformURL)
-    printStartInfo()
-    
-    // 移除 printStartInfo() 中的 listenAddr 打印，在下面统一打印
-    fmt.Printf("📌 监听地址：http://%s\n", listenAddr)
-
+	printStartInfo()
+	fmt.Printf("📌 监听地址：http://%s（对外地址：%s）\n", listenAddr, siteCfg.URL)
 
 	if err := r.Run(listenAddr); err != nil {
 		fmt.Printf("服务启动失败：%v\n", err)
@@ -134,7 +215,7 @@ func initDB() error {
 // loadUsedResource：从数据库加载历史资源占用（避免重启后统计清零）
 func loadUsedResource() error {
 	// 查询所有已部署实例的总资源占用
-	row := db.QueryRow(`SELECT SUM(total_resource_used) FROM deployed_services`)
+	row := db.QueryRow(`SELECT COALESCE(SUM(total_resource_used), 0) FROM deployed_services`)
 	var totalUsed int
 	err := row.Scan(&totalUsed)
 	if err != nil && err != sql.ErrNoRows {
@@ -159,6 +240,7 @@ func deployServiceHandler(c *gin.Context) {
 	var req struct {
 		ServiceID string `json:"service_id" binding:"required"` // 目标服务ID（动态生成的ID，如AR1760108514766）
 		Gas       int    `json:"gas" binding:"min=1"`           // 部署实例数量（至少1个）
+		Version   string `json:"version"`                       // 可选：部署包版本（或 latest），按其清单确定资源并启动工作负载
 	}
 
 	// 1. 解析请求参数
@@ -171,7 +253,7 @@ func deployServiceHandler(c *gin.Context) {
 	}
 
 	// 2. 获取服务类型（按服务ID查询公共服务平台，获取服务名）
-	serviceName, err := getServiceNameByID(req.ServiceID)
+	service, err := getServiceByID(req.ServiceID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -179,26 +261,56 @@ func deployServiceHandler(c *gin.Context) {
 		})
 		return
 	}
+	serviceName := service.Name
 
-	// 3. 确定单个实例的资源占用（按服务名区分）
-	resourcePerInst, err := getResourcePerInstance(serviceName)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	// 2.1 生命周期检查：草稿或已下线的服务不允许部署
+	if !service.IsDeployable() {
+		c.JSON(http.StatusConflict, gin.H{
 			"success": false,
-			"message": err.Error(),
+			"message": fmt.Sprintf("服务%s当前状态为%s，不允许部署", req.ServiceID, service.Status),
+			"status":  service.Status,
 		})
 		return
 	}
+	if service.Status == models.ServiceStatusDeprecated {
+		fmt.Printf("⚠️ 服务%s已被标记为deprecated，仍按请求部署\n", req.ServiceID)
+	}
+
+	// 3. 确定单个实例的资源占用（按服务注册的结构化资源需求计算，部署包清单指定了 resource_units 时以清单为准）
+	var bundle *Bundle
+	if req.Version != "" {
+		b, err := loadBundle(req.ServiceID, req.Version)
+		if err != nil {
+			respondBundleLookupError(c, err)
+			return
+		}
+		bundle = &b
+	}
+	resourcePerInst := 0
+	if bundle == nil || bundle.Manifest.ResourceUnits == 0 {
+		resourcePerInst, err = getResourcePerInstance(service)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	if bundle != nil {
+		resourcePerInst = bundle.resourcePerInst(resourcePerInst)
+	}
 
 	// 4. 计算本次部署的总资源需求
 	totalResourceNeed := resourcePerInst * req.Gas
 
-	// 5. 检查资源是否充足（加读写锁：读已用资源，避免并发冲突）
-	resourceMutex.RLock()
+	// 5. 检查资源是否充足（写锁持有到入库与资源占用完成，避免与删除/扩缩容并发导致超额占用；
+	// 记录版本与启动工作负载在释放锁之后进行，与 activateBundle 一致）
+	resourceMutex.Lock()
 	remainingResource := TotalResource - usedResource
-	resourceMutex.RUnlock()
 
 	if totalResourceNeed > remainingResource {
+		resourceMutex.Unlock()
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
 			"message": fmt.Sprintf("资源不足！当前已用%d/总%d单位，本次需%d单位，剩余%d单位",
@@ -217,19 +329,14 @@ func deployServiceHandler(c *gin.Context) {
 	cost := calculateCostByResource(totalResourceNeed)
 
 	// 7. 生成实例基础信息
-    // ❗ 修正：监听地址使用配置中的 Site1 IP
+	// 实例地址使用配置中的本站点对外地址
 	instanceID := fmt.Sprintf("%s-%s-%d", req.ServiceID, SiteID, time.Now().UnixNano()/1e6)
-	listenAddr := fmt.Sprintf("%s:%d", config.Cfg.Site1.IP, config.Cfg.Site1.Port)
+	listenAddr := fmt.Sprintf("%s:%d", siteCfg.IP, siteCfg.Port)
 	csciID := fmt.Sprintf("http://%s/%s", listenAddr, instanceID)
-	delay := 10 + (req.Gas % 10) // 模拟延迟（10-20ms，与实例数量正相关）
+	delay := simulatedDelay(req.Gas)
 	createdAt := time.Now()
 
-	// 8. 占用资源（加写锁：更新已用资源）
-	resourceMutex.Lock()
-	usedResource += totalResourceNeed
-	resourceMutex.Unlock()
-
-	// 9. 存入数据库（含资源相关字段，便于重启后加载）
+	// 8. 存入数据库（含资源相关字段，便于重启后加载）
 	_, err = db.Exec(`
 		INSERT INTO deployed_services (
 			id, service_id, gas, cost, csci_id, created_at, delay,
@@ -239,11 +346,7 @@ func deployServiceHandler(c *gin.Context) {
 		resourcePerInst, totalResourceNeed) // 存储资源相关信息
 
 	if err != nil {
-		// 数据库插入失败，回滚资源占用
-		resourceMutex.Lock()
-		usedResource -= totalResourceNeed
 		resourceMutex.Unlock()
-
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": "部署失败（数据库错误）：" + err.Error(),
@@ -251,10 +354,28 @@ func deployServiceHandler(c *gin.Context) {
 		return
 	}
 
+	// 9. 入库成功后占用资源
+	usedResource += totalResourceNeed
+	pushMetricsUpdate(models.MetricsUpdate{
+		Event: models.MetricsEventDeploy,
+		Instance: &models.ServiceInstanceInfo{
+			ServiceID: req.ServiceID,
+			Gas:       req.Gas,
+			Capacity:  req.Gas,
+			Cost:      cost,
+			CSCI_ID:   csciID,
+			Delay:     delay,
+			SiteID:    SiteID,
+		},
+	})
+	currentUsed := usedResource
+	resourceMutex.Unlock()
+
 	// 10. 返回成功响应（包含资源和成本明细）
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": fmt.Sprintf("服务实例部署成功：%s（服务名：%s，%d个实例）", req.ServiceID, serviceName, req.Gas),
+	resp := gin.H{
+		"success":       true,
+		"message":       fmt.Sprintf("服务实例部署成功：%s（服务名：%s，%d个实例）", req.ServiceID, serviceName, req.Gas),
+		"deployment_id": instanceID,
 		"info": models.ServiceInstanceInfo{
 			ServiceID: req.ServiceID,
 			Gas:       req.Gas,
@@ -263,12 +384,25 @@ func deployServiceHandler(c *gin.Context) {
 			Delay:     delay,
 		},
 		"resource_detail": map[string]int{
-			"single_inst_resource": resourcePerInst,              // 单个实例资源占用
-			"total_resource_used":  totalResourceNeed,            // 本次占用总资源
-			"current_used":         usedResource,                 // 部署后总已用资源
-			"remaining_resource":   TotalResource - usedResource, // 剩余资源
+			"single_inst_resource": resourcePerInst,             // 单个实例资源占用
+			"total_resource_used":  totalResourceNeed,           // 本次占用总资源
+			"current_used":         currentUsed,                 // 部署后总已用资源
+			"remaining_resource":   TotalResource - currentUsed, // 剩余资源
 		},
-	})
+	}
+	// 指定了部署包版本时记录版本并按清单启动工作负载（启动失败不影响部署本身，见 workload_error）
+	if bundle != nil {
+		resp["bundle"] = bundle
+		if err := recordDeploymentVersion(instanceID, *bundle, 0); err != nil {
+			resp["workload_error"] = err.Error()
+		} else if w, _, err := startWorkload(instanceID, bundle.workloadSpec()); err != nil {
+			resp["workload_error"] = err.Error()
+			resp["workload"] = w
+		} else {
+			resp["workload"] = w
+		}
+	}
+	c.JSON(http.StatusOK, resp)
 	fmt.Printf("[%s] 部署成功：ID=%s, 服务名=%s, 实例数=%d, 成本=%d（占用资源%d单位）\n",
 		time.Now().Format("15:04:05"), instanceID, serviceName, req.Gas, cost, totalResourceNeed)
 }
@@ -277,14 +411,14 @@ func deployServiceHandler(c *gin.Context) {
 // 核心3：服务信息查询与资源计算工具函数 (保持不变)
 // ------------------------------
 
-// getServiceNameByID：按服务ID查询公共服务平台，获取服务名（含缓存）
-func getServiceNameByID(serviceID string) (string, error) {
+// getServiceByID：按服务ID查询公共服务平台，获取服务信息（含缓存，超过ServiceCacheTTL后重新查询）
+func getServiceByID(serviceID string) (models.Service, error) {
 	// 1. 先查缓存，避免重复请求公共服务平台
 	serviceStoreMutex.RLock()
-	cachedService, exists := serviceStore[serviceID]
+	cached, exists := serviceStore[serviceID]
 	serviceStoreMutex.RUnlock()
-	if exists {
-		return cachedService.Name, nil
+	if exists && time.Since(cached.fetchedAt) < ServiceCacheTTL {
+		return cached.service, nil
 	}
 
 	// 2. 缓存未命中，调用公共服务平台接口查询
@@ -292,13 +426,13 @@ func getServiceNameByID(serviceID string) (string, error) {
 	reqURL := publicPlatformURL + serviceID
 	resp, err := http.Get(reqURL)
 	if err != nil {
-		return "", fmt.Errorf("调用公共服务平台失败：%w", err)
+		return models.Service{}, fmt.Errorf("调用公共服务平台失败：%w", err)
 	}
 	defer resp.Body.Close()
 
 	// 3. 检查响应状态码
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("公共服务平台返回错误状态码：%d（服务ID：%s）", resp.StatusCode, serviceID)
+		return models.Service{}, fmt.Errorf("公共服务平台返回错误状态码：%d（服务ID：%s）", resp.StatusCode, serviceID)
 	}
 
 	// 4. 解析响应，提取服务名
@@ -308,35 +442,30 @@ func getServiceNameByID(serviceID string) (string, error) {
 		Message string         `json:"message"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("解析服务信息失败：%w", err)
+		return models.Service{}, fmt.Errorf("解析服务信息失败：%w", err)
 	}
 	if !result.Success {
-		return "", fmt.Errorf("公共服务平台查询失败：%s（服务ID：%s）", result.Message, serviceID)
+		return models.Service{}, fmt.Errorf("公共服务平台查询失败：%s（服务ID：%s）", result.Message, serviceID)
 	}
 
 	// 5. 存入缓存，后续复用
 	serviceStoreMutex.Lock()
-	serviceStore[serviceID] = result.Service
+	serviceStore[serviceID] = cachedService{service: result.Service, fetchedAt: time.Now()}
 	serviceStoreMutex.Unlock()
 
-	return result.Service.Name, nil
+	return result.Service, nil
 }
 
-// getResourcePerInstance：根据服务名分配单个实例的资源占用
-func getResourcePerInstance(serviceName string) (int, error) {
-	// 按服务名定义资源占用（覆盖所有已注册服务类型）
-	switch serviceName {
-	case "AR/VR": // AR/VR服务（如AR1760106899671）
-		return 60, nil
-	case "交通流量监测": // 交通流量监测服务（如AR1760108487856）
-		return 15, nil // 交通服务资源占用较低，15单位/实例
-	case "人脸识别": // 人脸识别服务（如AR1760108501919）
-		return 50, nil // 人脸识别计算密集，50单位/实例
-	case "语音转文字": // 语音转文字服务（如AR1760108514766）
-		return 30, nil // 语音处理中等资源消耗，30单位/实例
-	default:
-		return 0, fmt.Errorf("不支持的服务类型：%s（请先在getResourcePerInstance函数中定义资源占用）", serviceName)
+// getResourcePerInstance：根据服务的结构化资源需求计算单个实例的资源占用
+func getResourcePerInstance(service models.Service) (int, error) {
+	if service.Requirements != nil {
+		if err := service.Requirements.Validate(); err != nil {
+			return 0, fmt.Errorf("服务%s的资源需求不合法：%w", service.ID, err)
+		}
+		return service.Requirements.UnitsPerInstance(), nil
 	}
+	return 0, fmt.Errorf("服务%s（%s）未登记结构化资源需求，请在公共服务平台通过 PATCH /api/v1/services/%s 补充requirements",
+		service.ID, service.Name, service.ID)
 }
 
 // calculateCostByResource：按资源占比计算成本（向上取整，避免零成本）
@@ -381,10 +510,7 @@ func getResourceStatus(c *gin.Context) {
 func getMetricsHandler(c *gin.Context) {
 	fmt.Println("[DEBUG] /metrics endpoint accessed")
 
-	rows, err := db.Query(`
-		SELECT service_id, gas, cost, csci_id, delay
-		FROM deployed_services
-		ORDER BY created_at DESC`)
+	metrics, err := currentInstances()
 	if err != nil {
 		fmt.Printf("[ERROR] Failed to query metrics: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -393,29 +519,49 @@ func getMetricsHandler(c *gin.Context) {
 		})
 		return
 	}
+
+	fmt.Printf("[DEBUG] Metrics retrieved: %d records\n", len(metrics))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"site_id": SiteID,
+		"count":   len(metrics),
+		"metrics": metrics,
+		"time":    time.Now().Format(time.RFC3339),
+	})
+}
+
+// currentInstances 站点当前全部实例（/metrics 与全量推送共用）
+// gas 为剩余可用能力（部署gas - 进行中的会话数）
+func currentInstances() ([]models.ServiceInstanceInfo, error) {
+	rows, err := db.Query(`
+		SELECT id, service_id, gas, cost, csci_id, delay
+		FROM deployed_services
+		ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
+	inUse := inUseGasSnapshot()
 	var metrics []models.ServiceInstanceInfo
 	for rows.Next() {
 		var m models.ServiceInstanceInfo
+		var deploymentID string
 		if err := rows.Scan(
-			&m.ServiceID, &m.Gas, &m.Cost, &m.CSCI_ID, &m.Delay,
+			&deploymentID, &m.ServiceID, &m.Capacity, &m.Cost, &m.CSCI_ID, &m.Delay,
 		); err != nil {
 			fmt.Printf("[WARNING] Failed to parse metrics row: %v\n", err)
 			continue
 		}
+		m.Gas = m.Capacity - inUse[deploymentID]
+		if m.Gas < 0 {
+			m.Gas = 0
+		}
+		m.SiteID = SiteID
 		metrics = append(metrics, m)
 	}
-
-	fmt.Printf("[DEBUG] Metrics retrieved: %d records\n", len(metrics))
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"site_id": SiteID,
-		"count":   len(metrics),
-		"metrics": metrics,
-		"time":    time.Now().Format(time.RFC3339),
-	})
+	return metrics, rows.Err()
 }
 
 // healthCheckHandler：健康检查接口（含资源状态） (保持不变)
@@ -424,7 +570,7 @@ func healthCheckHandler(c *gin.Context) {
 	resourceMutex.RLock()
 	resourceStatus := "healthy"
 	usageRate := fmt.Sprintf("%.1f%%", float64(usedResource)/float64(TotalResource)*100)
-	if usedResource > TotalResource*0.9 { // 资源占用超90%标记为预警
+	if usedResource*10 > TotalResource*9 { // 资源占用超90%标记为预警
 		resourceStatus = "warning (high resource usage)"
 	}
 	resourceMutex.RUnlock()
@@ -464,170 +610,64 @@ func uploadHandler(c *gin.Context) {
 		return
 	}
 
-	// 确保文件是ZIP格式
-	if !strings.HasSuffix(strings.ToLower(file.Filename), ".zip") {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "仅支持ZIP格式的文件",
-		})
-		return
-	}
-
-	// 创建上传目录
-	uploadDir := "./uploads"
-	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		os.MkdirAll(uploadDir, 0755)
-	}
-
-	// 保存上传的文件
-	filePath := filepath.Join(uploadDir, file.Filename)
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "保存文件失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 解压ZIP文件
-	extractPath := filepath.Join(uploadDir, strings.TrimSuffix(file.Filename, ".zip"))
-	if err := extractZip(filePath, extractPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	// 按文件头识别 zip/tar/tar.gz，按内容摘要存储：相同内容只解压一次，不同上传互不覆盖；启动脚本单独上传（script 字段）时一并计入摘要
+	script, _ := c.FormFile("script")
+	stored, err := storeUpload(file, script, c.PostForm("service_id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, errBadManifest), errors.Is(err, errBadArchive):
+			status = http.StatusBadRequest
+		case errors.Is(err, errArchiveTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{
 			"success": false,
-			"error":   "解压文件失败: " + err.Error(),
+			"error":   err.Error(),
 		})
 		return
 	}
 
-	// 读取startScript参数
 	startScript := c.PostForm("startScript")
-
-	c.JSON(http.StatusOK, gin.H{
+	if script != nil {
+		startScript = filepath.Base(script.Filename)
+	}
+	resp := gin.H{
 		"success":     true,
-		"message":     fmt.Sprintf("文件已成功上传并解压到: %s", extractPath),
-		"extractPath": extractPath,
+		"message":     fmt.Sprintf("文件已成功上传并解压到: %s", stored.Root),
+		"extractPath": stored.Root,
 		"startScript": startScript,
-	})
-}
-
-// executeHandler 在服务器上执行脚本
-func executeHandler(c *gin.Context) {
-	var req struct {
-		Script    string `json:"script"`
-		ScriptPath string `json:"script_path"`
-		WorkDir   string `json:"work_dir"`
-	}
-	
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "请求格式错误: " + err.Error(),
-		})
-		return
-	}
-
-	// 验证脚本文件是否存在
-	if req.ScriptPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "脚本路径不能为空",
-		})
-		return
-	}
-
-	// 简单的安全检查：不允许路径遍历
-	if strings.Contains(req.Script, "..") || strings.Contains(req.ScriptPath, "..") {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "非法脚本路径",
-		})
-		return
-	}
-
-	// 检查脚本文件是否存在
-	if _, err := os.Stat(req.ScriptPath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"error":   "脚本文件不存在: " + req.ScriptPath,
-		})
-		return
-	}
-
-	// 在实际实现中，这里会执行脚本，例如：
-	// cmd := exec.Command("/bin/bash", req.ScriptPath)
-	// cmd.Dir = req.WorkDir
-	// output, err := cmd.CombinedOutput()
-	// 
-	// 由于安全原因，当前实现只记录日志，不实际执行脚本
-	fmt.Printf("[INFO] 请求执行脚本: %s 在目录: %s\n", req.ScriptPath, req.WorkDir)
-
-	// 模拟执行成功
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": fmt.Sprintf("脚本 %s 已成功启动", req.Script),
-		"script":  req.Script,
-		"work_dir": req.WorkDir,
-	})
-}
-
-// extractZip 解压ZIP文件到指定目录
-func extractZip(zipPath, extractPath string) error {
-	// 创建解压目录
-	if err := os.MkdirAll(extractPath, 0755); err != nil {
-		return err
-	}
-
-	// 打开ZIP文件
-	zipReader, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return err
-	}
-	defer zipReader.Close()
-
-	// 遍历ZIP中的文件
-	for _, file := range zipReader.File {
-		// 构建文件路径
-		filePath := filepath.Join(extractPath, file.Name)
-
-		// 检查路径安全性（防止路径遍历攻击）
-		if !strings.HasPrefix(filePath, extractPath+string(os.PathSeparator)) {
-			return fmt.Errorf("非法文件路径: %s", file.Name)
+		"digest":      stored.Digest,
+		"format":      stored.Format,
+	}
+
+	// 包内带清单时登记为服务的一个版本
+	if stored.Manifest != nil {
+		bundle, created, err := registerBundle(stored)
+		if errors.Is(err, errBundleConflict) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("%v：%s@%s 已登记为 %s，请使用新的版本号", err, bundle.ServiceID, bundle.Version, bundle.Digest),
+				"bundle":  bundle,
+			})
+			return
 		}
-
-		if file.FileInfo().IsDir() {
-			// 是目录，创建目录
-			if err := os.MkdirAll(filePath, file.Mode()); err != nil {
-				return err
-			}
-		} else {
-			// 是文件，创建目录并写入文件
-			if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-				return err
-			}
-
-			// 打开源文件
-			srcFile, err := file.Open()
-			if err != nil {
-				return err
-			}
-			defer srcFile.Close()
-
-			// 创建目标文件
-			dstFile, err := os.Create(filePath)
-			if err != nil {
-				return err
-			}
-			defer dstFile.Close()
-
-			// 复制文件内容
-			_, err = io.Copy(dstFile, srcFile)
-			if err != nil {
-				return err
-			}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		resp["startScript"], _ = bundle.Manifest.command()
+		resp["bundle"] = bundle
+		resp["created"] = created
+		resp["message"] = fmt.Sprintf("部署包%s@%s已登记（%s）", bundle.ServiceID, bundle.Version, bundle.Digest)
+		if !created {
+			resp["message"] = fmt.Sprintf("部署包%s@%s已存在，内容相同（%s）", bundle.ServiceID, bundle.Version, bundle.Digest)
 		}
 	}
-
-	return nil
+	c.JSON(http.StatusOK, resp)
 }
 
 // printStartInfo：打印启动信息（格式化输出）
@@ -636,9 +676,8 @@ func printStartInfo() {
 	defer resourceMutex.RUnlock()
 
 	usageRate := fmt.Sprintf("%.1f%%", float64(usedResource)/float64(TotalResource)*100)
-	fmt.Printf("\n✅ 服务站点（site-1）启动成功！\n")
+	fmt.Printf("\n✅ 服务站点（%s）启动成功！\n", SiteID)
 	fmt.Printf("📌 站点ID：%s\n", SiteID)
-    // 移除：原有的监听地址打印，避免重复
 	fmt.Printf("📌 当前资源：已用%d / 总%d 单位（使用率%s）\n",
 		usedResource, TotalResource, usageRate)
 	fmt.Printf("📌 可用接口：\n")
@@ -646,5 +685,16 @@ func printStartInfo() {
 	fmt.Printf("   - GET    /metrics             查看实例metrics\n")
 	fmt.Printf("   - GET    /health              健康检查\n")
 	fmt.Printf("   - GET    /resource-status     查看资源占用\n")
-	fmt.Printf("   - POST   /upload              上传代码文件并解压\n")
-}
\ No newline at end of file
+	fmt.Printf("   - POST   /upload              上传部署包（zip/tar/tar.gz，按内容摘要存储，带清单时登记版本）\n")
+	fmt.Printf("   - POST   /validate            提交部署验证结果\n")
+	fmt.Printf("   - GET    /deployments         查看部署列表（支持过滤）\n")
+	fmt.Printf("   - PATCH  /deployments/:id     调整实例数量\n")
+	fmt.Printf("   - DELETE /deployments/:id     删除部署并释放资源\n")
+	fmt.Printf("   - POST   /execute             为部署启动上传包中的脚本\n")
+	fmt.Printf("   - POST   /stop                停止部署（或上传包）的工作负载并释放资源\n")
+	fmt.Printf("   - POST   /deployments/:id/start|stop  启动/停止工作负载（GET status、logs 查看）\n")
+	fmt.Printf("   - GET    /deployments/:id/events      部署事件（沙箱越限、隔离降级、健康检查失败）\n")
+	fmt.Printf("   - GET    /bundles             查看已登记的部署包版本\n")
+	fmt.Printf("   - POST   /deployments/:id/version|rollback  切换部署包版本/回滚到上一个版本\n")
+	fmt.Printf("   - POST   /sessions            预占gas（DELETE /sessions/:id 释放）\n")
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// configureProcess 工作负载单独成进程组（停止时整组发信号，脚本派生的子进程一并结束），
+// 站点进程退出时内核向其发送 SIGTERM，避免遗留无人管理的进程
+func configureProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGTERM,
+	}
+}
+
+// terminateProcess 向工作负载进程组发送 SIGTERM
+func terminateProcess(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGTERM)
+}
+
+// killProcess 向工作负载进程组发送 SIGKILL
+func killProcess(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// 非 Linux 平台不支持进程组与父进程退出信号，仅结束工作负载主进程
+
+func configureProcess(cmd *exec.Cmd) {}
+
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
+
+func killProcess(p *os.Process) error {
+	return p.Kill()
+}
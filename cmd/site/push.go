@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"cmas-cats-go/config"
+	"cmas-cats-go/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	MetricsPushTimeout   = 3 * time.Second // 单次推送的超时时间（推送失败仅记录日志，由C-SMA定时拉取兜底）
+	MetricsPushQueueSize = 256             // 每个C-SMA的待发送事件队列长度，队列满时丢弃新事件（由定时拉取兜底）
+)
+
+var (
+	pushTargets []string                                     // 监控本站点的C-SMA地址
+	pushQueues  = make(map[string]chan models.MetricsUpdate) // C-SMA地址 → 待发送事件队列（按序号顺序发送）
+	pushEpoch   int64                                        // 本进程启动时间，C-SMA据此识别站点重启
+	pushSeq     int64                                        // 推送序号（原子递增）
+	pushClient  = &http.Client{Timeout: MetricsPushTimeout}
+)
+
+// initMetricsPush 确定推送目标（监控本站点的C-SMA），为每个目标启动一个按序发送的协程；
+// 全量推送在向各C-SMA注册成功后进行。C-SMA 只接受携带站点令牌的注册与推送，未配置令牌时不推送，由C-SMA定时拉取
+func initMetricsPush() {
+	pushEpoch = time.Now().UnixNano()
+	if siteCfg.Token == "" {
+		fmt.Println("⚠️ 本站点未配置令牌（token），不向C-SMA注册与推送，由C-SMA定时拉取")
+		return
+	}
+	for _, sma := range config.SMAsFor(SiteID) {
+		pushTargets = append(pushTargets, sma.URL)
+		queue := make(chan models.MetricsUpdate, MetricsPushQueueSize)
+		pushQueues[sma.URL] = queue
+		go runPushQueue(sma.URL, queue)
+	}
+	if len(pushTargets) == 0 {
+		fmt.Println("⚠️ 没有监控本站点的C-SMA，不推送实例变化")
+	}
+}
+
+// runPushQueue 逐条发送某个C-SMA的推送事件：同一目标的事件按入队（即序号）顺序到达，
+// C-SMA 按序号丢弃旧事件时不会误丢后产生的事件
+func runPushQueue(target string, queue <-chan models.MetricsUpdate) {
+	for update := range queue {
+		sendMetricsUpdate(target, update)
+	}
+}
+
+// enqueuePush 将事件放入目标的发送队列（不阻塞；调用方持有 resourceMutex，入队顺序与序号一致）
+func enqueuePush(target string, update models.MetricsUpdate) {
+	select {
+	case pushQueues[target] <- update:
+	default:
+		fmt.Printf("⚠️ 推送到C-SMA %s 的队列已满，丢弃%s事件（seq=%d，等待定时拉取）\n", target, update.Event, update.Seq)
+	}
+}
+
+// pushSnapshot 向单个C-SMA推送站点当前全部实例（覆盖C-SMA中可能残留的旧状态）；
+// 读取实例与分配序号在同一次 resourceMutex 写锁内完成，不会与部署、扩缩容及gas变化的推送交错
+func pushSnapshot(target string) {
+	resourceMutex.Lock()
+	defer resourceMutex.Unlock()
+	instances, err := currentInstances()
+	if err != nil {
+		fmt.Printf("⚠️ 读取实例失败，跳过全量推送：%v\n", err)
+		return
+	}
+	enqueuePush(target, stampUpdate(models.MetricsUpdate{
+		Event:     models.MetricsEventSnapshot,
+		Instances: instances,
+	}))
+}
+
+// stampUpdate 填充站点标识与序号
+func stampUpdate(update models.MetricsUpdate) models.MetricsUpdate {
+	update.SiteID = SiteID
+	update.Epoch = pushEpoch
+	update.Seq = atomic.AddInt64(&pushSeq, 1)
+	update.Time = time.Now()
+	return update
+}
+
+// pushMetricsUpdate 向所有C-SMA推送一条实例变化：同步分配序号并放入各目标的发送队列，由发送协程异步发送；
+// 调用方持有 resourceMutex，保证序号、入队顺序与数据库提交顺序一致
+func pushMetricsUpdate(update models.MetricsUpdate) {
+	if len(pushTargets) == 0 {
+		return
+	}
+	update = stampUpdate(update)
+	for _, target := range pushTargets {
+		enqueuePush(target, update)
+	}
+}
+
+// sendMetricsUpdate 同步发送一条推送事件
+func sendMetricsUpdate(target string, update models.MetricsUpdate) {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		fmt.Printf("⚠️ 序列化推送事件失败：%v\n", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, target+"/push", bytes.NewReader(payload))
+	if err != nil {
+		fmt.Printf("⚠️ 构造推送请求失败：%v\n", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Site-Token", siteCfg.Token)
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		fmt.Printf("⚠️ 推送%s事件到C-SMA %s失败：%v（等待定时拉取）\n", update.Event, target, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Printf("⚠️ C-SMA %s 拒绝%s事件：状态码%d\n", target, update.Event, resp.StatusCode)
+	}
+}
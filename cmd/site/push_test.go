@@ -0,0 +1,68 @@
+package main
+
+import (
+	"cmas-cats-go/config"
+	"cmas-cats-go/models"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 同一C-SMA收到的推送须携带站点令牌，并按序号递增到达，即使单次发送耗时各不相同
+func TestPushMetricsUpdateOrdered(t *testing.T) {
+	const events = 30
+	var (
+		mu   sync.Mutex
+		seqs []int64
+		done = make(chan struct{})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Site-Token") != "token-test" {
+			t.Errorf("推送未携带站点令牌")
+		}
+		var update models.MetricsUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			t.Errorf("解析推送失败：%v", err)
+		}
+		time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+		mu.Lock()
+		seqs = append(seqs, update.Seq)
+		if len(seqs) == events {
+			close(done)
+		}
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	savedCfg := siteCfg
+	siteCfg = &config.SiteConfig{ID: "site-test", Token: "token-test"}
+	queue := make(chan models.MetricsUpdate, MetricsPushQueueSize)
+	pushTargets = []string{srv.URL}
+	pushQueues = map[string]chan models.MetricsUpdate{srv.URL: queue}
+	defer func() {
+		close(queue)
+		siteCfg = savedCfg
+		pushTargets, pushQueues = nil, make(map[string]chan models.MetricsUpdate)
+	}()
+	go runPushQueue(srv.URL, queue)
+
+	for i := 0; i < events; i++ {
+		pushMetricsUpdate(models.MetricsUpdate{Event: models.MetricsEventUndeploy, CSCI_ID: "x"})
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		mu.Lock()
+		defer mu.Unlock()
+		t.Fatalf("超时：只收到%d条推送", len(seqs))
+	}
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] <= seqs[i-1] {
+			t.Fatalf("推送乱序到达：%v", seqs)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"cmas-cats-go/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultHeartbeatInterval = 5 * time.Second  // C-SMA 未给出建议值时的心跳间隔
+	RegisterRetryMax         = 30 * time.Second // 注册失败后的最大重试间隔（指数退避）
+)
+
+// startRegistration 向每个监控本站点的C-SMA注册并维持心跳（未配置令牌时没有推送目标，不注册）
+func startRegistration() {
+	for _, target := range pushTargets {
+		go maintainRegistration(target)
+	}
+}
+
+// maintainRegistration 注册 → 推送全量 → 周期心跳；心跳被拒（C-SMA重启或判定本站点失联）时重新注册
+func maintainRegistration(target string) {
+	for {
+		interval := registerUntilSuccess(target)
+		pushSnapshot(target)
+
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			status, err := postToCSMA(target+"/sites/"+SiteID+"/heartbeat", currentRegistration(), nil)
+			if err != nil {
+				fmt.Printf("⚠️ 向C-SMA %s 发送心跳失败：%v\n", target, err)
+				continue
+			}
+			if status == http.StatusNotFound {
+				fmt.Printf("⚠️ C-SMA %s 不认识本站点，重新注册\n", target)
+				break
+			}
+		}
+		ticker.Stop()
+	}
+}
+
+// registerUntilSuccess 注册直到成功，返回C-SMA建议的心跳间隔
+func registerUntilSuccess(target string) time.Duration {
+	backoff := time.Second
+	for {
+		var result struct {
+			HeartbeatIntervalSeconds int `json:"heartbeat_interval_seconds"`
+		}
+		status, err := postToCSMA(target+"/sites/register", currentRegistration(), &result)
+		if err == nil && status == http.StatusOK {
+			fmt.Printf("✅ 已向C-SMA %s 注册\n", target)
+			if result.HeartbeatIntervalSeconds > 0 {
+				return time.Duration(result.HeartbeatIntervalSeconds) * time.Second
+			}
+			return DefaultHeartbeatInterval
+		}
+		if err == nil {
+			err = fmt.Errorf("状态码%d", status)
+		}
+		fmt.Printf("⚠️ 向C-SMA %s 注册失败：%v（%v后重试）\n", target, err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, RegisterRetryMax)
+	}
+}
+
+// currentRegistration 本站点的注册信息（含当前资源占用与部署数）
+func currentRegistration() models.SiteRegistration {
+	resourceMutex.RLock()
+	used := usedResource
+	resourceMutex.RUnlock()
+
+	deployments := 0
+	if err := db.QueryRow(`SELECT COUNT(*) FROM deployed_services`).Scan(&deployments); err != nil {
+		fmt.Printf("⚠️ 统计部署数失败：%v\n", err)
+	}
+	return models.SiteRegistration{
+		SiteID:        SiteID,
+		URL:           siteCfg.URL,
+		TotalResource: TotalResource,
+		UsedResource:  used,
+		Deployments:   deployments,
+	}
+}
+
+// postToCSMA 向C-SMA发送JSON请求（携带站点令牌），out 非空时解析响应
+func postToCSMA(url string, body interface{}, out interface{}) (int, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Site-Token", siteCfg.Token)
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("解析响应失败：%w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"cmas-cats-go/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ------------------------------
+// 工作负载沙箱：每次运行实际生效的隔离措施、cgroup 越限监控，以及部署事件（越限、隔离降级）
+// 命名空间/文件系统视图/cgroup 的创建见 sandbox_linux.go
+// ------------------------------
+
+const (
+	SandboxAppDir         = "/app"           // 沙箱内上传包解压目录的挂载点
+	SandboxDataDir        = "/data"          // 沙箱内部署独立可写数据目录的挂载点
+	SandboxPollInterval   = 5 * time.Second  // cgroup 越限计数的轮询周期
+	SandboxEventInterval  = time.Minute      // 同类触顶/限流事件的最小记录间隔（OOM 每次都记录）
+	SandboxCPUPeriod      = 100000           // cpu.max 的周期（微秒）
+	SandboxMinCPUQuota    = 1000             // cpu.max 配额下限（微秒，内核要求）
+	DeploymentEventsLimit = 100              // 事件接口默认返回条数
+	sandboxInitArg        = "__sandbox-init" // 站点以该参数重新执行自身时作为沙箱内的1号进程
+)
+
+// 部署事件类型
+const (
+	EventOOMKill         = "oom_kill"         // 内存超出上限，进程被OOM终止
+	EventMemoryLimit     = "memory_limit"     // 内存使用触及上限（内核回收）
+	EventCPUThrottled    = "cpu_throttled"    // CPU使用超出上限被限流
+	EventSandboxDegraded = "sandbox_degraded" // 部分隔离手段不可用，以降级方式运行
+
+	EventHealthCheckFailed = "health_check_failed" // 健康检查连续失败，进程被结束（见 health.go）
+)
+
+// 事件级别
+const (
+	EventWarning = "warning"
+	EventError   = "error"
+)
+
+// SandboxInfo 工作负载本次运行实际生效的隔离措施
+type SandboxInfo struct {
+	Mode          string   `json:"mode"`
+	Isolation     []string `json:"isolation,omitempty"` // user / namespaces / filesystem / cgroup
+	Missing       []string `json:"missing,omitempty"`   // 不可用的隔离手段及原因
+	User          string   `json:"user,omitempty"`
+	Cgroup        string   `json:"cgroup,omitempty"`
+	ResourceUnits int      `json:"resource_units"`
+	CPULimit      float64  `json:"cpu_limit,omitempty"` // 核
+	MemoryLimitMB int      `json:"memory_limit_mb,omitempty"`
+}
+
+// DeploymentEvent 部署事件
+type DeploymentEvent struct {
+	ID           int64     `json:"id"`
+	DeploymentID string    `json:"deployment_id"`
+	Kind         string    `json:"kind"`
+	Level        string    `json:"level"`
+	Message      string    `json:"message"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func initDeploymentEvents() error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS deployment_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		deployment_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		level TEXT NOT NULL,
+		message TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_deployment_events ON deployment_events (deployment_id, id);`)
+	if err != nil {
+		return fmt.Errorf("创建部署事件表失败：%w", err)
+	}
+	return nil
+}
+
+// recordDeploymentEvent 记录部署事件
+func recordDeploymentEvent(deploymentID, kind, level, message string) {
+	_, err := db.Exec(`INSERT INTO deployment_events (deployment_id, kind, level, message, created_at) VALUES (?, ?, ?, ?, ?)`,
+		deploymentID, kind, level, message, time.Now())
+	if err != nil {
+		fmt.Printf("⚠️ 记录部署%s的事件失败：%v\n", deploymentID, err)
+	}
+	deploymentEventsTotal.Inc(kind)
+	icon := "⚠️"
+	if level == EventError {
+		icon = "🚨"
+	}
+	fmt.Printf("%s 部署%s事件[%s]：%s\n", icon, deploymentID, kind, message)
+}
+
+// deploymentEventsHandler：GET /deployments/:id/events?kind=&limit=100 —— 最新的在前
+func deploymentEventsHandler(c *gin.Context) {
+	id := c.Param("id")
+	limit := DeploymentEventsLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "参数limit必须为正整数：" + v,
+			})
+			return
+		}
+		limit = n
+	}
+	query := `SELECT id, deployment_id, kind, level, message, created_at FROM deployment_events WHERE deployment_id = ?`
+	args := []any{id}
+	if kind := c.Query("kind"); kind != "" {
+		query += ` AND kind = ?`
+		args = append(args, kind)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "查询部署事件失败：" + err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+	events := []DeploymentEvent{}
+	for rows.Next() {
+		var e DeploymentEvent
+		if err := rows.Scan(&e.ID, &e.DeploymentID, &e.Kind, &e.Level, &e.Message, &e.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "读取部署事件失败：" + err.Error(),
+			})
+			return
+		}
+		events = append(events, e)
+	}
+	if len(events) == 0 {
+		if _, err := loadDeployment(id); err != nil {
+			respondDeploymentLookupError(c, id, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "count": len(events), "events": events})
+}
+
+// ------------------------------
+// 单次运行的沙箱资源与越限监控
+// ------------------------------
+
+// sandboxRun 一次工作负载运行占用的沙箱资源：cgroup 目录与沙箱根目录挂载点
+type sandboxRun struct {
+	deploymentID string
+	cgroup       string   // 工作负载 cgroup 目录，空表示未启用 cgroup
+	cgroupDir    *os.File // 启动时用于将进程直接放入 cgroup，启动后关闭
+	root         string   // 沙箱根目录的挂载点（宿主上为空目录），退出后删除
+
+	counters  map[string]uint64    // 最近一次记录事件时的计数
+	lastEvent map[string]time.Time // 各类事件最近记录时间
+	oomKills  int                  // 本次运行被OOM终止的次数
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// started 进程已启动：关闭 cgroup 目录句柄，开始轮询越限计数
+func (r *sandboxRun) started() {
+	if r.cgroupDir != nil {
+		r.cgroupDir.Close()
+		r.cgroupDir = nil
+	}
+	if r.cgroup == "" {
+		return
+	}
+	r.counters = r.readCounters()
+	r.lastEvent = make(map[string]time.Time)
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.watch()
+}
+
+func (r *sandboxRun) watch() {
+	defer close(r.done)
+	ticker := time.NewTicker(SandboxPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.check()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// finish 进程已退出：做最后一次越限检查并释放 cgroup 与沙箱根目录（nil 安全）
+func (r *sandboxRun) finish() {
+	if r == nil {
+		return
+	}
+	if r.stop != nil {
+		close(r.stop)
+		<-r.done
+		r.check()
+	}
+	r.release()
+}
+
+// release 释放沙箱资源：结束 cgroup 中残留的进程后删除 cgroup，删除沙箱根目录（nil 安全）
+func (r *sandboxRun) release() {
+	if r == nil {
+		return
+	}
+	if r.cgroupDir != nil {
+		r.cgroupDir.Close()
+		r.cgroupDir = nil
+	}
+	if r.cgroup != "" {
+		os.WriteFile(filepath.Join(r.cgroup, "cgroup.kill"), []byte("1"), 0644)
+		var err error
+		for i := 0; i < 20; i++ {
+			if err = os.Remove(r.cgroup); err == nil || os.IsNotExist(err) {
+				err = nil
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		if err != nil {
+			fmt.Printf("⚠️ 删除工作负载%s的cgroup失败：%v\n", r.deploymentID, err)
+		}
+	}
+	if r.root != "" {
+		os.Remove(r.root)
+	}
+}
+
+// oomKilled 本次运行是否发生过OOM终止（nil 安全）
+func (r *sandboxRun) oomKilled() bool {
+	return r != nil && r.oomKills > 0
+}
+
+func (r *sandboxRun) readCounters() map[string]uint64 {
+	counters := make(map[string]uint64)
+	for _, file := range []string{"memory.events", "cpu.stat"} {
+		for k, v := range readCgroupStat(filepath.Join(r.cgroup, file)) {
+			counters[file+":"+k] = v
+		}
+	}
+	return counters
+}
+
+// check 比较越限计数，增加时记录部署事件；触顶/限流类事件按 SandboxEventInterval 合并，
+// 被合并的增量保留到下一次记录
+func (r *sandboxRun) check() {
+	current := r.readCounters()
+	delta := func(key string) uint64 {
+		if current[key] > r.counters[key] {
+			return current[key] - r.counters[key]
+		}
+		return 0
+	}
+	due := func(kind string) bool {
+		return time.Since(r.lastEvent[kind]) >= SandboxEventInterval
+	}
+	commit := func(kind string, keys ...string) {
+		for _, k := range keys {
+			r.counters[k] = current[k]
+		}
+		r.lastEvent[kind] = time.Now()
+	}
+
+	if n := delta("memory.events:oom_kill"); n > 0 {
+		r.oomKills += int(n)
+		commit(EventOOMKill, "memory.events:oom_kill")
+		recordDeploymentEvent(r.deploymentID, EventOOMKill, EventError,
+			fmt.Sprintf("内存超出上限%s，进程被OOM终止（%d次）", r.memoryLimit(), n))
+	}
+	if n := delta("memory.events:max"); n > 0 && due(EventMemoryLimit) {
+		commit(EventMemoryLimit, "memory.events:max")
+		recordDeploymentEvent(r.deploymentID, EventMemoryLimit, EventWarning,
+			fmt.Sprintf("内存使用触及上限%s（%d次）", r.memoryLimit(), n))
+	}
+	if n := delta("cpu.stat:nr_throttled"); n > 0 && due(EventCPUThrottled) {
+		usec := delta("cpu.stat:throttled_usec")
+		commit(EventCPUThrottled, "cpu.stat:nr_throttled", "cpu.stat:throttled_usec")
+		recordDeploymentEvent(r.deploymentID, EventCPUThrottled, EventWarning,
+			fmt.Sprintf("CPU使用超出上限%s，被限流%d次（累计%.1f秒）", r.cpuLimit(), n, float64(usec)/1e6))
+	}
+}
+
+func (r *sandboxRun) memoryLimit() string {
+	data, err := os.ReadFile(filepath.Join(r.cgroup, "memory.max"))
+	if err != nil {
+		return "（未知）"
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return strings.TrimSpace(string(data))
+	}
+	return fmt.Sprintf("%dMB", v>>20)
+}
+
+func (r *sandboxRun) cpuLimit() string {
+	data, err := os.ReadFile(filepath.Join(r.cgroup, "cpu.max"))
+	if err != nil {
+		return "（未知）"
+	}
+	var quota, period float64
+	if _, err := fmt.Sscanf(string(data), "%g %g", &quota, &period); err != nil || period <= 0 {
+		return strings.TrimSpace(string(data))
+	}
+	return fmt.Sprintf("%.2f核", quota/period)
+}
+
+// readCgroupStat 解析 "键 值" 形式的 cgroup 统计文件（memory.events、cpu.stat）
+func readCgroupStat(path string) map[string]uint64 {
+	stats := make(map[string]uint64)
+	f, err := os.Open(path)
+	if err != nil {
+		return stats
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			stats[fields[0]] = v
+		}
+	}
+	return stats
+}
+
+// ------------------------------
+// 资源单位 → cgroup 限制
+// ------------------------------
+
+// sandboxLimits 按部署占用的资源单位换算CPU核数与内存上限
+func sandboxLimits(units int) (cpu float64, memoryMB int) {
+	cfg := siteCfg.Sandbox
+	return float64(units) * cfg.CPUPerUnit, units * cfg.MemoryMBPerUnit
+}
+
+// writeCgroupLimits 写入 cpu.max / memory.max；关闭 swap 并在OOM时结束整个 cgroup（内核不支持时忽略）
+func writeCgroupLimits(dir string, units int) error {
+	cpu, memoryMB := sandboxLimits(units)
+	quota := max(int(cpu*SandboxCPUPeriod), SandboxMinCPUQuota)
+	if err := os.WriteFile(filepath.Join(dir, "cpu.max"), []byte(fmt.Sprintf("%d %d", quota, SandboxCPUPeriod)), 0644); err != nil {
+		return fmt.Errorf("设置CPU上限失败：%w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(strconv.FormatInt(int64(memoryMB)<<20, 10)), 0644); err != nil {
+		return fmt.Errorf("设置内存上限失败：%w", err)
+	}
+	os.WriteFile(filepath.Join(dir, "memory.swap.max"), []byte("0"), 0644)
+	os.WriteFile(filepath.Join(dir, "memory.oom.group"), []byte("1"), 0644)
+	return nil
+}
+
+// updateSandboxLimits 部署扩缩容后按新的资源单位调整运行中工作负载的 cgroup 限制
+func updateSandboxLimits(deploymentID string, units int) {
+	workloadMutex.Lock()
+	defer workloadMutex.Unlock()
+	w, ok := workloads[deploymentID]
+	if !ok || w.sandbox == nil || w.sandbox.cgroup == "" || w.Sandbox == nil {
+		return
+	}
+	if err := writeCgroupLimits(w.sandbox.cgroup, units); err != nil {
+		fmt.Printf("⚠️ 调整工作负载%s的资源上限失败：%v\n", deploymentID, err)
+		return
+	}
+	info := *w.Sandbox
+	info.ResourceUnits = units
+	info.CPULimit, info.MemoryLimitMB = sandboxLimits(units)
+	w.Sandbox = &info
+	saveWorkload(w)
+	fmt.Printf("🛡️ 工作负载%s资源上限已调整：CPU %.2f核，内存 %dMB\n", deploymentID, info.CPULimit, info.MemoryLimitMB)
+}
+
+// sandboxMissing 在 strict 模式下拒绝启动，否则记为降级
+func sandboxMissing(info *SandboxInfo, what string) error {
+	if siteCfg.Sandbox.Mode == config.SandboxStrict {
+		return fmt.Errorf("沙箱隔离不完整（strict 模式拒绝启动）：%s", what)
+	}
+	info.Missing = append(info.Missing, what)
+	return nil
+}
+
+// plainWorkloadCmd 不使用命名空间时直接在工作目录中启动脚本
+func plainWorkloadCmd(w *Workload) *exec.Cmd {
+	argv := workloadArgv(w, w.WorkDir)
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = w.WorkDir
+	cmd.Env = workloadEnv(w, workloadDataDir(w.DeploymentID))
+	configureProcess(cmd)
+	return cmd
+}
+
+// workloadArgv 启动命令：可执行脚本直接执行（遵循 shebang），否则交给 /bin/sh；dir 为进程看到的工作目录
+func workloadArgv(w *Workload, dir string) []string {
+	scriptPath := filepath.Join(dir, w.Script)
+	if info, err := os.Stat(filepath.Join(w.WorkDir, w.Script)); err == nil && info.Mode()&0111 != 0 {
+		return append([]string{scriptPath}, w.Args...)
+	}
+	return append([]string{"/bin/sh", scriptPath}, w.Args...)
+}
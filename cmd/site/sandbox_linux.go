@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"cmas-cats-go/config"
+)
+
+const (
+	sandboxInitFailed   = 125 // 沙箱初始化失败时1号进程的退出码
+	sandboxProbeTimeout = 10 * time.Second
+)
+
+// sandboxCaps 站点启动时探测到的可用隔离手段（初始化后只读）
+type sandboxCaps struct {
+	namespaces bool     // 可创建 mount/PID/IPC/UTS 命名空间并构造受限文件系统视图
+	uid, gid   int      // 站点以 root 运行时工作负载切换到的用户，-1 表示不切换
+	cgroupRoot string   // 父 cgroup 目录，空表示 cgroup 限制不可用
+	missing    []string // 不可用的隔离手段及原因
+}
+
+var sandboxState = sandboxCaps{uid: -1, gid: -1}
+
+// initSandbox 探测用户、命名空间与 cgroup v2 是否可用（站点非 root 运行时借助用户命名空间获得挂载权限）
+func initSandbox() {
+	cfg := siteCfg.Sandbox
+	if cfg.Mode == config.SandboxOff {
+		fmt.Printf("🛡️ 工作负载沙箱：已关闭（sandbox.mode=off）\n")
+		return
+	}
+
+	if os.Geteuid() == 0 {
+		if uid, gid, err := lookupSandboxUser(cfg.User); err != nil {
+			sandboxState.missing = append(sandboxState.missing, "user："+err.Error())
+		} else {
+			sandboxState.uid, sandboxState.gid = uid, gid
+		}
+	} else {
+		sandboxState.missing = append(sandboxState.missing, "user：站点未以root运行，工作负载沿用站点用户身份")
+	}
+
+	if err := probeNamespaces(); err != nil {
+		sandboxState.missing = append(sandboxState.missing, "namespaces："+err.Error())
+	} else {
+		sandboxState.namespaces = true
+	}
+
+	if root, err := prepareCgroupRoot(cfg.CgroupRoot); err != nil {
+		sandboxState.missing = append(sandboxState.missing, "cgroup："+err.Error())
+	} else {
+		sandboxState.cgroupRoot = root
+	}
+
+	fmt.Printf("🛡️ 工作负载沙箱（%s）：命名空间=%v，切换用户=%v，cgroup=%s\n",
+		cfg.Mode, sandboxState.namespaces, sandboxState.uid >= 0, valueOr(sandboxState.cgroupRoot, "不可用"))
+	for _, m := range sandboxState.missing {
+		fmt.Printf("   ⚠️ 不可用：%s\n", m)
+	}
+}
+
+func valueOr(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
+func lookupSandboxUser(name string) (uid, gid int, err error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return -1, -1, fmt.Errorf("沙箱用户%s不存在：%w", name, err)
+	}
+	if uid, err = strconv.Atoi(u.Uid); err != nil {
+		return -1, -1, err
+	}
+	if gid, err = strconv.Atoi(u.Gid); err != nil {
+		return -1, -1, err
+	}
+	if uid == 0 {
+		return -1, -1, fmt.Errorf("沙箱用户%s是root", name)
+	}
+	return uid, gid, nil
+}
+
+// probeNamespaces 在临时目录上完整走一遍沙箱初始化并执行 /bin/sh
+func probeNamespaces() error {
+	workDir, err := os.MkdirTemp("", "cmas-sandbox-probe-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+	os.Chmod(workDir, 0755)
+	root := sandboxRootDir("probe")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	defer os.Remove(root)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sandboxProbeTimeout)
+	defer cancel()
+	cmd := sandboxInitCmd(ctx, sandboxInitSpec{
+		Root:        root,
+		WorkDir:     workDir,
+		AppReadOnly: true,
+		DataDir:     workDir,
+		ReadOnly:    siteCfg.Sandbox.ReadOnlyPaths,
+		UID:         sandboxState.uid,
+		GID:         sandboxState.gid,
+		Argv:        []string{"/bin/sh", "-c", "exit 0"},
+	})
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%v（%s）", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// sandboxRootDir 沙箱根目录挂载点（tmpfs 仅挂载在工作负载的 mount 命名空间内，宿主上始终为空目录）
+func sandboxRootDir(deploymentID string) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("cmas-sandbox-%d", os.Geteuid()), SiteID, deploymentID)
+}
+
+// ------------------------------
+// cgroup v2
+// ------------------------------
+
+// prepareCgroupRoot 确定父 cgroup 目录并沿路径开启 cpu/memory 控制器
+func prepareCgroupRoot(root string) (string, error) {
+	mount, err := cgroup2Mount()
+	if err != nil {
+		return "", err
+	}
+	if root == "" {
+		root = filepath.Join(mount, "cmas", SiteID)
+	}
+	rel, err := filepath.Rel(mount, root)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("cgroup_root 必须位于 cgroup v2 挂载点 %s 内：%s", mount, root)
+	}
+	dir := mount
+	dirs := []string{mount}
+	if rel != "." {
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			dir = filepath.Join(dir, part)
+			dirs = append(dirs, dir)
+		}
+	}
+	for i, d := range dirs {
+		if i > 0 {
+			if err := os.Mkdir(d, 0755); err != nil && !os.IsExist(err) {
+				return "", fmt.Errorf("创建 cgroup %s 失败：%w", d, err)
+			}
+		}
+		data, err := os.ReadFile(filepath.Join(d, "cgroup.controllers"))
+		if err != nil {
+			return "", err
+		}
+		available := strings.Fields(string(data))
+		for _, c := range []string{"cpu", "memory"} {
+			if !contains(available, c) {
+				return "", fmt.Errorf("%s 未启用 %s 控制器（可用：%s）", d, c, strings.Join(available, " "))
+			}
+		}
+		if err := os.WriteFile(filepath.Join(d, "cgroup.subtree_control"), []byte("+cpu +memory"), 0644); err != nil {
+			return "", fmt.Errorf("在 %s 开启 cpu/memory 控制器失败：%w", d, err)
+		}
+	}
+	return root, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// cgroup2Mount 从 /proc/self/mountinfo 查找 cgroup v2 挂载点（优先 /sys/fs/cgroup）
+func cgroup2Mount() (string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var found string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式：ID 父ID 主:次 根 挂载点 选项 [可选字段...] - 文件系统类型 来源 超级块选项
+		left, right, ok := strings.Cut(scanner.Text(), " - ")
+		fields := strings.Fields(left)
+		if !ok || len(fields) < 5 || !strings.HasPrefix(right, "cgroup2 ") {
+			continue
+		}
+		if fields[4] == "/sys/fs/cgroup" {
+			return fields[4], nil
+		}
+		if found == "" {
+			found = fields[4]
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("未挂载 cgroup v2")
+	}
+	return found, nil
+}
+
+// createWorkloadCgroup 为一次运行创建 cgroup 并写入资源上限，返回供 clone 使用的目录句柄
+func createWorkloadCgroup(deploymentID string, units int) (string, *os.File, error) {
+	dir := filepath.Join(sandboxState.cgroupRoot, deploymentID)
+	os.Remove(dir) // 上次运行残留的空 cgroup
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return "", nil, fmt.Errorf("创建 cgroup 失败：%w", err)
+	}
+	if err := writeCgroupLimits(dir, units); err != nil {
+		os.Remove(dir)
+		return "", nil, err
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		os.Remove(dir)
+		return "", nil, err
+	}
+	return dir, f, nil
+}
+
+// ------------------------------
+// 启动工作负载
+// ------------------------------
+
+// buildWorkloadCmd 按沙箱配置构造工作负载进程；命名空间可用时由站点自身（sandboxInitArg）作为沙箱内的1号进程，
+// 在受限文件系统视图中以沙箱用户执行脚本，并直接在工作负载 cgroup 中创建
+func buildWorkloadCmd(w *Workload, units int) (*exec.Cmd, *sandboxRun, error) {
+	cfg := siteCfg.Sandbox
+	info := &SandboxInfo{Mode: cfg.Mode, ResourceUnits: units}
+	if cfg.Mode == config.SandboxOff {
+		w.Sandbox = info
+		return plainWorkloadCmd(w), nil, nil
+	}
+	for _, m := range sandboxState.missing {
+		if err := sandboxMissing(info, m); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	run := &sandboxRun{deploymentID: w.DeploymentID}
+	var cmd *exec.Cmd
+	if sandboxState.namespaces {
+		run.root = sandboxRootDir(w.DeploymentID)
+		if err := os.MkdirAll(run.root, 0755); err != nil {
+			return nil, nil, fmt.Errorf("创建沙箱根目录失败：%w", err)
+		}
+		cmd = sandboxInitCmd(context.Background(), sandboxInitSpec{
+			Root:        run.root,
+			WorkDir:     w.WorkDir,
+			AppReadOnly: w.Digest != "",
+			DataDir:     workloadDataDir(w.DeploymentID),
+			ReadOnly:    cfg.ReadOnlyPaths,
+			UID:         sandboxState.uid,
+			GID:         sandboxState.gid,
+			Hostname:    w.DeploymentID,
+			Argv:        workloadArgv(w, SandboxAppDir),
+			Env:         workloadEnv(w, SandboxDataDir),
+		})
+		info.Isolation = append(info.Isolation, "namespaces", "filesystem")
+	} else {
+		cmd = plainWorkloadCmd(w)
+		if sandboxState.uid >= 0 {
+			cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(sandboxState.uid), Gid: uint32(sandboxState.gid), Groups: []uint32{}}
+		}
+	}
+
+	if sandboxState.uid >= 0 {
+		// 数据目录归沙箱用户所有，工作负载可在其中写入；按内容寻址的部署包目录由多个部署共享，保持站点所有、只读，
+		// 旧版上传目录仍归沙箱用户所有
+		if err := chownTree(workloadDataDir(w.DeploymentID), sandboxState.uid, sandboxState.gid); err != nil {
+			run.release()
+			return nil, nil, fmt.Errorf("设置数据目录属主失败：%w", err)
+		}
+		if w.Digest == "" {
+			if err := chownTree(w.WorkDir, sandboxState.uid, sandboxState.gid); err != nil {
+				run.release()
+				return nil, nil, fmt.Errorf("设置工作目录属主失败：%w", err)
+			}
+		}
+		info.Isolation = append(info.Isolation, "user")
+		info.User = cfg.User
+	}
+
+	if sandboxState.cgroupRoot != "" {
+		dir, f, err := createWorkloadCgroup(w.DeploymentID, units)
+		if err != nil {
+			if err := sandboxMissing(info, "cgroup："+err.Error()); err != nil {
+				run.release()
+				return nil, nil, err
+			}
+		} else {
+			run.cgroup, run.cgroupDir = dir, f
+			cmd.SysProcAttr.UseCgroupFD = true
+			cmd.SysProcAttr.CgroupFD = int(f.Fd())
+			info.Isolation = append(info.Isolation, "cgroup")
+			info.Cgroup = dir
+			info.CPULimit, info.MemoryLimitMB = sandboxLimits(units)
+		}
+	}
+	w.Sandbox = info
+	return cmd, run, nil
+}
+
+func chownTree(root string, uid, gid int) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
+
+// sandboxInitSpec 传给沙箱内1号进程的参数
+type sandboxInitSpec struct {
+	Root        string   `json:"root"`
+	WorkDir     string   `json:"work_dir"`
+	AppReadOnly bool     `json:"app_readonly"` // 按内容寻址的部署包目录只读挂载到 /app
+	DataDir     string   `json:"data_dir"`     // 挂载到 /data 的可写数据目录
+	ReadOnly    []string `json:"readonly"`
+	UID         int      `json:"uid"` // -1 表示不切换用户
+	GID         int      `json:"gid"`
+	Hostname    string   `json:"hostname,omitempty"`
+	Argv        []string `json:"argv"`
+	Env         []string `json:"env"` // 工作负载的完整环境（1号进程自身也只带这些变量）
+}
+
+func sandboxInitCmd(ctx context.Context, spec sandboxInitSpec) *exec.Cmd {
+	data, _ := json.Marshal(spec)
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", sandboxInitArg, string(data))
+	cmd.Dir = "/"
+	cmd.Env = append([]string{}, spec.Env...) // 非 nil：不继承站点进程的环境
+	cmd.SysProcAttr = sandboxSysProcAttr()
+	return cmd
+}
+
+// sandboxSysProcAttr 新建 mount/PID/IPC/UTS 命名空间；非 root 时另建用户命名空间，将站点用户映射为其中的 root
+func sandboxSysProcAttr() *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{
+		Setpgid:    true,
+		Pdeathsig:  syscall.SIGTERM,
+		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+	}
+	if os.Geteuid() != 0 {
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Geteuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getegid(), Size: 1}}
+		attr.GidMappingsEnableSetgroups = false
+	}
+	return attr
+}
+
+// ------------------------------
+// 沙箱内的1号进程（站点以 sandboxInitArg 重新执行自身）
+// ------------------------------
+
+// runSandboxInit 构造文件系统视图后启动工作负载，转发终止信号并回收孤儿进程，以工作负载的退出码退出
+// （工作负载被信号结束时退出码为 128+信号值）
+func runSandboxInit(args []string) int {
+	var spec sandboxInitSpec
+	if len(args) != 1 || json.Unmarshal([]byte(args[0]), &spec) != nil || len(spec.Argv) == 0 {
+		fmt.Fprintln(os.Stderr, "❌ 沙箱初始化参数错误")
+		return sandboxInitFailed
+	}
+	if err := setupSandboxRoot(spec); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ 沙箱初始化失败：%v\n", err)
+		return sandboxInitFailed
+	}
+	if spec.Hostname != "" {
+		syscall.Sethostname([]byte(spec.Hostname))
+	}
+
+	attr := &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGKILL}
+	if spec.UID >= 0 {
+		attr.Credential = &syscall.Credential{Uid: uint32(spec.UID), Gid: uint32(spec.GID), Groups: []uint32{}}
+	}
+	sigs := make(chan os.Signal, 4)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT)
+	proc, err := os.StartProcess(spec.Argv[0], spec.Argv, &os.ProcAttr{
+		Dir:   SandboxAppDir,
+		Env:   spec.Env,
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
+		Sys:   attr,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ 启动工作负载失败：%v\n", err)
+		return sandboxInitFailed
+	}
+	go func() {
+		for s := range sigs {
+			syscall.Kill(-proc.Pid, s.(syscall.Signal))
+		}
+	}()
+
+	for {
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ 等待工作负载失败：%v\n", err)
+			return sandboxInitFailed
+		}
+		if pid != proc.Pid {
+			continue // 孤儿进程
+		}
+		if ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return ws.ExitStatus()
+	}
+}
+
+// setupSandboxRoot 在 spec.Root 上构造新的根文件系统并 pivot_root：
+// 只读的系统目录、/app（上传包解压目录，部署包只读）、可写的 /data、独立的 /tmp、/proc 与最小 /dev，根目录本身只读
+func setupSandboxRoot(spec sandboxInitSpec) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("设置挂载传播失败：%w", err)
+	}
+	root := spec.Root
+	if err := syscall.Mount("tmpfs", root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755,size=16m"); err != nil {
+		return fmt.Errorf("挂载根目录失败：%w", err)
+	}
+
+	for _, p := range spec.ReadOnly {
+		info, err := os.Lstat(p)
+		if err != nil {
+			continue // 宿主上不存在的路径跳过
+		}
+		target := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			// 如 /bin -> usr/bin：在新根中重建符号链接
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := bindMount(p, target, info.IsDir(), true); err != nil {
+			return err
+		}
+	}
+
+	if err := bindMount(spec.WorkDir, filepath.Join(root, SandboxAppDir), true, spec.AppReadOnly); err != nil {
+		return err
+	}
+	if spec.DataDir != "" {
+		if err := bindMount(spec.DataDir, filepath.Join(root, SandboxDataDir), true, false); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", filepath.Join(root, "tmp"), "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777,size=64m"); err != nil {
+		return fmt.Errorf("挂载 /tmp 失败：%w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "proc"), 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("proc", filepath.Join(root, "proc"), "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("挂载 /proc 失败：%w", err)
+	}
+	dev := filepath.Join(root, "dev")
+	if err := os.MkdirAll(dev, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", dev, "tmpfs", syscall.MS_NOSUID|syscall.MS_NOEXEC, "mode=0755,size=64k"); err != nil {
+		return fmt.Errorf("挂载 /dev 失败：%w", err)
+	}
+	for _, name := range []string{"null", "zero", "full", "random", "urandom", "tty"} {
+		if _, err := os.Stat("/dev/" + name); err != nil {
+			continue
+		}
+		if err := bindMount("/dev/"+name, filepath.Join(dev, name), false, false); err != nil {
+			return err
+		}
+	}
+
+	oldRoot := filepath.Join(root, ".oldroot")
+	if err := os.Mkdir(oldRoot, 0700); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("pivot_root 失败：%w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	if err := syscall.Unmount("/.oldroot", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("卸载原根目录失败：%w", err)
+	}
+	if err := os.Remove("/.oldroot"); err != nil {
+		return err
+	}
+	if err := syscall.Mount("", "/", "", syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("根目录设为只读失败：%w", err)
+	}
+	return nil
+}
+
+// bindMount 绑定挂载 src 到 dst（按需创建挂载点）；readonly 时重新挂载为只读，
+// 并保留源挂载已有的 nosuid/nodev/noexec 等标志（用户命名空间内这些标志不可清除）
+func bindMount(src, dst string, dir, readonly bool) error {
+	if dir {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+	if err := syscall.Mount(src, dst, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("绑定挂载 %s 失败：%w", src, err)
+	}
+	if !readonly {
+		return nil
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(src, &st); err != nil {
+		return err
+	}
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY | syscall.MS_NOSUID)
+	// statfs 的 ST_* 标志与 MS_* 取值相同（relatime 除外）
+	for _, f := range []int64{syscall.MS_NODEV, syscall.MS_NOEXEC, syscall.MS_NOATIME, syscall.MS_NODIRATIME} {
+		if int64(st.Flags)&f != 0 {
+			flags |= uintptr(f)
+		}
+	}
+	const stRelatime = 0x1000
+	if int64(st.Flags)&stRelatime != 0 {
+		flags |= syscall.MS_RELATIME
+	}
+	if err := syscall.Mount("", dst, "", flags, ""); err != nil {
+		return fmt.Errorf("只读挂载 %s 失败：%w", src, err)
+	}
+	return nil
+}
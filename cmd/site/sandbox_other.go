@@ -0,0 +1,32 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+
+	"cmas-cats-go/config"
+)
+
+// 非 Linux 平台没有命名空间与 cgroup：best-effort 模式下直接运行并记为降级，strict 模式拒绝启动
+
+func initSandbox() {
+	fmt.Printf("🛡️ 工作负载沙箱（%s）：当前平台不支持，仅 Linux 可用\n", siteCfg.Sandbox.Mode)
+}
+
+func buildWorkloadCmd(w *Workload, units int) (*exec.Cmd, *sandboxRun, error) {
+	info := &SandboxInfo{Mode: siteCfg.Sandbox.Mode, ResourceUnits: units}
+	if siteCfg.Sandbox.Mode != config.SandboxOff {
+		if err := sandboxMissing(info, "sandbox：仅支持 Linux"); err != nil {
+			return nil, nil, err
+		}
+	}
+	w.Sandbox = info
+	return plainWorkloadCmd(w), nil, nil
+}
+
+func runSandboxInit(args []string) int {
+	fmt.Println("❌ 当前平台不支持工作负载沙箱")
+	return 1
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"cmas-cats-go/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gas预占会话：每个会话占用某部署的1个gas单位，释放或超时后归还；
+// /metrics 中的 gas 即「部署gas - 进行中的会话数」，每次变化即推送给C-SMA，再经订阅流到达各C-PS
+// 锁顺序：resourceMutex（读锁，避免与部署/扩缩容交错）→ sessionMutex
+const (
+	DefaultSessionTTL = 60 * time.Second // 未指定有效期时的默认会话时长
+	MaxSessionTTL     = time.Hour        // 单次申请/续期允许的最长会话时长
+	SessionReapPeriod = 5 * time.Second  // 超时会话清理周期
+)
+
+var (
+	sessions      = make(map[string]*models.GasSession) // 进行中的会话（会话ID → 会话）
+	sessionsInUse = make(map[string]int)                // 各部署进行中的会话数（部署ID → 数量）
+	sessionMutex  sync.Mutex                            // 会话表锁
+)
+
+// inUseGas 返回部署当前被占用的gas（调用方需持有sessionMutex）
+func inUseGas(deploymentID string) int {
+	return sessionsInUse[deploymentID]
+}
+
+// inUseGasSnapshot 返回各部署被占用gas的快照（供 /metrics 使用）
+func inUseGasSnapshot() map[string]int {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+	snapshot := make(map[string]int, len(sessionsInUse))
+	for id, n := range sessionsInUse {
+		snapshot[id] = n
+	}
+	return snapshot
+}
+
+// removeSessionLocked 删除会话并归还gas（调用方需持有sessionMutex）
+func removeSessionLocked(s *models.GasSession) {
+	delete(sessions, s.ID)
+	sessionsInUse[s.DeploymentID]--
+	if sessionsInUse[s.DeploymentID] <= 0 {
+		delete(sessionsInUse, s.DeploymentID)
+	}
+}
+
+// pushGasUpdate 推送部署当前的可用gas（调用方持有 resourceMutex 读锁与 sessionMutex，
+// 推送序号因此与扩缩容、删除部署的推送保持先后一致）
+func pushGasUpdate(d Deployment) {
+	d.InUseGas = inUseGas(d.ID)
+	pushMetricsUpdate(models.MetricsUpdate{
+		Event:    models.MetricsEventGas,
+		Instance: d.instanceInfo(),
+	})
+}
+
+// pushGasUpdateFor 按部署ID推送可用gas；部署已不存在时跳过（删除部署时已推送 undeploy）
+func pushGasUpdateFor(deploymentID string) {
+	d, err := loadDeployment(deploymentID)
+	if err != nil {
+		return
+	}
+	pushGasUpdate(d)
+}
+
+// dropDeploymentSessions 部署被删除时丢弃其全部会话，返回丢弃数量
+func dropDeploymentSessions(deploymentID string) int {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+	dropped := 0
+	for _, s := range sessions {
+		if s.DeploymentID == deploymentID {
+			removeSessionLocked(s)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// startSessionReaper 周期性释放超时会话（客户端崩溃或忘记释放时兜底）
+func startSessionReaper() {
+	ticker := time.NewTicker(SessionReapPeriod)
+	go func() {
+		for now := range ticker.C {
+			resourceMutex.RLock()
+			sessionMutex.Lock()
+			released := make(map[string]bool)
+			for _, s := range sessions {
+				if now.After(s.ExpiresAt) {
+					removeSessionLocked(s)
+					released[s.DeploymentID] = true
+					fmt.Printf("[%s] 会话超时释放：%s（部署=%s）\n", now.Format("15:04:05"), s.ID, s.DeploymentID)
+				}
+			}
+			for id := range released {
+				pushGasUpdateFor(id)
+			}
+			sessionMutex.Unlock()
+			resourceMutex.RUnlock()
+		}
+	}()
+}
+
+// sessionTTL 将请求中的有效期（秒）转换为时长：0 取默认值，超过上限按上限处理
+func sessionTTL(seconds int) time.Duration {
+	if seconds <= 0 {
+		return DefaultSessionTTL
+	}
+	ttl := time.Duration(seconds) * time.Second
+	if ttl > MaxSessionTTL {
+		return MaxSessionTTL
+	}
+	return ttl
+}
+
+// acquireSessionHandler：POST /sessions
+// 预占指定部署（deployment_id 或 csci_id）的1个gas单位；无剩余gas时返回409
+func acquireSessionHandler(c *gin.Context) {
+	var req models.AcquireGasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+	if req.DeploymentID == "" && req.CSCI_ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "deployment_id 与 csci_id 至少提供一个",
+		})
+		return
+	}
+
+	resourceMutex.RLock()
+	defer resourceMutex.RUnlock()
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	var d Deployment
+	var err error
+	if req.DeploymentID != "" {
+		d, err = loadDeployment(req.DeploymentID)
+	} else {
+		d, err = scanDeployment(db.QueryRow(`SELECT `+deploymentColumns+` FROM deployed_services WHERE csci_id = ?`, req.CSCI_ID))
+	}
+	if err != nil {
+		respondDeploymentLookupError(c, req.DeploymentID+req.CSCI_ID, err)
+		return
+	}
+
+	if inUseGas(d.ID) >= d.Gas {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":       false,
+			"message":       fmt.Sprintf("部署%s的gas已全部占用（%d/%d）", d.ID, inUseGas(d.ID), d.Gas),
+			"available_gas": 0,
+		})
+		return
+	}
+
+	now := time.Now()
+	session := &models.GasSession{
+		ID:           fmt.Sprintf("%s-sess-%d", d.ID, now.UnixNano()),
+		DeploymentID: d.ID,
+		ServiceID:    d.ServiceID,
+		CSCI_ID:      d.CSCI_ID,
+		Holder:       req.Holder,
+		AcquiredAt:   now,
+		ExpiresAt:    now.Add(sessionTTL(req.TTLSeconds)),
+	}
+	sessions[session.ID] = session
+	sessionsInUse[d.ID]++
+	pushGasUpdate(d)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"message":       "gas预占成功",
+		"session":       session,
+		"available_gas": d.Gas - inUseGas(d.ID),
+	})
+	fmt.Printf("[%s] 预占gas：会话=%s, 部署=%s, 占用%d/%d, 持有方=%s\n",
+		now.Format("15:04:05"), session.ID, d.ID, inUseGas(d.ID), d.Gas, req.Holder)
+}
+
+// releaseSessionHandler：DELETE /sessions/:id —— 请求处理完成后归还gas
+func releaseSessionHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	resourceMutex.RLock()
+	defer resourceMutex.RUnlock()
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	s, ok := sessions[id]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "会话不存在或已超时释放：" + id,
+		})
+		return
+	}
+	removeSessionLocked(s)
+	pushGasUpdateFor(s.DeploymentID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "会话已释放",
+		"session": s,
+	})
+	fmt.Printf("[%s] 释放gas：会话=%s, 部署=%s\n", time.Now().Format("15:04:05"), id, s.DeploymentID)
+}
+
+// renewSessionHandler：POST /sessions/:id/renew —— 长时间请求在到期前续期
+func renewSessionHandler(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		TTLSeconds int `json:"ttl_seconds"`
+	}
+	// 请求体可选
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "请求格式错误：" + err.Error(),
+			})
+			return
+		}
+	}
+
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	s, ok := sessions[id]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "会话不存在或已超时释放：" + id,
+		})
+		return
+	}
+	s.ExpiresAt = time.Now().Add(sessionTTL(req.TTLSeconds))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "会话已续期",
+		"session": s,
+	})
+}
+
+// listSessionsHandler：GET /sessions（支持 ?deployment_id= / ?service_id= 过滤）
+func listSessionsHandler(c *gin.Context) {
+	deploymentID, serviceID := c.Query("deployment_id"), c.Query("service_id")
+
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	list := []models.GasSession{}
+	for _, s := range sessions {
+		if deploymentID != "" && s.DeploymentID != deploymentID {
+			continue
+		}
+		if serviceID != "" && s.ServiceID != serviceID {
+			continue
+		}
+		list = append(list, *s)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"site_id":  SiteID,
+		"count":    len(list),
+		"sessions": list,
+	})
+}
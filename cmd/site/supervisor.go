@@ -0,0 +1,878 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ------------------------------
+// 工作负载进程监管：按部署ID在上传包的解压目录中启动启动脚本，记录PID、退出码与重启次数，
+// 标准输出/错误写入每个部署的日志文件，按重启策略自动重启
+// ------------------------------
+
+const (
+	WorkloadStopTimeout   = 10 * time.Second // SIGTERM 后等待退出的时长，超时发送 SIGKILL
+	WorkloadRestartMin    = time.Second      // 重启退避初始值
+	WorkloadRestartMax    = 30 * time.Second // 重启退避上限
+	WorkloadStableAfter   = time.Minute      // 连续运行超过该时长后退避复位
+	DefaultMaxRestarts    = 5                // 未指定 max_restarts 时的最大重启次数
+	WorkloadLogTailLines  = 200              // 日志接口默认返回的行数
+	WorkloadLogTailMaxLen = 1 << 20          // 日志接口最多读取的末尾字节数
+)
+
+// 工作负载状态
+const (
+	WorkloadRunning    = "running"    // 运行中
+	WorkloadRestarting = "restarting" // 已退出，等待按退避重启
+	WorkloadStopped    = "stopped"    // 已被停止
+	WorkloadExited     = "exited"     // 正常退出（退出码0）且不再重启
+	WorkloadFailed     = "failed"     // 启动失败或异常退出且不再重启
+)
+
+// 重启策略
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure" // 默认：仅退出码非0时重启
+	RestartAlways    = "always"
+)
+
+// Workload 一个部署对应的工作负载进程
+type Workload struct {
+	DeploymentID  string       `json:"deployment_id"`
+	WorkDir       string       `json:"work_dir"`
+	Script        string       `json:"script"` // 相对 work_dir 的启动脚本
+	Args          []string     `json:"args,omitempty"`
+	RestartPolicy string       `json:"restart_policy"`
+	MaxRestarts   int          `json:"max_restarts"` // 负数表示不限
+	Status        string       `json:"status"`
+	PID           int          `json:"pid,omitempty"`
+	Restarts      int          `json:"restarts"`
+	ExitCode      *int         `json:"exit_code,omitempty"` // 最近一次退出的退出码（被信号结束时为-1，沙箱内为128+信号值）
+	LastError     string       `json:"last_error,omitempty"`
+	StartedAt     *time.Time   `json:"started_at,omitempty"`
+	FinishedAt    *time.Time   `json:"finished_at,omitempty"`
+	StdoutLog     string       `json:"stdout_log"`
+	StderrLog     string       `json:"stderr_log"`
+	Sandbox       *SandboxInfo `json:"sandbox,omitempty"` // 本次运行实际生效的隔离措施
+	// 按部署包清单启动时的包版本（服务ID@版本）与内容摘要、环境变量与健康检查
+	Bundle      string            `json:"bundle,omitempty"`
+	Digest      string            `json:"digest,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	HealthCheck *HealthCheck      `json:"health_check,omitempty"`
+	Health      string            `json:"health,omitempty"` // 配置了健康检查时：starting / healthy / unhealthy
+
+	cmd      *exec.Cmd
+	sandbox  *sandboxRun   // 本次运行的沙箱资源（cgroup、根目录挂载点）
+	active   bool          // 监管协程是否在运行
+	stopping bool          // 已请求停止，退出后不再重启
+	stopCh   chan struct{} // 关闭以中断重启退避
+	done     chan struct{} // 监管协程退出时关闭
+}
+
+var (
+	workloads     = make(map[string]*Workload) // 部署ID → 工作负载
+	workloadMutex sync.Mutex
+
+	errWorkloadNotFound = errors.New("工作负载不存在")
+)
+
+// initSupervisor 建表并加载已有工作负载；站点重启前仍在运行的工作负载按重启策略重新拉起
+func initSupervisor() error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS workloads (
+		deployment_id TEXT PRIMARY KEY,
+		spec TEXT NOT NULL,               -- Workload 的 JSON（含状态）
+		updated_at DATETIME NOT NULL
+	);`)
+	if err != nil {
+		return fmt.Errorf("创建工作负载表失败：%w", err)
+	}
+	if err := os.MkdirAll(siteCfg.WorkloadLogDir, 0755); err != nil {
+		return fmt.Errorf("创建工作负载日志目录失败：%w", err)
+	}
+	if err := initDeploymentEvents(); err != nil {
+		return err
+	}
+	initSandbox()
+
+	rows, err := db.Query(`SELECT spec FROM workloads`)
+	if err != nil {
+		return fmt.Errorf("加载工作负载失败：%w", err)
+	}
+	var loaded []*Workload
+	for rows.Next() {
+		var spec string
+		if err := rows.Scan(&spec); err != nil {
+			rows.Close()
+			return fmt.Errorf("读取工作负载失败：%w", err)
+		}
+		w := &Workload{}
+		if err := json.Unmarshal([]byte(spec), w); err != nil {
+			fmt.Printf("⚠️ 跳过无法解析的工作负载记录：%v\n", err)
+			continue
+		}
+		loaded = append(loaded, w)
+	}
+	rows.Close()
+
+	workloadMutex.Lock()
+	defer workloadMutex.Unlock()
+	for _, w := range loaded {
+		workloads[w.DeploymentID] = w
+		if w.Status != WorkloadRunning && w.Status != WorkloadRestarting {
+			continue
+		}
+		// 站点退出时工作负载已随之结束（Linux 下由父进程退出信号保证）
+		w.PID = 0
+		if w.RestartPolicy == RestartNever {
+			w.Status, w.LastError = WorkloadFailed, "站点重启，工作负载已随之结束"
+			saveWorkload(w)
+			continue
+		}
+		fmt.Printf("🔁 站点重启，重新拉起工作负载：%s\n", w.DeploymentID)
+		w.Restarts++
+		runWorkload(w)
+	}
+	return nil
+}
+
+// saveWorkload 持久化工作负载（调用方须持有 workloadMutex）
+func saveWorkload(w *Workload) {
+	spec, _ := json.Marshal(w)
+	_, err := db.Exec(`INSERT INTO workloads (deployment_id, spec, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(deployment_id) DO UPDATE SET spec = excluded.spec, updated_at = excluded.updated_at`,
+		w.DeploymentID, string(spec), time.Now())
+	if err != nil {
+		fmt.Printf("⚠️ 保存工作负载%s失败：%v\n", w.DeploymentID, err)
+	}
+}
+
+// snapshot 返回工作负载的只读副本（用于响应）
+func (w *Workload) snapshot() Workload {
+	return Workload{
+		DeploymentID:  w.DeploymentID,
+		WorkDir:       w.WorkDir,
+		Script:        w.Script,
+		Args:          w.Args,
+		RestartPolicy: w.RestartPolicy,
+		MaxRestarts:   w.MaxRestarts,
+		Status:        w.Status,
+		PID:           w.PID,
+		Restarts:      w.Restarts,
+		ExitCode:      w.ExitCode,
+		LastError:     w.LastError,
+		StartedAt:     w.StartedAt,
+		FinishedAt:    w.FinishedAt,
+		StdoutLog:     w.StdoutLog,
+		StderrLog:     w.StderrLog,
+		Sandbox:       w.Sandbox,
+		Bundle:        w.Bundle,
+		Digest:        w.Digest,
+		Env:           w.Env,
+		HealthCheck:   w.HealthCheck,
+		Health:        w.Health,
+	}
+}
+
+// runWorkload 启动工作负载进程并交由监管协程等待（调用方须持有 workloadMutex）
+func runWorkload(w *Workload) error {
+	logDir := filepath.Join(siteCfg.WorkloadLogDir, w.DeploymentID)
+	w.StdoutLog = filepath.Join(logDir, "stdout.log")
+	w.StderrLog = filepath.Join(logDir, "stderr.log")
+	w.stopping = false
+	w.stopCh = make(chan struct{})
+	w.done = make(chan struct{})
+	w.ExitCode, w.FinishedAt, w.LastError = nil, nil, ""
+
+	cmd, closeLogs, err := launchWorkload(w)
+	if err != nil {
+		w.Status, w.LastError, w.PID, w.active = WorkloadFailed, err.Error(), 0, false
+		saveWorkload(w)
+		close(w.done)
+		fmt.Printf("❌ 工作负载%s启动失败：%v\n", w.DeploymentID, err)
+		return err
+	}
+	w.active = true
+	go superviseWorkload(w, cmd, closeLogs)
+	return nil
+}
+
+// superviseWorkload 等待进程退出，按重启策略与退避决定是否重启
+func superviseWorkload(w *Workload, cmd *exec.Cmd, closeLogs func()) {
+	defer close(w.done)
+	backoff := WorkloadRestartMin
+	for {
+		startedAt := *w.StartedAt
+		waitErr := cmd.Wait()
+		closeLogs()
+		code := exitCode(waitErr)
+		finishedAt := time.Now()
+
+		workloadMutex.Lock()
+		w.sandbox.finish()
+		oomKilled := w.sandbox.oomKilled()
+		w.cmd, w.sandbox, w.PID, w.ExitCode, w.FinishedAt = nil, nil, 0, &code, &finishedAt
+		restart := !w.stopping && shouldRestart(w, code)
+		switch {
+		case w.stopping:
+			w.Status = WorkloadStopped
+		case restart:
+			w.Status = WorkloadRestarting
+			w.Restarts++
+			workloadRestartsTotal.Inc()
+		case code == 0:
+			w.Status = WorkloadExited
+		default:
+			w.Status = WorkloadFailed
+			w.LastError = fmt.Sprintf("进程退出码%d，已达重启上限或重启策略为%s", code, w.RestartPolicy)
+		}
+		if oomKilled && !w.stopping {
+			w.LastError = strings.TrimPrefix(w.LastError+"；内存超出沙箱上限，进程被OOM终止", "；")
+		}
+		w.active = restart
+		saveWorkload(w)
+		workloadMutex.Unlock()
+		fmt.Printf("⏹️ 工作负载%s已退出：退出码=%d, 状态=%s\n", w.DeploymentID, code, w.Status)
+		if !restart {
+			return
+		}
+
+		if finishedAt.Sub(startedAt) > WorkloadStableAfter {
+			backoff = WorkloadRestartMin
+		}
+		select {
+		case <-time.After(backoff):
+		case <-w.stopCh:
+		}
+		backoff = min(backoff*2, WorkloadRestartMax)
+
+		workloadMutex.Lock()
+		if w.stopping {
+			w.Status, w.active = WorkloadStopped, false
+			saveWorkload(w)
+			workloadMutex.Unlock()
+			return
+		}
+		var err error
+		cmd, closeLogs, err = launchWorkload(w)
+		if err != nil {
+			w.Status, w.LastError, w.active = WorkloadFailed, err.Error(), false
+			saveWorkload(w)
+			workloadMutex.Unlock()
+			fmt.Printf("❌ 工作负载%s重启失败：%v\n", w.DeploymentID, err)
+			return
+		}
+		workloadMutex.Unlock()
+	}
+}
+
+func shouldRestart(w *Workload, code int) bool {
+	if w.MaxRestarts >= 0 && w.Restarts >= w.MaxRestarts {
+		return false
+	}
+	switch w.RestartPolicy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return code != 0
+	}
+	return false
+}
+
+// workloadInheritedEnv 从站点进程环境继承给工作负载的变量；其余变量（如 CMAS_* 令牌与配置覆盖）一律不传
+var workloadInheritedEnv = []string{"PATH", "LANG"}
+
+const defaultWorkloadPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// workloadEnv 工作负载的环境变量：允许继承的少量变量、清单 env 与站点注入的 CMAS_* 标识；
+// dataDir 为进程看到的可写数据目录，同时作为 HOME
+func workloadEnv(w *Workload, dataDir string) []string {
+	env := make([]string, 0, len(workloadInheritedEnv)+len(w.Env)+5)
+	for _, k := range workloadInheritedEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		} else if k == "PATH" {
+			env = append(env, "PATH="+defaultWorkloadPath)
+		}
+	}
+	env = append(env, "HOME="+dataDir)
+	for k, v := range w.Env {
+		env = append(env, k+"="+v)
+	}
+	env = append(env,
+		"CMAS_SITE_ID="+SiteID,
+		"CMAS_DEPLOYMENT_ID="+w.DeploymentID,
+		"CMAS_DATA_DIR="+dataDir,
+	)
+	if w.Bundle != "" {
+		env = append(env, "CMAS_BUNDLE="+w.Bundle, "CMAS_BUNDLE_DIGEST="+w.Digest)
+	}
+	return env
+}
+
+// workloadDataDir 部署独立的可写数据目录（绝对路径）：按内容寻址的部署包目录由多个部署共享且只读，
+// 工作负载的运行时数据写在这里，切换版本时保留，删除部署时清除
+func workloadDataDir(deploymentID string) string {
+	dir, err := filepath.Abs(filepath.Join(siteCfg.WorkloadDataDir, deploymentID))
+	if err != nil {
+		return filepath.Join(siteCfg.WorkloadDataDir, deploymentID)
+	}
+	return dir
+}
+
+// launchWorkload 在沙箱中启动脚本，标准输出/错误追加到日志文件，并记录PID与启动时间（调用方须持有 workloadMutex）
+func launchWorkload(w *Workload) (*exec.Cmd, func(), error) {
+	d, err := loadDeployment(w.DeploymentID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询部署记录失败：%w", err)
+	}
+	if err := os.MkdirAll(workloadDataDir(w.DeploymentID), 0755); err != nil {
+		return nil, nil, fmt.Errorf("创建数据目录失败：%w", err)
+	}
+	cmd, run, err := buildWorkloadCmd(w, d.TotalResourceUsed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(w.StdoutLog), 0755); err != nil {
+		run.release()
+		return nil, nil, fmt.Errorf("创建日志目录失败：%w", err)
+	}
+	stdout, err := os.OpenFile(w.StdoutLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		run.release()
+		return nil, nil, fmt.Errorf("打开日志文件失败：%w", err)
+	}
+	stderr, err := os.OpenFile(w.StderrLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		stdout.Close()
+		run.release()
+		return nil, nil, fmt.Errorf("打开日志文件失败：%w", err)
+	}
+	closeLogs := func() {
+		stdout.Close()
+		stderr.Close()
+	}
+	banner := fmt.Sprintf("===== [%s] 启动 %s =====\n", time.Now().Format(time.RFC3339), w.Script)
+	if w.Restarts > 0 {
+		banner = fmt.Sprintf("===== [%s] 启动 %s（第%d次重启）=====\n", time.Now().Format(time.RFC3339), w.Script, w.Restarts)
+	}
+	stdout.WriteString(banner)
+	stderr.WriteString(banner)
+
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		closeLogs()
+		run.release()
+		return nil, nil, err
+	}
+	if run != nil {
+		run.started()
+	}
+	startedAt := time.Now()
+	w.cmd, w.sandbox, w.PID, w.Status, w.StartedAt = cmd, run, cmd.Process.Pid, WorkloadRunning, &startedAt
+	w.Health = ""
+	if w.HealthCheck != nil {
+		w.Health = HealthStarting
+		stopHealth := make(chan struct{})
+		go watchHealth(w, *w.HealthCheck, cmd.Process, stopHealth)
+		closeFiles := closeLogs
+		closeLogs = func() {
+			close(stopHealth)
+			closeFiles()
+		}
+	}
+	saveWorkload(w)
+	fmt.Printf("▶️ 工作负载%s已启动：PID=%d, 脚本=%s, 隔离=%v\n", w.DeploymentID, w.PID, w.Script, w.Sandbox.Isolation)
+	if len(w.Sandbox.Missing) > 0 && w.Restarts == 0 {
+		recordDeploymentEvent(w.DeploymentID, EventSandboxDegraded, EventWarning,
+			"部分隔离手段不可用，以降级方式运行："+strings.Join(w.Sandbox.Missing, "；"))
+	}
+	return cmd, closeLogs, nil
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// workloadSpec 启动请求（/execute 与 /deployments/:id/start 共用）
+type workloadSpec struct {
+	Script        string            `json:"script"`      // 相对 work_dir 的启动脚本
+	ScriptPath    string            `json:"script_path"` // 兼容旧请求：脚本完整路径（未指定 work_dir 时取其所在目录）
+	WorkDir       string            `json:"work_dir"`    // 上传包解压目录（须位于上传目录内）
+	Args          []string          `json:"args"`
+	RestartPolicy string            `json:"restart_policy"`
+	MaxRestarts   *int              `json:"max_restarts"`
+	Env           map[string]string `json:"env"`
+	HealthCheck   *HealthCheck      `json:"health_check"`
+
+	bundle, digest string // 按部署包清单启动时的包版本与摘要
+}
+
+// resolve 校验路径与策略，得到工作目录与相对脚本路径
+func (s workloadSpec) resolve() (workDir, script string, err error) {
+	if s.WorkDir == "" && s.ScriptPath != "" {
+		s.WorkDir = filepath.Dir(s.ScriptPath)
+	}
+	if s.Script == "" && s.ScriptPath != "" {
+		s.Script = filepath.Base(s.ScriptPath)
+	}
+	if s.WorkDir == "" || s.Script == "" {
+		return "", "", fmt.Errorf("需要指定 work_dir 与 script（或 script_path）")
+	}
+
+	root, err := filepath.Abs(UploadDir)
+	if err != nil {
+		return "", "", err
+	}
+	workDir, err = filepath.Abs(s.WorkDir)
+	if err != nil {
+		return "", "", err
+	}
+	if rel, err := filepath.Rel(root, workDir); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", "", fmt.Errorf("工作目录必须位于上传目录 %s 内：%s", UploadDir, s.WorkDir)
+	}
+	scriptPath := s.Script
+	if !filepath.IsAbs(scriptPath) {
+		scriptPath = filepath.Join(workDir, scriptPath)
+	}
+	script, err = filepath.Rel(workDir, filepath.Clean(scriptPath))
+	if err != nil || strings.HasPrefix(script, "..") {
+		return "", "", fmt.Errorf("启动脚本必须位于工作目录内：%s", s.Script)
+	}
+	info, err := os.Stat(filepath.Join(workDir, script))
+	if err != nil || !info.Mode().IsRegular() {
+		return "", "", fmt.Errorf("启动脚本不存在：%s", filepath.Join(s.WorkDir, script))
+	}
+	switch s.RestartPolicy {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return "", "", fmt.Errorf("未知的重启策略：%s（可选：never / on-failure / always）", s.RestartPolicy)
+	}
+	for k := range s.Env {
+		if !envNamePattern.MatchString(k) {
+			return "", "", fmt.Errorf("环境变量名不合法：%q", k)
+		}
+	}
+	if s.HealthCheck != nil {
+		if err := s.HealthCheck.normalize(); err != nil {
+			return "", "", err
+		}
+	}
+	return workDir, script, nil
+}
+
+// startWorkload 为部署启动工作负载；spec 为空时沿用该部署上一次的启动配置
+func startWorkload(deploymentID string, spec *workloadSpec) (Workload, int, error) {
+	if _, err := loadDeployment(deploymentID); err != nil {
+		if err == sql.ErrNoRows {
+			return Workload{}, http.StatusNotFound, fmt.Errorf("部署不存在：%s", deploymentID)
+		}
+		return Workload{}, http.StatusInternalServerError, fmt.Errorf("查询部署记录失败：%w", err)
+	}
+
+	workloadMutex.Lock()
+	defer workloadMutex.Unlock()
+
+	w, ok := workloads[deploymentID]
+	if ok && w.active {
+		return w.snapshot(), http.StatusConflict, fmt.Errorf("部署%s的工作负载正在运行（状态：%s）", deploymentID, w.Status)
+	}
+	if spec == nil {
+		if !ok {
+			return Workload{}, http.StatusBadRequest, fmt.Errorf("部署%s尚无工作负载，需要指定 work_dir 与 script", deploymentID)
+		}
+	} else {
+		workDir, script, err := spec.resolve()
+		if err != nil {
+			return Workload{}, http.StatusBadRequest, err
+		}
+		w = &Workload{
+			DeploymentID:  deploymentID,
+			WorkDir:       workDir,
+			Script:        script,
+			Args:          spec.Args,
+			RestartPolicy: spec.RestartPolicy,
+			MaxRestarts:   DefaultMaxRestarts,
+			Bundle:        spec.bundle,
+			Digest:        spec.digest,
+			Env:           spec.Env,
+			HealthCheck:   spec.HealthCheck,
+		}
+		if w.RestartPolicy == "" {
+			w.RestartPolicy = RestartOnFailure
+		}
+		if spec.MaxRestarts != nil {
+			w.MaxRestarts = *spec.MaxRestarts
+		}
+		workloads[deploymentID] = w
+	}
+	w.Restarts = 0
+	if err := runWorkload(w); err != nil {
+		return w.snapshot(), http.StatusInternalServerError, fmt.Errorf("启动工作负载失败：%w", err)
+	}
+	return w.snapshot(), http.StatusOK, nil
+}
+
+// stopWorkload 停止部署的工作负载：SIGTERM 整个进程组，超时后 SIGKILL，返回最终状态
+func stopWorkload(deploymentID string) (Workload, error) {
+	workloadMutex.Lock()
+	w, ok := workloads[deploymentID]
+	if !ok {
+		workloadMutex.Unlock()
+		return Workload{}, errWorkloadNotFound
+	}
+	if !w.active {
+		snap := w.snapshot()
+		workloadMutex.Unlock()
+		return snap, nil
+	}
+	if !w.stopping {
+		w.stopping = true
+		close(w.stopCh)
+	}
+	cmd, done := w.cmd, w.done
+	if cmd != nil {
+		if err := terminateProcess(cmd.Process); err != nil {
+			fmt.Printf("⚠️ 向工作负载%s发送SIGTERM失败：%v\n", deploymentID, err)
+		}
+	}
+	workloadMutex.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(WorkloadStopTimeout):
+		fmt.Printf("⚠️ 工作负载%s在%s内未退出，强制结束\n", deploymentID, WorkloadStopTimeout)
+		if cmd != nil {
+			killProcess(cmd.Process)
+		}
+		<-done
+	}
+
+	workloadMutex.Lock()
+	defer workloadMutex.Unlock()
+	fmt.Printf("⏹️ 工作负载%s已停止\n", deploymentID)
+	return w.snapshot(), nil
+}
+
+// ------------------------------
+// 接口
+// ------------------------------
+
+// executeHandler：POST /execute —— 为部署启动上传包中的启动脚本（供WebUI使用）
+func executeHandler(c *gin.Context) {
+	var req struct {
+		DeploymentID string `json:"deployment_id"`
+		workloadSpec
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+	if req.DeploymentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "deployment_id不能为空（先通过 /deploy 创建部署）",
+		})
+		return
+	}
+	respondWorkloadStart(c, req.DeploymentID, &req.workloadSpec)
+}
+
+// stopHandler：POST /stop —— 按部署ID或上传包停止工作负载并删除部署：释放资源，实例从 /metrics 中移除，
+// 返回部署与工作负载的最终状态（供WebUI使用）
+func stopHandler(c *gin.Context) {
+	var req struct {
+		DeploymentID string `json:"deployment_id"`
+		Bundle       string `json:"bundle"` // 包版本（服务ID@版本）、内容摘要（sha256:...）或解压目录，停止使用该包的全部部署
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "请求格式错误：" + err.Error(),
+			})
+			return
+		}
+	}
+	if req.DeploymentID == "" && req.Bundle == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "需要指定 deployment_id 或 bundle",
+		})
+		return
+	}
+
+	ids := []string{req.DeploymentID}
+	if req.DeploymentID == "" {
+		var err error
+		if ids, err = deploymentsUsingBundle(req.Bundle); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errBundleNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	type stopped struct {
+		Deployment Deployment `json:"deployment"`
+		Workload   *Workload  `json:"workload,omitempty"` // 工作负载最终状态，未启动过工作负载时为空
+	}
+	results := []stopped{}
+	released := 0
+	for _, id := range ids {
+		d, w, err := removeDeployment(id)
+		if errors.Is(err, sql.ErrNoRows) && req.DeploymentID == "" {
+			continue // 使用该上传包的部署已被删除
+		}
+		if err != nil {
+			respondRemoveError(c, id, err)
+			return
+		}
+		results = append(results, stopped{Deployment: d, Workload: w})
+		released += d.TotalResourceUsed
+	}
+	if len(results) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "没有使用上传包的部署：" + req.Bundle,
+		})
+		return
+	}
+
+	resourceMutex.RLock()
+	used := usedResource
+	resourceMutex.RUnlock()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("已停止%d个部署，释放%d单位资源", len(results), released),
+		"stopped": results,
+		"resource_detail": map[string]int{
+			"released":           released,
+			"current_used":       used,
+			"remaining_resource": TotalResource - used,
+		},
+	})
+}
+
+// deploymentsUsingBundle 工作目录位于该上传包解压目录内的部署ID；bundle 可以是包版本（服务ID@版本）、
+// 内容摘要（sha256:...）、解压目录，或旧版上传目录下的包名
+func deploymentsUsingBundle(bundle string) ([]string, error) {
+	switch {
+	case strings.HasPrefix(bundle, "sha256:"):
+		bundle = filepath.Join(UploadDir, BundleStoreDir, strings.TrimPrefix(bundle, "sha256:"))
+	case strings.ContainsRune(bundle, '@') && !strings.ContainsRune(bundle, '/'):
+		serviceID, version, _ := strings.Cut(bundle, "@")
+		b, err := loadBundle(serviceID, version)
+		if err != nil {
+			return nil, err
+		}
+		bundle = b.Path
+	case !strings.ContainsRune(bundle, '/'):
+		bundle = filepath.Join(UploadDir, bundle)
+	}
+	root, err := filepath.Abs(UploadDir)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(bundle)
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(root, dir); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("上传包必须位于上传目录 %s 内：%s", UploadDir, bundle)
+	}
+
+	workloadMutex.Lock()
+	defer workloadMutex.Unlock()
+	var ids []string
+	for id, w := range workloads {
+		if w.WorkDir == dir || strings.HasPrefix(w.WorkDir, dir+string(filepath.Separator)) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// startWorkloadHandler：POST /deployments/:id/start —— 请求体为空时按上一次的配置重新启动
+func startWorkloadHandler(c *gin.Context) {
+	var spec *workloadSpec
+	if c.Request.ContentLength > 0 {
+		spec = &workloadSpec{}
+		if err := c.ShouldBindJSON(spec); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "请求格式错误：" + err.Error(),
+			})
+			return
+		}
+	}
+	respondWorkloadStart(c, c.Param("id"), spec)
+}
+
+func respondWorkloadStart(c *gin.Context, deploymentID string, spec *workloadSpec) {
+	w, status, err := startWorkload(deploymentID, spec)
+	if err != nil {
+		resp := gin.H{"success": false, "message": err.Error()}
+		if w.DeploymentID != "" {
+			resp["workload"] = w
+		}
+		c.JSON(status, resp)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  fmt.Sprintf("部署%s的工作负载已启动（PID=%d）", deploymentID, w.PID),
+		"workload": w,
+	})
+}
+
+// stopWorkloadHandler：POST /deployments/:id/stop —— 仅停止进程，部署与资源保留
+func stopWorkloadHandler(c *gin.Context) {
+	w, err := stopWorkload(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("部署%s没有工作负载", c.Param("id")),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  fmt.Sprintf("部署%s的工作负载已停止", w.DeploymentID),
+		"workload": w,
+	})
+}
+
+// workloadStatusHandler：GET /deployments/:id/status
+func workloadStatusHandler(c *gin.Context) {
+	workloadMutex.Lock()
+	w, ok := workloads[c.Param("id")]
+	var snap Workload
+	if ok {
+		snap = w.snapshot()
+	}
+	workloadMutex.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("部署%s没有工作负载", c.Param("id")),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "workload": snap})
+}
+
+// listWorkloadsHandler：GET /workloads（?status= 过滤）
+func listWorkloadsHandler(c *gin.Context) {
+	status := c.Query("status")
+	workloadMutex.Lock()
+	list := make([]Workload, 0, len(workloads))
+	for _, w := range workloads {
+		if status == "" || w.Status == status {
+			list = append(list, w.snapshot())
+		}
+	}
+	workloadMutex.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].DeploymentID < list[j].DeploymentID })
+	c.JSON(http.StatusOK, gin.H{"success": true, "count": len(list), "workloads": list})
+}
+
+// workloadLogsHandler：GET /deployments/:id/logs?stream=stdout|stderr&tail=200 —— 日志末尾若干行（纯文本）
+func workloadLogsHandler(c *gin.Context) {
+	workloadMutex.Lock()
+	w, ok := workloads[c.Param("id")]
+	var path string
+	if ok {
+		path = w.StdoutLog
+		if c.DefaultQuery("stream", "stdout") == "stderr" {
+			path = w.StderrLog
+		}
+	}
+	workloadMutex.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("部署%s没有工作负载", c.Param("id")),
+		})
+		return
+	}
+	lines := WorkloadLogTailLines
+	if v := c.Query("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "参数tail必须为正整数：" + v,
+			})
+			return
+		}
+		lines = n
+	}
+
+	text, err := tailFile(path, lines)
+	if err != nil && !os.IsNotExist(err) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "读取日志失败：" + err.Error(),
+		})
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(text))
+}
+
+// tailFile 读取文件最后 lines 行（最多读取末尾 WorkloadLogTailMaxLen 字节）
+func tailFile(path string, lines int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := max(info.Size()-WorkloadLogTailMaxLen, 0)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	all := strings.SplitAfter(string(data), "\n")
+	if all[len(all)-1] == "" {
+		all = all[:len(all)-1]
+	}
+	return strings.Join(all[max(len(all)-lines, 0):], ""), nil
+}
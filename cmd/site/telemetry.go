@@ -0,0 +1,59 @@
+package main
+
+import (
+	"cmas-cats-go/telemetry"
+	"fmt"
+)
+
+// 站点运维指标（GET /prometheus；与供C-SMA拉取的 /metrics 互不相干）
+var (
+	resourceTotalGauge  = telemetry.NewGauge("cmas_site_resource_total", "站点总资源单位")
+	resourceUsedGauge   = telemetry.NewGauge("cmas_site_resource_used", "已占用资源单位")
+	deploymentsGauge    = telemetry.NewGauge("cmas_site_deployments", "部署实例数", "service_id")
+	gasAvailableGauge   = telemetry.NewGauge("cmas_site_gas_available", "剩余可用gas合计", "service_id")
+	gasCapacityGauge    = telemetry.NewGauge("cmas_site_gas_capacity", "部署总gas合计", "service_id")
+	sessionsActiveGauge = telemetry.NewGauge("cmas_site_sessions_active", "进行中的gas预占会话数")
+
+	workloadsGauge        = telemetry.NewGauge("cmas_site_workloads", "工作负载数（按状态）", "status")
+	workloadRestartsTotal = telemetry.NewCounter("cmas_site_workload_restarts_total", "工作负载按重启策略自动重启的次数")
+	deploymentEventsTotal = telemetry.NewCounter("cmas_site_deployment_events_total", "部署事件数（沙箱越限、隔离降级）", "kind")
+)
+
+func init() {
+	telemetry.OnScrape(collectSiteMetrics)
+}
+
+// collectSiteMetrics 抓取时读取资源占用与部署状态
+func collectSiteMetrics() {
+	resourceMutex.RLock()
+	resourceTotalGauge.Set(float64(TotalResource))
+	resourceUsedGauge.Set(float64(usedResource))
+	resourceMutex.RUnlock()
+
+	sessionMutex.Lock()
+	sessionsActiveGauge.Set(float64(len(sessions)))
+	sessionMutex.Unlock()
+
+	workloadMutex.Lock()
+	for _, status := range []string{WorkloadRunning, WorkloadRestarting, WorkloadStopped, WorkloadExited, WorkloadFailed} {
+		workloadsGauge.Set(0, status)
+	}
+	for _, w := range workloads {
+		workloadsGauge.Add(1, w.Status)
+	}
+	workloadMutex.Unlock()
+
+	deploymentsGauge.Reset()
+	gasAvailableGauge.Reset()
+	gasCapacityGauge.Reset()
+	instances, err := currentInstances()
+	if err != nil {
+		fmt.Printf("⚠️ 采集部署指标失败：%v\n", err)
+		return
+	}
+	for _, inst := range instances {
+		deploymentsGauge.Add(1, inst.ServiceID)
+		gasAvailableGauge.Add(float64(inst.Gas), inst.ServiceID)
+		gasCapacityGauge.Add(float64(inst.Capacity), inst.ServiceID)
+	}
+}
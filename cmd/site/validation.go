@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cmas-cats-go/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ------------------------------
+// 部署验证：站点从平台取验证样本，交给本站点正在运行的工作负载处理，
+// 将工作负载的输出连同部署ID提交至平台 /validate（须配置站点令牌）
+// ------------------------------
+
+const ValidationOutputMaxLen = 1 << 20 // 工作负载验证输出的最大字节数
+
+// ValidationEndpoint 部署包清单中的验证入口：站点向 http://127.0.0.1:<port><path> POST 验证样本，响应体即验证输出
+type ValidationEndpoint struct {
+	Port           int    `yaml:"port" json:"port"`
+	Path           string `yaml:"path,omitempty" json:"path,omitempty"`                       // 默认 /validate
+	TimeoutSeconds int    `yaml:"timeout_seconds,omitempty" json:"timeout_seconds,omitempty"` // 默认10
+}
+
+// normalize 校验并补全默认值
+func (v *ValidationEndpoint) normalize() error {
+	if v.Port <= 0 || v.Port > 65535 {
+		return fmt.Errorf("验证入口端口不合法：%d", v.Port)
+	}
+	if v.Path == "" {
+		v.Path = "/validate"
+	}
+	if !strings.HasPrefix(v.Path, "/") {
+		return fmt.Errorf("验证入口路径必须以 / 开头：%s", v.Path)
+	}
+	if v.TimeoutSeconds < 0 {
+		return fmt.Errorf("验证入口超时不能为负数")
+	}
+	if v.TimeoutSeconds == 0 {
+		v.TimeoutSeconds = 10
+	}
+	return nil
+}
+
+// run 将样本交给工作负载处理，返回其输出
+func (v ValidationEndpoint) run(sample string) (string, error) {
+	client := &http.Client{Timeout: time.Duration(v.TimeoutSeconds) * time.Second}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(v.Port))
+	resp, err := client.Post("http://"+addr+v.Path, "text/plain; charset=utf-8", strings.NewReader(sample))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, ValidationOutputMaxLen+1))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("HTTP状态码%d", resp.StatusCode)
+	}
+	if len(body) > ValidationOutputMaxLen {
+		return "", fmt.Errorf("输出超过%d字节", ValidationOutputMaxLen)
+	}
+	return string(body), nil
+}
+
+// validationTarget 部署当前运行的工作负载及其部署包清单中的验证入口
+func validationTarget(d Deployment) (ValidationEndpoint, error) {
+	workloadMutex.Lock()
+	w, ok := workloads[d.ID]
+	var snap Workload
+	if ok {
+		snap = w.snapshot()
+	}
+	workloadMutex.Unlock()
+	if !ok || snap.Status != WorkloadRunning {
+		return ValidationEndpoint{}, fmt.Errorf("部署%s的工作负载未在运行", d.ID)
+	}
+	serviceID, version, found := strings.Cut(snap.Bundle, "@")
+	if !found || serviceID != d.ServiceID {
+		return ValidationEndpoint{}, fmt.Errorf("部署%s的工作负载不是按服务%s的部署包清单启动的", d.ID, d.ServiceID)
+	}
+	b, err := loadBundle(serviceID, version)
+	if err != nil {
+		return ValidationEndpoint{}, err
+	}
+	if b.Manifest.Validation == nil {
+		return ValidationEndpoint{}, fmt.Errorf("部署包%s的清单未配置验证入口 validation", snap.Bundle)
+	}
+	return *b.Manifest.Validation, nil
+}
+
+// fetchValidationSample 从平台获取服务的验证样本；required 为 false 表示服务无需验证
+func fetchValidationSample(serviceID string) (sample string, required bool, err error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/services/%s/validation-sample", config.Cfg.Platform.URL, url.PathEscape(serviceID)))
+	if err != nil {
+		return "", false, fmt.Errorf("调用公共服务平台失败：%w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Success  bool   `json:"success"`
+		Message  string `json:"message"`
+		Required bool   `json:"required"`
+		Sample   string `json:"sample"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", false, fmt.Errorf("解析验证样本失败：%w", err)
+	}
+	if resp.StatusCode != http.StatusOK || !result.Success {
+		return "", false, fmt.Errorf("公共服务平台返回错误（状态码%d）：%s", resp.StatusCode, result.Message)
+	}
+	return result.Sample, result.Required, nil
+}
+
+// validateHandler：POST /validate
+// 请求体 {"deployment_id": "..."}：站点用平台的验证样本调用该部署正在运行的工作负载，将输出提交至平台 /validate
+func validateHandler(c *gin.Context) {
+	var req struct {
+		DeploymentID string `json:"deployment_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求格式错误：" + err.Error(),
+		})
+		return
+	}
+	if siteCfg.Token == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"message": "本站点未配置令牌（token），无法向平台提交部署验证",
+		})
+		return
+	}
+
+	d, err := loadDeployment(req.DeploymentID)
+	if err != nil {
+		respondDeploymentLookupError(c, req.DeploymentID, err)
+		return
+	}
+	endpoint, err := validationTarget(d)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	sample, required, err := fetchValidationSample(d.ServiceID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !required {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("服务%s未配置验证样本，无需验证", d.ServiceID),
+		})
+		return
+	}
+	output, err := endpoint.run(sample)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("部署%s的工作负载处理验证样本失败：%v", d.ID, err),
+		})
+		return
+	}
+
+	payload, _ := json.Marshal(map[string]string{
+		"service_id":    d.ServiceID,
+		"site_id":       SiteID,
+		"deployment_id": d.ID,
+		"result":        output,
+	})
+	httpReq, err := http.NewRequest(http.MethodPost, config.Cfg.Platform.URL+"/validate", bytes.NewReader(payload))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "构造平台请求失败：" + err.Error(),
+		})
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Site-Token", siteCfg.Token)
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"message": "调用公共服务平台失败：" + err.Error(),
+		})
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	c.Data(resp.StatusCode, "application/json; charset=utf-8", body)
+	fmt.Printf("[%s] 已提交部署验证：部署=%s, 服务=%s, 平台响应状态码=%d\n",
+		time.Now().Format("15:04:05"), d.ID, d.ServiceID, resp.StatusCode)
+}
@@ -1,649 +0,0 @@
-package main
-
-import (
-	"archive/zip"
-	"database/sql"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"path/filepath"
-	"strconv" // ❗ 需要添加 strconv 导入，因为它在其他站点中被使用，尽管此文件中没有直接使用
-	"strings"
-	"sync"
-	"time"
-
-	"cmas-cats-go/config"
-	"cmas-cats-go/models"
-
-	"github.com/gin-gonic/gin"
-	_ "github.com/mattn/go-sqlite3" // SQLite驱动
-)
-
-// 全局状态：资源管理（需加锁保证并发安全）
-var (
-	db                *sql.DB
-	usedResource      int                               // 已使用资源单位（动态更新）
-	resourceMutex     sync.RWMutex                      // 资源操作锁（避免并发修改冲突）
-	serviceStore      = make(map[string]models.Service) // 缓存已查询的服务信息，减少重复请求
-	serviceStoreMutex sync.RWMutex                      // 服务信息缓存锁
-)
-
-// 服务站点核心配置（资源与成本相关）
-const (
-	DBFile          = "./db/site2.db" // 数据库文件路径
-	SiteID          = "site-2"        // 站点唯一标识
-	TotalResource   = 400             // 站点总资源单位（可根据硬件调整）
-	ResourcePerCost = 40              // 每40单位资源对应1个成本单位（成本换算系数）
-)
-
-func main() {
-	// 启动标识日志
-	fmt.Println("=====================================")
-	fmt.Println("          服务站点（site-2）启动中...          ")
-	fmt.Println("=====================================")
-	fmt.Printf("📌 站点总资源：%d 单位 | 成本换算：每%d单位资源=1成本\n",
-		TotalResource, ResourcePerCost)
-
-	// 1. 初始化数据库（启动时加载已用资源）
-	if err := initDB(); err != nil {
-		fmt.Printf("❌ 初始化失败，程序退出：%v\n", err)
-		return
-	}
-	defer db.Close()
-
-	// 2. 初始化已用资源（从数据库加载历史部署的实例资源占用）
-	if err := loadUsedResource(); err != nil {
-		fmt.Printf("⚠️ 加载历史资源占用失败：%v（将从0开始计算）\n", err)
-	}
-
-	// 3. 初始化Gin引擎
-	r := gin.Default()
-
-	// 4. 注册API接口
-	r.POST("/deploy", deployServiceHandler)      // 部署服务实例（核心：资源+成本计算）
-	r.GET("/metrics", getMetricsHandler)         // 暴露实例metrics（供C-SMA拉取）
-	r.GET("/health", healthCheckHandler)         // 健康检查接口
-	r.GET("/resource-status", getResourceStatus) // 查看资源占用状态
-	r.POST("/upload", uploadHandler)             // 文件上传接口（供WebUI使用）
-	r.POST("/execute", executeHandler)           // 执行脚本接口（供WebUI使用）
-
-	// 5. 启动服务配置
-	// ❗ 修正：监听地址使用 0.0.0.0 确保远程 SSH 启动时可以绑定，端口从配置中获取
-	listenAddr := "0.0.0.0:" + strconv.Itoa(config.Cfg.Site2.Port)
-
-	publicPlatformURL := fmt.Sprintf("%s/api/v1/services/", config.Cfg.Platform.URL)
-
-	// ❗ 修正：将原有的启动信息打印函数 printStartInfo() 挪到 r.Run() 之前 ❗
-	// ❗ 原始代码的 printStartInfo() 里面有重复的 listenAddr 打印，已在下面简化
-	fmt.Printf("📌 平台地址：%s\n", publicPlatformURL)
-	printStartInfo()
-
-	// 移除 printStartInfo() 中的 listenAddr 打印，在下面统一打印
-	fmt.Printf("📌 监听地址：http://%s\n", listenAddr)
-
-	if err := r.Run(listenAddr); err != nil {
-		fmt.Printf("服务启动失败：%v\n", err)
-	}
-}
-
-// ------------------------------
-// 核心1：数据库初始化与资源加载 (保持不变)
-// ------------------------------
-
-// initDB：初始化SQLite数据库（含资源相关字段）
-func initDB() error {
-	var err error
-
-	// 1. 打开数据库
-	db, err = sql.Open("sqlite3", DBFile)
-	if err != nil {
-		return fmt.Errorf("数据库连接失败：%w", err)
-	}
-
-	// 2. 验证连接
-	err = db.Ping()
-	if err != nil {
-		return fmt.Errorf("数据库验证失败：%w", err)
-	}
-
-	// 3. 创建部署实例表（含资源相关字段，支持重启后加载）
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS deployed_services (
-		id TEXT PRIMARY KEY,
-		service_id TEXT NOT NULL,
-		gas INT NOT NULL,
-		cost INT NOT NULL,
-		csci_id TEXT NOT NULL,
-		created_at DATETIME NOT NULL,
-		delay INT NOT NULL,
-		resource_per_inst INT NOT NULL, -- 单个实例资源占用（用于重启加载）
-		total_resource_used INT NOT NULL -- 该部署总资源占用（用于重启加载）
-	);`
-	_, err = db.Exec(createTableSQL)
-	if err != nil {
-		return fmt.Errorf("创建部署表失败：%w", err)
-	}
-
-	fmt.Println("✅ 数据库初始化成功（SQLite）")
-	return nil
-}
-
-// loadUsedResource：从数据库加载历史资源占用（避免重启后统计清零）
-func loadUsedResource() error {
-	// 查询所有已部署实例的总资源占用
-	row := db.QueryRow(`SELECT SUM(total_resource_used) FROM deployed_services`)
-	var totalUsed int
-	err := row.Scan(&totalUsed)
-	if err != nil && err != sql.ErrNoRows {
-		return err
-	}
-
-	// 更新已用资源（加写锁）
-	resourceMutex.Lock()
-	usedResource = totalUsed
-	resourceMutex.Unlock()
-
-	fmt.Printf("✅ 加载历史资源完成：已用%d / 总%d 单位\n", usedResource, TotalResource)
-	return nil
-}
-
-// ------------------------------
-// 核心2：部署接口（支持多服务类型） (保持不变)
-// ------------------------------
-
-// deployServiceHandler：处理服务部署请求（按资源占比计算成本）
-func deployServiceHandler(c *gin.Context) {
-	var req struct {
-		ServiceID string `json:"service_id" binding:"required"` // 目标服务ID（动态生成的ID，如AR1760108514766）
-		Gas       int    `json:"gas" binding:"min=1"`           // 部署实例数量（至少1个）
-	}
-
-	// 1. 解析请求参数
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "请求格式错误：" + err.Error(),
-		})
-		return
-	}
-
-	// 2. 获取服务类型（按服务ID查询公共服务平台，获取服务名）
-	serviceName, err := getServiceNameByID(req.ServiceID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "获取服务信息失败：" + err.Error(),
-		})
-		return
-	}
-
-	// 3. 确定单个实例的资源占用（按服务名区分）
-	resourcePerInst, err := getResourcePerInstance(serviceName)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": err.Error(),
-		})
-		return
-	}
-
-	// 4. 计算本次部署的总资源需求
-	totalResourceNeed := resourcePerInst * req.Gas
-
-	// 5. 检查资源是否充足（加读写锁：读已用资源，避免并发冲突）
-	resourceMutex.RLock()
-	remainingResource := TotalResource - usedResource
-	resourceMutex.RUnlock()
-
-	if totalResourceNeed > remainingResource {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": fmt.Sprintf("资源不足！当前已用%d/总%d单位，本次需%d单位，剩余%d单位",
-				usedResource, TotalResource, totalResourceNeed, remainingResource),
-			"resource_status": map[string]int{
-				"used":      usedResource,
-				"total":     TotalResource,
-				"remaining": remainingResource,
-				"need":      totalResourceNeed,
-			},
-		})
-		return
-	}
-
-	// 6. （核心）按资源占比计算成本：成本 = 总占用资源 / 资源成本系数（向上取整）
-	cost := calculateCostByResource(totalResourceNeed)
-
-	// 7. 生成实例基础信息
-	// ❗ 修正：监听地址使用配置中的 Site2 IP
-	instanceID := fmt.Sprintf("%s-%s-%d", req.ServiceID, SiteID, time.Now().UnixNano()/1e6)
-	listenAddr := fmt.Sprintf("%s:%d", config.Cfg.Site2.IP, config.Cfg.Site2.Port)
-	csciID := fmt.Sprintf("http://%s/%s", listenAddr, instanceID)
-	delay := 10 + (req.Gas % 10) // 模拟延迟（10-20ms，与实例数量正相关）
-	createdAt := time.Now()
-
-	// 8. 占用资源（加写锁：更新已用资源）
-	resourceMutex.Lock()
-	usedResource += totalResourceNeed
-	resourceMutex.Unlock()
-
-	// 9. 存入数据库（含资源相关字段，便于重启后加载）
-	_, err = db.Exec(`
-		INSERT INTO deployed_services (
-			id, service_id, gas, cost, csci_id, created_at, delay,
-			resource_per_inst, total_resource_used
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		instanceID, req.ServiceID, req.Gas, cost, csciID, createdAt, delay,
-		resourcePerInst, totalResourceNeed) // 存储资源相关信息
-
-	if err != nil {
-		// 数据库插入失败，回滚资源占用
-		resourceMutex.Lock()
-		usedResource -= totalResourceNeed
-		resourceMutex.Unlock()
-
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "部署失败（数据库错误）：" + err.Error(),
-		})
-		return
-	}
-
-	// 10. 返回成功响应（包含资源和成本明细）
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": fmt.Sprintf("服务实例部署成功：%s（服务名：%s，%d个实例）", req.ServiceID, serviceName, req.Gas),
-		"info": models.ServiceInstanceInfo{
-			ServiceID: req.ServiceID,
-			Gas:       req.Gas,
-			Cost:      cost, // 按资源计算的成本
-			CSCI_ID:   csciID,
-			Delay:     delay,
-		},
-		"resource_detail": map[string]int{
-			"single_inst_resource": resourcePerInst,              // 单个实例资源占用
-			"total_resource_used":  totalResourceNeed,            // 本次占用总资源
-			"current_used":         usedResource,                 // 部署后总已用资源
-			"remaining_resource":   TotalResource - usedResource, // 剩余资源
-		},
-	})
-	fmt.Printf("[%s] 部署成功：ID=%s, 服务名=%s, 实例数=%d, 成本=%d（占用资源%d单位）\n",
-		time.Now().Format("15:04:05"), instanceID, serviceName, req.Gas, cost, totalResourceNeed)
-}
-
-// ------------------------------
-// 核心3：服务信息查询与资源计算工具函数 (保持不变)
-// ------------------------------
-
-// getServiceNameByID：按服务ID查询公共服务平台，获取服务名（含缓存）
-func getServiceNameByID(serviceID string) (string, error) {
-	// 1. 先查缓存，避免重复请求公共服务平台
-	serviceStoreMutex.RLock()
-	cachedService, exists := serviceStore[serviceID]
-	serviceStoreMutex.RUnlock()
-	if exists {
-		return cachedService.Name, nil
-	}
-
-	// 2. 缓存未命中，调用公共服务平台接口查询
-	publicPlatformURL := fmt.Sprintf("%s/api/v1/services/", config.Cfg.Platform.URL)
-	reqURL := publicPlatformURL + serviceID
-	resp, err := http.Get(reqURL)
-	if err != nil {
-		return "", fmt.Errorf("调用公共服务平台失败：%w", err)
-	}
-	defer resp.Body.Close()
-
-	// 3. 检查响应状态码
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("公共服务平台返回错误状态码：%d（服务ID：%s）", resp.StatusCode, serviceID)
-	}
-
-	// 4. 解析响应，提取服务名
-	var result struct {
-		Success bool           `json:"success"`
-		Service models.Service `json:"service"`
-		Message string         `json:"message"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("解析服务信息失败：%w", err)
-	}
-	if !result.Success {
-		return "", fmt.Errorf("公共服务平台查询失败：%s（服务ID：%s）", result.Message, serviceID)
-	}
-
-	// 5. 存入缓存，后续复用
-	serviceStoreMutex.Lock()
-	serviceStore[serviceID] = result.Service
-	serviceStoreMutex.Unlock()
-
-	return result.Service.Name, nil
-}
-
-// getResourcePerInstance：根据服务名分配单个实例的资源占用
-func getResourcePerInstance(serviceName string) (int, error) {
-	// 按服务名定义资源占用（覆盖所有已注册服务类型）
-	switch serviceName {
-	case "AR/VR": // AR/VR服务（如AR1760106899671）
-		return 60, nil
-	case "交通流量监测": // 交通流量监测服务（如AR1760108487856）
-		return 15, nil // 交通服务资源占用较低，15单位/实例
-	case "人脸识别": // 人脸识别服务（如AR1760108501919）
-		return 50, nil // 人脸识别计算密集，50单位/实例
-	case "语音转文字": // 语音转文字服务（如AR1760108514766）
-		return 30, nil // 语音处理中等资源消耗，30单位/实例
-	default:
-		return 0, fmt.Errorf("不支持的服务类型：%s（请先在getResourcePerInstance函数中定义资源占用）", serviceName)
-	}
-}
-
-// calculateCostByResource：按资源占比计算成本（向上取整，避免零成本）
-func calculateCostByResource(totalResource int) int {
-	if totalResource <= 0 {
-		return 1 // 最低成本1，避免免费服务
-	}
-	// 核心公式：成本 = 总资源 / 资源成本系数（向上取整）
-	cost := totalResource / ResourcePerCost
-	if totalResource%ResourcePerCost != 0 {
-		cost += 1
-	}
-	return cost
-}
-
-// ------------------------------
-// 辅助接口：状态查询与日志打印 (修正了 printStartInfo)
-// ------------------------------
-
-// getResourceStatus：查看当前资源占用状态 (保持不变)
-func getResourceStatus(c *gin.Context) {
-	resourceMutex.RLock()
-	defer resourceMutex.RUnlock()
-
-	remaining := TotalResource - usedResource
-	usageRate := fmt.Sprintf("%.1f%%", float64(usedResource)/float64(TotalResource)*100)
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"site_id": SiteID,
-		"resource": map[string]string{
-			"total":      fmt.Sprintf("%d", TotalResource),
-			"used":       fmt.Sprintf("%d", usedResource),
-			"remaining":  fmt.Sprintf("%d", remaining),
-			"usage_rate": usageRate,
-		},
-		"cost_conversion": fmt.Sprintf("每%d单位资源 = 1成本单位", ResourcePerCost),
-	})
-}
-
-// getMetricsHandler：暴露实例metrics（供C-SMA拉取） (保持不变)
-func getMetricsHandler(c *gin.Context) {
-	fmt.Println("[DEBUG] /metrics endpoint accessed")
-
-	rows, err := db.Query(`
-		SELECT service_id, gas, cost, csci_id, delay
-		FROM deployed_services
-		ORDER BY created_at DESC`)
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to query metrics: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "查询metrics失败：" + err.Error(),
-		})
-		return
-	}
-	defer rows.Close()
-
-	var metrics []models.ServiceInstanceInfo
-	for rows.Next() {
-		var m models.ServiceInstanceInfo
-		if err := rows.Scan(
-			&m.ServiceID, &m.Gas, &m.Cost, &m.CSCI_ID, &m.Delay,
-		); err != nil {
-			fmt.Printf("[WARNING] Failed to parse metrics row: %v\n", err)
-			continue
-		}
-		metrics = append(metrics, m)
-	}
-
-	fmt.Printf("[DEBUG] Metrics retrieved: %d records\n", len(metrics))
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"site_id": SiteID,
-		"count":   len(metrics),
-		"metrics": metrics,
-		"time":    time.Now().Format(time.RFC3339),
-	})
-}
-
-// healthCheckHandler：健康检查接口（含资源状态） (保持不变)
-func healthCheckHandler(c *gin.Context) {
-	// 检查数据库连接和资源状态
-	resourceMutex.RLock()
-	resourceStatus := "healthy"
-	usageRate := fmt.Sprintf("%.1f%%", float64(usedResource)/float64(TotalResource)*100)
-	if usedResource > TotalResource*0.9 { // 资源占用超90%标记为预警
-		resourceStatus = "warning (high resource usage)"
-	}
-	resourceMutex.RUnlock()
-
-	if err := db.Ping(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success":         false,
-			"status":          "unhealthy",
-			"reason":          "数据库连接失败",
-			"resource_status": resourceStatus,
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"status":  "healthy",
-		"site_id": SiteID,
-		"time":    time.Now().Format(time.RFC3339),
-		"resource_status": map[string]string{
-			"status":     resourceStatus,
-			"used":       fmt.Sprintf("%d/%d", usedResource, TotalResource),
-			"usage_rate": usageRate,
-		},
-	})
-}
-
-// uploadHandler 处理文件上传
-func uploadHandler(c *gin.Context) {
-	// 获取上传的文件
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "获取上传文件失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 确保文件是ZIP格式
-	if !strings.HasSuffix(strings.ToLower(file.Filename), ".zip") {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "仅支持ZIP格式的文件",
-		})
-		return
-	}
-
-	// 创建上传目录
-	uploadDir := "./uploads"
-	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		os.MkdirAll(uploadDir, 0755)
-	}
-
-	// 保存上传的文件
-	filePath := filepath.Join(uploadDir, file.Filename)
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "保存文件失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 解压ZIP文件
-	extractPath := filepath.Join(uploadDir, strings.TrimSuffix(file.Filename, ".zip"))
-	if err := extractZip(filePath, extractPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "解压文件失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 读取startScript参数
-	startScript := c.PostForm("startScript")
-
-	c.JSON(http.StatusOK, gin.H{
-		"success":     true,
-		"message":     fmt.Sprintf("文件已成功上传并解压到: %s", extractPath),
-		"extractPath": extractPath,
-		"startScript": startScript,
-	})
-}
-
-// executeHandler 在服务器上执行脚本
-func executeHandler(c *gin.Context) {
-	var req struct {
-		Script     string `json:"script"`
-		ScriptPath string `json:"script_path"`
-		WorkDir    string `json:"work_dir"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "请求格式错误: " + err.Error(),
-		})
-		return
-	}
-
-	// 验证脚本文件是否存在
-	if req.ScriptPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "脚本路径不能为空",
-		})
-		return
-	}
-
-	// 简单的安全检查：不允许路径遍历
-	if strings.Contains(req.Script, "..") || strings.Contains(req.ScriptPath, "..") {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "非法脚本路径",
-		})
-		return
-	}
-
-	// 检查脚本文件是否存在
-	if _, err := os.Stat(req.ScriptPath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{
-			"success": false,
-			"error":   "脚本文件不存在: " + req.ScriptPath,
-		})
-		return
-	}
-
-	// 在实际实现中，这里会执行脚本，例如：
-	// cmd := exec.Command("/bin/bash", req.ScriptPath)
-	// cmd.Dir = req.WorkDir
-	// output, err := cmd.CombinedOutput()
-	//
-	// 由于安全原因，当前实现只记录日志，不实际执行脚本
-	fmt.Printf("[INFO] 请求执行脚本: %s 在目录: %s\n", req.ScriptPath, req.WorkDir)
-
-	// 模拟执行成功
-	c.JSON(http.StatusOK, gin.H{
-		"success":  true,
-		"message":  fmt.Sprintf("脚本 %s 已成功启动", req.Script),
-		"script":   req.Script,
-		"work_dir": req.WorkDir,
-	})
-}
-
-// extractZip 解压ZIP文件到指定目录
-func extractZip(zipPath, extractPath string) error {
-	// 创建解压目录
-	if err := os.MkdirAll(extractPath, 0755); err != nil {
-		return err
-	}
-
-	// 打开ZIP文件
-	zipReader, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return err
-	}
-	defer zipReader.Close()
-
-	// 遍历ZIP中的文件
-	for _, file := range zipReader.File {
-		// 构建文件路径
-		filePath := filepath.Join(extractPath, file.Name)
-
-		// 检查路径安全性（防止路径遍历攻击）
-		if !strings.HasPrefix(filePath, extractPath+string(os.PathSeparator)) {
-			return fmt.Errorf("非法文件路径: %s", file.Name)
-		}
-
-		if file.FileInfo().IsDir() {
-			// 是目录，创建目录
-			if err := os.MkdirAll(filePath, file.Mode()); err != nil {
-				return err
-			}
-		} else {
-			// 是文件，创建目录并写入文件
-			if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-				return err
-			}
-
-			// 打开源文件
-			srcFile, err := file.Open()
-			if err != nil {
-				return err
-			}
-			defer srcFile.Close()
-
-			// 创建目标文件
-			dstFile, err := os.Create(filePath)
-			if err != nil {
-				return err
-			}
-			defer dstFile.Close()
-
-			// 复制文件内容
-			_, err = io.Copy(dstFile, srcFile)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
-
-// printStartInfo：打印启动信息（格式化输出）
-func printStartInfo() {
-	resourceMutex.RLock()
-	defer resourceMutex.RUnlock()
-
-	usageRate := fmt.Sprintf("%.1f%%", float64(usedResource)/float64(TotalResource)*100)
-	fmt.Printf("\n✅ 服务站点（site-2）启动成功！\n")
-	fmt.Printf("📌 站点ID：%s\n", SiteID)
-	// 移除：原有的监听地址打印，避免重复
-	fmt.Printf("📌 当前资源：已用%d / 总%d 单位（使用率%s）\n",
-		usedResource, TotalResource, usageRate)
-	fmt.Printf("📌 可用接口：\n")
-	fmt.Printf("   - POST   /deploy              部署服务实例（支持多服务类型）\n")
-	fmt.Printf("   - GET    /metrics             查看实例metrics\n")
-	fmt.Printf("   - GET    /health              健康检查\n")
-	fmt.Printf("   - GET    /resource-status     查看资源占用\n")
-	fmt.Printf("   - POST   /upload              上传代码文件并解压\n")
-}
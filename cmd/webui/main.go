@@ -2,13 +2,14 @@ package main
 
 import (
 	"bytes"
+	"cmas-cats-go/config"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -41,11 +42,17 @@ type DeployResponse struct {
 	Site        Resource `json:"site"`
 	Files       []string `json:"files"`
 	StartScript string   `json:"startScript"`
+	// 站点上的部署ID（site1 部署、或 site2 指定 service_id 时创建），停止服务时使用
+	DeploymentID string `json:"deployment_id,omitempty"`
+	// site2 上传带清单的部署包时登记的版本与内容摘要
+	Version string `json:"version,omitempty"`
+	Digest  string `json:"digest,omitempty"`
 }
 
 // StopRequest 停止请求结构
 type StopRequest struct {
-	SiteID string `json:"site_id" binding:"required"`
+	SiteID       string `json:"site_id" binding:"required"`
+	DeploymentID string `json:"deployment_id" binding:"required"` // 部署时返回的部署ID
 }
 
 // StatusResponse 状态响应结构
@@ -69,31 +76,29 @@ type ServiceInstanceInfo struct {
 
 // 从C-SMA服务获取真实的资源信息
 func getRealResourcesFromCSMA() ([]Resource, error) {
-	// 直接从配置获取真实站点地址，而不是从CSCI_ID解析
-	site1RealURL := "http://192.168.235.48:8081"
-	site2RealURL := "http://192.168.67.159:8085"
-	
+	// 直接从拓扑配置获取真实站点地址，而不是从CSCI_ID解析
 	resources := []Resource{}
-	
-	// 获取Site1的实时资源信息
-	site1Info := getRealResourceInfoFromSite(site1RealURL)
-	if site1Info != nil {
-		site1Info.ID = "site1"
-		site1Info.Name = "服务器节点 Site-1 (Linux)"
-		resources = append(resources, *site1Info)
-	}
-	
-	// 获取Site2的实时资源信息
-	site2Info := getRealResourceInfoFromSite(site2RealURL)
-	if site2Info != nil {
-		site2Info.ID = "site2"
-		site2Info.Name = "服务器节点 Site-2 (Mac)"
-		resources = append(resources, *site2Info)
-	}
-	
+
+	for _, site := range config.Cfg.Sites {
+		info := getRealResourceInfoFromSite(site.URL)
+		if info == nil {
+			continue
+		}
+		info.ID = webuiSiteID(site.ID)
+		if site.Name != "" {
+			info.Name = site.Name
+		}
+		resources = append(resources, *info)
+	}
+
 	return resources, nil
 }
 
+// webuiSiteID 前端页面使用不带连字符的站点ID（site-1 → site1）
+func webuiSiteID(siteID string) string {
+	return strings.ReplaceAll(siteID, "-", "")
+}
+
 // getRealResourceInfoFromSite 从实际站点获取实时资源信息
 func getRealResourceInfoFromSite(siteURL string) *Resource {
 	resource := &Resource{
@@ -121,19 +126,19 @@ func getRealResourceInfoFromSite(siteURL string) *Resource {
 	resp, err := client.Get(resourceStatusURL)
 	if err == nil && resp.StatusCode == http.StatusOK {
 		defer resp.Body.Close()
-		
+
 		var statusData struct {
-			Success bool `json:"success"`
-			SiteID  string `json:"site_id"`
+			Success  bool   `json:"success"`
+			SiteID   string `json:"site_id"`
 			Resource struct {
-				Total      string `json:"total"`
-				Used       string `json:"used"`
-				Remaining  string `json:"remaining"`
-				UsageRate  string `json:"usage_rate"`
+				Total     string `json:"total"`
+				Used      string `json:"used"`
+				Remaining string `json:"remaining"`
+				UsageRate string `json:"usage_rate"`
 			} `json:"resource"`
 			CostConversion string `json:"cost_conversion"`
 		}
-		
+
 		if err := json.NewDecoder(resp.Body).Decode(&statusData); err == nil && statusData.Success {
 			// 更新资源信息
 			resource.AvailableStorage = statusData.Resource.Remaining + " 单位"
@@ -147,19 +152,19 @@ func getRealResourceInfoFromSite(siteURL string) *Resource {
 	resp, err = client.Get(healthURL)
 	if err == nil && resp.StatusCode == http.StatusOK {
 		defer resp.Body.Close()
-		
+
 		var healthData struct {
-			Success bool `json:"success"`
-			Status  string `json:"status"`
-			SiteID  string `json:"site_id"`
-			Time    string `json:"time"`
+			Success        bool   `json:"success"`
+			Status         string `json:"status"`
+			SiteID         string `json:"site_id"`
+			Time           string `json:"time"`
 			ResourceStatus struct {
 				Status    string `json:"status"`
 				Used      string `json:"used"`
 				UsageRate string `json:"usage_rate"`
 			} `json:"resource_status"`
 		}
-		
+
 		if err := json.NewDecoder(resp.Body).Decode(&healthData); err == nil && healthData.Success {
 			// 更新延迟信息
 			resource.Latency = "正常"
@@ -179,24 +184,24 @@ func extractSiteIDFromURL(urlStr string) string {
 	if err != nil {
 		return "unknown"
 	}
-	
+
 	host := parsed.Host
 	if host == "" {
 		host = parsed.Path // 回退到路径
 	}
-	
+
 	// 对于localhost，包含端口来区分不同站点
 	if strings.Contains(host, "localhost") || strings.Contains(host, "127.0.0.1") {
 		return host // 例如: localhost:8082, localhost:8085
 	}
-	
+
 	// 对于其他情况，去掉端口号之后的部分
 	for i, ch := range host {
 		if ch == ':' || ch == '/' || ch == '?' || ch == '#' {
 			return host[:i]
 		}
 	}
-	
+
 	return host
 }
 
@@ -212,7 +217,7 @@ func getLocationFromURL(urlStr string) string {
 	if host == "" {
 		host = parsed.Path
 	}
-	
+
 	// 根据端口返回对应的站点信息
 	if strings.Contains(host, "localhost:8082") || strings.Contains(host, "127.0.0.1:8082") {
 		return "Site-1 (Linux)"
@@ -232,6 +237,18 @@ func getLocationFromURL(urlStr string) string {
 }
 
 func main() {
+	// 加载拓扑配置（站点列表）
+	flags := config.BindFlags()
+	flag.Parse()
+	if err := flags.Load(); err != nil {
+		fmt.Printf("❌ 加载配置失败，程序退出：%v\n", err)
+		return
+	}
+	listenPort := 9091
+	if flags.Port > 0 {
+		listenPort = flags.Port
+	}
+
 	r := gin.Default()
 
 	// 设置多部分形式上传的最大内存为 8 MB
@@ -263,9 +280,9 @@ func main() {
 	})
 
 	// 启动服务器
-	fmt.Println("🚀 Web界面服务器启动在 :9091")
-	fmt.Println("🌐 访问 http://localhost:9091 查看界面")
-	r.Run(":9091")
+	fmt.Printf("🚀 Web界面服务器启动在 :%d\n", listenPort)
+	fmt.Printf("🌐 访问 http://localhost:%d 查看界面\n", listenPort)
+	r.Run(fmt.Sprintf(":%d", listenPort))
 }
 
 // getResources 获取可用资源
@@ -330,9 +347,9 @@ func deployCode(c *gin.Context) {
 		if serviceType == "" {
 			serviceType = "AR100" // 默认服务类型
 		}
-		
+
 		// 直接调用部署接口
-		err = deployToSite1(targetSite.URL, serviceType)
+		deploymentID, err := deployToSite1(targetSite.URL, serviceType, "")
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
@@ -340,15 +357,16 @@ func deployCode(c *gin.Context) {
 			})
 			return
 		}
-		
+
 		c.JSON(http.StatusOK, DeployResponse{
-			Success:     true,
-			Message:     fmt.Sprintf("服务已部署到 %s", targetSite.Name),
-			Site:        *targetSite,
-			Files:       []string{}, // site1不支持文件上传
-			StartScript: serviceType,
+			Success:      true,
+			Message:      fmt.Sprintf("服务已部署到 %s", targetSite.Name),
+			Site:         *targetSite,
+			Files:        []string{}, // site1不支持文件上传
+			StartScript:  serviceType,
+			DeploymentID: deploymentID,
 		})
-		
+
 	case "site2":
 		// Site2: 使用/upload接口，支持文件上传
 		// 获取上传的文件
@@ -361,37 +379,30 @@ func deployCode(c *gin.Context) {
 			return
 		}
 
-		// 确保uploads目录存在
-		uploadDir := "./uploads"
-		if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-			os.MkdirAll(uploadDir, 0755)
-		}
-
-		// 保存上传的ZIP文件
-		zipFilename := filepath.Join(uploadDir, file.Filename)
-		if err := c.SaveUploadedFile(file, zipFilename); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   "保存ZIP文件失败: " + err.Error(),
-			})
-			return
-		}
-
-		// 读取ZIP文件内容
-		zipData, err := os.ReadFile(zipFilename)
+		// 读取代码包内容（zip/tar/tar.gz，由站点识别格式；不在本地落盘，避免同名上传互相覆盖）
+		archiveData, err := readFormFile(file)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
-				"error":   "读取ZIP文件失败: " + err.Error(),
+				"error":   "读取代码包失败: " + err.Error(),
 			})
 			return
 		}
 
-		// 读取startScript参数
+		// 读取startScript参数（前端以文件形式上传启动脚本时取其文件名，并随代码包一起上传）
 		startScriptName := c.PostForm("startScript")
+		var scriptData []byte
+		if scriptFile, err := c.FormFile("startScript"); err == nil {
+			startScriptName = filepath.Base(scriptFile.Filename)
+			if f, err := scriptFile.Open(); err == nil {
+				scriptData, _ = io.ReadAll(f)
+				f.Close()
+			}
+		}
 
-		// 上传到site2
-		err = uploadToSite2(targetSite.URL, zipData, startScriptName)
+		// 上传到site2（包内带清单时站点登记为服务的一个版本）
+		serviceID := c.PostForm("service_id")
+		upload, err := uploadToSite2(targetSite.URL, file.Filename, archiveData, startScriptName, scriptData, serviceID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
@@ -400,14 +411,48 @@ func deployCode(c *gin.Context) {
 			return
 		}
 
+		// 带清单的部署包：按清单的版本创建部署，站点按清单启动工作负载
+		message := fmt.Sprintf("代码已成功上传到 %s", targetSite.Name)
+		var deploymentID string
+		if upload.Version != "" {
+			if deploymentID, err = deployToSite1(targetSite.URL, upload.ServiceID, upload.Version); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   "在site2创建部署失败: " + err.Error(),
+				})
+				return
+			}
+			message = fmt.Sprintf("部署包 %s@%s 已部署到 %s 并已启动（部署ID：%s）", upload.ServiceID, upload.Version, targetSite.Name, deploymentID)
+		} else if serviceID != "" && startScriptName != "" {
+			// 指定了服务ID时：在站点创建部署并由站点的进程监管启动脚本
+			if deploymentID, err = deployToSite1(targetSite.URL, serviceID, ""); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   "在site2创建部署失败: " + err.Error(),
+				})
+				return
+			}
+			if err := executeStartScriptOnServer(targetSite.URL, deploymentID, upload.ExtractPath, startScriptName); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   "启动脚本失败: " + err.Error(),
+				})
+				return
+			}
+			message = fmt.Sprintf("代码已上传到 %s 并已启动（部署ID：%s）", targetSite.Name, deploymentID)
+		}
+
 		c.JSON(http.StatusOK, DeployResponse{
-			Success:     true,
-			Message:     fmt.Sprintf("代码已成功上传到 %s", targetSite.Name),
-			Site:        *targetSite,
-			Files:       []string{file.Filename},
-			StartScript: startScriptName,
+			Success:      true,
+			Message:      message,
+			Site:         *targetSite,
+			Files:        []string{file.Filename},
+			StartScript:  startScriptName,
+			DeploymentID: deploymentID,
+			Version:      upload.Version,
+			Digest:       upload.Digest,
 		})
-		
+
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -416,21 +461,23 @@ func deployCode(c *gin.Context) {
 	}
 }
 
-// deployToSite1 向site1发送部署请求（使用/deploy接口）
-func deployToSite1(serverURL string, serviceType string) error {
+// deployToSite1 向站点发送部署请求（使用/deploy接口），返回站点上的部署ID；version 非空时按该部署包版本部署
+func deployToSite1(serverURL string, serviceType string, version string) (string, error) {
 	// Site1的/deploy接口需要service_id和gas参数
 	// 由于site1不支持自定义上传，我们使用预设的服务类型
 	deployData := struct {
 		ServiceID string `json:"service_id"`
 		Gas       int    `json:"gas"`
+		Version   string `json:"version,omitempty"`
 	}{
 		ServiceID: serviceType, // 使用用户选择的脚本名称作为服务类型
 		Gas:       1,           // 默认部署1个实例
+		Version:   version,
 	}
 
 	jsonData, err := json.Marshal(deployData)
 	if err != nil {
-		return fmt.Errorf("序列化部署数据失败: %v", err)
+		return "", fmt.Errorf("序列化部署数据失败: %v", err)
 	}
 
 	client := &http.Client{
@@ -443,19 +490,20 @@ func deployToSite1(serverURL string, serviceType string) error {
 	deployURL := serverURL + "/deploy"
 	resp, err := client.Post(deployURL, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("部署请求失败: %v", err)
+		return "", fmt.Errorf("部署请求失败: %v", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("部署失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return "", fmt.Errorf("部署失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
 	}
 
 	var deployResp struct {
-		Success bool   `json:"success"`
-		Message string `json:"message"`
-		Info    struct {
+		Success      bool   `json:"success"`
+		Message      string `json:"message"`
+		DeploymentID string `json:"deployment_id"`
+		Info         struct {
 			ServiceID string `json:"service_id"`
 			Gas       int    `json:"gas"`
 			Cost      int    `json:"cost"`
@@ -465,42 +513,75 @@ func deployToSite1(serverURL string, serviceType string) error {
 	}
 
 	if err := json.Unmarshal(respBody, &deployResp); err != nil {
-		return fmt.Errorf("解析部署响应失败: %v", err)
+		return "", fmt.Errorf("解析部署响应失败: %v", err)
 	}
 
 	if !deployResp.Success {
-		return fmt.Errorf("部署失败: %s", deployResp.Message)
+		return "", fmt.Errorf("部署失败: %s", deployResp.Message)
 	}
 
 	fmt.Printf("✅ Site1部署成功: %s\n", deployResp.Message)
-	return nil
+	return deployResp.DeploymentID, nil
+}
+
+// siteUpload 站点 /upload 的结果
+type siteUpload struct {
+	ExtractPath string // 站点上的解压目录（按内容摘要存放）
+	Digest      string
+	ServiceID   string // 包内带清单时登记的服务与版本
+	Version     string
+}
+
+// readFormFile 读取表单上传文件的内容
+func readFormFile(file *multipart.FileHeader) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
 }
 
-// uploadToSite2 向site2上传文件（使用/upload接口）
-func uploadToSite2(serverURL string, zipData []byte, startScriptName string) error {
+// uploadToSite2 向site2上传文件（使用/upload接口），scriptData 非空时一并上传启动脚本；
+// serviceID 非空时随表单提交（清单未写 service_id 时使用）
+func uploadToSite2(serverURL string, filename string, archiveData []byte, startScriptName string, scriptData []byte, serviceID string) (siteUpload, error) {
 	// 创建一个临时的多部分表单
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 
-	// 添加ZIP数据
-	part, err := writer.CreateFormFile("file", "code.zip")
+	// 添加代码包（保留原文件名，站点据此辅助识别格式）
+	part, err := writer.CreateFormFile("file", filepath.Base(filename))
 	if err != nil {
-		return err
+		return siteUpload{}, err
 	}
-	_, err = part.Write(zipData)
+	_, err = part.Write(archiveData)
 	if err != nil {
-		return err
+		return siteUpload{}, err
 	}
 
 	// 添加startScript参数
 	err = writer.WriteField("startScript", startScriptName)
 	if err != nil {
-		return err
+		return siteUpload{}, err
+	}
+	if serviceID != "" {
+		if err := writer.WriteField("service_id", serviceID); err != nil {
+			return siteUpload{}, err
+		}
+	}
+	if len(scriptData) > 0 {
+		part, err := writer.CreateFormFile("script", startScriptName)
+		if err != nil {
+			return siteUpload{}, err
+		}
+		if _, err := part.Write(scriptData); err != nil {
+			return siteUpload{}, err
+		}
 	}
 
 	err = writer.Close()
 	if err != nil {
-		return err
+		return siteUpload{}, err
 	}
 
 	// 发送POST请求到目标服务器的上传端点
@@ -508,20 +589,20 @@ func uploadToSite2(serverURL string, zipData []byte, startScriptName string) err
 	client := &http.Client{Timeout: 60 * time.Second} // 增加超时时间
 	req, err := http.NewRequest("POST", uploadURL, &body)
 	if err != nil {
-		return err
+		return siteUpload{}, err
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return siteUpload{}, err
 	}
 	defer resp.Body.Close()
 
-	// 检查响应
+	// 检查响应（同一版本号上传了不同内容时站点返回409）
 	respBody, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("上传失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		return siteUpload{}, fmt.Errorf("上传失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
 	}
 
 	// 解析响应
@@ -530,29 +611,55 @@ func uploadToSite2(serverURL string, zipData []byte, startScriptName string) err
 		Message     string `json:"message"`
 		ExtractPath string `json:"extractPath"`
 		StartScript string `json:"startScript"`
+		Digest      string `json:"digest"`
 		Error       string `json:"error"`
+		Bundle      *struct {
+			ServiceID string `json:"service_id"`
+			Version   string `json:"version"`
+		} `json:"bundle"`
 	}
 	if err := json.Unmarshal(respBody, &uploadResponse); err != nil {
-		return fmt.Errorf("解析上传响应失败: %v", err)
+		return siteUpload{}, fmt.Errorf("解析上传响应失败: %v", err)
 	}
 
 	if !uploadResponse.Success {
-		return fmt.Errorf("上传失败: %s", uploadResponse.Error)
+		return siteUpload{}, fmt.Errorf("上传失败: %s", uploadResponse.Error)
 	}
 
 	fmt.Printf("✅ Site2文件上传成功: %s\n", uploadResponse.Message)
+	upload := siteUpload{ExtractPath: uploadResponse.ExtractPath, Digest: uploadResponse.Digest}
+	if uploadResponse.Bundle != nil {
+		upload.ServiceID, upload.Version = uploadResponse.Bundle.ServiceID, uploadResponse.Bundle.Version
+	}
+	return upload, nil
+}
+
+// executeStartScriptOnServer 请求站点为部署启动解压目录中的启动脚本（由站点进程监管）
+func executeStartScriptOnServer(serverURL, deploymentID, workDir, script string) error {
+	jsonData, err := json.Marshal(map[string]string{
+		"deployment_id": deploymentID,
+		"work_dir":      workDir,
+		"script":        script,
+	})
+	if err != nil {
+		return err
+	}
 
-	// 暂时不执行脚本，因为执行端点可能不存在
-	// 如果有startScript，可以后续手动执行
-	if startScriptName != "" {
-		fmt.Printf("ℹ️ 启动脚本 %s 已上传，可以在路径 %s 下手动执行\n", startScriptName, uploadResponse.ExtractPath)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(serverURL+"/execute", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("执行请求失败: %v", err)
 	}
+	defer resp.Body.Close()
 
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("执行失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+	}
+	fmt.Printf("✅ 已在站点启动 %s（部署ID：%s）\n", script, deploymentID)
 	return nil
 }
 
-// executeStartScriptOnServer 在目标服务器上执行start.sh
-
 // stopCode 停止在指定站点上运行的服务
 func stopCode(c *gin.Context) {
 	var req StopRequest
@@ -592,7 +699,7 @@ func stopCode(c *gin.Context) {
 	}
 
 	// 发送停止请求到目标服务器
-	err = sendStopRequestToServer(targetSite.URL)
+	stopped, err := sendStopRequestToServer(targetSite.URL, req.DeploymentID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -602,31 +709,44 @@ func stopCode(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": fmt.Sprintf("已在 %s 上停止服务", targetSite.Name),
+		"success":       true,
+		"message":       fmt.Sprintf("已在 %s 上停止服务", targetSite.Name),
+		"site":          targetSite,
+		"deployment_id": req.DeploymentID,
+		"stopped":       stopped,
 	})
 }
 
-// sendStopRequestToServer 向目标服务器发送停止请求
-func sendStopRequestToServer(serverURL string) error {
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	req, err := http.NewRequest("POST", serverURL+"/stop", nil)
+// sendStopRequestToServer 请求站点停止部署的工作负载并释放资源，返回站点给出的部署与工作负载最终状态
+func sendStopRequestToServer(serverURL, deploymentID string) (json.RawMessage, error) {
+	jsonData, err := json.Marshal(map[string]string{"deployment_id": deploymentID})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	resp, err := client.Do(req)
+	// 站点等待工作负载退出最长约10秒
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(serverURL+"/stop", "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("停止服务失败，状态码: %d", resp.StatusCode)
+	respBody, _ := io.ReadAll(resp.Body)
+	var stopResp struct {
+		Success bool            `json:"success"`
+		Message string          `json:"message"`
+		Stopped json.RawMessage `json:"stopped"`
+	}
+	if err := json.Unmarshal(respBody, &stopResp); err != nil {
+		return nil, fmt.Errorf("解析停止响应失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+	}
+	if resp.StatusCode != http.StatusOK || !stopResp.Success {
+		return nil, fmt.Errorf("状态码: %d, %s", resp.StatusCode, stopResp.Message)
 	}
 
-	return nil
+	fmt.Printf("✅ 已在站点停止部署 %s：%s\n", deploymentID, stopResp.Message)
+	return stopResp.Stopped, nil
 }
 
 // getStatus 获取指定站点的状态
@@ -1,30 +1,622 @@
 package config
 
-// LOCAL_LISTEN_IP 供本地服务 (Platform, C-SMA, C-PS) 实际监听使用 (应为 0.0.0.0 或 127.0.0.1)
-var LOCAL_LISTEN_IP = "127.0.0.1"
-
-var Cfg = struct {
-    Platform struct{ IP string; Port int; URL string }
-    Site1    struct{ IP string; Port int; URL string }
-    Site2    struct{ IP string; Port int; URL string }
-    SMA      struct{ IP string; Port int; URL string }
-    PS       struct{ IP string; Port int; URL string }
-    MonitoredSites []string
-    Resource struct{ Site1Total, Site2Total int }
-}{
-    Platform: struct{ IP string; Port int; URL string }{IP: "192.168.67.185", Port: 8080, URL: "http://192.168.67.185:8080"},
-    Site1:    struct{ IP string; Port int; URL string }{IP: "192.168.235.48", Port: 8081, URL: "http://192.168.235.48:8081"},
-    Site2:    struct{ IP string; Port int; URL string }{IP: "192.168.67.159", Port: 8085, URL: "http://192.168.67.159:8085"},
-    SMA:      struct{ IP string; Port int; URL string }{IP: "192.168.67.185", Port: 8083, URL: "http://192.168.67.185:8083"},
-    PS:       struct{ IP string; Port int; URL string }{IP: "192.168.67.185", Port: 8084, URL: "http://192.168.67.185:8084"},
-    MonitoredSites: []string{
-        "http://192.168.235.48:8081",
-        "http://192.168.67.159:8085",
-    },
-    Resource: struct{ Site1Total, Site2Total int }{Site1Total: 400, Site2Total: 500},
-}
-
-// GetAllSiteURLs 用于 C-SMA 模块，它需要一个函数来获取所有监控站点的URL
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigPath 默认拓扑配置文件路径（可通过 -config 参数或 CMAS_CONFIG 环境变量覆盖）
+const DefaultConfigPath = "./config/cmas.yaml"
+
+// Endpoint 组件的对外地址
+type Endpoint struct {
+	IP   string `yaml:"ip"`
+	Port int    `yaml:"port"`
+	URL  string `yaml:"url,omitempty"` // 留空时由 IP/Port 推导为 http://IP:Port
+}
+
+// OverridePort 覆盖端口并重新推导URL（用于 -port 参数）
+func (e *Endpoint) OverridePort(port int) {
+	e.Port = port
+	e.URL = fmt.Sprintf("http://%s:%d", e.IP, e.Port)
+}
+
+func (e *Endpoint) normalize() {
+	if e.URL == "" && e.IP != "" && e.Port > 0 {
+		e.URL = fmt.Sprintf("http://%s:%d", e.IP, e.Port)
+	}
+	e.URL = strings.TrimRight(e.URL, "/")
+}
+
+// SiteConfig 服务站点配置
+type SiteConfig struct {
+	ID       string `yaml:"id"`
+	Name     string `yaml:"name,omitempty"` // 展示名称（WebUI使用）
+	Endpoint `yaml:",inline"`
+	// 资源与成本
+	TotalResource   int    `yaml:"total_resource"`              // 站点总资源单位
+	ResourcePerCost int    `yaml:"resource_per_cost"`           // 每多少资源单位折算1个成本单位
+	DBFile          string `yaml:"db_file"`                     // 站点数据库文件
+	WorkloadLogDir  string `yaml:"workload_log_dir,omitempty"`  // 工作负载标准输出/错误日志目录，默认 ./logs/workloads/<id>
+	WorkloadDataDir string `yaml:"workload_data_dir,omitempty"` // 每个部署独立的可写数据目录的父目录，默认 ./data/workloads/<id>
+	BundleRetention int    `yaml:"bundle_retention,omitempty"`  // 每个服务保留的部署包版本数（部署当前及上一个版本不清理），默认5
+	MaxBundleMB     int    `yaml:"max_bundle_mb,omitempty"`     // 上传包（zip/tar/tar.gz）及其解压后总大小的上限（MB），默认512
+	Token           string `yaml:"token,omitempty"`             // 站点令牌：站点调用平台（提交部署验证）与C-SMA（注册、心跳、推送）时以 X-Site-Token 证明身份
+	// 工作负载沙箱（仅 Linux）
+	Sandbox SandboxConfig `yaml:"sandbox,omitempty"`
+}
+
+// 沙箱模式
+const (
+	SandboxOff        = "off"         // 不隔离，直接以站点进程身份运行
+	SandboxBestEffort = "best-effort" // 默认：使用可用的隔离手段，缺失项记为部署事件
+	SandboxStrict     = "strict"      // 任一隔离手段不可用即拒绝启动工作负载
+)
+
+// SandboxConfig 工作负载沙箱：以非特权用户在独立的 mount/PID/IPC/UTS 命名空间中运行，
+// 文件系统仅可见上传包解压目录（/app）与只读的系统目录，CPU/内存上限按部署占用的资源单位换算为 cgroup v2 限制
+type SandboxConfig struct {
+	Mode            string   `yaml:"mode,omitempty"`               // off / best-effort / strict，默认 best-effort
+	User            string   `yaml:"user,omitempty"`               // 站点以 root 运行时工作负载使用的用户，默认 nobody
+	CgroupRoot      string   `yaml:"cgroup_root,omitempty"`        // 父 cgroup 目录（cgroup v2），默认 <cgroup2挂载点>/cmas/<id>
+	CPUPerUnit      float64  `yaml:"cpu_per_unit,omitempty"`       // 每资源单位的CPU核数，默认0.01（100单位=1核）
+	MemoryMBPerUnit int      `yaml:"memory_mb_per_unit,omitempty"` // 每资源单位的内存（MB），默认16
+	ReadOnlyPaths   []string `yaml:"readonly_paths,omitempty"`     // 只读挂载进沙箱的宿主路径，默认 /bin /sbin /usr /lib /lib32 /lib64 /etc
+}
+
+// SMAConfig C-SMA 实例配置
+type SMAConfig struct {
+	ID       string `yaml:"id"`
+	Endpoint `yaml:",inline"`
+	Sites    []string `yaml:"sites,omitempty"` // 监控的站点ID，留空表示监控全部站点（配置了 children 时为不直接监控站点）
+	// 层级部署：聚合的下级C-SMA ID，本C-SMA拉取其 /sync 并与直接监控的站点合并
+	Children []string `yaml:"children,omitempty"`
+	// 实例最近一次被确认（拉取/推送）后超过该时长标记为过期，不再通过 /sync 通告，默认30秒
+	InstanceTTLSeconds int `yaml:"instance_ttl_seconds,omitempty"`
+	// 历史数据（每轮拉取的实例度量与站点拉取结果）
+	DBFile                string `yaml:"db_file,omitempty"`                 // 历史数据库文件，默认 ./db/<id>.db
+	HistoryRetentionHours int    `yaml:"history_retention_hours,omitempty"` // 历史保留时长（小时），默认72
+}
+
+// NMAConfig C-NMA 网络度量代理配置：部署在某个观测点（vantage），主动探测到各站点的网络质量
+type NMAConfig struct {
+	ID              string `yaml:"id"`
+	Endpoint        `yaml:",inline"`
+	Vantage         string   `yaml:"vantage,omitempty"`          // 观测点名称，留空使用ID
+	Sites           []string `yaml:"sites,omitempty"`            // 探测的站点ID，留空表示全部站点
+	Probe           string   `yaml:"probe,omitempty"`            // 探测方式：http / tcp，默认 http
+	ProbeCount      int      `yaml:"probe_count,omitempty"`      // 每轮每站点探测次数（用于计算抖动和丢包），默认5
+	IntervalSeconds int      `yaml:"interval_seconds,omitempty"` // 探测周期（秒），默认10
+	TimeoutMillis   int      `yaml:"timeout_ms,omitempty"`       // 单次探测超时（毫秒），超时计为丢包，默认1000
+}
+
+// PSConfig C-PS 实例配置
+type PSConfig struct {
+	ID       string `yaml:"id"`
+	Endpoint `yaml:",inline"`
+	SMA      string `yaml:"sma,omitempty"` // 数据来源的C-SMA实例ID，留空取第一个
+	NMA      string `yaml:"nma,omitempty"` // 网络时延来源的C-NMA实例ID（应与C-PS位于同一观测点），留空则仅使用计算时延
+	// 路径选择策略：请求未指定时取 API Key 的默认策略，再取 strategy，默认 lowest-cost
+	Strategy string `yaml:"strategy,omitempty"`
+	// 已废弃：API Key 及其默认策略改由 API Key 库管理；仅在库为空、首次导入旧版内置 Key 时作为其默认策略
+	APIKeyStrategies map[string]string `yaml:"api_key_strategies,omitempty"`
+	DBFile           string            `yaml:"db_file,omitempty"`     // API Key 库文件，默认 ./db/<id>.db
+	AdminToken       string            `yaml:"admin_token,omitempty"` // 管理接口（/admin/*）令牌，留空则禁用管理接口
+	Limits           RateLimits        `yaml:"limits,omitempty"`      // 每个 API Key 的默认限流与配额（可按 Key 覆盖）
+}
+
+// RateLimits API Key 的令牌桶限流与按自然日/自然月（UTC）的请求数、成本配额，0 表示不限。
+// 作为单个 Key 的覆盖值时，0 表示沿用 C-PS 默认值，负数表示该项不限
+type RateLimits struct {
+	RatePerSecond   float64 `yaml:"rate_per_second,omitempty" json:"rate_per_second,omitempty"` // 令牌补充速率（请求/秒）
+	Burst           int     `yaml:"burst,omitempty" json:"burst,omitempty"`                     // 令牌桶容量，默认 ceil(rate_per_second)
+	DailyRequests   int     `yaml:"daily_requests,omitempty" json:"daily_requests,omitempty"`
+	MonthlyRequests int     `yaml:"monthly_requests,omitempty" json:"monthly_requests,omitempty"`
+	DailyCost       int     `yaml:"daily_cost,omitempty" json:"daily_cost,omitempty"` // 所选实例成本之和
+	MonthlyCost     int     `yaml:"monthly_cost,omitempty" json:"monthly_cost,omitempty"`
+}
+
+// Config 整体拓扑配置：任意数量的站点、C-SMA、C-PS、C-NMA 以及一个公共服务平台
+type Config struct {
+	ListenIP string       `yaml:"listen_ip"` // 本地服务 (Platform, C-SMA, C-PS) 实际监听地址（应为 0.0.0.0 或 127.0.0.1）
+	Platform Endpoint     `yaml:"platform"`
+	Sites    []SiteConfig `yaml:"sites"`
+	SMA      []SMAConfig  `yaml:"sma"`
+	PS       []PSConfig   `yaml:"ps"`
+	NMA      []NMAConfig  `yaml:"nma,omitempty"`
+}
+
+// Cfg 当前生效的配置。未调用 Load 时为内置默认拓扑（与原硬编码配置一致）
+var Cfg = defaultConfig()
+
+func defaultConfig() Config {
+	cfg := Config{
+		ListenIP: "127.0.0.1",
+		Platform: Endpoint{IP: "192.168.67.185", Port: 8080},
+		Sites: []SiteConfig{
+			{ID: "site-1", Name: "服务器节点 Site-1 (Linux)", Endpoint: Endpoint{IP: "192.168.235.48", Port: 8081},
+				TotalResource: 400, ResourcePerCost: 40, DBFile: "./db/site1.db"},
+			{ID: "site-2", Name: "服务器节点 Site-2 (Mac)", Endpoint: Endpoint{IP: "192.168.67.159", Port: 8085},
+				TotalResource: 500, ResourcePerCost: 40, DBFile: "./db/site2.db"},
+		},
+		SMA: []SMAConfig{{ID: "sma-1", Endpoint: Endpoint{IP: "192.168.67.185", Port: 8083}}},
+		PS:  []PSConfig{{ID: "ps-1", Endpoint: Endpoint{IP: "192.168.67.185", Port: 8084}, SMA: "sma-1", NMA: "nma-1"}},
+		NMA: []NMAConfig{{ID: "nma-1", Endpoint: Endpoint{IP: "192.168.67.185", Port: 8086}, Vantage: "ps-1"}},
+	}
+	cfg.normalize()
+	return cfg
+}
+
+// Flags 各二进制共用的命令行参数
+type Flags struct {
+	ConfigPath string // -config：配置文件路径
+	ID         string // -id：本进程对应的组件实例ID（站点/C-SMA/C-PS），留空取配置中的第一个
+	ListenIP   string // -listen：覆盖监听地址
+	Port       int    // -port：覆盖本组件端口
+}
+
+// BindFlags 在默认 FlagSet 上注册通用参数，需在 flag.Parse() 之前调用
+func BindFlags() *Flags {
+	f := &Flags{}
+	flag.StringVar(&f.ConfigPath, "config", "", "拓扑配置文件路径（默认 $CMAS_CONFIG 或 "+DefaultConfigPath+"）")
+	flag.StringVar(&f.ID, "id", "", "本组件实例ID（默认取配置中的第一个）")
+	flag.StringVar(&f.ListenIP, "listen", "", "覆盖监听地址")
+	flag.IntVar(&f.Port, "port", 0, "覆盖本组件端口")
+	return f
+}
+
+// Load 按「内置默认值 → 配置文件 → 环境变量 → 命令行参数」的顺序加载配置并写入 Cfg
+// 显式指定的配置文件不存在时报错；默认路径不存在时使用内置默认拓扑
+func (f *Flags) Load() error {
+	path, explicit := f.ConfigPath, f.ConfigPath != ""
+	if !explicit {
+		if env := os.Getenv("CMAS_CONFIG"); env != "" {
+			path, explicit = env, true
+		} else {
+			path = DefaultConfigPath
+		}
+	}
+
+	cfg := defaultConfig()
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		cfg = Config{}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return fmt.Errorf("解析配置文件%s失败：%w", path, err)
+		}
+		fmt.Printf("✅ 已加载拓扑配置：%s\n", path)
+	case os.IsNotExist(err) && !explicit:
+		fmt.Printf("⚠️ 未找到配置文件%s，使用内置默认拓扑\n", path)
+	default:
+		return fmt.Errorf("读取配置文件%s失败：%w", path, err)
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return err
+	}
+	if f.ListenIP != "" {
+		cfg.ListenIP = f.ListenIP
+	}
+	cfg.normalize()
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("配置不合法：%w", err)
+	}
+
+	Cfg = cfg
+	return nil
+}
+
+// applyEnv 环境变量覆盖：
+//
+//	CMAS_LISTEN_IP
+//	CMAS_PLATFORM_IP / CMAS_PLATFORM_PORT / CMAS_PLATFORM_URL
+//	CMAS_SITE_<ID>_IP / _PORT / _URL / _TOTAL_RESOURCE / _RESOURCE_PER_COST / _DB_FILE / _WORKLOAD_LOG_DIR / _WORKLOAD_DATA_DIR
+//	                                  / _BUNDLE_RETENTION / _MAX_BUNDLE_MB / _SANDBOX_MODE / _SANDBOX_USER / _TOKEN
+//	                                  （ID 大写，'-' 替换为 '_'，如 CMAS_SITE_SITE_1_PORT）
+//	CMAS_SMA_<ID>_IP / _PORT / _URL / _INSTANCE_TTL_SECONDS / _DB_FILE / _HISTORY_RETENTION_HOURS
+//	CMAS_PS_<ID>_IP / _PORT / _URL / _DB_FILE / _ADMIN_TOKEN
+//	CMAS_NMA_<ID>_IP / _PORT / _URL
+func (c *Config) applyEnv() error {
+	if v := os.Getenv("CMAS_LISTEN_IP"); v != "" {
+		c.ListenIP = v
+	}
+	if err := applyEndpointEnv("CMAS_PLATFORM", &c.Platform); err != nil {
+		return err
+	}
+	for i := range c.Sites {
+		prefix := "CMAS_SITE_" + envKey(c.Sites[i].ID)
+		if err := applyEndpointEnv(prefix, &c.Sites[i].Endpoint); err != nil {
+			return err
+		}
+		if err := applyIntEnv(prefix+"_TOTAL_RESOURCE", &c.Sites[i].TotalResource); err != nil {
+			return err
+		}
+		if err := applyIntEnv(prefix+"_RESOURCE_PER_COST", &c.Sites[i].ResourcePerCost); err != nil {
+			return err
+		}
+		if v := os.Getenv(prefix + "_DB_FILE"); v != "" {
+			c.Sites[i].DBFile = v
+		}
+		if v := os.Getenv(prefix + "_WORKLOAD_LOG_DIR"); v != "" {
+			c.Sites[i].WorkloadLogDir = v
+		}
+		if v := os.Getenv(prefix + "_WORKLOAD_DATA_DIR"); v != "" {
+			c.Sites[i].WorkloadDataDir = v
+		}
+		if err := applyIntEnv(prefix+"_BUNDLE_RETENTION", &c.Sites[i].BundleRetention); err != nil {
+			return err
+		}
+		if err := applyIntEnv(prefix+"_MAX_BUNDLE_MB", &c.Sites[i].MaxBundleMB); err != nil {
+			return err
+		}
+		if v := os.Getenv(prefix + "_SANDBOX_MODE"); v != "" {
+			c.Sites[i].Sandbox.Mode = v
+		}
+		if v := os.Getenv(prefix + "_SANDBOX_USER"); v != "" {
+			c.Sites[i].Sandbox.User = v
+		}
+		if v := os.Getenv(prefix + "_TOKEN"); v != "" {
+			c.Sites[i].Token = v
+		}
+	}
+	for i := range c.SMA {
+		prefix := "CMAS_SMA_" + envKey(c.SMA[i].ID)
+		if err := applyEndpointEnv(prefix, &c.SMA[i].Endpoint); err != nil {
+			return err
+		}
+		if err := applyIntEnv(prefix+"_INSTANCE_TTL_SECONDS", &c.SMA[i].InstanceTTLSeconds); err != nil {
+			return err
+		}
+		if err := applyIntEnv(prefix+"_HISTORY_RETENTION_HOURS", &c.SMA[i].HistoryRetentionHours); err != nil {
+			return err
+		}
+		if v := os.Getenv(prefix + "_DB_FILE"); v != "" {
+			c.SMA[i].DBFile = v
+		}
+	}
+	for i := range c.PS {
+		prefix := "CMAS_PS_" + envKey(c.PS[i].ID)
+		if err := applyEndpointEnv(prefix, &c.PS[i].Endpoint); err != nil {
+			return err
+		}
+		if v := os.Getenv(prefix + "_DB_FILE"); v != "" {
+			c.PS[i].DBFile = v
+		}
+		if v := os.Getenv(prefix + "_ADMIN_TOKEN"); v != "" {
+			c.PS[i].AdminToken = v
+		}
+	}
+	for i := range c.NMA {
+		if err := applyEndpointEnv("CMAS_NMA_"+envKey(c.NMA[i].ID), &c.NMA[i].Endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyEndpointEnv(prefix string, e *Endpoint) error {
+	changed := false
+	if v := os.Getenv(prefix + "_IP"); v != "" {
+		e.IP, changed = v, true
+	}
+	if v := os.Getenv(prefix + "_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("环境变量%s_PORT不是整数：%s", prefix, v)
+		}
+		e.Port, changed = port, true
+	}
+	if changed {
+		e.URL = "" // IP/端口变化后重新推导URL
+	}
+	if v := os.Getenv(prefix + "_URL"); v != "" {
+		e.URL = v
+	}
+	return nil
+}
+
+func applyIntEnv(key string, dst *int) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("环境变量%s不是整数：%s", key, v)
+	}
+	*dst = n
+	return nil
+}
+
+func envKey(id string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(id))
+}
+
+func (c *Config) normalize() {
+	c.Platform.normalize()
+	for i := range c.Sites {
+		c.Sites[i].normalize()
+		if c.Sites[i].ResourcePerCost <= 0 {
+			c.Sites[i].ResourcePerCost = 40
+		}
+		if c.Sites[i].DBFile == "" {
+			c.Sites[i].DBFile = fmt.Sprintf("./db/%s.db", strings.ReplaceAll(c.Sites[i].ID, "-", ""))
+		}
+		if c.Sites[i].WorkloadLogDir == "" {
+			c.Sites[i].WorkloadLogDir = filepath.Join("./logs/workloads", c.Sites[i].ID)
+		}
+		if c.Sites[i].WorkloadDataDir == "" {
+			c.Sites[i].WorkloadDataDir = filepath.Join("./data/workloads", c.Sites[i].ID)
+		}
+		if c.Sites[i].BundleRetention <= 0 {
+			c.Sites[i].BundleRetention = 5
+		}
+		if c.Sites[i].MaxBundleMB <= 0 {
+			c.Sites[i].MaxBundleMB = 512
+		}
+		c.Sites[i].Sandbox.normalize()
+	}
+	for i := range c.SMA {
+		c.SMA[i].normalize()
+		if c.SMA[i].InstanceTTLSeconds <= 0 {
+			c.SMA[i].InstanceTTLSeconds = 30
+		}
+		if c.SMA[i].DBFile == "" {
+			c.SMA[i].DBFile = fmt.Sprintf("./db/%s.db", strings.ReplaceAll(c.SMA[i].ID, "-", ""))
+		}
+		if c.SMA[i].HistoryRetentionHours <= 0 {
+			c.SMA[i].HistoryRetentionHours = 72
+		}
+	}
+	for i := range c.PS {
+		c.PS[i].normalize()
+		if c.PS[i].DBFile == "" {
+			c.PS[i].DBFile = fmt.Sprintf("./db/%s.db", strings.ReplaceAll(c.PS[i].ID, "-", ""))
+		}
+	}
+	for i := range c.NMA {
+		n := &c.NMA[i]
+		n.normalize()
+		if n.Vantage == "" {
+			n.Vantage = n.ID
+		}
+		if n.Probe == "" {
+			n.Probe = "http"
+		}
+		if n.ProbeCount <= 0 {
+			n.ProbeCount = 5
+		}
+		if n.IntervalSeconds <= 0 {
+			n.IntervalSeconds = 10
+		}
+		if n.TimeoutMillis <= 0 {
+			n.TimeoutMillis = 1000
+		}
+	}
+}
+
+func (s *SandboxConfig) normalize() {
+	if s.Mode == "" {
+		s.Mode = SandboxBestEffort
+	}
+	if s.User == "" {
+		s.User = "nobody"
+	}
+	if s.CPUPerUnit <= 0 {
+		s.CPUPerUnit = 0.01
+	}
+	if s.MemoryMBPerUnit <= 0 {
+		s.MemoryMBPerUnit = 16
+	}
+	if len(s.ReadOnlyPaths) == 0 {
+		s.ReadOnlyPaths = []string{"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/etc"}
+	}
+}
+
+func (c *Config) validate() error {
+	if c.Platform.URL == "" {
+		return fmt.Errorf("platform 缺少 ip/port 或 url")
+	}
+	seen := map[string]bool{}
+	for _, s := range c.Sites {
+		if s.ID == "" || s.URL == "" {
+			return fmt.Errorf("站点配置缺少 id 或地址：%+v", s)
+		}
+		if s.TotalResource <= 0 {
+			return fmt.Errorf("站点%s的 total_resource 必须大于0", s.ID)
+		}
+		switch s.Sandbox.Mode {
+		case SandboxOff, SandboxBestEffort, SandboxStrict:
+		default:
+			return fmt.Errorf("站点%s的 sandbox.mode 仅支持 off / best-effort / strict：%s", s.ID, s.Sandbox.Mode)
+		}
+		if seen["site/"+s.ID] {
+			return fmt.Errorf("站点ID重复：%s", s.ID)
+		}
+		seen["site/"+s.ID] = true
+	}
+	for _, m := range c.SMA {
+		if m.ID == "" || m.URL == "" {
+			return fmt.Errorf("C-SMA配置缺少 id 或地址：%+v", m)
+		}
+		for _, siteID := range m.Sites {
+			if !seen["site/"+siteID] {
+				return fmt.Errorf("C-SMA %s 引用了未定义的站点：%s", m.ID, siteID)
+			}
+		}
+		seen["sma/"+m.ID] = true
+	}
+	if err := c.validateSMAHierarchy(seen); err != nil {
+		return err
+	}
+	for _, n := range c.NMA {
+		if n.ID == "" || n.URL == "" {
+			return fmt.Errorf("C-NMA配置缺少 id 或地址：%+v", n)
+		}
+		if n.Probe != "http" && n.Probe != "tcp" {
+			return fmt.Errorf("C-NMA %s 的 probe 仅支持 http / tcp：%s", n.ID, n.Probe)
+		}
+		for _, siteID := range n.Sites {
+			if !seen["site/"+siteID] {
+				return fmt.Errorf("C-NMA %s 引用了未定义的站点：%s", n.ID, siteID)
+			}
+		}
+		seen["nma/"+n.ID] = true
+	}
+	for _, p := range c.PS {
+		if p.ID == "" || p.URL == "" {
+			return fmt.Errorf("C-PS配置缺少 id 或地址：%+v", p)
+		}
+		if p.SMA != "" && !seen["sma/"+p.SMA] {
+			return fmt.Errorf("C-PS %s 引用了未定义的C-SMA：%s", p.ID, p.SMA)
+		}
+		if p.NMA != "" && !seen["nma/"+p.NMA] {
+			return fmt.Errorf("C-PS %s 引用了未定义的C-NMA：%s", p.ID, p.NMA)
+		}
+	}
+	return nil
+}
+
+// validateSMAHierarchy 检查 C-SMA 的 children 均已定义且不成环
+func (c *Config) validateSMAHierarchy(seen map[string]bool) error {
+	children := make(map[string][]string, len(c.SMA))
+	for _, m := range c.SMA {
+		for _, child := range m.Children {
+			if !seen["sma/"+child] {
+				return fmt.Errorf("C-SMA %s 引用了未定义的下级C-SMA：%s", m.ID, child)
+			}
+		}
+		children[m.ID] = m.Children
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(c.SMA))
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("C-SMA 层级存在环：%s", id)
+		case done:
+			return nil
+		}
+		state[id] = visiting
+		for _, child := range children[id] {
+			if err := visit(child); err != nil {
+				return err
+			}
+		}
+		state[id] = done
+		return nil
+	}
+	for _, m := range c.SMA {
+		if err := visit(m.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Site 按ID查找站点配置（id 为空时返回第一个站点）
+func Site(id string) (*SiteConfig, error) {
+	for i := range Cfg.Sites {
+		if id == "" || Cfg.Sites[i].ID == id {
+			return &Cfg.Sites[i], nil
+		}
+	}
+	return nil, fmt.Errorf("配置中不存在站点：%q", id)
+}
+
+// SMA 按ID查找C-SMA配置（id 为空时返回第一个）
+func SMA(id string) (*SMAConfig, error) {
+	for i := range Cfg.SMA {
+		if id == "" || Cfg.SMA[i].ID == id {
+			return &Cfg.SMA[i], nil
+		}
+	}
+	return nil, fmt.Errorf("配置中不存在C-SMA：%q", id)
+}
+
+// PS 按ID查找C-PS配置（id 为空时返回第一个）
+func PS(id string) (*PSConfig, error) {
+	for i := range Cfg.PS {
+		if id == "" || Cfg.PS[i].ID == id {
+			return &Cfg.PS[i], nil
+		}
+	}
+	return nil, fmt.Errorf("配置中不存在C-PS：%q", id)
+}
+
+// NMA 按ID查找C-NMA配置（id 为空时返回第一个）
+func NMA(id string) (*NMAConfig, error) {
+	for i := range Cfg.NMA {
+		if id == "" || Cfg.NMA[i].ID == id {
+			return &Cfg.NMA[i], nil
+		}
+	}
+	return nil, fmt.Errorf("配置中不存在C-NMA：%q", id)
+}
+
+// SitesFor 返回某个C-SMA需要直接监控的站点（未配置 sites 时为全部站点；只聚合下级C-SMA的父级为空）
+func (m SMAConfig) SitesFor() []SiteConfig {
+	if len(m.Sites) == 0 && len(m.Children) > 0 {
+		return nil
+	}
+	return sitesByID(m.Sites)
+}
+
+// Monitors 判断该C-SMA是否监控指定站点
+func (m SMAConfig) Monitors(siteID string) bool {
+	for _, s := range m.SitesFor() {
+		if s.ID == siteID {
+			return true
+		}
+	}
+	return false
+}
+
+// SMAsFor 返回监控指定站点的全部C-SMA（站点据此推送实例变化）
+func SMAsFor(siteID string) []SMAConfig {
+	var smas []SMAConfig
+	for _, m := range Cfg.SMA {
+		if m.Monitors(siteID) {
+			smas = append(smas, m)
+		}
+	}
+	return smas
+}
+
+// SitesFor 返回某个C-NMA需要探测的站点（未配置 sites 时为全部站点）
+func (n NMAConfig) SitesFor() []SiteConfig {
+	return sitesByID(n.Sites)
+}
+
+func sitesByID(ids []string) []SiteConfig {
+	if len(ids) == 0 {
+		return Cfg.Sites
+	}
+	var sites []SiteConfig
+	for _, id := range ids {
+		if s, err := Site(id); err == nil && id != "" {
+			sites = append(sites, *s)
+		}
+	}
+	return sites
+}
+
+// GetAllSiteURLs 返回配置中所有站点的URL
 func GetAllSiteURLs() []string {
-    return Cfg.MonitoredSites
+	urls := make([]string, 0, len(Cfg.Sites))
+	for _, s := range Cfg.Sites {
+		urls = append(urls, s.URL)
+	}
+	return urls
 }
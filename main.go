@@ -1,313 +0,0 @@
-package main
-
-import (
-	"database/sql"
-	"fmt"
-	"net/http"
-	"time"
-
-	"cmas-cats-go/models"
-
-	"github.com/gin-gonic/gin"
-	_ "github.com/mattn/go-sqlite3" // SQLite驱动
-)
-
-// 全局数据库连接
-var db *sql.DB
-
-// 全局状态：资源管理
-var usedResource int = 0 // 已使用资源单位（动态更新）
-
-// 服务站点配置
-const (
-	ListenPort    = ":8082"     // 服务站点监听端口
-	DBFile        = "./site.db" // 数据库文件路径
-	SiteID        = "site-1"    // 站点唯一标识（可修改为不同值部署多站点）
-	TotalResource = 400         // 站点总资源单位
-)
-
-func main() {
-	// 启动标识日志
-	fmt.Println("=====================================")
-	fmt.Println("          服务站点启动中...          ")
-	fmt.Println("=====================================")
-
-	// 1. 初始化数据库
-	if err := initDB(); err != nil {
-		fmt.Printf("❌ 初始化失败，程序退出：%v\n", err)
-		return
-	}
-	defer db.Close()
-
-	// 2. 初始化Gin引擎
-	r := gin.Default()
-
-	// 3. 注册API接口
-	r.POST("/deploy", deployServiceHandler) // 部署服务实例
-	r.GET("/metrics", getMetricsHandler)    // 暴露实例metrics（供C-SMA拉取）
-	r.GET("/health", healthCheckHandler)    // 健康检查接口
-
-	// 4. 启动服务
-	fmt.Printf("\n✅ 服务站点启动成功！\n")
-	fmt.Printf("📌 站点ID：%s\n", SiteID)
-	fmt.Printf("📌 监听地址：http://localhost%s\n", ListenPort)
-	fmt.Printf("📌 可用接口：\n")
-	fmt.Printf("   - POST   /deploy        部署服务实例\n")
-	fmt.Printf("   - GET    /metrics       查看实例metrics\n")
-	fmt.Printf("   - GET    /health        健康检查\n")
-
-	// 启动HTTP服务
-	if err := r.Run(ListenPort); err != nil {
-		fmt.Printf("❌ 服务启动失败：%v\n", err)
-	}
-}
-
-// initDB：初始化SQLite数据库
-func initDB() error {
-	var err error
-
-	// 1. 打开数据库
-	db, err = sql.Open("sqlite3", DBFile)
-	if err != nil {
-		return fmt.Errorf("数据库连接失败：%w", err)
-	}
-
-	// 2. 验证连接
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("数据库验证失败：%w", err)
-	}
-
-	// 3. 创建部署实例表
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS deployed_services (
-		id TEXT PRIMARY KEY,
-		service_id TEXT NOT NULL,
-		gas INT NOT NULL,
-		cost INT NOT NULL,
-		csci_id TEXT NOT NULL,
-		created_at DATETIME NOT NULL,
-		delay INT NOT NULL,
-		resource_per_inst INT NOT NULL,
-		total_resource_used INT NOT NULL
-	);`
-	_, err = db.Exec(createTableSQL)
-	if err != nil {
-		return fmt.Errorf("创建部署表失败：%w", err)
-	}
-
-	fmt.Println("✅ 数据库初始化成功（SQLite）")
-	return nil
-}
-
-// deployServiceHandler：处理服务部署请求
-func deployServiceHandler(c *gin.Context) {
-	var req struct {
-		ServiceID string `json:"service_id" binding:"required"` // 目标服务ID（必须）
-		Gas       int    `json:"gas" binding:"min=1"`           // 部署实例数量（至少1个）
-	}
-
-	// 1. 解析请求参数
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "请求格式错误：" + err.Error(),
-		})
-		return
-	}
-
-	// 2. 验证服务类型是否支持（预设服务列表，不依赖平台服务）
-	serviceName, resourcePerInst, err := getPresetServiceInfo(req.ServiceID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": err.Error(),
-		})
-		return
-	}
-
-	// 3. 生成实例信息
-	instanceID := fmt.Sprintf("%s-%s-%d", req.ServiceID, SiteID, time.Now().UnixNano()/1e6)
-	csciID := fmt.Sprintf("http://192.168.235.48%s/%s", ListenPort, instanceID)
-	cost := req.Gas * 2          // 成本计算逻辑（示例：数量×2）
-	delay := 10 + (req.Gas % 10) // 模拟延迟（10-20ms）
-	createdAt := time.Now()
-
-	// 4. 计算资源需求
-	totalResourceNeed := resourcePerInst * req.Gas
-
-	// 5. 检查资源是否充足
-	remainingResource := TotalResource - usedResource
-	if totalResourceNeed > remainingResource {
-		c.JSON(http.StatusForbidden, gin.H{
-			"success": false,
-			"message": fmt.Sprintf("资源不足！当前已用%d/总%d单位，本次需%d单位，剩余%d单位",
-				usedResource, TotalResource, totalResourceNeed, remainingResource),
-			"resource_status": map[string]int{
-				"used":      usedResource,
-				"total":     TotalResource,
-				"remaining": remainingResource,
-				"need":      totalResourceNeed,
-			},
-		})
-		return
-	}
-
-	// 6. 占用资源（加写锁：更新已用资源）
-	usedResource += totalResourceNeed
-
-	// 7. 存入数据库
-	_, err = db.Exec(`
-		INSERT INTO deployed_services (
-			id, service_id, gas, cost, csci_id, created_at, delay, resource_per_inst, total_resource_used
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		instanceID, req.ServiceID, req.Gas, cost, csciID, createdAt, delay, resourcePerInst, totalResourceNeed)
-
-	if err != nil {
-		// 数据库插入失败，回滚资源占用
-		usedResource -= totalResourceNeed
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "部署失败（数据库错误）：" + err.Error(),
-		})
-		return
-	}
-
-	// 8. 返回成功响应
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": fmt.Sprintf("服务实例部署成功：%s（%s，%d个实例）", req.ServiceID, serviceName, req.Gas),
-		"info": map[string]interface{}{
-			"service_id": req.ServiceID,
-			"gas":        req.Gas,
-			"cost":       cost,
-			"csci_id":    csciID,
-			"delay":      delay,
-		},
-		"resource_detail": map[string]int{
-			"single_inst_resource": resourcePerInst,
-			"total_resource_used":  totalResourceNeed,
-			"current_used":         usedResource,
-			"remaining_resource":   TotalResource - usedResource,
-		},
-	})
-	fmt.Printf("[%s] 部署成功：ID=%s, 服务=%s, 实例数=%d, 成本=%d（占用资源%d单位）\n",
-		time.Now().Format("15:04:05"), instanceID, serviceName, req.Gas, cost, totalResourceNeed)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "部署失败（数据库错误）：" + err.Error(),
-		})
-		return
-	}
-
-	// 4. 返回成功响应
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": fmt.Sprintf("服务实例部署成功：%s（%d个）", req.ServiceID, req.Gas),
-		"info": models.ServiceInstanceInfo{
-			ServiceID: req.ServiceID,
-			Gas:       req.Gas,
-			Cost:      cost,
-			CSCI_ID:   csciID,
-			Delay:     delay,
-		},
-	})
-	fmt.Printf("[%s] 部署成功：ID=%s, 服务=%s, 实例数=%d\n",
-		time.Now().Format("15:04:05"), instanceID, req.ServiceID, req.Gas)
-}
-
-// getMetricsHandler：暴露实例metrics（供C-SMA拉取）
-func getMetricsHandler(c *gin.Context) {
-	// 1. 查询所有部署的实例
-	rows, err := db.Query(`
-                SELECT service_id, gas, cost, csci_id, delay
-                FROM deployed_services
-                ORDER BY created_at DESC`)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "查询metrics失败：" + err.Error(),
-		})
-		return
-	}
-	defer rows.Close()
-
-	// 2. 解析结果
-	var metrics []models.ServiceInstanceInfo
-	for rows.Next() {
-		var m models.ServiceInstanceInfo
-		if err := rows.Scan(
-			&m.ServiceID, &m.Gas, &m.Cost, &m.CSCI_ID, &m.Delay,
-		); err != nil {
-			fmt.Printf("⚠️ 解析metrics失败：%v\n", err)
-			continue
-		}
-		metrics = append(metrics, m)
-	}
-
-	// 3. 返回metrics数据（供C-SMA聚合）
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"site_id": SiteID,
-		"count":   len(metrics),
-		"metrics": metrics,
-		"time":    time.Now().Format(time.RFC3339),
-	})
-}
-func healthCheckHandler(c *gin.Context) {
-	// 简单检查数据库连接
-	if err := db.Ping(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"status":  "unhealthy",
-			"reason":  "数据库连接失败",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"status":  "healthy",
-		"site_id": SiteID,
-		"time":    time.Now().Format(time.RFC3339),
-	})
-}
-
-// getPresetServiceInfo 获取预设服务的信息（不依赖平台服务）
-func getPresetServiceInfo(serviceID string) (serviceName string, resourcePerInst int, err error) {
-	// 预设服务列表 - 模拟平台注册的服务
-	presetServices := map[string]struct {
-		name            string
-		resourcePerInst int
-		description     string
-	}{
-		"AR100": {
-			name:            "基础计算服务",
-			resourcePerInst: 50, // 每个实例50单位资源
-			description:     "基础计算服务，适用于一般性计算任务",
-		},
-		"AR1760108487856": {
-			name:            "交通流量监测",
-			resourcePerInst: 15, // 每个实例15单位资源
-			description:     "智能交通流量监测服务",
-		},
-		"AR1760108501919": {
-			name:            "人脸识别",
-			resourcePerInst: 50, // 每个实例50单位资源
-			description:     "高精度人脸识别服务",
-		},
-		"AR1760108514766": {
-			name:            "语音转文字",
-			resourcePerInst: 30, // 每个实例30单位资源
-			description:     "实时语音转文字服务",
-		},
-	}
-
-	// 查找预设服务
-	if service, exists := presetServices[serviceID]; exists {
-		return service.name, service.resourcePerInst, nil
-	}
-
-	// 未知服务类型，返回错误
-	return "", 0, fmt.Errorf("不支持的服务类型: %s（请使用预设服务ID：AR100, AR1760108487856, AR1760108501919, AR1760108514766）", serviceID)
-}
@@ -0,0 +1,64 @@
+// file: models/metrics.go
+package models
+
+import "time"
+
+// 站点推送给C-SMA的实例变化事件类型
+const (
+	MetricsEventDeploy   = "deploy"   // 新部署：Instance 为新实例
+	MetricsEventScale    = "scale"    // 扩缩容：Instance 为调整后的实例
+	MetricsEventUndeploy = "undeploy" // 删除部署：CSCI_ID 为被删除的实例
+	MetricsEventGas      = "gas"      // gas预占会话变化：Instance 为可用gas变化后的实例
+	MetricsEventSnapshot = "snapshot" // 全量：Instances 为站点当前全部实例（站点启动时推送）
+)
+
+// MetricsUpdate 站点主动推送的实例变化（C-SMA 定时拉取仍作为兜底对账）
+type MetricsUpdate struct {
+	SiteID    string                `json:"site_id"`
+	Epoch     int64                 `json:"epoch"` // 站点进程启动时间（UnixNano），站点重启后序号重新计数
+	Seq       int64                 `json:"seq"`   // 同一 epoch 内单调递增，C-SMA 据此丢弃乱序到达的旧事件
+	Event     string                `json:"event"`
+	Instance  *ServiceInstanceInfo  `json:"instance,omitempty"`
+	CSCI_ID   string                `json:"csci_id,omitempty"`
+	Instances []ServiceInstanceInfo `json:"instances,omitempty"`
+	Time      time.Time             `json:"time"`
+}
+
+// ServiceSummary C-SMA 向 C-PS 通告的单个服务的实例汇总（/sync 与订阅流共用）
+type ServiceSummary struct {
+	ServiceID string                `json:"service_id"`
+	Instances []ServiceInstanceInfo `json:"instances"`
+	TotalGas  int                   `json:"total_gas"`
+	MinDelay  int                   `json:"min_delay"`
+	MaxDelay  int                   `json:"max_delay"`
+}
+
+// 订阅流事件类型
+const (
+	StreamEventSnapshot = "snapshot" // 全量：Services 为当前全部通告的服务
+	StreamEventUpdate   = "update"   // 增量：Services 为有变化的服务（整体替换），Removed 为已无实例的服务
+)
+
+// MetricsStreamEvent C-SMA 订阅流（GET /subscribe，SSE）中的一条事件
+type MetricsStreamEvent struct {
+	Type     string           `json:"type"`
+	StreamID int64            `json:"stream_id"` // C-SMA 进程启动时间，变化说明C-SMA已重启
+	Version  int64            `json:"version"`   // 每次变化加1；订阅方发现不连续时应重新订阅以获取全量
+	Services []ServiceSummary `json:"services,omitempty"`
+	Removed  []string         `json:"removed,omitempty"`
+	// 实例过期时长（秒，仅 snapshot 携带）：订阅方据此在断流期间自行判断 last_seen 是否过期
+	InstanceTTLSeconds int       `json:"instance_ttl_seconds,omitempty"`
+	Time               time.Time `json:"time"`
+}
+
+// AggregationLevel 层级C-SMA中某一级的聚合延迟（/sync 与 /health 中按「本级在前、下级依次在后」列出）
+type AggregationLevel struct {
+	SMAID        string    `json:"sma_id"`
+	Depth        int       `json:"depth"`              // 1 表示直接聚合站点，父级为下级最大深度+1
+	Parent       string    `json:"parent,omitempty"`   // 拉取本级的上级C-SMA（由上级填写）
+	Instances    int       `json:"instances"`          // 本级聚合的实例数
+	RoundMillis  int64     `json:"round_ms"`           // 本级最近一轮拉取（站点与下级C-SMA）的耗时
+	FetchMillis  int64     `json:"fetch_ms,omitempty"` // 上级拉取本级 /sync 的往返耗时（由上级填写）
+	MaxAgeMillis int64     `json:"max_age_ms"`         // 本级实例中最旧的 last_seen 距今时长（站点确认到本级可见的最大延迟）
+	At           time.Time `json:"at"`                 // 统计时间
+}
@@ -0,0 +1,34 @@
+// file: models/network.go
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// PathMetrics C-NMA 测得的一条网络路径（观测点 → 站点）的质量
+type PathMetrics struct {
+	Vantage    string    `json:"vantage"`     // 观测点（发起探测的C-NMA所在位置）
+	SiteID     string    `json:"site_id"`     // 目标站点ID
+	Target     string    `json:"target"`      // 探测目标地址
+	Method     string    `json:"method"`      // 探测方式：http / tcp
+	RTTMs      float64   `json:"rtt_ms"`      // 平均往返时延（毫秒，仅统计成功探测）
+	MinRTTMs   float64   `json:"min_rtt_ms"`  // 最小往返时延（毫秒）
+	MaxRTTMs   float64   `json:"max_rtt_ms"`  // 最大往返时延（毫秒）
+	JitterMs   float64   `json:"jitter_ms"`   // 抖动：相邻两次成功探测RTT差值的平均值（毫秒）
+	LossRate   float64   `json:"loss_rate"`   // 丢包率：失败/超时探测占比（0~1）
+	Sent       int       `json:"sent"`        // 本轮探测次数
+	Received   int       `json:"received"`    // 本轮成功次数
+	MeasuredAt time.Time `json:"measured_at"` // 测量完成时间
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// Reachable 本轮至少有一次探测成功
+func (p PathMetrics) Reachable() bool {
+	return p.Received > 0
+}
+
+// OneWayDelayMs 估算单向网络时延（RTT/2，向上取整到毫秒），与站点上报的计算时延相加即为端到端时延
+func (p PathMetrics) OneWayDelayMs() int {
+	return int(math.Ceil(p.RTTMs / 2))
+}
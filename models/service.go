@@ -1,38 +1,263 @@
 // file: models/service.go
 package models
-import "time"  // 新增这一行：导入time包，用于识别time.Time类型
+
+import (
+	"fmt"
+	"net/url"
+	"time" // 新增这一行：导入time包，用于识别time.Time类型
+)
+
 // Service 对应草案中“公共服务平台”的服务表（Table 1）
 // 存储服务的元数据、计算/存储要求、代码位置等公开信息
 type Service struct {
-	ID                 string   `json:"id"`                   // 服务唯一ID，如 "AR1"（草案中Service ID）
-	Name               string   `json:"name"`                 // 服务名称，如 "AR/VR"（草案中Service Name）
-	Description        string   `json:"description"`          // 服务功能描述（草案中Service Description）
-	InputFormat        string   `json:"input_format"`         // 服务输入格式，如 "Motion Capture, Voice Tracking"（草案中Input）
-	ComputingRequirement string `json:"computing_requirement"`// 计算资源要求，如 "multi-thread CPUs ≥2.0GHz, GPU > RTX4060"（草案中Computing Requirement）
-	StorageRequirement string   `json:"storage_requirement"`  // 存储资源要求，如 "16GB DRAM, 256GB SSD"（草案中Storage Requirement）
-	ComputingTime      string   `json:"computing_time"`       // 单次计算延迟，如 "≤1ms"（草案中Computing Time）
-	CodeLocation       string   `json:"code_location"`        // 服务代码地址，如 "https://github.com/xxx/ar-service"（草案中Service Running Code）
-	SoftwareDependency []string `json:"software_dependency"`  // 软件依赖，如 ["Unity", "Unreal Engine"]（草案中Software Dependency）
-	CreatedAt          time.Time `json:"created_at"`           // 新增：服务创建时间（用于记录注册时间）
+	ID                   string                `json:"id"`                     // 服务唯一ID，如 "AR1"（草案中Service ID）
+	Name                 string                `json:"name"`                   // 服务名称，如 "AR/VR"（草案中Service Name）
+	Description          string                `json:"description"`            // 服务功能描述（草案中Service Description）
+	InputFormat          string                `json:"input_format"`           // 服务输入格式，如 "Motion Capture, Voice Tracking"（草案中Input）
+	ComputingRequirement string                `json:"computing_requirement"`  // 计算资源要求，如 "multi-thread CPUs ≥2.0GHz, GPU > RTX4060"（草案中Computing Requirement）
+	StorageRequirement   string                `json:"storage_requirement"`    // 存储资源要求，如 "16GB DRAM, 256GB SSD"（草案中Storage Requirement）
+	ComputingTime        string                `json:"computing_time"`         // 单次计算延迟，如 "≤1ms"（草案中Computing Time）
+	CodeLocation         string                `json:"code_location"`          // 服务代码地址，如 "https://github.com/xxx/ar-service"（草案中Service Running Code）
+	SoftwareDependency   []string              `json:"software_dependency"`    // 软件依赖，如 ["Unity", "Unreal Engine"]（草案中Software Dependency）
+	Requirements         *ResourceRequirements `json:"requirements,omitempty"` // 结构化资源需求（机器可读，站点据此计算资源占用）
+	CreatedAt            time.Time             `json:"created_at"`             // 新增：服务创建时间（用于记录注册时间）
+	UpdatedAt            time.Time             `json:"updated_at"`             // 最近一次修改时间（PUT/PATCH/状态变更）
+	Status               string                `json:"status"`                 // 生命周期状态：draft / published / deprecated / retired
 	// 私有字段：仅用于服务部署验证，不通过API暴露给客户端/服务站点（草案中Service Sample Result Table）
 	ValidationSample string `json:"-"` // 服务验证用的输入样本（如AR服务的测试视频流）
 	ValidationResult string `json:"-"` // 服务验证的预期输出（如样本的正确渲染结果）
 }
 
+// 加速器等级（ResourceRequirements.Accelerator）
+const (
+	AcceleratorNone     = "none"
+	AcceleratorGPUEntry = "gpu-entry" // 入门级GPU，如 GTX1650 / RTX3050
+	AcceleratorGPUMid   = "gpu-mid"   // 中端GPU，如 RTX4060 / RTX4070
+	AcceleratorGPUHigh  = "gpu-high"  // 高端GPU，如 RTX4090 / A100
+	AcceleratorNPU      = "npu"       // 专用AI加速卡
+)
+
+// acceleratorUnits 各加速器等级折算的资源单位
+var acceleratorUnits = map[string]int{
+	AcceleratorNone:     0,
+	AcceleratorGPUEntry: 20,
+	AcceleratorGPUMid:   40,
+	AcceleratorGPUHigh:  80,
+	AcceleratorNPU:      40,
+}
+
+// ResourceRequirements 单个服务实例的结构化资源需求
+// ComputingRequirement/StorageRequirement 保留为人类可读描述，站点只依据本结构计算资源占用
+type ResourceRequirements struct {
+	CPUCores        int     `json:"cpu_cores"`                // CPU核数（≥1）
+	CPUFrequencyGHz float64 `json:"cpu_frequency_ghz"`        // 最低主频（GHz），0 表示不限
+	MemoryGB        int     `json:"memory_gb"`                // 内存（GB）
+	DiskGB          int     `json:"disk_gb"`                  // 磁盘（GB）
+	Accelerator     string  `json:"accelerator,omitempty"`    // 加速器等级：none / gpu-entry / gpu-mid / gpu-high / npu，留空视为 none
+	ResourceUnits   int     `json:"resource_units,omitempty"` // 单实例资源单位；0 表示按上述需求自动折算
+}
+
+// DefaultRequirements 结构化资源需求上线前注册的服务在迁移时补齐的默认需求（1核、1GB内存、1GB磁盘，折算12个资源单位）
+var DefaultRequirements = ResourceRequirements{CPUCores: 1, MemoryGB: 1, DiskGB: 1}
+
+// Validate 校验资源需求是否合法
+func (r ResourceRequirements) Validate() error {
+	if r.CPUCores < 1 {
+		return fmt.Errorf("cpu_cores必须≥1")
+	}
+	if r.CPUFrequencyGHz < 0 {
+		return fmt.Errorf("cpu_frequency_ghz不能为负数")
+	}
+	if r.MemoryGB < 0 || r.DiskGB < 0 {
+		return fmt.Errorf("memory_gb/disk_gb不能为负数")
+	}
+	if r.ResourceUnits < 0 {
+		return fmt.Errorf("resource_units不能为负数")
+	}
+	if _, ok := acceleratorUnits[r.accelerator()]; !ok {
+		return fmt.Errorf("未知的加速器等级：%s（可选：none, gpu-entry, gpu-mid, gpu-high, npu）", r.Accelerator)
+	}
+	return nil
+}
+
+// UnitsPerInstance 单个实例占用的站点资源单位
+// 显式指定 ResourceUnits 时直接使用；否则按 CPU每核10 + 内存每GB 2 + 磁盘每32GB 1 + 加速器等级 折算
+func (r ResourceRequirements) UnitsPerInstance() int {
+	if r.ResourceUnits > 0 {
+		return r.ResourceUnits
+	}
+	units := r.CPUCores*10 + r.MemoryGB*2 + r.DiskGB/32 + acceleratorUnits[r.accelerator()]
+	if units < 1 {
+		units = 1
+	}
+	return units
+}
+
+func (r ResourceRequirements) accelerator() string {
+	if r.Accelerator == "" {
+		return AcceleratorNone
+	}
+	return r.Accelerator
+}
+
+// 服务生命周期状态
+// draft：已登记但未发布，站点不可部署；published：正常提供；
+// deprecated：仍可部署和路由，但提示使用方迁移；retired：已下线，站点拒绝部署、C-PS拒绝路由
+const (
+	ServiceStatusDraft      = "draft"
+	ServiceStatusPublished  = "published"
+	ServiceStatusDeprecated = "deprecated"
+	ServiceStatusRetired    = "retired"
+)
+
+// serviceStatusTransitions 允许的状态迁移（同状态视为无变化，始终允许）
+var serviceStatusTransitions = map[string][]string{
+	ServiceStatusDraft:      {ServiceStatusPublished, ServiceStatusRetired},
+	ServiceStatusPublished:  {ServiceStatusDeprecated, ServiceStatusRetired},
+	ServiceStatusDeprecated: {ServiceStatusPublished, ServiceStatusRetired},
+	ServiceStatusRetired:    {},
+}
+
+// IsValidServiceStatus 判断是否为已知的生命周期状态
+func IsValidServiceStatus(status string) bool {
+	_, ok := serviceStatusTransitions[status]
+	return ok
+}
+
+// CanTransitionServiceStatus 判断服务能否从 from 状态迁移到 to 状态
+func CanTransitionServiceStatus(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range serviceStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDeployable 服务是否允许被站点部署（仅 published / deprecated）
+func (s Service) IsDeployable() bool {
+	return s.Status == ServiceStatusPublished || s.Status == ServiceStatusDeprecated
+}
+
+// IsRoutable 服务是否允许被C-PS路由（未下线即可；旧数据无状态字段时视为可路由）
+func (s Service) IsRoutable() bool {
+	return s.Status != ServiceStatusRetired
+}
+
 // ServiceInstanceInfo 对应草案中“服务站点”的服务模型表（Table 3）
 // 存储服务站点已部署的服务实例信息，用于向C-SMA上报
 type ServiceInstanceInfo struct {
-	ServiceID string `json:"service_id"` // 关联的服务ID，如 "AR1"（对应Service.ID）
-	Gas       int    `json:"gas"`        // 可用服务实例数量（草案中Gas），如 3 表示可同时处理3个AR请求
-	Cost      int    `json:"cost"`       // 单次服务成本（草案中Cost），如 4 表示每次调用消耗4个“资源单位”
-	CSCI_ID   string `json:"csci_id"`    // 服务接触实例地址（草案中CSCI-ID），如 "http://192.168.1.100:8080/ar1"（客户端实际访问的地址）
- 	Delay     int    `json:"delay"` // 新增：延迟（ms）
+	ServiceID string `json:"service_id"`         // 关联的服务ID，如 "AR1"（对应Service.ID）
+	Gas       int    `json:"gas"`                // 当前可用的服务能力（草案中Gas）= Capacity - 进行中的会话数，如 3 表示还可同时处理3个AR请求
+	Capacity  int    `json:"capacity,omitempty"` // 部署的总服务能力（部署时的gas）
+	Cost      int    `json:"cost"`               // 单次服务成本（草案中Cost），如 4 表示每次调用消耗4个“资源单位”
+	CSCI_ID   string `json:"csci_id"`            // 服务接触实例地址（草案中CSCI-ID），如 "http://192.168.1.100:8080/ar1"（客户端实际访问的地址）
+	Delay     int    `json:"delay"`              // 新增：延迟（ms）
+	SiteID    string `json:"site_id,omitempty"`  // 实例所属（上报）站点ID（用于按站点核对部署验证结果）
+
+	// C-SMA填充：最近一次由站点确认（拉取或推送）的时间，超过TTL未确认即为过期
+	LastSeen time.Time `json:"last_seen"`
+	Stale    bool      `json:"stale,omitempty"`
+	// 层级C-SMA填充：实例经由的下级C-SMA（从最靠近站点的一级开始），直接由站点上报时为空
+	Path []string `json:"path,omitempty"`
+
+	NetworkDelay int `json:"network_delay,omitempty"` // C-PS填充：观测点到站点的网络时延（ms，来自C-NMA）
+}
+
+// TotalDelay 端到端时延 = 站点计算时延 + 网络时延，用于与客户端的MaxAcceptDelay比较
+func (i ServiceInstanceInfo) TotalDelay() int {
+	return i.Delay + i.NetworkDelay
+}
+
+// SiteURL 从CSCI_ID推导实例所属站点的基础地址（如 "http://192.168.1.100:8080"），用于预占/释放gas
+func (i ServiceInstanceInfo) SiteURL() (string, error) {
+	u, err := url.Parse(i.CSCI_ID)
+	if err != nil {
+		return "", fmt.Errorf("无法解析CSCI_ID %q：%w", i.CSCI_ID, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("CSCI_ID %q 缺少协议或主机", i.CSCI_ID)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// GasSession 站点上的一个gas预占会话：占用某实例的1个gas单位，释放或超时后归还
+type GasSession struct {
+	ID           string    `json:"session_id"`
+	DeploymentID string    `json:"deployment_id"`
+	ServiceID    string    `json:"service_id"`
+	CSCI_ID      string    `json:"csci_id"`
+	Holder       string    `json:"holder,omitempty"` // 预占方标识（如C-PS实例ID或客户端API Key）
+	AcquiredAt   time.Time `json:"acquired_at"`
+	ExpiresAt    time.Time `json:"expires_at"` // 未续期时到期自动释放
+}
+
+// AcquireGasRequest 向站点 POST /sessions 预占gas的请求（DeploymentID 与 CSCI_ID 二选一）
+type AcquireGasRequest struct {
+	DeploymentID string `json:"deployment_id,omitempty"`
+	CSCI_ID      string `json:"csci_id,omitempty"`
+	Holder       string `json:"holder,omitempty"`
+	TTLSeconds   int    `json:"ttl_seconds,omitempty"` // 会话有效期（秒），0 使用站点默认值
+}
+
+// ValidationAttestation 公共服务平台记录的部署验证结果（每个站点+服务一条）
+// 站点部署服务后提交对ValidationSample的输出，平台与ValidationResult比对后生成
+type ValidationAttestation struct {
+	SiteID      string    `json:"site_id"`
+	ServiceID   string    `json:"service_id"`
+	Passed      bool      `json:"passed"`
+	Message     string    `json:"message"`
+	ValidatedAt time.Time `json:"validated_at"`
+}
+
+// ValidationSnapshot 平台验证状态快照，供C-SMA/C-PS在通告或选择实例前核对
+type ValidationSnapshot struct {
+	RequiredServices []string                `json:"required_services"` // 配置了验证样本、需要验证的服务
+	Attestations     []ValidationAttestation `json:"attestations"`
+}
+
+// Allows 判断某站点上的服务实例是否可被通告/选择：
+// 未配置验证样本的服务无需验证；否则该站点必须存在通过的验证记录
+func (v ValidationSnapshot) Allows(siteID, serviceID string) bool {
+	required := false
+	for _, id := range v.RequiredServices {
+		if id == serviceID {
+			required = true
+			break
+		}
+	}
+	if !required {
+		return true
+	}
+	for _, a := range v.Attestations {
+		if a.SiteID == siteID && a.ServiceID == serviceID {
+			return a.Passed
+		}
+	}
+	return false
 }
 
 // ClientRequest 客户端向C-PS发起的服务请求结构（草案中Client Service Request）
 // 包含客户端的服务需求、成本和延迟限制
 type ClientRequest struct {
-	ServiceID     string `json:"service_id"`     // 目标服务ID，如 "AR1"
-	MaxAcceptCost int    `json:"max_accept_cost"`// 客户端可接受的最高成本，如 5（超过此值的服务实例会被过滤）
-	MaxAcceptDelay int   `json:"max_accept_delay"`// 客户端可接受的最大总延迟（毫秒），如 25（计算延迟+网络延迟）
+	ServiceID      string `json:"service_id"`            // 目标服务ID，如 "AR1"
+	MaxAcceptCost  int    `json:"max_accept_cost"`       // 客户端可接受的最高成本，如 5（超过此值的服务实例会被过滤）
+	MaxAcceptDelay int    `json:"max_accept_delay"`      // 客户端可接受的最大总延迟（毫秒），如 25（计算延迟+网络延迟）
+	SessionTTL     int    `json:"session_ttl,omitempty"` // 预占gas会话的有效期（秒），0 使用站点默认值；到期前未释放或续期则自动归还
+	// 路径选择策略（lowest-cost / lowest-delay / weighted / most-gas / round-robin / weighted-random），
+	// 为空时使用API Key配置的策略或C-PS默认策略
+	Strategy   string           `json:"strategy,omitempty"`
+	Weights    *StrategyWeights `json:"weights,omitempty"`     // weighted 策略的权重，为空使用默认权重
+	Explain    bool             `json:"explain,omitempty"`     // 为 true 时响应附带每个候选实例的约束判定、得分与排名（也可用 ?explain=true）
+	AllowStale bool             `json:"allow_stale,omitempty"` // 为 true 时允许选择已过期（站点长时间未确认）的实例
+}
+
+// StrategyWeights weighted 策略中各指标的权重（各指标先归一化到0~1，得分越低越优）
+type StrategyWeights struct {
+	Cost  float64 `json:"cost" yaml:"cost"`
+	Delay float64 `json:"delay" yaml:"delay"`
+	Gas   float64 `json:"gas" yaml:"gas"` // 剩余gas越多越优
 }
@@ -0,0 +1,32 @@
+// file: models/site.go
+package models
+
+import "time"
+
+// SiteRegistration 站点向C-SMA注册及发送心跳时上报的信息
+type SiteRegistration struct {
+	SiteID        string `json:"site_id"`
+	URL           string `json:"url"`            // 站点对外地址（C-SMA据此拉取 /metrics）
+	TotalResource int    `json:"total_resource"` // 站点总资源单位
+	UsedResource  int    `json:"used_resource"`  // 已用资源单位
+	Deployments   int    `json:"deployments"`    // 当前部署数
+}
+
+// C-SMA 中站点的存活状态
+const (
+	SiteStatePending = "pending" // 配置中的站点，尚未注册（仍按配置拉取）
+	SiteStateAlive   = "alive"   // 心跳正常
+	SiteStateSuspect = "suspect" // 心跳超时，暂不剔除
+	SiteStateDead    = "dead"    // 心跳长时间中断，实例已从聚合数据中剔除，停止拉取
+)
+
+// SiteStatus C-SMA 中一个站点的注册与存活信息
+type SiteStatus struct {
+	SiteRegistration
+	Source        string    `json:"source"` // config：来自拓扑配置；registered：运行时注册
+	State         string    `json:"state"`
+	RegisteredAt  time.Time `json:"registered_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	LastPolled    time.Time `json:"last_polled"` // 最近一次成功拉取 /metrics 的时间，与心跳一样视为存活证明
+	StateSince    time.Time `json:"state_since"`
+}
@@ -29,23 +29,23 @@ echo "🚀 开始远程启动服务站点..."
 echo "📌 启动 site1 @$SITE1_HOST:8081"
 sshpass -p "$SITE1_PASS" ssh -o StrictHostKeyChecking=no "$SITE1_USER@$SITE1_HOST" "
   cd '$SITE1_DIR' && \
-  nohup go run cmd/site/main.go > /tmp/site1.log 2>&1 &
+  nohup go run ./cmd/site -id site-1 > /tmp/site1.log 2>&1 &
   echo '✅ site1 PID:' \$!
 " &
 SITE1_PID=$!
 
 # site2: Windows（✓ 本地直接启动，不走 WinRM）
 echo "📌 启动 site2 @$SITE2_HOST:8082"
-cmd.exe /c "start /B D:\\tools\\go\\bin\\go.exe run $SITE2_DIR\\cmd\\site2\\main.go"
+cmd.exe /c "start /B D:\\tools\\go\\bin\\go.exe run $SITE2_DIR\\cmd\\site -id site-2 -port 8082"
 sleep 1
 echo "✅ site2 已启动（本地后台）" &
 SITE2_PID=$!
 
-# site3: Mac（✓ SSH + 后台）
+# site3: Mac（✓ SSH + 后台；需在 config/cmas.yaml 中配置 site-3）
 echo "📌 启动 site3 @$SITE3_HOST:8085"
 sshpass -p "$SITE3_PASS" ssh -o StrictHostKeyChecking=no "$SITE3_USER@$SITE3_HOST" "
   cd '$SITE3_DIR' && \
-  nohup go run cmd/site3/main.go > /tmp/site3.log 2>&1 &
+  nohup go run ./cmd/site -id site-3 > /tmp/site3.log 2>&1 &
   echo '✅ site3 PID:' \$!
 " &
 SITE3_PID=$!
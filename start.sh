@@ -17,6 +17,9 @@ export SMA_PORT=8083
 export PS_IP="192.168.67.185"
 export PS_PORT=8084
 
+export NMA_IP="192.168.67.185"
+export NMA_PORT=8086
+
 # SSH 账户
 export SITE1_USER="daiyina"
 export SITE1_PASS="hUJ9!s8B"
@@ -50,57 +53,31 @@ for site in 1 2; do
   ok "Site$site SSH 正常"
 done
 
-# ==================== 生成 config.go (保持不变) ====================
-mkdir -p config templates/platform templates/ps templates/sma
-cat > config/config.go <<EOF
-package config
-
-// LOCAL_LISTEN_IP 供本地服务 (Platform, C-SMA, C-PS) 实际监听使用 (应为 0.0.0.0 或 127.0.0.1)
-var LOCAL_LISTEN_IP = "$LOCAL_LISTEN_IP"
-
-var Cfg = struct {
-    Platform struct{ IP string; Port int; URL string }
-    Site1    struct{ IP string; Port int; URL string }
-    Site2    struct{ IP string; Port int; URL string }
-    SMA      struct{ IP string; Port int; URL string }
-    PS       struct{ IP string; Port int; URL string }
-    MonitoredSites []string
-    Resource struct{ Site1Total, Site2Total int }
-}{
-    Platform: struct{ IP string; Port int; URL string }{IP: "$PLATFORM_IP", Port: $PLATFORM_PORT, URL: "http://$PLATFORM_IP:$PLATFORM_PORT"},
-    Site1:    struct{ IP string; Port int; URL string }{IP: "$SITE1_IP", Port: $SITE1_PORT, URL: "http://$SITE1_IP:$SITE1_PORT"},
-    Site2:    struct{ IP string; Port int; URL string }{IP: "$SITE2_IP", Port: $SITE2_PORT, URL: "http://$SITE2_IP:$SITE2_PORT"},
-    SMA:      struct{ IP string; Port int; URL string }{IP: "$SMA_IP", Port: $SMA_PORT, URL: "http://$SMA_IP:$SMA_PORT"},
-    PS:       struct{ IP string; Port int; URL string }{IP: "$PS_IP", Port: $PS_PORT, URL: "http://$PS_IP:$PS_PORT"},
-    MonitoredSites: []string{
-        "http://$SITE1_IP:$SITE1_PORT",
-        "http://$SITE2_IP:$SITE2_PORT",
-    },
-    Resource: struct{ Site1Total, Site2Total int }{Site1Total: 400, Site2Total: 500},
-}
-
-// GetAllSiteURLs 用于 C-SMA 模块，它需要一个函数来获取所有监控站点的URL
-func GetAllSiteURLs() []string {
-    return Cfg.MonitoredSites
-}
-EOF
-ok "配置文件已生成"
+# ==================== 拓扑配置 ====================
+# 拓扑来自 config/cmas.yaml；这里仅把上面的地址以环境变量方式覆盖（规则见 config/config.go）
+mkdir -p templates/platform templates/ps templates/sma
+export CMAS_CONFIG="${CMAS_CONFIG:-./config/cmas.yaml}"
+export CMAS_LISTEN_IP="$LOCAL_LISTEN_IP"
+export CMAS_PLATFORM_IP="$PLATFORM_IP" CMAS_PLATFORM_PORT="$PLATFORM_PORT"
+export CMAS_SITE_SITE_1_IP="$SITE1_IP" CMAS_SITE_SITE_1_PORT="$SITE1_PORT"
+export CMAS_SITE_SITE_2_IP="$SITE2_IP" CMAS_SITE_SITE_2_PORT="$SITE2_PORT"
+export CMAS_SMA_SMA_1_IP="$SMA_IP" CMAS_SMA_SMA_1_PORT="$SMA_PORT"
+export CMAS_PS_PS_1_IP="$PS_IP" CMAS_PS_PS_1_PORT="$PS_PORT"
+export CMAS_NMA_NMA_1_IP="$NMA_IP" CMAS_NMA_NMA_1_PORT="$NMA_PORT"
+ok "使用拓扑配置 $CMAS_CONFIG"
 
 
 # ==================== 启动远程服务 (修复 go 命令找不到) ====================
 for site in 1 2; do
   u="SITE${site}_USER"; h="SITE${site}_IP"; p="SITE${site}_PASS"; port="SITE${site}_PORT"
   
-  if [ $site -eq 1 ]; then
-    cmd_name="site"; log_name="site1" # cmd/site/main.go -> logs/site1.log
-  else # site 2 (Mac)
-    cmd_name="site2"; log_name="site2" # cmd/site2/main.go -> logs/site2.log
-  fi
+  # 所有站点共用 cmd/site，通过 -id 选择 config/cmas.yaml 中的站点条目
+  site_id="site-${site}"; log_name="site${site}" # -> logs/site1.log, logs/site2.log
   
   REMOTE_BASE_DIR="\$HOME/go-work/src/cmas-cats-go" 
   
   # ❗ 最终修复远程 Go 启动问题:使用 bash -lc 启动登录 Shell，确保 $PATH 正确加载
-  CMD="mkdir -p logs && bash -lc 'cd \"${REMOTE_BASE_DIR}\" && nohup go run cmd/${cmd_name}/main.go > logs/${log_name}.log 2>&1 &' && sleep 3 && echo SERVICE_STARTED"
+  CMD="mkdir -p logs && bash -lc 'cd \"${REMOTE_BASE_DIR}\" && nohup go run ./cmd/site -id ${site_id} > logs/${log_name}.log 2>&1 &' && sleep 3 && echo SERVICE_STARTED"
 
   echo ""
   ok "启动 Site$site ($h)..."
@@ -117,18 +94,20 @@ for site in 1 2; do
 done
 
 # ==================== 启动本地 WSL 服务 (保持不变) ====================
-ok "启动本地 Platform / C-SMA / C-PS ..."
-for svc in platform c-sma c-ps; do
+ok "启动本地 Platform / C-NMA / C-SMA / C-PS ..."
+for svc in platform c-nma c-sma c-ps; do
   # 确保变量名正确映射
   if [ "$svc" == "c-sma" ]; then
     port_var="SMA_PORT"
   elif [ "$svc" == "c-ps" ]; then
     port_var="PS_PORT"
+  elif [ "$svc" == "c-nma" ]; then
+    port_var="NMA_PORT"
   else
     port_var="PLATFORM_PORT"
   fi
   
-  # 远程启动命令:依赖 Go 代码使用 config.LOCAL_LISTEN_IP (127.0.0.1) 
+  # 监听地址取自 CMAS_LISTEN_IP (127.0.0.1)
   nohup go run cmd/${svc}/main.go > "$LOG_DIR/${svc}.log" 2>&1 &
   sleep 2
   
@@ -150,6 +129,7 @@ Site1:    http://$SITE1_IP:$SITE1_PORT  (Linux)
 Site2:    http://$SITE2_IP:$SITE2_PORT  (Mac)
 C-SMA:    http://$SMA_IP:$SMA_PORT
 C-PS:     http://$PS_IP:$PS_PORT
+C-NMA:    http://$NMA_IP:$NMA_PORT
 日志:     $LOG_DIR
 停止:     ./stop.sh
 ==================================================
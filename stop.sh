@@ -4,10 +4,11 @@ set -e
 
 # --- 停止本地服务 (WSL) ---
 # 增加 -r 参数（xargs -r：无参数时不执行命令，消除 kill 帮助信息）
-echo ">>> 停止本地 8080/8083/8084 端口进程..."
+echo ">>> 停止本地 8080/8083/8084/8086 端口进程..."
 lsof -i:8080 | grep -v "PID" | awk '{print $2}' | xargs -r kill -9
 lsof -i:8083 | grep -v "PID" | awk '{print $2}' | xargs -r kill -9
 lsof -i:8084 | grep -v "PID" | awk '{print $2}' | xargs -r kill -9
+lsof -i:8086 | grep -v "PID" | awk '{print $2}' | xargs -r kill -9
 lsof -i:9091 | grep -v "PID" | awk '{print $2}' | xargs -r kill -9
 echo ">>> 本地进程处理完成"
 
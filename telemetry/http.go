@@ -0,0 +1,52 @@
+package telemetry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Path 运维指标导出路径（站点的 /metrics 已用于向 C-SMA 上报实例，故使用独立路径）
+const Path = "/prometheus"
+
+var (
+	componentInfo = NewGauge("cmas_component_info", "组件信息（值恒为1）", "component", "id")
+	startTime     = NewGauge("cmas_process_start_time_seconds", "进程启动时间（Unix秒）")
+
+	httpRequests = NewCounter("cmas_http_requests_total", "HTTP请求数（按路由模板与状态码）", "method", "route", "code")
+	httpDuration = NewHistogram("cmas_http_request_duration_seconds", "HTTP请求处理耗时（秒）", nil, "method", "route")
+)
+
+// Register 为 gin 引擎启用运维指标：记录每个请求，并在 Path 导出全部指标
+// component 为组件类型（platform / site / c-sma / c-ps），id 为配置中的实例ID
+func Register(r *gin.Engine, component, id string) {
+	componentInfo.Set(1, component, id)
+	startTime.Set(float64(time.Now().Unix()))
+	r.Use(middleware())
+	r.GET(Path, handler)
+}
+
+// middleware 按路由模板（如 /sites/:id）统计请求数与耗时，未匹配路由的请求归入 "unmatched"
+func middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		if route == Path {
+			return // 抓取本身不计入
+		}
+		httpRequests.Inc(c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		httpDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route)
+	}
+}
+
+func handler(c *gin.Context) {
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WriteAll(c.Writer)
+}
@@ -0,0 +1,261 @@
+// Package telemetry 提供各组件共用的 Prometheus 文本格式（exposition format 0.0.4）运维指标：
+// 计数器、仪表、直方图，以及 HTTP 请求统计中间件与 /prometheus 导出接口。
+// 指标均以 cmas_ 为前缀；与站点供 C-SMA 拉取的 /metrics（JSON）互不相干。
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets 默认直方图分桶（秒），与 Prometheus 客户端库一致
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector 一个可导出的指标族
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+var (
+	registry      = make(map[string]collector)
+	scrapeHooks   []func()
+	registryMutex sync.Mutex
+)
+
+func register(c collector) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, ok := registry[c.name()]; ok {
+		panic("telemetry: 指标重复注册：" + c.name())
+	}
+	registry[c.name()] = c
+}
+
+// OnScrape 注册导出前执行的回调，用于在抓取时刷新由内存状态计算的仪表（缓存年龄、资源占用等）
+func OnScrape(hook func()) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	scrapeHooks = append(scrapeHooks, hook)
+}
+
+// WriteAll 按指标名顺序写出全部指标
+func WriteAll(w io.Writer) {
+	registryMutex.Lock()
+	hooks := append([]func(){}, scrapeHooks...)
+	collectors := make([]collector, 0, len(registry))
+	for _, c := range registry {
+		collectors = append(collectors, c)
+	}
+	registryMutex.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// family 指标族的公共部分：名称、说明、标签名及按标签值保存的序列
+type family struct {
+	fullName string
+	help     string
+	kind     string
+	labels   []string
+	mu       sync.Mutex
+}
+
+func (f *family) name() string { return f.fullName }
+
+// key 将标签值编码为序列键；标签值个数必须与标签名一致
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("telemetry: %s 需要%d个标签值，实际%d个", f.fullName, len(f.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func (f *family) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.fullName, f.help, f.fullName, f.kind)
+}
+
+// labelString 渲染 {a="x",b="y"}，extra 为附加标签（如直方图的 le）
+func (f *family) labelString(key string, extra ...string) string {
+	var pairs []string
+	if len(f.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, f.labels[i]+`="`+escapeLabel(v)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ------------------------------
+// Counter / Gauge
+// ------------------------------
+
+// Vec 计数器或仪表（按标签值区分序列）
+type Vec struct {
+	family
+	values map[string]float64
+}
+
+func newVec(kind, name, help string, labels []string) *Vec {
+	v := &Vec{
+		family: family{fullName: name, help: help, kind: kind, labels: labels},
+		values: make(map[string]float64),
+	}
+	register(v)
+	return v
+}
+
+// NewCounter 注册计数器（只增不减）
+func NewCounter(name, help string, labels ...string) *Vec {
+	return newVec("counter", name, help, labels)
+}
+
+// NewGauge 注册仪表（可任意设置）
+func NewGauge(name, help string, labels ...string) *Vec {
+	return newVec("gauge", name, help, labels)
+}
+
+// Inc 加1
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Add 累加（计数器只应传入非负数）
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	k := v.key(labelValues)
+	v.mu.Lock()
+	v.values[k] += delta
+	v.mu.Unlock()
+}
+
+// Set 设置仪表值
+func (v *Vec) Set(value float64, labelValues ...string) {
+	k := v.key(labelValues)
+	v.mu.Lock()
+	v.values[k] = value
+	v.mu.Unlock()
+}
+
+// Reset 清空全部序列（抓取时整体重建的仪表使用，避免残留已消失的标签组合）
+func (v *Vec) Reset() {
+	v.mu.Lock()
+	v.values = make(map[string]float64)
+	v.mu.Unlock()
+}
+
+func (v *Vec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.header(w)
+	for _, k := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.fullName, v.labelString(k), formatValue(v.values[k]))
+	}
+}
+
+// ------------------------------
+// Histogram
+// ------------------------------
+
+type histogramValue struct {
+	counts []uint64 // 各分桶（不含 +Inf）的非累计计数
+	count  uint64
+	sum    float64
+}
+
+// Histogram 直方图（按标签值区分序列）
+type Histogram struct {
+	family
+	buckets []float64
+	values  map[string]*histogramValue
+}
+
+// NewHistogram 注册直方图，buckets 为空时使用 DefaultBuckets
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{
+		family:  family{fullName: name, help: help, kind: "histogram", labels: labels},
+		buckets: append([]float64(nil), buckets...),
+		values:  make(map[string]*histogramValue),
+	}
+	sort.Float64s(h.buckets)
+	register(h)
+	return h
+}
+
+// Observe 记录一次观测值
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	k := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[k]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hv
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+	hv.count++
+	hv.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	for _, k := range sortedKeys(h.values) {
+		hv := h.values[k]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += hv.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.fullName, h.labelString(k, "le", formatValue(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.fullName, h.labelString(k, "le", "+Inf"), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.fullName, h.labelString(k), formatValue(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.fullName, h.labelString(k), hv.count)
+	}
+}